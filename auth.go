@@ -0,0 +1,212 @@
+package mysql
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"database/sql/driver"
+	"encoding/pem"
+	"fmt"
+	"sync"
+)
+
+var (
+	serverPubKeyLock     sync.RWMutex
+	serverPubKeyRegister map[string]*rsa.PublicKey
+)
+
+// RegisterServerPubKey registers the RSA public key of the server to be used with sql.Open.
+// Use the name as a value in the DSN where serverPubKey=name. The key is used to encrypt the password
+// of the users authenticated by caching_sha2_password or sha256_password without TLS, which is usually
+// read from the file of the caching_sha2_password_public_key_path variable of the server.
+//
+//	data, err := ioutil.ReadFile("/path/public_key.pem")
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	block, _ := pem.Decode(data)
+//	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	mysql.RegisterServerPubKey("mykey", pub.(*rsa.PublicKey))
+//	db, err := sql.Open("mysql", "user:password@tcp(localhost:3306)/test?serverPubKey=mykey")
+func RegisterServerPubKey(name string, pubKey *rsa.PublicKey) {
+	serverPubKeyLock.Lock()
+	if serverPubKeyRegister == nil {
+		serverPubKeyRegister = make(map[string]*rsa.PublicKey)
+	}
+	serverPubKeyRegister[name] = pubKey
+	serverPubKeyLock.Unlock()
+}
+
+// DeregisterServerPubKey removes the public key registered with the name.
+func DeregisterServerPubKey(name string) {
+	serverPubKeyLock.Lock()
+	delete(serverPubKeyRegister, name)
+	serverPubKeyLock.Unlock()
+}
+
+func getServerPubKey(name string) *rsa.PublicKey {
+	serverPubKeyLock.RLock()
+	defer serverPubKeyLock.RUnlock()
+	return serverPubKeyRegister[name]
+}
+
+const (
+	// iAuthMoreData is the indicator of the extra data of the authentication
+	iAuthMoreData byte = 0x01
+
+	cachingSha2FastAuthSuccess = 3
+	cachingSha2PerformFullAuth = 4
+	// the requests of the public key of caching_sha2_password and sha256_password
+	cachingSha2RequestPubKey = 2
+	sha256RequestPubKey      = 1
+)
+
+// scrambleSHA256Password hashes the password for caching_sha2_password:
+// XOR(SHA256(password), SHA256(SHA256(SHA256(password)), scramble))
+func scrambleSHA256Password(scramble []byte, password string) []byte {
+	if len(password) == 0 {
+		return nil
+	}
+
+	crypt := sha256.New()
+	crypt.Write([]byte(password))
+	message1 := crypt.Sum(nil)
+
+	crypt.Reset()
+	crypt.Write(message1)
+	message1Hash := crypt.Sum(nil)
+
+	crypt.Reset()
+	crypt.Write(message1Hash)
+	crypt.Write(scramble)
+	message2 := crypt.Sum(nil)
+
+	for i := range message1 {
+		message1[i] ^= message2[i]
+	}
+	return message1
+}
+
+// encryptPassword encrypts the NUL terminated password XORed with the scramble by the public key of the server.
+func encryptPassword(password string, scramble []byte, pubKey *rsa.PublicKey) ([]byte, error) {
+	plain := append([]byte(password), 0)
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, pubKey, plain, nil)
+}
+
+// writeAuthSwitchPacket writes the response to the authentication plugin.
+// http://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::AuthSwitchResponse
+func (mc *mysqlConn) writeAuthSwitchPacket(authData []byte) error {
+	data := mc.buf.takeSmallBuffer(4 + len(authData))
+	if data == nil {
+		// can not take the buffer. Something must be wrong with the connection
+		errLog.Print(ErrBusyBuffer)
+		return driver.ErrBadConn
+	}
+	copy(data[4:], authData)
+	return mc.writePacket(data)
+}
+
+// cachingSha2Auth authenticates by caching_sha2_password, the fast authentication succeeds if the server
+// has cached the password of the user, otherwise the full authentication is performed.
+func (mc *mysqlConn) cachingSha2Auth(cipher []byte) error {
+	// the cipher is in the read buffer
+	scramble := append([]byte(nil), cipher...)
+	if err := mc.writeAuthSwitchPacket(scrambleSHA256Password(scramble, mc.cfg.Passwd)); err != nil {
+		return err
+	}
+
+	data, err := mc.readPacket()
+	if err != nil {
+		return err
+	}
+	switch data[0] {
+	case iOK:
+		return mc.handleOkPacket(data)
+	case iAuthMoreData:
+		if len(data) < 2 {
+			return ErrMalformPkt
+		}
+		switch data[1] {
+		case cachingSha2FastAuthSuccess:
+			_, err = mc.readResultOK()
+			return err
+		case cachingSha2PerformFullAuth:
+			return mc.sha256FullAuth(scramble, cachingSha2RequestPubKey)
+		}
+		return ErrMalformPkt
+	default:
+		return mc.handleErrorPacket(data)
+	}
+}
+
+// sha256Auth authenticates by sha256_password, which always requires the full authentication.
+func (mc *mysqlConn) sha256Auth(cipher []byte) error {
+	return mc.sha256FullAuth(append([]byte(nil), cipher...), sha256RequestPubKey)
+}
+
+// sha256FullAuth sends the password in clear text over TLS or the unix socket, otherwise it's encrypted
+// by the public key of the server, which is fetched from the server by the request if it's allowed.
+func (mc *mysqlConn) sha256FullAuth(scramble []byte, pubKeyRequest byte) error {
+	if mc.cfg.tls != nil || mc.cfg.Net == "unix" {
+		if err := mc.writeClearAuthPacket(); err != nil {
+			return err
+		}
+		_, err := mc.readResultOK()
+		return err
+	}
+
+	pubKey := mc.cfg.pubKey
+	if pubKey == nil {
+		if !mc.cfg.AllowPublicKeyRetrieval {
+			return ErrNoPubKey
+		}
+		if err := mc.writeAuthSwitchPacket([]byte{pubKeyRequest}); err != nil {
+			return err
+		}
+		data, err := mc.readPacket()
+		if err != nil {
+			return err
+		}
+		if data[0] != iAuthMoreData {
+			return mc.handleErrorPacket(data)
+		}
+		if pubKey, err = parsePubKey(data[1:]); err != nil {
+			return err
+		}
+	}
+
+	enc, err := encryptPassword(mc.cfg.Passwd, scramble, pubKey)
+	if err != nil {
+		return err
+	}
+	if err = mc.writeAuthSwitchPacket(enc); err != nil {
+		return err
+	}
+	_, err = mc.readResultOK()
+	return err
+}
+
+// parsePubKey parses the RSA public key in PEM sent by the server.
+func parsePubKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("invalid public key of the server")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unexpected public key type %T of the server", pub)
+	}
+	return pubKey, nil
+}
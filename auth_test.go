@@ -0,0 +1,135 @@
+package mysql
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"testing"
+)
+
+// serveAuth accepts a connection and switches the client to the plugin, auth handles the rest of the authentication.
+func serveAuth(t *testing.T, plugin string, auth func(sc *ServerConn, scramble, response []byte) error) (string, <-chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		sc := NewServerConn(conn)
+		defer sc.Close()
+		scramble, _ := newScramble()
+		if err = sc.writeHandshake("8.0.20", 1, scramble); err != nil {
+			done <- err
+			return
+		}
+		if _, _, _, err = sc.readHandshakeResponse(); err != nil {
+			done <- err
+			return
+		}
+		response, err := sc.switchAuthPlugin(plugin, scramble)
+		if err != nil {
+			done <- err
+			return
+		}
+		done <- auth(sc, scramble, response)
+	}()
+	return ln.Addr().String(), done
+}
+
+func TestCachingSha2Auth(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	RegisterServerPubKey("test", &key.PublicKey)
+	defer DeregisterServerPubKey("test")
+
+	// fullAuth checks the password encrypted by the public key, which is sent first if requested
+	fullAuth := func(sendKey bool) func(sc *ServerConn, scramble, response []byte) error {
+		return func(sc *ServerConn, scramble, response []byte) error {
+			if !bytes.Equal(response, scrambleSHA256Password(scramble, "secret")) {
+				return fmt.Errorf("unexpected scramble %x", response)
+			}
+			if err := sc.writePacket([]byte{0, 0, 0, 0, iAuthMoreData, cachingSha2PerformFullAuth}); err != nil {
+				return err
+			}
+			data, err := sc.readPacket()
+			if err != nil {
+				return err
+			}
+			if sendKey {
+				if !bytes.Equal(data, []byte{cachingSha2RequestPubKey}) {
+					return fmt.Errorf("expected the public key request, got %x", data)
+				}
+				if err = sc.writePacket(append([]byte{0, 0, 0, 0, iAuthMoreData}, pemKey...)); err != nil {
+					return err
+				}
+				if data, err = sc.readPacket(); err != nil {
+					return err
+				}
+			}
+			plain, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, data, nil)
+			if err != nil {
+				return err
+			}
+			for i := range plain {
+				plain[i] ^= scramble[i%len(scramble)]
+			}
+			if string(plain) != "secret\x00" {
+				return fmt.Errorf("unexpected password %q", plain)
+			}
+			return sc.WriteOK()
+		}
+	}
+
+	for _, c := range []struct {
+		name   string
+		params string
+		plugin string
+		auth   func(sc *ServerConn, scramble, response []byte) error
+		err    error
+	}{
+		{"fast", "", "caching_sha2_password", func(sc *ServerConn, scramble, response []byte) error {
+			if !bytes.Equal(response, scrambleSHA256Password(scramble, "secret")) {
+				return fmt.Errorf("unexpected scramble %x", response)
+			}
+			if err := sc.writePacket([]byte{0, 0, 0, 0, iAuthMoreData, cachingSha2FastAuthSuccess}); err != nil {
+				return err
+			}
+			return sc.WriteOK()
+		}, nil},
+		{"retrieval", "&allowPublicKeyRetrieval=true", "caching_sha2_password", fullAuth(true), nil},
+		{"registered", "&serverPubKey=test", "caching_sha2_password", fullAuth(false), nil},
+		{"no key", "", "caching_sha2_password", func(sc *ServerConn, scramble, response []byte) error {
+			return sc.writePacket([]byte{0, 0, 0, 0, iAuthMoreData, cachingSha2PerformFullAuth})
+		}, ErrNoPubKey},
+		{"unsupported", "", "auth_socket", func(*ServerConn, []byte, []byte) error { return nil },
+			&UnsupportedPluginError{Plugin: "auth_socket"}},
+	} {
+		addr, done := serveAuth(t, c.plugin, c.auth)
+		cw := NewConnWrapper()
+		err := cw.Connect("root:secret@tcp(" + addr + ")/?maxAllowedPacket=16777216" + c.params)
+		if fmt.Sprint(err) != fmt.Sprint(c.err) {
+			t.Errorf("%s: expected error %v, got %v", c.name, c.err, err)
+		}
+		if err == nil {
+			cw.cleanup()
+		}
+		if err = <-done; c.err == nil && err != nil {
+			t.Errorf("%s: %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+// benchmarkValue is a column value of the representative payloads.
+type benchmarkValue struct {
+	name  string
+	typ   byte
+	meta  uint16
+	value interface{}
+	// allocs is the limit of the allocations to decode the value
+	allocs float64
+	data   []byte
+}
+
+var benchmarkValues = []*benchmarkValue{
+	{name: "Long", typ: fieldTypeLong, value: int64(272374570), allocs: 1},
+	{name: "LongLong", typ: fieldTypeLongLong, value: int64(1169865190436412202), allocs: 1},
+	{name: "VarChar", typ: fieldTypeVarChar, meta: 80, value: "a varchar of 20 char", allocs: 2},
+	{name: "Decimal", typ: fieldTypeNewDecimal, meta: 20<<8 | 6, value: "12345678901234.567890", allocs: 2},
+	{name: "DateTimeV2", typ: fieldTypeDateTimeV2, meta: 6, value: "2020-01-02 03:04:05.123456", allocs: 2},
+	{name: "TimeV2", typ: fieldTypeTimeV2, meta: 3, value: "12:34:56.789", allocs: 2},
+	{name: "TimestampV2", typ: fieldTypeTimestampV2, value: int64(1577934245000000000), allocs: 1},
+}
+
+func init() {
+	for _, v := range benchmarkValues {
+		p := newBinlogPacket(nil)
+		if err := p.writeTableColumnValue(nil, v.typ, v.meta, v.value); err != nil {
+			panic(err)
+		}
+		v.data = p.Raw()
+	}
+}
+
+// BenchmarkReadTableColumnValue measures the decoding of a single value of the common types.
+func BenchmarkReadTableColumnValue(b *testing.B) {
+	for _, v := range benchmarkValues {
+		v := v
+		b.Run(v.name, func(b *testing.B) {
+			b.SetBytes(int64(len(v.data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := newBinlogPacket(v.data).readTableColumnValue(nil, v.typ, v.meta, false); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReadDecimal(b *testing.B) {
+	v := benchmarkValues[3]
+	for _, format := range []struct {
+		name   string
+		format DecimalFormat
+	}{{"Float64", DecimalFloat64}, {"String", DecimalString}, {"Rat", DecimalRat}} {
+		dec := &EventDecoder{DecimalFormat: format.format}
+		b.Run(format.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := newBinlogPacket(v.data).readDecimal(dec, v.meta); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDecodeEvent measures the decoding of the small events, which is dominated by the header parsing.
+func BenchmarkDecodeEvent(b *testing.B) {
+	for _, event := range []struct {
+		name string
+		data []byte
+	}{
+		{"Xid", buildEvent(XidEventType, []byte{7, 0, 0, 0, 0, 0, 0, 0}, true)},
+		{"Query", buildEvent(QueryEventType, append([]byte{1, 0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 0, 0}, "test\x00BEGIN"...), true)},
+		{"Gtid", buildEvent(GtidEventType, make([]byte, 42), true)},
+	} {
+		data := event.data
+		b.Run(event.name, func(b *testing.B) {
+			dec := &EventDecoder{ChecksumPolicy: ChecksumVerify, format: &FormatDescriptionEvent{ChecksumAlgorithm: ChecksumAlgorithmCRC32}}
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := dec.decode(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// fixtureRowsEvent returns the decoder which has decoded the table map of the 8.0 fixture and the data of
+// its WriteRowsEvent, whose rows cover all the field types.
+func fixtureRowsEvent(tb testing.TB) (*EventDecoder, []byte) {
+	f := fixtures[len(fixtures)-1]
+	data, err := ioutil.ReadFile(f.path(".bin"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	dec := &EventDecoder{ChecksumPolicy: ChecksumVerify, tables: make(map[uint64]*TableMapEvent)}
+	for data = data[len(binlogMagic):]; len(data) >= eventHeaderSize; {
+		size := binary.LittleEndian.Uint32(data[9:])
+		ev, err := dec.decode(data[:size])
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if _, ok := ev.(*RowsEvent); ok {
+			return dec, data[:size]
+		}
+		data = data[size:]
+	}
+	tb.Fatal("no rows event in the fixture")
+	return nil, nil
+}
+
+func BenchmarkDecodeRowsEvent(b *testing.B) {
+	dec, data := fixtureRowsEvent(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := dec.decode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestDecodeAllocs guards the allocations of the hot paths, raise the limits only if an allocation is intended.
+func TestDecodeAllocs(t *testing.T) {
+	for _, v := range benchmarkValues {
+		allocs := testing.AllocsPerRun(100, func() {
+			newBinlogPacket(v.data).readTableColumnValue(nil, v.typ, v.meta, false)
+		})
+		if allocs > v.allocs {
+			t.Errorf("%s: expected at most %v allocations, got %v", v.name, v.allocs, allocs)
+		}
+	}
+
+	xid := buildEvent(XidEventType, []byte{7, 0, 0, 0, 0, 0, 0, 0}, false)
+	dec := &EventDecoder{}
+	if allocs := testing.AllocsPerRun(100, func() { dec.decode(xid) }); allocs > 5 {
+		t.Errorf("XidEvent: expected at most 5 allocations, got %v", allocs)
+	}
+
+	dec, data := fixtureRowsEvent(t)
+	if allocs := testing.AllocsPerRun(100, func() { dec.decode(data) }); allocs > 51 {
+		t.Errorf("RowsEvent: expected at most 51 allocations, got %v", allocs)
+	}
+}
@@ -0,0 +1,112 @@
+package binlog
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrStopReached is returned like io.EOF when a stop bound of Bounds is reached, after the events before it.
+var ErrStopReached = errors.New("stop bound reached")
+
+// Bounds limits the events replayed by Streamer or FileReader for the bounded jobs like the point-in-time
+// recovery, the zero fields are not checked. The replay ends with ErrStopReached once any stop bound is reached.
+type Bounds struct {
+	// StartPos skips the events starting before the offset in the first file, StartTime skips the ones
+	// executed before the time. FormatDescriptionEvent and RotateEvent are never skipped.
+	StartPos  uint32
+	StartTime time.Time
+	// StopFile and StopPos stop at the first event starting at or after the offset of the file, or any event
+	// of the later files. StopFile is the first file if it's empty.
+	StopFile string
+	StopPos  uint32
+	// StopGTID stops after the transaction of the GTID is complete, e.g. "3E11FA47-71CA-11E1-9E33-C80AA9429562:23"
+	// or "0-1-100" of MariaDB.
+	StopGTID string
+	// StopTime stops at the first event executed at or after the time.
+	StopTime time.Time
+	// StopEvents stops after the number of the events are replayed.
+	StopEvents int
+}
+
+// bounder checks the events against Bounds.
+type bounder struct {
+	Bounds
+	// first is the first file, file is the current one, they are empty if unknown
+	first   string
+	file    string
+	events  int
+	txr     TransactionReader
+	stopped bool
+}
+
+func newBounder(b *Bounds, file string) *bounder {
+	if b == nil {
+		return nil
+	}
+	bd := &bounder{Bounds: *b}
+	bd.rotate(file)
+	return bd
+}
+
+func (b *bounder) rotate(file string) {
+	if file == "" {
+		return
+	}
+	if b.first == "" {
+		b.first = file
+		if b.StopFile == "" {
+			b.StopFile = file
+		}
+	}
+	b.file = file
+}
+
+// skip reports whether the event is before the start bounds.
+func (b *bounder) skip(ev Event) bool {
+	switch ev.(type) {
+	case *FormatDescriptionEvent, *RotateEvent:
+		return false
+	}
+	h := ev.Header()
+	if b.StartPos > 0 && h.NextLogPos > 0 && b.file == b.first && h.NextLogPos-h.EventSize < b.StartPos {
+		return true
+	}
+	return !b.StartTime.IsZero() && h.Timestamp > 0 && time.Unix(int64(h.Timestamp), 0).Before(b.StartTime)
+}
+
+// reached reports whether the event of the header fields is at or after the stop bounds, the positions of
+// the artificial events whose next is 0 and the time of the ones whose timestamp is 0 are not checked.
+func (b *bounder) reached(timestamp, size, next uint32) bool {
+	if b.stopped {
+		return true
+	}
+	if b.StopPos > 0 && next >= size && next > 0 {
+		switch {
+		case b.file == "" || b.file == b.StopFile:
+			b.stopped = next-size >= b.StopPos
+		default:
+			b.stopped = comparePositions(Position{File: b.file}, Position{File: b.StopFile}) > 0
+		}
+	}
+	if !b.StopTime.IsZero() && timestamp > 0 && !time.Unix(int64(timestamp), 0).Before(b.StopTime) {
+		b.stopped = true
+	}
+	return b.stopped
+}
+
+// replayed accounts the event replayed and reports whether the stop bounds are reached after it.
+func (b *bounder) replayed(ev Event) bool {
+	if e, ok := ev.(*RotateEvent); ok {
+		b.rotate(string(e.NextLogName))
+	}
+	b.events++
+	if b.StopEvents > 0 && b.events >= b.StopEvents {
+		b.stopped = true
+	}
+	if b.StopGTID != "" {
+		if tx := b.txr.add(ev); tx != nil && tx.GTID == b.StopGTID {
+			b.stopped = true
+		}
+	}
+	return b.stopped
+}
@@ -0,0 +1,295 @@
+package binlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the enums and field numbers of Alibaba Canal's EntryProtocol.proto
+// refer to https://github.com/alibaba/canal/blob/master/protocol/src/main/java/com/alibaba/otter/canal/protocol/EntryProtocol.proto
+const (
+	canalEntryTransactionBegin = 1
+	canalEntryRowData          = 2
+	canalEntryTransactionEnd   = 3
+
+	canalEventInsert   = 1
+	canalEventUpdate   = 2
+	canalEventDelete   = 3
+	canalEventCreate   = 4
+	canalEventAlter    = 5
+	canalEventErase    = 6
+	canalEventQuery    = 7
+	canalEventTruncate = 8
+	canalEventRename   = 9
+	canalEventCIndex   = 10
+	canalEventDIndex   = 11
+
+	canalSourceMySQL = 2
+)
+
+// canalTypes maps the column types to the java.sql.Types and the MySQL type names used by Canal.
+var canalTypes = map[byte]struct {
+	sqlType   int32
+	mysqlType string
+}{
+	fieldTypeDecimal:     {3, "decimal"},
+	fieldTypeNewDecimal:  {3, "decimal"},
+	fieldTypeTiny:        {-6, "tinyint"},
+	fieldTypeShort:       {5, "smallint"},
+	fieldTypeInt24:       {4, "mediumint"},
+	fieldTypeLong:        {4, "int"},
+	fieldTypeLongLong:    {-5, "bigint"},
+	fieldTypeFloat:       {7, "float"},
+	fieldTypeDouble:      {8, "double"},
+	fieldTypeBit:         {-7, "bit"},
+	fieldTypeYear:        {12, "year"},
+	fieldTypeDate:        {91, "date"},
+	fieldTypeNewDate:     {91, "date"},
+	fieldTypeTime:        {92, "time"},
+	fieldTypeTimeV2:      {92, "time"},
+	fieldTypeDateTime:    {93, "datetime"},
+	fieldTypeDateTimeV2:  {93, "datetime"},
+	fieldTypeTimestamp:   {93, "timestamp"},
+	fieldTypeTimestampV2: {93, "timestamp"},
+	fieldTypeVarChar:     {12, "varchar"},
+	fieldTypeVarString:   {12, "varchar"},
+	fieldTypeString:      {1, "char"},
+	fieldTypeEnum:        {4, "enum"},
+	fieldTypeSet:         {-7, "set"},
+	fieldTypeBLOB:        {2004, "blob"},
+	fieldTypeJSON:        {12, "json"},
+	fieldTypeGeometry:    {-2, "geometry"},
+}
+
+// CanalAdapter converts transactions into the serialized Entry messages of Alibaba Canal's EntryProtocol,
+// so that the consumers of Canal can be fed without changes.
+type CanalAdapter struct {
+	// Encoding is the serverenCode of the entry headers, default is UTF-8.
+	Encoding string
+}
+
+// Entries returns the Entry messages of the transaction: TRANSACTIONBEGIN, ROWDATA for every rows event
+// or the DDL, and TRANSACTIONEND.
+func (a *CanalAdapter) Entries(tx *Transaction) ([][]byte, error) {
+	var entries [][]byte
+	if tx.Begin != nil {
+		begin := appendProtoVarint(nil, 4, uint64(tx.Begin.ThreadID))
+		entries = append(entries, a.entry(tx, tx.Begin, "", "", canalEventQuery, canalEntryTransactionBegin, begin))
+	}
+	for _, ev := range tx.Events {
+		if e, ok := ev.(*RowsEvent); ok {
+			change, err := canalRowChange(e)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, a.entry(tx, e, string(e.Table.Database), string(e.Table.TableName),
+				canalRowEventType(e), canalEntryRowData, change))
+		}
+	}
+
+	switch e := tx.End.(type) {
+	case *XIDEvent:
+		end := appendProtoString(nil, 2, strconv.FormatUint(e.TransactionID, 10))
+		entries = append(entries, a.entry(tx, e, "", "", canalEventQuery, canalEntryTransactionEnd, end))
+	case *QueryEvent:
+		if tx.Begin != nil {
+			entries = append(entries, a.entry(tx, e, "", "", canalEventQuery, canalEntryTransactionEnd, nil))
+			break
+		}
+		// the standalone statement like DDL
+		typ := canalDDLEventType(string(e.Query))
+		change := appendProtoVarint(nil, 2, uint64(typ))
+		change = appendProtoVarint(change, 10, 1)
+		change = appendProtoString(change, 11, string(e.Query))
+		change = appendProtoString(change, 14, string(e.Database))
+		entries = append(entries, a.entry(tx, e, string(e.Database), "", typ, canalEntryRowData, change))
+	}
+	return entries, nil
+}
+
+// entry encodes the Entry with the header built from the event.
+func (a *CanalAdapter) entry(tx *Transaction, ev Event, schema, table string, eventType, entryType int, storeValue []byte) []byte {
+	h := ev.Header()
+	encoding := a.Encoding
+	if encoding == "" {
+		encoding = "UTF-8"
+	}
+
+	// the oneof fields are always written
+	header := appendProtoUint(nil, 1, 1)
+	header = appendProtoString(header, 2, tx.Position.File)
+	if h.NextLogPos >= h.EventSize {
+		header = appendProtoVarint(header, 3, uint64(h.NextLogPos-h.EventSize))
+	}
+	header = appendProtoVarint(header, 4, uint64(h.ServerID))
+	header = appendProtoString(header, 5, encoding)
+	header = appendProtoVarint(header, 6, uint64(h.Timestamp)*1000)
+	header = appendProtoUint(header, 7, canalSourceMySQL)
+	header = appendProtoString(header, 9, schema)
+	header = appendProtoString(header, 10, table)
+	header = appendProtoVarint(header, 11, uint64(h.EventSize))
+	header = appendProtoUint(header, 12, uint64(eventType))
+	header = appendProtoString(header, 14, tx.GTID)
+
+	entry := appendProtoBytes(nil, 1, header)
+	entry = appendProtoUint(entry, 2, uint64(entryType))
+	return appendProtoBytes(entry, 3, storeValue)
+}
+
+func canalRowEventType(e *RowsEvent) int {
+	switch {
+	case e.isUpdate():
+		return canalEventUpdate
+	case e.isDelete():
+		return canalEventDelete
+	default:
+		return canalEventInsert
+	}
+}
+
+func canalDDLEventType(query string) int {
+	fields := strings.Fields(strings.ToUpper(query))
+	if len(fields) < 2 {
+		return canalEventQuery
+	}
+	switch fields[0] + " " + fields[1] {
+	case "CREATE TABLE":
+		return canalEventCreate
+	case "ALTER TABLE":
+		return canalEventAlter
+	case "DROP TABLE":
+		return canalEventErase
+	case "TRUNCATE TABLE":
+		return canalEventTruncate
+	case "RENAME TABLE":
+		return canalEventRename
+	case "CREATE INDEX", "CREATE UNIQUE":
+		return canalEventCIndex
+	case "DROP INDEX":
+		return canalEventDIndex
+	}
+	if fields[0] == "TRUNCATE" {
+		return canalEventTruncate
+	}
+	return canalEventQuery
+}
+
+// canalRowChange encodes the RowChange of the rows event.
+func canalRowChange(e *RowsEvent) ([]byte, error) {
+	change := appendProtoVarint(nil, 1, e.TableID)
+	change = appendProtoUint(change, 2, uint64(canalRowEventType(e)))
+
+	keys := make(map[int]bool)
+	for _, i := range e.Table.primaryKey() {
+		keys[i] = true
+	}
+	rows, err := e.allRows()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(rows); i++ {
+		var before, after []byte
+		switch {
+		case e.isUpdate():
+			if before, err = canalColumns(e, keys, 1, e.Columns, rows[i], nil); err != nil {
+				return nil, err
+			}
+			after, err = canalColumns(e, keys, 2, e.UpdatedColumns, rows[i+1], rows[i])
+			i++
+		case e.isDelete():
+			before, err = canalColumns(e, keys, 1, e.Columns, rows[i], nil)
+		default:
+			after, err = canalColumns(e, keys, 2, e.Columns, rows[i], nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+		change = appendProtoBytes(change, 12, append(before, after...))
+	}
+	return change, nil
+}
+
+// canalColumns encodes the columns of the row as the field num of RowData. The after image of an update
+// is compared with the before image, the columns are marked as updated if they are changed.
+func canalColumns(e *RowsEvent, keys map[int]bool, num int, includedColumns []byte, row, before []interface{}) ([]byte, error) {
+	var buf []byte
+	n := 0
+	for i := 0; i < int(e.ColumnCount); i++ {
+		if !isBitSet(includedColumns, i) {
+			continue
+		}
+		value, isNull, err := canalValue(row[n])
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", e.Table.ColumnName(i), err)
+		}
+		updated := num == 2 && (before == nil || !canalEqual(e, i, before, row[n]))
+		typ := canalTypes[e.Table.realType(i)]
+
+		column := appendProtoVarint(nil, 1, uint64(i))
+		column = appendProtoVarint(column, 2, uint64(uint32(typ.sqlType)))
+		column = appendProtoString(column, 3, e.Table.ColumnName(i))
+		column = appendProtoVarint(column, 4, boolUint(keys[i]))
+		column = appendProtoVarint(column, 5, boolUint(updated))
+		column = appendProtoUint(column, 6, boolUint(isNull))
+		column = appendProtoString(column, 8, value)
+		column = appendProtoString(column, 10, typ.mysqlType)
+		buf = appendProtoBytes(buf, num, column)
+		n++
+	}
+	return buf, nil
+}
+
+// canalEqual reports whether the i-th column of the before image equals v.
+func canalEqual(e *RowsEvent, i int, before []interface{}, v interface{}) bool {
+	n := 0
+	for j := 0; j < i; j++ {
+		if isBitSet(e.Columns, j) {
+			n++
+		}
+	}
+	if !isBitSet(e.Columns, i) {
+		return false
+	}
+	x, xNull, _ := canalValue(before[n])
+	y, yNull, _ := canalValue(v)
+	return x == y && xNull == yNull
+}
+
+// canalValue returns the column value in the textual form of Canal.
+func canalValue(v interface{}) (string, bool, error) {
+	switch value := v.(type) {
+	case nil:
+		return "", true, nil
+	case string:
+		return value, false, nil
+	case []byte:
+		return string(value), false, nil
+	case time.Time:
+		return value.Format("2006-01-02 15:04:05.999999"), false, nil
+	case time.Duration:
+		return formatTimeDuration(value), false, nil
+	case *big.Rat:
+		return value.FloatString(ratScale(value)), false, nil
+	case float32:
+		return strconv.FormatFloat(float64(value), 'f', -1, 32), false, nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), false, nil
+	case int, int8, int16, int32, int64, uint8, uint16, uint32, uint64, fmt.Stringer:
+		return fmt.Sprint(value), false, nil
+	default:
+		// the parsed JSON values
+		data, err := json.Marshal(value)
+		return string(data), false, err
+	}
+}
+
+func boolUint(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
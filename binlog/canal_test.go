@@ -0,0 +1,119 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+type protoField struct {
+	num   int
+	value uint64
+	data  []byte
+}
+
+// protoFields parses the varint and length-delimited fields of a protobuf message.
+func protoFields(t *testing.T, data []byte) map[int][]protoField {
+	fields := make(map[int][]protoField)
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		data = data[n:]
+		f := protoField{num: int(tag >> 3)}
+		switch tag & 7 {
+		case 0:
+			f.value, n = binary.Uvarint(data)
+			data = data[n:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			f.data = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+		fields[f.num] = append(fields[f.num], f)
+	}
+	return fields
+}
+
+func TestCanalAdapter(t *testing.T) {
+	header := &EventHeader{Type: UpdateRowsEventType, Timestamp: 1500000000, ServerID: 1, EventSize: 50, NextLogPos: 1050}
+	table := &TableMapEvent{
+		Database:    []byte("db"),
+		TableName:   []byte("t"),
+		ColumnCount: 2,
+		ColumnTypes: []byte{fieldTypeLong, fieldTypeVarChar},
+		columns:     []*Column{{Name: "id", IsPrimary: true}, {Name: "v"}},
+	}
+	rows := &RowsEvent{
+		baseEvent:      &baseEvent{header: header},
+		TableID:        9,
+		Table:          table,
+		ColumnCount:    2,
+		Columns:        []byte{0x03},
+		UpdatedColumns: []byte{0x03},
+		Rows:           [][]interface{}{{int64(1), "a"}, {int64(1), "b"}},
+	}
+	tx := &Transaction{
+		GTID:     "3e11fa47-71ca-11e1-9e33-c80aa9429562:6",
+		Begin:    &QueryEvent{baseEvent: &baseEvent{header: header}, ThreadID: 3, Query: []byte("BEGIN")},
+		Events:   []Event{table, rows},
+		End:      &XIDEvent{baseEvent: &baseEvent{header: header}, TransactionID: 77},
+		Position: Position{File: "mysql-bin.000001"},
+	}
+
+	entries, err := (&CanalAdapter{}).Entries(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	for i, typ := range []uint64{canalEntryTransactionBegin, canalEntryRowData, canalEntryTransactionEnd} {
+		if entry := protoFields(t, entries[i]); entry[2][0].value != typ {
+			t.Errorf("expected entry type %d, got %d", typ, entry[2][0].value)
+		}
+	}
+
+	entry := protoFields(t, entries[1])
+	h := protoFields(t, entry[1][0].data)
+	if string(h[2][0].data) != "mysql-bin.000001" || h[3][0].value != 1000 || string(h[9][0].data) != "db" ||
+		string(h[10][0].data) != "t" || h[12][0].value != canalEventUpdate || string(h[14][0].data) != tx.GTID {
+		t.Errorf("unexpected header %v", h)
+	}
+
+	change := protoFields(t, entry[3][0].data)
+	if change[1][0].value != 9 || len(change[12]) != 1 {
+		t.Fatalf("unexpected row change %v", change)
+	}
+	row := protoFields(t, change[12][0].data)
+	if len(row[1]) != 2 || len(row[2]) != 2 {
+		t.Fatalf("unexpected row data %v", row)
+	}
+	id, v := protoFields(t, row[2][0].data), protoFields(t, row[2][1].data)
+	if id[4][0].value != 1 || id[5] != nil || string(id[8][0].data) != "1" {
+		t.Errorf("unexpected id column %v", id)
+	}
+	if v[4] != nil || v[5][0].value != 1 || string(v[8][0].data) != "b" || string(v[10][0].data) != "varchar" {
+		t.Errorf("unexpected v column %v", v)
+	}
+
+	end := protoFields(t, protoFields(t, entries[2])[3][0].data)
+	if string(end[2][0].data) != "77" {
+		t.Errorf("unexpected transaction end %v", end)
+	}
+}
+
+func TestCanalDDLEventType(t *testing.T) {
+	tests := map[string]int{
+		"create table t (id int)":       canalEventCreate,
+		"ALTER TABLE t ADD c INT":       canalEventAlter,
+		"drop table t":                  canalEventErase,
+		"TRUNCATE t":                    canalEventTruncate,
+		"CREATE UNIQUE INDEX i ON t(c)": canalEventCIndex,
+		"GRANT ALL ON *.* TO u":         canalEventQuery,
+	}
+	for query, expected := range tests {
+		if typ := canalDDLEventType(query); typ != expected {
+			t.Errorf("%s: expected %d, got %d", query, expected, typ)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package binlog
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Column is the metadata of a column retrieved from information_schema.COLUMNS by RetrieveColumns,
+// or taken from the optional metadata of TableMapEvent, in which case only some of the fields are known.
+type Column struct {
+	Name string
+	// Position is the ordinal position of the column starting from 1.
+	Position int
+	// DataType is the type name like "int" or "varchar", ColumnType is the full type like "int(10) unsigned".
+	DataType   string
+	ColumnType string
+	// Charset is empty for the non-textual columns.
+	Charset   string
+	Nullable  bool
+	IsPrimary bool
+	Unsigned  bool
+	// EnumValues and SetValues are the member names of the ENUM and SET columns.
+	EnumValues []string
+	SetValues  []string
+	// NumericPrecision and NumericScale are 0 for the non-numeric columns.
+	NumericPrecision int
+	NumericScale     int
+	// Generated is true for the virtual and stored generated columns.
+	Generated bool
+}
+
+// RetrieveColumns retrieves the column metadata of the table from information_schema in ordinal order.
+func RetrieveColumns(db *sql.DB, database, table string) ([]*Column, error) {
+	rows, err := db.Query("SELECT COLUMN_NAME, ORDINAL_POSITION, DATA_TYPE, COLUMN_TYPE, IFNULL(CHARACTER_SET_NAME, ''), "+
+		"IS_NULLABLE, COLUMN_KEY, IFNULL(NUMERIC_PRECISION, 0), IFNULL(NUMERIC_SCALE, 0), EXTRA "+
+		"FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", database, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []*Column
+	for rows.Next() {
+		var nullable, columnKey, extra string
+		c := new(Column)
+		if err = rows.Scan(&c.Name, &c.Position, &c.DataType, &c.ColumnType, &c.Charset, &nullable, &columnKey,
+			&c.NumericPrecision, &c.NumericScale, &extra); err != nil {
+			return nil, err
+		}
+		c.Nullable = nullable == "YES"
+		c.IsPrimary = columnKey == "PRI"
+		c.Generated = strings.Contains(strings.ToUpper(extra), "GENERATED")
+		c.Unsigned = strings.Contains(strings.ToLower(c.ColumnType), "unsigned")
+		c.parseColumnType(c.ColumnType)
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// parseColumnType parses the member names from COLUMN_TYPE like `enum('a','b')` or `set('x','y')`.
+func (c *Column) parseColumnType(columnType string) {
+	lower := strings.ToLower(columnType)
+	switch {
+	case strings.HasPrefix(lower, "enum(") && strings.HasSuffix(lower, ")"):
+		c.EnumValues = parseQuotedValues(columnType[len("enum(") : len(columnType)-1])
+	case strings.HasPrefix(lower, "set(") && strings.HasSuffix(lower, ")"):
+		c.SetValues = parseQuotedValues(columnType[len("set(") : len(columnType)-1])
+	}
+}
+
+// parseQuotedValues parses a comma separated list of single-quoted values, in which a quote is escaped by doubling it.
+func parseQuotedValues(s string) []string {
+	values := make([]string, 0)
+	var buf []byte
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		switch {
+		case !quoted:
+			if b == '\'' {
+				quoted = true
+				buf = buf[:0]
+			}
+		case b == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			buf = append(buf, b)
+			i++
+		case b == '\'':
+			quoted = false
+			values = append(values, string(buf))
+		default:
+			buf = append(buf, b)
+		}
+	}
+	return values
+}
+
+// EnumValue is an ENUM value resolved to its member name, Index is the raw ordinal starting from 1.
+type EnumValue struct {
+	Index int64
+	Name  string
+}
+
+func (v EnumValue) String() string {
+	return v.Name
+}
+
+// SetValue is a SET value resolved to its member names, Bits is the raw bitmask.
+type SetValue struct {
+	Bits    int64
+	Members []string
+}
+
+func (v SetValue) String() string {
+	return strings.Join(v.Members, ",")
+}
+
+// resolve maps the ordinal of an ENUM value or the bitmask of a SET value to the member names,
+// SET members are joined with commas in declaration order. Other values are returned unchanged.
+// If parse is true, EnumValue and SetValue are returned to keep the raw values.
+func (c *Column) resolve(v interface{}, parse bool) interface{} {
+	n, ok := v.(int64)
+	if !ok {
+		return v
+	}
+	switch {
+	case c.EnumValues != nil:
+		if n < 0 || n > int64(len(c.EnumValues)) {
+			return v
+		}
+		// 0 is the index of the empty string as the special error value
+		value := EnumValue{Index: n}
+		if n > 0 {
+			value.Name = c.EnumValues[n-1]
+		}
+		if parse {
+			return value
+		}
+		return value.Name
+	case c.SetValues != nil:
+		value := SetValue{Bits: n, Members: make([]string, 0, len(c.SetValues))}
+		for i, member := range c.SetValues {
+			if n&(1<<uint(i)) != 0 {
+				value.Members = append(value.Members, member)
+			}
+		}
+		if parse {
+			return value
+		}
+		return value.String()
+	}
+	return v
+}
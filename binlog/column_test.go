@@ -0,0 +1,137 @@
+package binlog
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseColumnType(t *testing.T) {
+	c := new(Column)
+	c.parseColumnType("enum('pending','shipped','it''s','a,b')")
+	expected := []string{"pending", "shipped", "it's", "a,b"}
+	if !reflect.DeepEqual(c.EnumValues, expected) {
+		t.Errorf("expected %v, got %v", expected, c.EnumValues)
+	}
+
+	c = new(Column)
+	c.parseColumnType("set('red','green','blue')")
+	expected = []string{"red", "green", "blue"}
+	if !reflect.DeepEqual(c.SetValues, expected) {
+		t.Errorf("expected %v, got %v", expected, c.SetValues)
+	}
+
+	c = new(Column)
+	c.parseColumnType("int(10) unsigned")
+	if c.EnumValues != nil || c.SetValues != nil {
+		t.Error("expected no members")
+	}
+}
+
+func TestResolveColumnValue(t *testing.T) {
+	enum := &Column{EnumValues: []string{"a", "b", "c"}}
+	set := &Column{SetValues: []string{"red", "green", "blue"}}
+	tests := []struct {
+		c        *Column
+		v        interface{}
+		expected interface{}
+	}{
+		{enum, int64(2), "b"},
+		{enum, int64(0), ""},
+		{enum, int64(4), int64(4)},
+		{set, int64(5), "red,blue"},
+		{set, int64(0), ""},
+		{set, int64(8), ""},
+		{set, nil, nil},
+		{&Column{}, int64(1), int64(1)},
+	}
+	for _, test := range tests {
+		if v := test.c.resolve(test.v, false); v != test.expected {
+			t.Errorf("resolve %v: expected %#v, got %#v", test.v, test.expected, v)
+		}
+	}
+}
+
+func TestResolveColumnValueParsed(t *testing.T) {
+	enum := &Column{EnumValues: []string{"a", "b", "c"}}
+	set := &Column{SetValues: []string{"red", "green", "blue"}}
+	tests := []struct {
+		c        *Column
+		v        interface{}
+		expected interface{}
+	}{
+		{enum, int64(2), EnumValue{Index: 2, Name: "b"}},
+		{enum, int64(0), EnumValue{}},
+		{enum, int64(4), int64(4)},
+		{set, int64(5), SetValue{Bits: 5, Members: []string{"red", "blue"}}},
+		{set, int64(0), SetValue{Members: []string{}}},
+	}
+	for _, test := range tests {
+		if v := test.c.resolve(test.v, true); !reflect.DeepEqual(v, test.expected) {
+			t.Errorf("resolve %v: expected %#v, got %#v", test.v, test.expected, v)
+		}
+	}
+	if s := set.resolve(int64(3), true).(SetValue).String(); s != "red,green" {
+		t.Errorf("expected red,green, got %s", s)
+	}
+}
+
+func TestColumnCacheInvalidation(t *testing.T) {
+	db, err := sql.Open("mysql", "user:password@tcp(127.0.0.1:1)/test?timeout=1s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	dec := &EventDecoder{DB: db, ColumnCacheTTL: time.Hour, Filter: &EventFilter{EventTypes: []EventType{WriteRowsEventType}}}
+	cache := func(keys ...string) {
+		dec.columns = make(map[string]*cachedColumns)
+		for _, key := range keys {
+			dec.columns[key] = &cachedColumns{columns: []*Column{{Name: "id"}}, retrieved: time.Now()}
+		}
+	}
+	cached := func(database, table string) bool {
+		columns, err := dec.tableColumns(database, table)
+		return err == nil && len(columns) == 1
+	}
+
+	cache("test.t", "test.u", "other.t")
+	if !cached("test", "t") {
+		t.Fatal("expected the cached columns")
+	}
+	dec.InvalidateColumns("test", "")
+	if _, ok := dec.columns["test.t"]; ok || len(dec.columns) != 1 {
+		t.Errorf("expected the tables of test invalidated, got %v", dec.columns)
+	}
+
+	for query, invalidated := range map[string][]string{
+		"ALTER TABLE `t` ADD COLUMN v int":               {"test.t"},
+		"RENAME TABLE t TO t2, other.t TO test.u":        {"test.t", "other.t", "test.u"},
+		"DROP TABLE IF EXISTS u, other.t":                {"test.u", "other.t"},
+		"CREATE TABLE IF NOT EXISTS t (id int)":          {"test.t"},
+		"/* comment */ INSERT INTO t VALUES (1)":         nil,
+		"ALTER TABLE t RENAME COLUMN a TO b, ENGINE=Foo": {"test.t"},
+	} {
+		cache("test.t", "test.u", "other.t")
+		body := make([]byte, 13)
+		body[8] = 4
+		body = append(body, "test\x00"+query...)
+		if ev, err := dec.decode(buildEvent(QueryEventType, body, false)); err != nil || ev != nil {
+			t.Fatalf("expected the QueryEvent decoded and filtered out, got %v, %v", ev, err)
+		}
+		for _, key := range invalidated {
+			if _, ok := dec.columns[key]; ok {
+				t.Errorf("%s: expected %s invalidated", query, key)
+			}
+		}
+		if len(dec.columns) != 3-len(invalidated) {
+			t.Errorf("%s: unexpected cached tables %v", query, dec.columns)
+		}
+	}
+
+	cache("test.t")
+	dec.columns["test.t"].retrieved = time.Now().Add(-2 * time.Hour)
+	if cached("test", "t") {
+		t.Error("expected the expired columns retrieved again")
+	}
+}
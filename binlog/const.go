@@ -2,8 +2,16 @@ package binlog
 
 type EventType byte
 
+// binlog event flags
+const (
+	// logEventArtificialFlag is set for events generated by the master which are not written to the binlog,
+	// e.g. the fake RotateEvent sent at the beginning of a dump.
+	logEventArtificialFlag uint16 = 0x20
+)
+
 // binlog event type constants
-// refer to https://github.com/mysql/mysql-server/blob/5.7/libbinlogevents/include/binlogEvent.h
+// refer to https://github.com/mysql/mysql-server/blob/5.7/libbinlogevents/include/binlogEvent.h,
+// the events after XaPrepareLogEventType are added in 8.0
 const (
 	UnknownEventType EventType = iota
 	StartEventTypeV3
@@ -44,6 +52,18 @@ const (
 	TransactionContextEventType
 	ViewChangeEventType
 	XaPrepareLogEventType
+	PartialUpdateRowsEventType
+	TransactionPayloadEventType
+)
+
+// MariaDB binlog event type constants
+// refer to https://github.com/MariaDB/server/blob/10.2/sql/log_event.h
+const (
+	MariadbAnnotateRowsEventType EventType = iota + 0xa0
+	MariadbBinlogCheckpointEventType
+	MariadbGtidEventType
+	MariadbGtidListEventType
+	MariadbStartEncryptionEventType
 )
 
 func (t EventType) String() string {
@@ -126,6 +146,20 @@ func (t EventType) String() string {
 		return "ViewChangeEvent"
 	case XaPrepareLogEventType:
 		return "XaPrepareLogEvent"
+	case PartialUpdateRowsEventType:
+		return "PartialUpdateRowsEvent"
+	case TransactionPayloadEventType:
+		return "TransactionPayloadEvent"
+	case MariadbAnnotateRowsEventType:
+		return "MariadbAnnotateRowsEvent"
+	case MariadbBinlogCheckpointEventType:
+		return "MariadbBinlogCheckpointEvent"
+	case MariadbGtidEventType:
+		return "MariadbGtidEvent"
+	case MariadbGtidListEventType:
+		return "MariadbGtidListEvent"
+	case MariadbStartEncryptionEventType:
+		return "MariadbStartEncryptionEvent"
 	default:
 		return "UnknownEvent"
 	}
@@ -167,3 +201,41 @@ const (
 	fieldTypeString
 	fieldTypeGeometry
 )
+
+// ColumnType is the type of a column in TableMapEvent.
+type ColumnType byte
+
+// column type constants exposed to ValueMapper
+const (
+	ColumnTypeDecimal     = ColumnType(fieldTypeDecimal)
+	ColumnTypeTiny        = ColumnType(fieldTypeTiny)
+	ColumnTypeShort       = ColumnType(fieldTypeShort)
+	ColumnTypeLong        = ColumnType(fieldTypeLong)
+	ColumnTypeFloat       = ColumnType(fieldTypeFloat)
+	ColumnTypeDouble      = ColumnType(fieldTypeDouble)
+	ColumnTypeNULL        = ColumnType(fieldTypeNULL)
+	ColumnTypeTimestamp   = ColumnType(fieldTypeTimestamp)
+	ColumnTypeLongLong    = ColumnType(fieldTypeLongLong)
+	ColumnTypeInt24       = ColumnType(fieldTypeInt24)
+	ColumnTypeDate        = ColumnType(fieldTypeDate)
+	ColumnTypeTime        = ColumnType(fieldTypeTime)
+	ColumnTypeDateTime    = ColumnType(fieldTypeDateTime)
+	ColumnTypeYear        = ColumnType(fieldTypeYear)
+	ColumnTypeNewDate     = ColumnType(fieldTypeNewDate)
+	ColumnTypeVarChar     = ColumnType(fieldTypeVarChar)
+	ColumnTypeBit         = ColumnType(fieldTypeBit)
+	ColumnTypeTimestampV2 = ColumnType(fieldTypeTimestampV2)
+	ColumnTypeDateTimeV2  = ColumnType(fieldTypeDateTimeV2)
+	ColumnTypeTimeV2      = ColumnType(fieldTypeTimeV2)
+	ColumnTypeJSON        = ColumnType(fieldTypeJSON)
+	ColumnTypeNewDecimal  = ColumnType(fieldTypeNewDecimal)
+	ColumnTypeEnum        = ColumnType(fieldTypeEnum)
+	ColumnTypeSet         = ColumnType(fieldTypeSet)
+	ColumnTypeTinyBLOB    = ColumnType(fieldTypeTinyBLOB)
+	ColumnTypeMediumBLOB  = ColumnType(fieldTypeMediumBLOB)
+	ColumnTypeLongBLOB    = ColumnType(fieldTypeLongBLOB)
+	ColumnTypeBLOB        = ColumnType(fieldTypeBLOB)
+	ColumnTypeVarString   = ColumnType(fieldTypeVarString)
+	ColumnTypeString      = ColumnType(fieldTypeString)
+	ColumnTypeGeometry    = ColumnType(fieldTypeGeometry)
+)
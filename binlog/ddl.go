@@ -0,0 +1,364 @@
+package binlog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the kinds of the DDL tokens
+const (
+	ddlEOF = iota
+	ddlWord
+	ddlQuotedIdent
+	ddlString
+	ddlNumber
+	ddlPunct
+)
+
+type ddlToken struct {
+	kind int
+	text string
+}
+
+// ddlParser parses the DDL statements which change the table definitions. The tokens are lexed on demand,
+// so that the other statements are given up after the first keyword.
+type ddlParser struct {
+	query  string
+	offset int
+	// next is the peeked token
+	next *ddlToken
+	err  error
+}
+
+func newDDLParser(query string) *ddlParser {
+	return &ddlParser{query: query}
+}
+
+func (p *ddlParser) peek() ddlToken {
+	if p.next == nil {
+		t := p.lex()
+		p.next = &t
+	}
+	return *p.next
+}
+
+func (p *ddlParser) take() ddlToken {
+	t := p.peek()
+	p.next = nil
+	return t
+}
+
+// lex reads the next token, the comments are skipped except the content of the version comments like /*!50100 */.
+func (p *ddlParser) lex() ddlToken {
+	q := p.query
+	for p.offset < len(q) {
+		c := q[p.offset]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.offset++
+		case strings.HasPrefix(q[p.offset:], "/*!"):
+			p.offset += 3
+			for p.offset < len(q) && q[p.offset] >= '0' && q[p.offset] <= '9' {
+				p.offset++
+			}
+		case strings.HasPrefix(q[p.offset:], "*/"):
+			// the end of a version comment
+			p.offset += 2
+		case strings.HasPrefix(q[p.offset:], "/*"):
+			end := strings.Index(q[p.offset+2:], "*/")
+			if end < 0 {
+				p.offset = len(q)
+			} else {
+				p.offset += end + 4
+			}
+		case c == '#' || strings.HasPrefix(q[p.offset:], "-- "):
+			end := strings.IndexByte(q[p.offset:], '\n')
+			if end < 0 {
+				p.offset = len(q)
+			} else {
+				p.offset += end + 1
+			}
+		case c == '`' || c == '\'' || c == '"':
+			return p.lexQuoted(c)
+		case isIdentChar(c):
+			start := p.offset
+			for p.offset < len(q) && isIdentChar(q[p.offset]) {
+				p.offset++
+			}
+			kind := ddlWord
+			if c >= '0' && c <= '9' {
+				kind = ddlNumber
+			}
+			return ddlToken{kind: kind, text: q[start:p.offset]}
+		default:
+			p.offset++
+			return ddlToken{kind: ddlPunct, text: string(c)}
+		}
+	}
+	return ddlToken{kind: ddlEOF}
+}
+
+func isIdentChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '$' || c >= 0x80
+}
+
+// lexQuoted reads an identifier quoted by backticks or a string, in which the quote is escaped by doubling it.
+// The backslash escapes of the strings are kept as they are.
+func (p *ddlParser) lexQuoted(quote byte) ddlToken {
+	q := p.query
+	var buf []byte
+	for p.offset++; p.offset < len(q); p.offset++ {
+		c := q[p.offset]
+		if c == '\\' && quote != '`' && p.offset+1 < len(q) {
+			buf = append(buf, c, q[p.offset+1])
+			p.offset++
+			continue
+		}
+		if c == quote {
+			if p.offset+1 < len(q) && q[p.offset+1] == quote {
+				buf = append(buf, c)
+				p.offset++
+				continue
+			}
+			p.offset++
+			break
+		}
+		buf = append(buf, c)
+	}
+	if quote == '`' {
+		return ddlToken{kind: ddlQuotedIdent, text: string(buf)}
+	}
+	return ddlToken{kind: ddlString, text: string(buf)}
+}
+
+// keyword consumes the next token if it's one of the keywords.
+func (p *ddlParser) keyword(keywords ...string) bool {
+	t := p.peek()
+	if t.kind != ddlWord {
+		return false
+	}
+	for _, kw := range keywords {
+		if strings.EqualFold(t.text, kw) {
+			p.take()
+			return true
+		}
+	}
+	return false
+}
+
+// punct consumes the next token if it's the punctuation.
+func (p *ddlParser) punct(s string) bool {
+	if t := p.peek(); t.kind == ddlPunct && t.text == s {
+		p.take()
+		return true
+	}
+	return false
+}
+
+func (p *ddlParser) expect(s string) {
+	if !p.punct(s) {
+		p.fail("expected %q", s)
+	}
+}
+
+func (p *ddlParser) fail(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%s near offset %d", fmt.Sprintf(format, args...), p.offset)
+	}
+}
+
+func (p *ddlParser) ident() string {
+	t := p.take()
+	if t.kind != ddlWord && t.kind != ddlQuotedIdent && t.kind != ddlNumber {
+		p.fail("expected identifier")
+	}
+	return t.text
+}
+
+// tableName parses `table` or `database`.`table`.
+func (p *ddlParser) tableName(database string) (string, string) {
+	name := p.ident()
+	if p.punct(".") {
+		return name, p.ident()
+	}
+	return database, name
+}
+
+// ddlTables returns the [database, table] names changed by the DDL query, the database is empty if it's
+// not qualified. The names of RENAME TABLE are the old and the new ones of every pair.
+func ddlTables(query string) [][2]string {
+	p := newDDLParser(query)
+	list := false
+	switch {
+	case p.keyword("CREATE"):
+		p.keyword("TEMPORARY")
+		if !p.keyword("TABLE") {
+			return nil
+		}
+		if p.keyword("IF") {
+			p.keyword("NOT")
+			p.keyword("EXISTS")
+		}
+	case p.keyword("ALTER"):
+		p.keyword("ONLINE", "OFFLINE")
+		p.keyword("IGNORE")
+		if !p.keyword("TABLE") {
+			return nil
+		}
+	case p.keyword("DROP"):
+		p.keyword("TEMPORARY")
+		if !p.keyword("TABLE", "TABLES") {
+			return nil
+		}
+		if p.keyword("IF") {
+			p.keyword("EXISTS")
+		}
+		list = true
+	case p.keyword("TRUNCATE"):
+		p.keyword("TABLE")
+	case p.keyword("RENAME"):
+		if !p.keyword("TABLE", "TABLES") {
+			return nil
+		}
+		list = true
+	default:
+		return nil
+	}
+
+	var names [][2]string
+	for {
+		database, table := p.tableName("")
+		if p.err != nil {
+			return names
+		}
+		names = append(names, [2]string{database, table})
+		if !list || !p.keyword("TO") && !p.punct(",") {
+			return names
+		}
+	}
+}
+
+// skip skips the tokens until a comma or a closing parenthesis at the current depth, which is not consumed.
+func (p *ddlParser) skip() {
+	depth := 0
+	for p.err == nil {
+		t := p.peek()
+		switch {
+		case t.kind == ddlEOF:
+			return
+		case t.kind == ddlPunct && t.text == "(":
+			depth++
+		case t.kind == ddlPunct && t.text == ")":
+			if depth == 0 {
+				return
+			}
+			depth--
+		case t.kind == ddlPunct && (t.text == "," || t.text == ";") && depth == 0:
+			return
+		}
+		p.take()
+	}
+}
+
+// skipParens skips the tokens up to the matching closing parenthesis of the next opening one.
+func (p *ddlParser) skipParens() {
+	p.expect("(")
+	p.skip()
+	p.expect(")")
+}
+
+// keyParts parses the column names of an index like (a, b(10) DESC).
+func (p *ddlParser) keyParts() []string {
+	var names []string
+	p.expect("(")
+	for p.err == nil {
+		if p.peek().kind == ddlPunct && p.peek().text == "(" {
+			// a functional key part
+			p.skipParens()
+		} else {
+			names = append(names, p.ident())
+		}
+		p.skip()
+		if !p.punct(",") {
+			break
+		}
+	}
+	p.expect(")")
+	return names
+}
+
+// textTypes are the column types with charsets.
+var textTypes = map[string]bool{
+	"char": true, "varchar": true, "tinytext": true, "text": true, "mediumtext": true, "longtext": true,
+	"enum": true, "set": true,
+}
+
+// columnDef parses a column definition, primary is true if it's declared as PRIMARY KEY.
+func (p *ddlParser) columnDef() (c ColumnSchema, primary bool) {
+	c.Name = p.ident()
+	c.Type = strings.ToLower(p.ident())
+	if p.punct("(") {
+		var args []string
+		for p.err == nil && !p.punct(")") {
+			t := p.take()
+			switch t.kind {
+			case ddlString:
+				args = append(args, "'"+strings.Replace(t.text, "'", "''", -1)+"'")
+			case ddlEOF:
+				p.fail("unterminated type arguments")
+			case ddlPunct:
+				if t.text != "," {
+					p.fail("unexpected %q in type arguments", t.text)
+				}
+			default:
+				args = append(args, t.text)
+			}
+		}
+		c.Type += "(" + strings.Join(args, ",") + ")"
+	}
+
+	for p.err == nil {
+		t := p.peek()
+		if t.kind == ddlEOF || t.kind == ddlPunct && (t.text == "," || t.text == ")" || t.text == ";") ||
+			t.kind == ddlWord && (strings.EqualFold(t.text, "FIRST") || strings.EqualFold(t.text, "AFTER")) {
+			break
+		}
+		switch {
+		case p.keyword("UNSIGNED"):
+			c.Type += " unsigned"
+		case p.keyword("ZEROFILL"):
+			c.Type += " zerofill"
+		case p.keyword("CHARACTER"):
+			p.keyword("SET")
+			c.Charset = strings.ToLower(p.ident())
+		case p.keyword("CHARSET"):
+			c.Charset = strings.ToLower(p.ident())
+		case p.keyword("COLLATE"):
+			collation := strings.ToLower(p.ident())
+			if c.Charset == "" {
+				c.Charset = strings.SplitN(collation, "_", 2)[0]
+			}
+		case p.keyword("PRIMARY"):
+			p.keyword("KEY")
+			primary = true
+		case t.kind == ddlPunct && t.text == "(":
+			// the expressions of DEFAULT, GENERATED ALWAYS AS and CHECK
+			p.skipParens()
+		default:
+			p.take()
+		}
+	}
+	return c, primary
+}
+
+// columnPosition parses FIRST or AFTER of ADD, MODIFY and CHANGE of ALTER TABLE, after is "" for the end
+// and first is true for FIRST.
+func (p *ddlParser) columnPosition() (first bool, after string) {
+	if p.keyword("FIRST") {
+		return true, ""
+	}
+	if p.keyword("AFTER") {
+		return false, p.ident()
+	}
+	return false, ""
+}
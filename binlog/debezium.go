@@ -0,0 +1,378 @@
+package binlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	debeziumConnector = "mysql"
+	// binaryCollationID is the collation of the binary strings and BLOBs
+	binaryCollationID = 63
+)
+
+// DebeziumRecord is a change record in the JSON envelope of the Debezium MySQL connector.
+type DebeziumRecord struct {
+	// Topic is <server name>.<database>.<table> like the Debezium topics.
+	Topic string
+	// Key is the JSON of the primary key columns, nil if the table has no primary key.
+	Key []byte
+	// Value is the JSON of the envelope with before, after, source, op and ts_ms in the payload,
+	// nil for the tombstone following a delete.
+	Value []byte
+}
+
+// DebeziumFormatter formats the row changes of transactions as DebeziumRecords, so that the consumers of
+// the Debezium MySQL connector can switch without changes. The records are written as the JSON converter does
+// with schemas disabled, and the column values are converted like the connector does by default, i.e.
+// time.precision.mode=adaptive_time_microseconds and decimal.handling.mode=precise:
+//
+//   - DATE is io.debezium.time.Date, the days since the epoch.
+//   - DATETIME is io.debezium.time.Timestamp in milliseconds since the epoch, or MicroTimestamp in microseconds
+//     if its fractional precision is over 3.
+//   - TIMESTAMP is io.debezium.time.ZonedTimestamp, the string in UTC like "2024-01-02T03:04:05.678Z".
+//   - TIME is io.debezium.time.MicroTime, the microseconds.
+//   - DECIMAL is the Kafka Connect Decimal, the bytes of the unscaled value in two's complement in base64.
+//   - BIT(1) is a boolean and BIT(n) is the little-endian bytes, ENUM and SET are the names if they're known.
+//
+// The zero dates are null. The DECIMAL values must be decoded with DecimalString or DecimalRat to be precise.
+// Every delete is followed by a tombstone of the same key, unless SkipTombstones is set.
+type DebeziumFormatter struct {
+	// ServerName is the logical name of the MySQL server, used as the topic prefix and source.name.
+	ServerName string
+	// SkipTombstones doesn't write the tombstones like tombstones.on.delete=false.
+	SkipTombstones bool
+}
+
+type debeziumKey struct {
+	Payload map[string]interface{} `json:"payload"`
+}
+
+type debeziumValue struct {
+	Payload debeziumPayload `json:"payload"`
+}
+
+type debeziumPayload struct {
+	Before map[string]interface{} `json:"before"`
+	After  map[string]interface{} `json:"after"`
+	Source debeziumSource         `json:"source"`
+	Op     string                 `json:"op"`
+	TsMs   int64                  `json:"ts_ms"`
+}
+
+type debeziumSource struct {
+	Connector string  `json:"connector"`
+	Name      string  `json:"name"`
+	TsMs      int64   `json:"ts_ms"`
+	Snapshot  string  `json:"snapshot"`
+	DB        string  `json:"db"`
+	Table     string  `json:"table"`
+	ServerID  uint32  `json:"server_id"`
+	GTID      *string `json:"gtid"`
+	File      string  `json:"file"`
+	Pos       uint32  `json:"pos"`
+	Row       int     `json:"row"`
+	Query     *string `json:"query"`
+}
+
+// Format returns the records of the rows events in the transaction.
+func (f *DebeziumFormatter) Format(tx *Transaction) ([]*DebeziumRecord, error) {
+	var gtid *string
+	if tx.GTID != "" {
+		gtid = &tx.GTID
+	}
+
+	var records []*DebeziumRecord
+	for _, e := range tx.RowsEvents() {
+		var query *string
+		if e.Query != nil {
+			q := string(e.Query)
+			query = &q
+		}
+
+		db, table := string(e.Table.Database), string(e.Table.TableName)
+		op := "c"
+		if e.isUpdate() {
+			op = "u"
+		} else if e.isDelete() {
+			op = "d"
+		}
+		for i, change := range e.RowChanges() {
+			var err error
+			if change.Before, err = debeziumRow(e.Table, change.Before); err != nil {
+				return nil, err
+			}
+			if change.After, err = debeziumRow(e.Table, change.After); err != nil {
+				return nil, err
+			}
+			source := debeziumSource{
+				Connector: debeziumConnector,
+				Name:      f.ServerName,
+				TsMs:      int64(e.header.Timestamp) * 1000,
+				Snapshot:  "false",
+				DB:        db,
+				Table:     table,
+				ServerID:  e.header.ServerID,
+				GTID:      gtid,
+				File:      tx.Position.File,
+				// the start position of the event
+				Pos:   e.header.NextLogPos - e.header.EventSize,
+				Row:   i,
+				Query: query,
+			}
+			value, err := json.Marshal(debeziumValue{debeziumPayload{
+				Before: change.Before,
+				After:  change.After,
+				Source: source,
+				Op:     op,
+				TsMs:   time.Now().UnixNano() / int64(time.Millisecond),
+			}})
+			if err != nil {
+				return nil, err
+			}
+
+			record := &DebeziumRecord{Topic: f.ServerName + "." + db + "." + table, Value: value}
+			if record.Key, err = debeziumRecordKey(e.Table, change); err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+			// the tombstone lets the log compaction remove the records of the key
+			if op == "d" && record.Key != nil && !f.SkipTombstones {
+				records = append(records, &DebeziumRecord{Topic: record.Topic, Key: record.Key})
+			}
+		}
+	}
+	return records, nil
+}
+
+func debeziumRecordKey(table *TableMapEvent, change RowChange) ([]byte, error) {
+	key := change.Key(table.PrimaryKeyColumns())
+	if key == nil {
+		return nil, nil
+	}
+	return json.Marshal(debeziumKey{key})
+}
+
+// debeziumRow converts the values of the row to the logical types of Debezium.
+func debeziumRow(table *TableMapEvent, row map[string]interface{}) (map[string]interface{}, error) {
+	if row == nil {
+		return nil, nil
+	}
+	converted := make(map[string]interface{}, len(row))
+	for i := 0; i < int(table.ColumnCount); i++ {
+		name := table.ColumnName(i)
+		v, ok := row[name]
+		if !ok {
+			continue
+		}
+		var err error
+		if converted[name], err = debeziumColumnValue(table, i, v); err != nil {
+			return nil, fmt.Errorf("column %s: %v", name, err)
+		}
+	}
+	return converted, nil
+}
+
+// debeziumColumnValue converts the value of the i-th column, see DebeziumFormatter.
+func debeziumColumnValue(table *TableMapEvent, i int, v interface{}) (interface{}, error) {
+	if v == nil || i >= len(table.ColumnTypes) {
+		return v, nil
+	}
+	var meta uint16
+	if i < len(table.ColumnMeta) {
+		meta = table.ColumnMeta[i]
+	}
+	switch typ := table.realType(i); typ {
+	case fieldTypeDate, fieldTypeNewDate:
+		t, ok := debeziumDateTime(v)
+		if !ok {
+			return nil, nil
+		}
+		return t.Unix() / 86400, nil
+	case fieldTypeDateTime, fieldTypeDateTimeV2:
+		t, ok := debeziumDateTime(v)
+		if !ok {
+			return nil, nil
+		}
+		if typ == fieldTypeDateTimeV2 && meta > 3 {
+			return t.UnixNano() / int64(time.Microsecond), nil
+		}
+		return t.UnixNano() / int64(time.Millisecond), nil
+	case fieldTypeTimestamp, fieldTypeTimestampV2:
+		var t time.Time
+		switch v := v.(type) {
+		case int64:
+			if v == 0 {
+				return nil, nil
+			}
+			t = time.Unix(0, v)
+		case time.Time:
+			if v.IsZero() {
+				return nil, nil
+			}
+			t = v
+		default:
+			return v, nil
+		}
+		layout := "2006-01-02T15:04:05"
+		if typ == fieldTypeTimestampV2 && meta > 0 {
+			layout += "." + strings.Repeat("0", int(meta))
+		}
+		return t.UTC().Format(layout) + "Z", nil
+	case fieldTypeTime, fieldTypeTimeV2:
+		if d, ok := v.(time.Duration); ok {
+			return int64(d / time.Microsecond), nil
+		}
+		t, err := parseTemporal(v)
+		if err != nil {
+			return nil, err
+		}
+		usec := ((t.hour*60+t.minute)*60+t.second)*1000000 + t.usec
+		if t.negative {
+			usec = -usec
+		}
+		return usec, nil
+	case fieldTypeNewDecimal:
+		return debeziumDecimal(v, int(meta&0xFF))
+	case fieldTypeBit:
+		u, ok := v.(int64)
+		if !ok {
+			return v, nil
+		}
+		nbits := int(meta>>8)*8 + int(meta&0xFF)
+		if nbits == 1 {
+			return u != 0, nil
+		}
+		b := make([]byte, (nbits+7)/8)
+		for j := range b {
+			b[j] = byte(u >> (8 * uint(j)))
+		}
+		return b, nil
+	case fieldTypeEnum, fieldTypeSet:
+		return debeziumEnumOrSet(table, i, typ, v), nil
+	case fieldTypeJSON:
+		switch v := v.(type) {
+		case string:
+			return v, nil
+		case []byte:
+			return string(v), nil
+		}
+		b, err := json.Marshal(v)
+		return string(b), err
+	case fieldTypeVarChar, fieldTypeVarString, fieldTypeString, fieldTypeBLOB:
+		// the TEXT columns are decoded as []byte like the BLOB ones
+		if b, ok := v.([]byte); ok && isTextColumn(table, i) {
+			return string(b), nil
+		}
+	}
+	return v, nil
+}
+
+// debeziumDateTime returns the DATE or DATETIME value as the wall clock in UTC, false for the zero dates.
+func debeziumDateTime(v interface{}) (time.Time, bool) {
+	t, err := parseTemporal(v)
+	if err != nil || t.year == 0 || t.month == 0 || t.day == 0 {
+		return time.Time{}, false
+	}
+	return time.Date(int(t.year), time.Month(t.month), int(t.day), int(t.hour), int(t.minute), int(t.second),
+		int(t.usec)*int(time.Microsecond), time.UTC), true
+}
+
+// debeziumDecimal returns the unscaled value of the DECIMAL in big-endian two's complement.
+func debeziumDecimal(v interface{}, scale int) (interface{}, error) {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case *big.Rat:
+		s = v.FloatString(scale)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', scale, 64)
+	default:
+		return v, nil
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %s", s)
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	if !r.IsInt() {
+		return nil, fmt.Errorf("decimal %s has more than %d digits of scale", s, scale)
+	}
+	return twosComplement(r.Num()), nil
+}
+
+// twosComplement returns the minimal big-endian two's complement of n like BigInteger.toByteArray of Java.
+func twosComplement(n *big.Int) []byte {
+	if n.Sign() >= 0 {
+		b := n.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return b
+	}
+	// -n = ^(n-1), so the bytes of n are the complement of the ones of -n-1
+	m := new(big.Int).Neg(n)
+	m.Sub(m, big.NewInt(1))
+	b := m.Bytes()
+	if len(b) == 0 || b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	for j := range b {
+		b[j] = ^b[j]
+	}
+	return b
+}
+
+// debeziumEnumOrSet returns the member names of the ENUM or SET value if they're known.
+func debeziumEnumOrSet(table *TableMapEvent, i int, typ byte, v interface{}) interface{} {
+	n, ok := v.(int64)
+	if !ok {
+		return v
+	}
+	var names []string
+	values := table.EnumValues
+	if typ == fieldTypeSet {
+		values = table.SetValues
+	}
+	if i < len(values) {
+		names = values[i]
+	} else if columns := table.Columns(); columns != nil && i < len(columns) {
+		names = columns[i].EnumValues
+		if typ == fieldTypeSet {
+			names = columns[i].SetValues
+		}
+	}
+	if names == nil {
+		return v
+	}
+	if typ == fieldTypeEnum {
+		if n < 1 || int(n) > len(names) {
+			return ""
+		}
+		return names[n-1]
+	}
+	var members []string
+	for j, name := range names {
+		if n&(1<<uint(j)) != 0 {
+			members = append(members, name)
+		}
+	}
+	return strings.Join(members, ",")
+}
+
+// isTextColumn reports whether the i-th column has a charset other than binary, which is known from the optional
+// metadata or the column metadata.
+func isTextColumn(table *TableMapEvent, i int) bool {
+	if i < len(table.ColumnCharsets) && table.ColumnCharsets[i] != 0 {
+		return table.ColumnCharsets[i] != binaryCollationID
+	}
+	if columns := table.Columns(); columns != nil && i < len(columns) {
+		return columns[i].Charset != "" && columns[i].Charset != "binary"
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package binlog
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDebeziumFormatter(t *testing.T) {
+	table := &TableMapEvent{
+		Database:    []byte("inventory"),
+		TableName:   []byte("customers"),
+		ColumnCount: 2,
+		ColumnTypes: []byte{fieldTypeLong, fieldTypeVarChar},
+		columns:     []*Column{{Name: "id", IsPrimary: true}, {Name: "email"}},
+	}
+	rows := &RowsEvent{
+		baseEvent:   &baseEvent{header: &EventHeader{Type: DeleteRowsEventType, Timestamp: 1500000000, ServerID: 1, EventSize: 50, NextLogPos: 1050}},
+		Table:       table,
+		ColumnCount: 2,
+		Columns:     []byte{0x03},
+		Rows:        [][]interface{}{{int64(1001), "a@example.com"}},
+	}
+	tx := &Transaction{
+		GTID:     "3e11fa47-71ca-11e1-9e33-c80aa9429562:6",
+		Events:   []Event{table, rows},
+		Position: Position{File: "mysql-bin.000003", Pos: 1100},
+	}
+
+	records, err := (&DebeziumFormatter{ServerName: "dbserver1"}).Format(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the delete is followed by a tombstone
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if tombstone := records[1]; tombstone.Value != nil || string(tombstone.Key) != string(records[0].Key) {
+		t.Errorf("unexpected tombstone %s: %s", tombstone.Key, tombstone.Value)
+	}
+	record := records[0]
+	if record.Topic != "dbserver1.inventory.customers" {
+		t.Errorf("unexpected topic %s", record.Topic)
+	}
+	if string(record.Key) != `{"payload":{"id":1001}}` {
+		t.Errorf("unexpected key %s", record.Key)
+	}
+
+	var value struct {
+		Payload struct {
+			Before map[string]interface{}
+			After  map[string]interface{}
+			Source map[string]interface{}
+			Op     string
+		}
+	}
+	if err = json.Unmarshal(record.Value, &value); err != nil {
+		t.Fatal(err)
+	}
+	payload := value.Payload
+	if payload.Op != "d" || payload.After != nil || payload.Before["email"] != "a@example.com" {
+		t.Errorf("unexpected payload %s", record.Value)
+	}
+	if payload.Source["gtid"] != tx.GTID || payload.Source["file"] != "mysql-bin.000003" ||
+		payload.Source["pos"] != float64(1000) || payload.Source["ts_ms"] != float64(1500000000000) {
+		t.Errorf("unexpected source %v", payload.Source)
+	}
+}
+
+func TestDebeziumColumnValue(t *testing.T) {
+	table := &TableMapEvent{
+		ColumnCount: 13,
+		ColumnTypes: []byte{fieldTypeDate, fieldTypeDateTimeV2, fieldTypeDateTimeV2, fieldTypeTimestampV2, fieldTypeTimeV2,
+			fieldTypeNewDecimal, fieldTypeNewDecimal, fieldTypeBit, fieldTypeBit, fieldTypeString, fieldTypeBLOB, fieldTypeBLOB,
+			fieldTypeDate},
+		ColumnMeta:     []uint16{0, 0, 6, 3, 0, 10<<8 | 2, 10<<8 | 2, 1, 1<<8 | 2, uint16(fieldTypeEnum)<<8 | 1, 2, 2, 0},
+		ColumnCharsets: []uint64{0, 0, 0, 0, 0, 0, 0, 0, 0, 45, 45, binaryCollationID, 0},
+		EnumValues:     [][]string{9: {"small", "large"}},
+	}
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 678000000, time.UTC)
+	tests := []struct {
+		value    interface{}
+		expected interface{}
+	}{
+		{"2024-01-02", int64(19724)},
+		{"2024-01-02 03:04:05", ts.Truncate(time.Second).UnixNano() / int64(time.Millisecond)},
+		{ts, ts.UnixNano() / int64(time.Microsecond)},
+		{ts.UnixNano(), "2024-01-02T03:04:05.678Z"},
+		{"-01:00:00.5", int64(-3600500000)},
+		{"-1.50", []byte{0xff, 0x6a}},
+		{new(big.Rat).SetInt64(1280), []byte{0x01, 0xf4, 0x00}},
+		{int64(1), true},
+		{int64(0x201), []byte{0x01, 0x02}},
+		{int64(2), "large"},
+		{[]byte("text"), "text"},
+		{[]byte("blob"), []byte("blob")},
+		{"0000-00-00", nil},
+	}
+	for i, test := range tests {
+		v, err := debeziumColumnValue(table, i, test.value)
+		if err != nil {
+			t.Errorf("column %d: %v", i, err)
+		} else if !reflect.DeepEqual(v, test.expected) {
+			t.Errorf("column %d: expected %#v, got %#v", i, test.expected, v)
+		}
+	}
+}
@@ -1,54 +1,543 @@
 package binlog
 
+import (
+	"container/list"
+	"database/sql"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LightKool/mysql-go"
+)
+
 type EventDecoder struct {
+	// DB is used to retrieve the column metadata of tables from information_schema.
+	// It's optional, without it the column names are unknown and all integers are decoded as signed,
+	// unless the master is 8.0 and writes the optional metadata with binlog_row_metadata=FULL.
+	DB *sql.DB
+	// ChecksumPolicy controls the verification of event checksums, default is ChecksumSkip.
+	ChecksumPolicy ChecksumPolicy
+	// ParseJSON decodes JSON values to trees of map[string]interface{} and []interface{} instead of JSON text.
+	ParseJSON bool
+	// DecimalFormat selects the Go type of DECIMAL values, default is DecimalFloat64.
+	DecimalFormat DecimalFormat
+	// UnsignedFormat selects the Go type of the unsigned integer values, default is UnsignedDefault which
+	// decodes BIGINT UNSIGNED values to uint64.
+	UnsignedFormat UnsignedFormat
+	// Location makes TIMESTAMP and DATETIME values decoded to time.Time in it, TIMESTAMP values are converted
+	// to the zone while DATETIME values are taken as the wall clock of it. Without it or ParseTime, TIMESTAMP
+	// values are decoded to UnixNano and DATETIME values to strings.
+	Location *time.Location
+	// ParseTime decodes TIMESTAMP and DATETIME values to time.Time in Location, or UTC if Location is not set,
+	// and TIME values to time.Duration with the sign and the microseconds.
+	ParseTime bool
+	// InvalidTemporalPolicy controls how the zero and invalid dates like 0000-00-00 are decoded, default is
+	// InvalidTemporalRaw.
+	InvalidTemporalPolicy InvalidTemporalPolicy
+	// ParseEnumSet decodes ENUM and SET values to EnumValue and SetValue carrying both the member names
+	// and the raw values, instead of the names only. The column metadata is required to resolve the names.
+	ParseEnumSet bool
+	// ValueMapper transforms the decoded column values of rows events if not nil.
+	ValueMapper ValueMapper
+	// Flavor selects the MySQL or MariaDB semantics, the MariaDB specific events are decoded only with MariaDBFlavor.
+	Flavor Flavor
+	// ParseGeometry decodes GEOMETRY values to *Geometry instead of the raw bytes of SRID + WKB.
+	ParseGeometry bool
+	// Filter skips the events of the unwanted types and tables if not nil, decode returns nil for them.
+	Filter *EventFilter
+	// DecodeWorkers decodes the rows of a RowsEvent in parallel by the number of goroutines if it's more than 1,
+	// which speeds up the large events of wide tables. The ValueMapper must be safe for concurrent use then.
+	DecodeWorkers int
+	// Decompress decompresses the payload of TransactionPayloadEvent, it's required for the compressed binlogs
+	// of MySQL 8.0.20+ with binlog_transaction_compression=ON.
+	Decompress Decompressor
+	// ColumnCacheTTL is how long the column metadata retrieved from DB is cached, it's cached until invalidated
+	// if it's 0. The cache of a table is invalidated as well when a DDL of the table is decoded.
+	ColumnCacheTTL time.Duration
+	// Schema tracks the table definitions by the DDL in the binlog if not nil, the column metadata is taken from
+	// it instead of DB then.
+	Schema *SchemaTracker
+	// DecodeErrorPolicy controls what to do with the events which can't be decoded, default is DecodeErrorFail.
+	DecodeErrorPolicy DecodeErrorPolicy
+	// OnDecodeError is called with the error and the raw event for every event which can't be decoded if not nil,
+	// unless the policy is DecodeErrorFail. The data is only valid during the call.
+	OnDecodeError func(err *DecodeError, data []byte)
+	// Log receives the diagnostic messages if not nil.
+	Log mysql.LeveledLogger
+	// MaxRowsEventSize is the size of the largest rows event whose rows are decoded into Rows, the rows of
+	// the larger ones are deferred to RowsEvent.Iter. It's unlimited if 0.
+	MaxRowsEventSize int
+	// MaxRows is the maximum number of the rows decoded into Rows per rows event, the rows of the events with
+	// more rows are deferred to RowsEvent.Iter. It's unlimited if 0.
+	MaxRows int
+	// TableMapCacheSize is the maximum number of the table maps kept, the least recently used ones are evicted.
+	// It's exceeded by the statements changing more tables, whose table maps are kept until the statement ends.
+	// It's unlimited if 0, which is fine unless the master has a huge number of tables.
+	TableMapCacheSize int
+
 	format *FormatDescriptionEvent
 	tables map[uint64]*TableMapEvent
+	// tableIDs are the table ids of the tables in tables by schemaKey
+	tableIDs map[string]uint64
+	// tableLRU is the table ids in tables from the most recently used with TableMapCacheSize, stmtTables are
+	// the ones mapped since the last statement ended, which are not evicted as its rows events may follow
+	tableLRU   *list.List
+	tableElems map[uint64]*list.Element
+	stmtTables map[uint64]bool
+	// columnsMu guards columns which may be invalidated by the other goroutines
+	columnsMu sync.Mutex
+	columns   map[string]*cachedColumns
+	// masterChecksum is the checksum algorithm announced by the master when dumping,
+	// which applies to the artificial RotateEvent sent before the FormatDescriptionEvent.
+	masterChecksum ChecksumAlgorithm
+	// rowsQuery is the query of the last RowsQueryEvent or MariadbAnnotateRowsEvent for the following rows events
+	rowsQuery []byte
+	// logFile and gtid are the binlog file and the GTID of the current transaction annotating the headers
+	logFile string
+	gtid    string
+	// boundary finds the ends of the transactions, txGtid is the GtidEvent of the current transaction and ended
+	// is the one of the last transaction ended, which is taken by the Streamer to track the consumed GTIDs
+	// even if the events are filtered out
+	boundary txBoundary
+	txGtid   *GtidEvent
+	ended    *GtidEvent
+}
+
+// NewEventDecoder returns an EventDecoder which verifies the checksums, the other options are the zero values.
+func NewEventDecoder() *EventDecoder {
+	return &EventDecoder{ChecksumPolicy: ChecksumVerify, tables: make(map[uint64]*TableMapEvent)}
+}
+
+// Reset clears the state tracked from the decoded events, i.e. the FormatDescriptionEvent, the table maps,
+// the binlog file and the GTID, so that the decoder can be reused for another stream with the same options.
+// The column metadata cached from DB is kept, see InvalidateColumns.
+func (dec *EventDecoder) Reset() {
+	dec.format, dec.masterChecksum = nil, ChecksumAlgorithmNone
+	dec.resetTables()
+	dec.rowsQuery, dec.logFile, dec.gtid = nil, "", ""
+	dec.boundary, dec.txGtid, dec.ended = txBoundary{}, nil, nil
+}
+
+// FormatDescription returns the last FormatDescriptionEvent decoded, it's nil before the first one.
+func (dec *EventDecoder) FormatDescription() *FormatDescriptionEvent {
+	return dec.format
+}
+
+// TableMap returns the last TableMapEvent of the table id decoded in the current binlog file, or nil.
+func (dec *EventDecoder) TableMap(tableID uint64) *TableMapEvent {
+	return dec.tables[tableID]
+}
+
+// table returns the table map of the rows event with the table id.
+func (dec *EventDecoder) table(tableID uint64) *TableMapEvent {
+	e := dec.tables[tableID]
+	if e != nil && dec.tableElems != nil {
+		if elem := dec.tableElems[tableID]; elem != nil {
+			dec.tableLRU.MoveToFront(elem)
+		}
+	}
+	return e
 }
 
+// putTable tracks the table map. The table map of the same table with another id is evicted, since the id
+// is changed by DDL or FLUSH TABLES and never used again, and so is the one of another table if the id is reused.
+func (dec *EventDecoder) putTable(e *TableMapEvent) {
+	if dec.tables == nil {
+		dec.tables = make(map[uint64]*TableMapEvent)
+	}
+	if dec.tableIDs == nil {
+		dec.tableIDs = make(map[string]uint64)
+	}
+	key := schemaKey(string(e.Database), string(e.TableName))
+	if id, ok := dec.tableIDs[key]; ok {
+		if id == e.TableID {
+			// the table map of every statement replaces the last one
+			dec.tables[id] = e
+			dec.table(id)
+			return
+		}
+		dec.evictTable(id)
+	}
+	dec.evictTable(e.TableID)
+	dec.tables[e.TableID] = e
+	dec.tableIDs[key] = e.TableID
+
+	if dec.TableMapCacheSize <= 0 {
+		return
+	}
+	if dec.tableLRU == nil {
+		dec.tableLRU, dec.tableElems = list.New(), make(map[uint64]*list.Element)
+		dec.stmtTables = make(map[uint64]bool)
+	}
+	dec.tableElems[e.TableID] = dec.tableLRU.PushFront(e.TableID)
+	dec.stmtTables[e.TableID] = true
+	dec.trimTables()
+}
+
+// endStatement is called with the rows event of the STMT_END flag, the table maps of the statement can be
+// evicted after it.
+func (dec *EventDecoder) endStatement() {
+	if len(dec.stmtTables) == 0 {
+		return
+	}
+	dec.stmtTables = make(map[uint64]bool)
+	dec.trimTables()
+}
+
+// trimTables evicts the least recently used table maps out of the current statement over TableMapCacheSize.
+func (dec *EventDecoder) trimTables() {
+	for elem := dec.tableLRU.Back(); elem != nil && len(dec.tables) > dec.TableMapCacheSize; {
+		prev := elem.Prev()
+		if id := elem.Value.(uint64); !dec.stmtTables[id] {
+			dec.log().Debug("evicted table map", "table_id", id)
+			dec.evictTable(id)
+		}
+		elem = prev
+	}
+}
+
+func (dec *EventDecoder) evictTable(tableID uint64) {
+	e, ok := dec.tables[tableID]
+	if !ok {
+		return
+	}
+	delete(dec.tables, tableID)
+	key := schemaKey(string(e.Database), string(e.TableName))
+	if id, ok := dec.tableIDs[key]; ok && id == tableID {
+		delete(dec.tableIDs, key)
+	}
+	if elem := dec.tableElems[tableID]; elem != nil {
+		dec.tableLRU.Remove(elem)
+		delete(dec.tableElems, tableID)
+		delete(dec.stmtTables, tableID)
+	}
+}
+
+// resetTables clears the table maps, whose ids are only valid in a binlog file.
+func (dec *EventDecoder) resetTables() {
+	dec.tables = make(map[uint64]*TableMapEvent)
+	dec.tableIDs, dec.tableLRU, dec.tableElems, dec.stmtTables = nil, nil, nil, nil
+}
+
+func (dec *EventDecoder) log() mysql.LeveledLogger {
+	if dec.Log != nil {
+		return dec.Log
+	}
+	return mysql.NopLogger
+}
+
+// checksumAlgorithm returns the checksum algorithm of the events being decoded.
+func (dec *EventDecoder) checksumAlgorithm() ChecksumAlgorithm {
+	if dec.format != nil {
+		return dec.format.ChecksumAlgorithm
+	}
+	return dec.masterChecksum
+}
+
+// DecodeErrorPolicy controls how the events which can't be decoded are handled, e.g. the malformed ones.
+// The errors of the event headers and checksums are always returned since the stream can't be trusted then.
+type DecodeErrorPolicy int
+
+const (
+	// DecodeErrorFail returns the *DecodeError, which stops the stream.
+	DecodeErrorFail DecodeErrorPolicy = iota
+	// DecodeErrorSkip skips the event like the filtered ones.
+	DecodeErrorSkip
+	// DecodeErrorUnsupported returns the event as an UnsupportedEvent with the raw data and the error.
+	DecodeErrorUnsupported
+)
+
 func (dec *EventDecoder) decode(data []byte) (Event, error) {
-	header := &EventHeader{packet: newBinlogPacket(data)}
-	err := header.Decode(dec)
-	if err != nil {
+	ev, err := dec.decodeEvent(data)
+	de, ok := err.(*DecodeError)
+	if !ok || dec.DecodeErrorPolicy == DecodeErrorFail {
+		return ev, err
+	}
+	dec.log().Warn("failed to decode event", "type", de.Type, "next_log_pos", de.NextLogPos, "error", de.Err)
+	if dec.OnDecodeError != nil {
+		dec.OnDecodeError(de, data)
+	}
+	if dec.DecodeErrorPolicy == DecodeErrorSkip {
+		return nil, nil
+	}
+	// the header has been decoded successfully
+	header := &EventHeader{packet: newBinlogPacket(data), LogFile: dec.logFile, GTID: dec.gtid}
+	header.Decode(dec)
+	e := &UnsupportedEvent{baseEvent: &baseEvent{header: header}, Err: de}
+	e.Decode(dec)
+	return e, nil
+}
+
+// panicError is the error of the malformed event whose decoding panics, the stack is kept for the fuzz tests
+// to tell the panics from the ordinary decoding errors.
+type panicError struct {
+	value interface{}
+	stack []byte
+}
+
+func newPanicError(value interface{}) *panicError {
+	return &panicError{value: value, stack: debug.Stack()}
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprint(e.value)
+}
+
+func (dec *EventDecoder) decodeEvent(data []byte) (ev Event, err error) {
+	header := &EventHeader{packet: newBinlogPacket(data), LogFile: dec.logFile, GTID: dec.gtid}
+	if err = header.Decode(dec); err != nil {
 		return nil, err
 	}
+	defer func() {
+		// the malformed events which are not caught by the bounds-checked reads
+		if r := recover(); r != nil {
+			ev, err = nil, &DecodeError{Type: header.Type, NextLogPos: header.NextLogPos, Err: newPanicError(r)}
+		}
+	}()
+
+	// TableMapEvents and the rows queries are always decoded for the rows events, QueryEvents for the Schema
+	// and the invalidation of the cached column metadata, and the events beginning and ending the transactions
+	// for tracking the GTIDs
+	switch header.Type {
+	case TableMapEventType, RowsQueryEventType, MariadbAnnotateRowsEventType, QueryEventType, XidEventType,
+		XaPrepareLogEventType, GtidEventType, AnonymousGtidEventType, MariadbGtidEventType:
+	default:
+		if !dec.Filter.allowType(header.Type) {
+			return nil, nil
+		}
+	}
 
-	var ev Event
 	be := &baseEvent{header: header}
 	switch header.Type {
 	case FormatDescriptionEventType:
 		ev = &FormatDescriptionEvent{baseEvent: be}
 	case RotateEventType:
 		ev = &RotateEvent{baseEvent: be}
+	case StopEventType:
+		ev = &StopEvent{baseEvent: be}
+	case IncidentEventType:
+		ev = &IncidentEvent{baseEvent: be}
+	case HeartbeatEventType:
+		ev = &HeartbeatEvent{baseEvent: be}
 	case QueryEventType:
 		ev = &QueryEvent{baseEvent: be}
+	case BeginLoadQueryEventType:
+		ev = &BeginLoadQueryEvent{baseEvent: be}
+	case ExecuteLoadQueryEventType:
+		ev = &ExecuteLoadQueryEvent{QueryEvent: QueryEvent{baseEvent: be}}
 	case XidEventType:
 		ev = &XIDEvent{baseEvent: be}
+	case IntvarEventType:
+		ev = &IntvarEvent{baseEvent: be}
+	case RandEventType:
+		ev = &RandEvent{baseEvent: be}
+	case UserVarEventType:
+		ev = &UserVarEvent{baseEvent: be}
 	case RowsQueryEventType:
 		ev = &RowsQueryEvent{baseEvent: be}
 	case GtidEventType:
 		ev = &GtidEvent{baseEvent: be}
+	case AnonymousGtidEventType:
+		ev = &AnonymousGtidEvent{GtidEvent{baseEvent: be}}
+	case PreviousGtidsEventType:
+		ev = &PreviousGtidsEvent{baseEvent: be}
 	case TableMapEventType:
 		ev = &TableMapEvent{baseEvent: be}
-	case WriteRowsEventType, UpdateRowsEventType, DeleteRowsEventType:
+	case WriteRowsEventType, UpdateRowsEventType, DeleteRowsEventType,
+		OldWriteRowsEventType, OldUpdateRowsEventType, OldDeleteRowsEventType,
+		PreGaWriteRowsEventType, PreGaUpdateRowsEventType, PreGaDeleteRowsEventType:
 		ev = &RowsEvent{baseEvent: be}
+	case TransactionContextEventType:
+		ev = &TransactionContextEvent{baseEvent: be}
+	case ViewChangeEventType:
+		ev = &ViewChangeEvent{baseEvent: be}
+	case XaPrepareLogEventType:
+		ev = &XaPrepareLogEvent{baseEvent: be}
+	case TransactionPayloadEventType:
+		ev = &TransactionPayloadEvent{baseEvent: be}
 	default:
-		ev = &UnsupportedEvent{baseEvent: be}
+		if factory := registeredEvent(header.Type); factory != nil {
+			ev = factory(header)
+		} else if dec.Flavor == MariaDBFlavor {
+			ev = newMariadbEvent(be)
+		}
+		if ev == nil {
+			dec.log().Debug("unsupported event", "type", header.Type, "next_log_pos", header.NextLogPos)
+			ev = &UnsupportedEvent{baseEvent: be}
+		}
 	}
 
-	if err = ev.Decode(dec); err != nil {
-		return nil, err
+	if err = ev.Decode(dec); err == nil {
+		err = header.packet.Err()
+	}
+	if err != nil {
+		if err == errEventFiltered {
+			return nil, nil
+		}
+		return nil, &DecodeError{Type: header.Type, NextLogPos: header.NextLogPos, Err: err}
 	}
 
 	if pd, ok := ev.(postDecoder); ok {
 		err = pd.postDecode(dec)
+		if err == errEventFiltered {
+			return nil, nil
+		}
 		if err != nil {
-			return nil, err
+			return nil, &DecodeError{Type: header.Type, NextLogPos: header.NextLogPos, Err: err}
 		}
 	}
 
+	if e, ok := ev.(*QueryEvent); ok && dec.DB != nil && dec.Schema == nil {
+		dec.invalidateDDL(e)
+	}
+	if dec.Schema != nil {
+		if err = dec.Schema.track(ev); err != nil {
+			dec.log().Warn("failed to track the schema", "next_log_pos", header.NextLogPos, "error", err)
+		}
+	}
+	dec.trackPosition(ev)
+	if !dec.Filter.allowType(header.Type) {
+		return nil, nil
+	}
 	return ev, nil
 }
 
+var (
+	eventFactoriesMu sync.RWMutex
+	eventFactories   = make(map[EventType]func(*EventHeader) Event)
+)
+
+// RegisterEventType registers the factory of the events of the type which is not decoded by the package,
+// e.g. the extensions of the other vendors. The event returned by the factory is decoded by its Decode,
+// which reads the data from Body of the header. It takes precedence over the MariaDB events and UnsupportedEvent,
+// but the types decoded by the package are never overridden. A nil factory removes the registration.
+func RegisterEventType(t EventType, factory func(*EventHeader) Event) {
+	eventFactoriesMu.Lock()
+	defer eventFactoriesMu.Unlock()
+	if factory == nil {
+		delete(eventFactories, t)
+		return
+	}
+	eventFactories[t] = factory
+}
+
+func registeredEvent(t EventType) func(*EventHeader) Event {
+	eventFactoriesMu.RLock()
+	defer eventFactoriesMu.RUnlock()
+	return eventFactories[t]
+}
+
+// tableIDSize returns the size of the table id in the post header of TableMapEvent and RowsEvent,
+// which is 4 bytes if the post header is 6 bytes long in the early 5.1 versions.
+func (dec *EventDecoder) tableIDSize(typ EventType) int {
+	return tableIDSize(dec.format, typ)
+}
+
+func tableIDSize(format *FormatDescriptionEvent, typ EventType) int {
+	if format != nil && int(typ) <= len(format.EventPostHeaderLengths) && format.EventPostHeaderLengths[typ-1] == 6 {
+		return 4
+	}
+	return 6
+}
+
+// tableColumns returns the column metadata of the table from the Schema, or DB which is fetched lazily and cached.
+func (dec *EventDecoder) tableColumns(database, table string) ([]*Column, error) {
+	if dec.Schema != nil {
+		return dec.Schema.columns(database, table), nil
+	}
+	key := schemaKey(database, table)
+	dec.columnsMu.Lock()
+	cached, ok := dec.columns[key]
+	dec.columnsMu.Unlock()
+	if ok && (dec.ColumnCacheTTL <= 0 || time.Since(cached.retrieved) < dec.ColumnCacheTTL) {
+		return cached.columns, nil
+	}
+
+	columns, err := RetrieveColumns(dec.DB, database, table)
+	if err != nil {
+		return nil, err
+	}
+	dec.log().Debug("retrieved column metadata", "database", database, "table", table)
+	dec.columnsMu.Lock()
+	if dec.columns == nil {
+		dec.columns = make(map[string]*cachedColumns)
+	}
+	dec.columns[key] = &cachedColumns{columns: columns, retrieved: time.Now()}
+	dec.columnsMu.Unlock()
+	return columns, nil
+}
+
+type cachedColumns struct {
+	columns   []*Column
+	retrieved time.Time
+}
+
+// InvalidateColumns removes the cached column metadata of the table, so that it's retrieved from DB again
+// by the next TableMapEvent of the table. All the tables of the database are invalidated if table is empty.
+func (dec *EventDecoder) InvalidateColumns(database, table string) {
+	dec.columnsMu.Lock()
+	defer dec.columnsMu.Unlock()
+	if table != "" {
+		delete(dec.columns, schemaKey(database, table))
+		return
+	}
+	for key := range dec.columns {
+		if strings.HasPrefix(key, database+".") {
+			delete(dec.columns, key)
+		}
+	}
+}
+
+// invalidateDDL invalidates the cached column metadata of the tables changed by the DDL.
+func (dec *EventDecoder) invalidateDDL(e *QueryEvent) {
+	for _, name := range ddlTables(string(e.Query)) {
+		database := name[0]
+		if database == "" {
+			database = string(e.Database)
+		}
+		dec.log().Debug("invalidated column metadata", "database", database, "table", name[1])
+		dec.InvalidateColumns(database, name[1])
+	}
+}
+
+// trackPosition tracks the binlog file and the GTID of the current transaction, which annotate the headers
+// of the following events.
+func (dec *EventDecoder) trackPosition(ev Event) {
+	switch e := ev.(type) {
+	case *RotateEvent:
+		dec.logFile = string(e.NextLogName)
+		// the artificial RotateEvent is sent at the start of the file it names
+		if e.header.LogFile == "" || e.header.NextLogPos == 0 {
+			e.header.LogFile = dec.logFile
+		}
+	case *GtidEvent:
+		dec.gtid = e.GTID()
+		e.header.GTID = dec.gtid
+		dec.txGtid = e
+	case *AnonymousGtidEvent:
+		dec.gtid, dec.txGtid = "", nil
+	case *MariadbGtidEvent:
+		dec.gtid = e.GTID.String()
+		e.header.GTID = dec.gtid
+		dec.txGtid = nil
+	}
+	if _, ends := dec.boundary.track(ev); ends {
+		dec.gtid = ""
+		if dec.txGtid != nil {
+			dec.ended, dec.txGtid = dec.txGtid, nil
+		}
+	}
+}
+
+// takeEnded returns the GtidEvent of the transaction ended since the last call, or nil.
+func (dec *EventDecoder) takeEnded() *GtidEvent {
+	e := dec.ended
+	dec.ended = nil
+	return e
+}
+
 type postDecoder interface {
 	postDecode(*EventDecoder) error
 }
@@ -0,0 +1,162 @@
+package binlog
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// binlogMagic is the header of every binlog file.
+var binlogMagic = []byte{0xfe, 'b', 'i', 'n'}
+
+// DumpTo dumps the raw binlog events from the given file into the local directory, which is the equivalent of
+// `mysqlbinlog --read-from-remote-server --raw --stop-never`. The local files are named and rotated after the
+// master's, the dump resumes from the end of the local file if it exists already.
+// DumpTo blocks until ctx is canceled or an unrecoverable error occurs.
+func (s *Streamer) DumpTo(ctx context.Context, dsn string, serverID uint32, file string, dir string) error {
+	pos := uint32(len(binlogMagic))
+	if fi, err := os.Stat(filepath.Join(dir, file)); err == nil && fi.Size() > int64(pos) {
+		pos = uint32(fi.Size())
+	}
+	if err := s.setServerID(serverID); err != nil {
+		return err
+	}
+	s.dsn, s.file, s.pos = dsn, file, pos
+	s.mu.Lock()
+	s.dec = &EventDecoder{ChecksumPolicy: s.ChecksumPolicy, Flavor: s.Flavor}
+	s.mu.Unlock()
+
+	conn, err := s.dump(ctx)
+	if err != nil {
+		return err
+	}
+	w := &rawWriter{dir: dir, dec: s.dec}
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+		w.close()
+	}()
+
+	for {
+		packet, err := conn.ReadPacket()
+		if err == nil {
+			if err = w.write(packet); err != nil {
+				return err
+			}
+			if conn.SemiSyncACKNeeded() {
+				// the events must be durable before acknowledged
+				if err = w.f.Sync(); err != nil {
+					return err
+				}
+				err = conn.WriteSemiSyncACK(w.file, uint64(w.pos))
+			}
+			if err == nil {
+				continue
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !isConnError(err) {
+			if renewed, err := s.serverIDConflict(err); !renewed {
+				return err
+			}
+		}
+
+		conn.Close()
+		s.mu.Lock()
+		s.file, s.pos = w.file, w.pos
+		s.mu.Unlock()
+		if conn, err = s.reconnect(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// rawWriter writes the raw binlog events into the local files.
+type rawWriter struct {
+	dir  string
+	dec  *EventDecoder
+	f    *os.File
+	file string
+	pos  uint32
+}
+
+func (w *rawWriter) write(data []byte) error {
+	if len(data) < eventHeaderSize {
+		return fmt.Errorf("event header size %d too short, expect %d", len(data), eventHeaderSize)
+	}
+	typ := EventType(data[4])
+	next := binary.LittleEndian.Uint32(data[13:])
+	artificial := binary.LittleEndian.Uint16(data[17:])&logEventArtificialFlag != 0
+
+	// the artificial events, the heartbeats and the FormatDescriptionEvent resent
+	// at the beginning of a resumed dump are not part of the binlog file
+	if !artificial && typ != HeartbeatEventType && !(typ == FormatDescriptionEventType && next == 0) {
+		if w.f == nil {
+			return errors.New("no binlog file to write, expect a RotateEvent first")
+		}
+		if _, err := w.f.Write(data); err != nil {
+			return err
+		}
+		w.pos = next
+	}
+
+	// the FormatDescriptionEvent is decoded for the checksum algorithm of the RotateEvent
+	if typ != FormatDescriptionEventType && typ != RotateEventType {
+		return nil
+	}
+	ev, err := w.dec.decode(data)
+	if err != nil {
+		return err
+	}
+	rotate, ok := ev.(*RotateEvent)
+	if !ok {
+		return nil
+	}
+	file := string(rotate.NextLogName)
+	if artificial && w.f != nil && file == w.file {
+		// the master sends the artificial RotateEvent again after the real one since 5.6
+		return nil
+	}
+	return w.open(file, uint32(rotate.Position))
+}
+
+// open opens the local file to write the events from pos, the content after pos is truncated.
+func (w *rawWriter) open(file string, pos uint32) error {
+	if err := w.close(); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(w.dir, file), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if pos <= uint32(len(binlogMagic)) {
+		pos = uint32(len(binlogMagic))
+		if err = f.Truncate(0); err == nil {
+			_, err = f.Write(binlogMagic)
+		}
+	} else if err = f.Truncate(int64(pos)); err == nil {
+		_, err = f.Seek(int64(pos), 0)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f, w.file, w.pos = f, file, pos
+	return nil
+}
+
+func (w *rawWriter) close() error {
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
@@ -0,0 +1,76 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func buildRotateEvent(file string, artificial bool) []byte {
+	body := make([]byte, 8, 8+len(file))
+	binary.LittleEndian.PutUint64(body, 4)
+	data := buildEvent(RotateEventType, append(body, file...), true)
+	if artificial {
+		binary.LittleEndian.PutUint16(data[17:], logEventArtificialFlag)
+		binary.LittleEndian.PutUint32(data[13:], 0)
+		binary.LittleEndian.PutUint32(data[len(data)-4:], crc32.ChecksumIEEE(data[:len(data)-4]))
+	}
+	return data
+}
+
+func TestRawWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fde := make([]byte, 2+50+4+1)
+	binary.LittleEndian.PutUint16(fde, 4)
+	copy(fde[2:], "5.7.18-log")
+	fde[56] = eventHeaderSize
+	fde = append(fde, 56, 13, 0, 8, 1)
+	xid := buildEvent(XidEventType, []byte{1, 0, 0, 0, 0, 0, 0, 0}, true)
+	heartbeat := buildEvent(HeartbeatEventType, []byte("mysql-bin.000001"), true)
+
+	w := &rawWriter{dir: dir, dec: &EventDecoder{ChecksumPolicy: ChecksumVerify, masterChecksum: ChecksumAlgorithmCRC32}}
+	for _, ev := range [][]byte{
+		buildRotateEvent("mysql-bin.000001", true),
+		buildEvent(FormatDescriptionEventType, fde, true),
+		xid,
+		heartbeat,
+		buildRotateEvent("mysql-bin.000002", false),
+		buildRotateEvent("mysql-bin.000002", true),
+		buildEvent(FormatDescriptionEventType, fde, true),
+		xid,
+	} {
+		if err = w.write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.close()
+
+	if w.file != "mysql-bin.000002" || w.pos != 1000 {
+		t.Errorf("unexpected position %s:%d", w.file, w.pos)
+	}
+	for file, events := range map[string][][]byte{
+		"mysql-bin.000001": {buildEvent(FormatDescriptionEventType, fde, true), xid, buildRotateEvent("mysql-bin.000002", false)},
+		"mysql-bin.000002": {buildEvent(FormatDescriptionEventType, fde, true), xid},
+	} {
+		expected := append([]byte{}, binlogMagic...)
+		for _, ev := range events {
+			expected = append(expected, ev...)
+		}
+		actual, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("unexpected content of %s: %d bytes, expect %d bytes", file, len(actual), len(expected))
+		}
+	}
+}
@@ -0,0 +1,652 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EventEncoder encodes the events back to the binlog format, it's the counterpart of EventDecoder for the tools
+// which rewrite or filter the binlog files. The events must be encoded in the order of the binlog file, so that
+// the FormatDescriptionEvent sets the checksum algorithm and the post header lengths of the following events.
+//
+// FormatDescriptionEvent, RotateEvent, QueryEvent, TableMapEvent, RowsEvent, GtidEvent, AnonymousGtidEvent
+// and XIDEvent can be encoded. The values of the rows must be the ones decoded without ValueMapper, JSON values
+// can be the text or the tree decoded with ParseJSON, GEOMETRY values must be the raw bytes.
+type EventEncoder struct {
+	// Pos is the position of the next event in the binlog file if it's not 0, the NextLogPos of the encoded
+	// events are rewritten by it and it's advanced by the size of every event. Set it to 4 to write a new file.
+	Pos uint32
+
+	format *FormatDescriptionEvent
+}
+
+// Encode encodes the event if its type is supported.
+func (enc *EventEncoder) Encode(ev Event) ([]byte, error) {
+	if e, ok := ev.(interface {
+		Encode(*EventEncoder) ([]byte, error)
+	}); ok {
+		return e.Encode(enc)
+	}
+	return nil, fmt.Errorf("encoding %s is not supported", ev.Header().Type)
+}
+
+// checksumAlgorithm returns the checksum algorithm of the events being encoded.
+func (enc *EventEncoder) checksumAlgorithm() ChecksumAlgorithm {
+	if enc.format != nil {
+		return enc.format.ChecksumAlgorithm
+	}
+	return ChecksumAlgorithmNone
+}
+
+// begin returns a packet with the header written, the event size and the next position are filled by end.
+func (enc *EventEncoder) begin(h *EventHeader) *binlogPacket {
+	p := newBinlogPacket(make([]byte, 0, 64))
+	p.writeUint32(h.Timestamp)
+	p.writeByte(byte(h.Type))
+	p.writeUint32(h.ServerID)
+	p.writeUint32(0)
+	p.writeUint32(0)
+	p.writeUint16(h.Flags)
+	return p
+}
+
+// end fills the event size and the next position into the header and appends the checksum of alg.
+func (enc *EventEncoder) end(h *EventHeader, p *binlogPacket, alg ChecksumAlgorithm) []byte {
+	data := p.Raw()
+	h.EventSize = uint32(len(data) + alg.size())
+	if enc.Pos != 0 {
+		h.NextLogPos = enc.Pos + h.EventSize
+		enc.Pos = h.NextLogPos
+	}
+	binary.LittleEndian.PutUint32(data[9:], h.EventSize)
+	binary.LittleEndian.PutUint32(data[13:], h.NextLogPos)
+	if alg == ChecksumAlgorithmCRC32 {
+		data = append(data, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(data[len(data)-4:], crc32.ChecksumIEEE(data[:len(data)-4]))
+	}
+	return data
+}
+
+func (e *FormatDescriptionEvent) Encode(enc *EventEncoder) ([]byte, error) {
+	p := enc.begin(e.header)
+	p.writeUint16(e.BinlogVersion)
+	serverVersion := make([]byte, 50)
+	copy(serverVersion, e.ServerVersion)
+	p.Write(serverVersion)
+	// create timestamp
+	p.writeUint32(0)
+	p.writeByte(e.EventHeaderLength)
+	p.Write(e.EventPostHeaderLengths)
+
+	alg := ChecksumAlgorithmUndef
+	if parseMysqlVersion(string(e.ServerVersion)).greaterOrEqual(checksumEnabledMysqlVersion) {
+		// the checksum part is always written, it's zero if the checksums are disabled
+		alg = e.ChecksumAlgorithm
+		p.writeByte(byte(alg))
+		if alg.size() == 0 {
+			p.writeUint32(0)
+		}
+	}
+	enc.format = e
+	return enc.end(e.header, p, alg), nil
+}
+
+func (e *RotateEvent) Encode(enc *EventEncoder) ([]byte, error) {
+	p := enc.begin(e.header)
+	p.writeUint64(e.Position)
+	p.Write(e.NextLogName)
+	return enc.end(e.header, p, enc.checksumAlgorithm()), nil
+}
+
+func (e *QueryEvent) Encode(enc *EventEncoder) ([]byte, error) {
+	// ExecuteLoadQueryEvent embeds QueryEvent but has more fields
+	if e.header.Type != QueryEventType {
+		return nil, fmt.Errorf("encoding %s is not supported", e.header.Type)
+	}
+	p := enc.begin(e.header)
+	p.writeUint32(e.ThreadID)
+	p.writeUint32(e.ExecutionTime)
+	p.writeByte(byte(len(e.Database)))
+	p.writeUint16(e.ErrorCode)
+	p.writeUint16(uint16(len(e.StatusVars)))
+	p.Write(e.StatusVars)
+	p.Write(e.Database)
+	p.writeByte(0)
+	p.Write(e.Query)
+	return enc.end(e.header, p, enc.checksumAlgorithm()), nil
+}
+
+func (e *XIDEvent) Encode(enc *EventEncoder) ([]byte, error) {
+	p := enc.begin(e.header)
+	p.writeUint64(e.TransactionID)
+	return enc.end(e.header, p, enc.checksumAlgorithm()), nil
+}
+
+// gtidPostHeaderLength is the post header length of GtidEvent before 5.7.6 which has no logical clock.
+const gtidPostHeaderLength = 1 + 16 + 8
+
+func (e *GtidEvent) Encode(enc *EventEncoder) ([]byte, error) {
+	p := enc.begin(e.header)
+	p.writeByte(e.CommitFlag)
+	p.Write(e.sid[:])
+	p.writeUint64(e.gno)
+	if f := enc.format; f == nil || int(e.header.Type) > len(f.EventPostHeaderLengths) ||
+		f.EventPostHeaderLengths[e.header.Type-1] != gtidPostHeaderLength {
+		p.writeByte(logicalClockTypeCode)
+		p.writeUint64(uint64(e.LastCommitted))
+		p.writeUint64(uint64(e.SequenceNumber))
+	}
+	return enc.end(e.header, p, enc.checksumAlgorithm()), nil
+}
+
+func (e *TableMapEvent) Encode(enc *EventEncoder) ([]byte, error) {
+	meta, err := encodeTableColumnMeta(e.ColumnTypes, e.ColumnMeta)
+	if err != nil {
+		return nil, err
+	}
+	p := enc.begin(e.header)
+	p.WriteUintBySize(tableIDSize(enc.format, e.header.Type), e.TableID)
+	p.writeUint16(e.Flags)
+	p.writeByte(byte(len(e.Database)))
+	p.Write(e.Database)
+	p.writeByte(0)
+	p.writeByte(byte(len(e.TableName)))
+	p.Write(e.TableName)
+	p.writeByte(0)
+	p.WriteLengthEncodedInteger(e.ColumnCount)
+	p.Write(e.ColumnTypes)
+	p.WriteLengthEncodedString(meta)
+	p.Write(e.ColumnNullability)
+	p.Write(e.optionalMetadata)
+	return enc.end(e.header, p, enc.checksumAlgorithm()), nil
+}
+
+// encodeTableColumnMeta encodes the column metadata of TableMapEvent, it's the reverse of readTableColumnMeta.
+func encodeTableColumnMeta(columnTypes []byte, meta []uint16) ([]byte, error) {
+	if len(meta) != len(columnTypes) {
+		return nil, fmt.Errorf("%d column metadata for %d columns", len(meta), len(columnTypes))
+	}
+	p := newBinlogPacket(nil)
+	for i, v := range columnTypes {
+		switch v {
+		case fieldTypeFloat, fieldTypeDouble, fieldTypeBLOB, fieldTypeJSON, fieldTypeGeometry,
+			fieldTypeTimestampV2, fieldTypeDateTimeV2, fieldTypeTimeV2:
+			p.writeByte(byte(meta[i]))
+		case fieldTypeBit, fieldTypeVarChar, fieldTypeVarString:
+			p.writeUint16(meta[i])
+		case fieldTypeString, fieldTypeNewDecimal:
+			p.WriteUintBySizeBE(2, uint64(meta[i]))
+		}
+	}
+	return p.Raw(), nil
+}
+
+func (e *RowsEvent) Encode(enc *EventEncoder) ([]byte, error) {
+	if e.Table == nil {
+		return nil, fmt.Errorf("table map of table id %d not found", e.TableID)
+	}
+	p := enc.begin(e.header)
+	p.WriteUintBySize(tableIDSize(enc.format, e.header.Type), e.TableID)
+	p.writeUint16(e.Flags)
+	if e.version() == 2 {
+		p.writeUint16(uint16(len(e.ExtraData) + 2))
+		p.Write(e.ExtraData)
+	}
+	p.WriteLengthEncodedInteger(e.ColumnCount)
+	p.Write(e.Columns)
+	if e.isUpdate() {
+		p.Write(e.UpdatedColumns)
+	}
+	rows, err := e.allRows()
+	if err != nil {
+		return nil, err
+	}
+	for i, row := range rows {
+		if err = e.encodeRow(p, row, e.rowColumns(i)); err != nil {
+			return nil, err
+		}
+	}
+	return enc.end(e.header, p, enc.checksumAlgorithm()), nil
+}
+
+func (e *RowsEvent) encodeRow(p *binlogPacket, row []interface{}, includedColumns []byte) error {
+	nullColumns := make([]byte, (len(row)+7)>>3)
+	for i, v := range row {
+		if v == nil {
+			nullColumns[i>>3] |= 1 << (uint(i) & 7)
+		}
+	}
+	p.Write(nullColumns)
+
+	index := 0
+	for i := 0; i < int(e.ColumnCount); i++ {
+		if !isBitSet(includedColumns, i) {
+			continue
+		}
+		if index >= len(row) {
+			return fmt.Errorf("row of %d values doesn't match the columns of %s.%s", len(row), e.Table.Database, e.Table.TableName)
+		}
+		if v := row[index]; v != nil {
+			var c *Column
+			if e.Table.columns != nil {
+				c = e.Table.columns[i]
+			}
+			if err := p.writeTableColumnValue(c, e.Table.ColumnTypes[i], e.Table.ColumnMeta[i], v); err != nil {
+				return fmt.Errorf("encode column %s: %v", e.Table.ColumnName(i), err)
+			}
+		}
+		index++
+	}
+	return nil
+}
+
+// writeTableColumnValue writes the column value, it's the reverse of readTableColumnValue.
+func (p *binlogPacket) writeTableColumnValue(c *Column, typ byte, meta uint16, v interface{}) error {
+	var length int
+	if typ == fieldTypeString {
+		if meta >= 256 {
+			realType := byte(meta >> 8)
+			if realType&0x30 != 0x30 {
+				length = int(uint16(meta&0xFF) | uint16((realType&0x30)^0x30)<<4)
+				typ = realType | 0x30
+			} else {
+				length = int(meta & 0xFF)
+				typ = realType
+			}
+		} else {
+			length = int(meta)
+		}
+	}
+
+	switch typ {
+	case fieldTypeTiny, fieldTypeShort, fieldTypeInt24, fieldTypeLong, fieldTypeLongLong:
+		n, err := toUint64(v)
+		if err != nil {
+			return err
+		}
+		size := 8
+		switch typ {
+		case fieldTypeTiny:
+			size = 1
+		case fieldTypeShort:
+			size = 2
+		case fieldTypeInt24:
+			size = 3
+		case fieldTypeLong:
+			size = 4
+		}
+		p.WriteUintBySize(size, n)
+	case fieldTypeFloat:
+		f, err := toFloat64(v)
+		if err != nil {
+			return err
+		}
+		p.writeUint32(math.Float32bits(float32(f)))
+	case fieldTypeDouble:
+		f, err := toFloat64(v)
+		if err != nil {
+			return err
+		}
+		p.writeUint64(math.Float64bits(f))
+	case fieldTypeNewDecimal:
+		return p.writeDecimal(meta, v)
+	case fieldTypeYear:
+		n, err := toUint64(v)
+		if err != nil {
+			return err
+		}
+		if n >= 1900 {
+			n -= 1900
+		}
+		p.writeByte(byte(n))
+	case fieldTypeDate:
+		t, err := parseTemporal(v)
+		if err != nil {
+			return err
+		}
+		p.WriteUintBySize(3, uint64(t.year<<9|t.month<<5|t.day))
+	case fieldTypeTime:
+		t, err := parseTemporal(v)
+		if err != nil {
+			return err
+		}
+		p.WriteUintBySize(3, uint64(t.hour*10000+t.minute*100+t.second))
+	case fieldTypeTimeV2:
+		t, err := parseTemporal(v)
+		if err != nil {
+			return err
+		}
+		p.writeTimeV2(meta, t)
+	case fieldTypeDateTime:
+		t, err := parseTemporal(v)
+		if err != nil {
+			return err
+		}
+		p.writeUint64(uint64((t.year*10000+t.month*100+t.day)*1000000 + t.hour*10000 + t.minute*100 + t.second))
+	case fieldTypeDateTimeV2:
+		t, err := parseTemporal(v)
+		if err != nil {
+			return err
+		}
+		ymd := (t.year*13+t.month)<<5 | t.day
+		p.WriteUintBySizeBE(5, uint64(ymd<<17|t.hour<<12|t.minute<<6|t.second)+0x8000000000)
+		p.writeMicroSeconds(int(meta), t.usec)
+	case fieldTypeTimestamp, fieldTypeTimestampV2:
+		var sec, usec int64
+		switch v := v.(type) {
+		case int64:
+			sec, usec = v/int64(time.Second), v%int64(time.Second)/int64(time.Microsecond)
+		case time.Time:
+			if !v.IsZero() {
+				sec, usec = v.Unix(), int64(v.Nanosecond())/int64(time.Microsecond)
+			}
+		default:
+			return fmt.Errorf("unsupported TIMESTAMP value of %T", v)
+		}
+		if typ == fieldTypeTimestamp {
+			p.writeUint32(uint32(sec))
+		} else {
+			p.WriteUintBySizeBE(4, uint64(sec))
+			p.writeMicroSeconds(int(meta), usec)
+		}
+	case fieldTypeVarChar, fieldTypeVarString:
+		length = int(meta)
+		fallthrough
+	case fieldTypeString:
+		b, err := toBytes(v)
+		if err != nil {
+			return err
+		}
+		if length < 256 {
+			p.writeByte(byte(len(b)))
+		} else {
+			p.writeUint16(uint16(len(b)))
+		}
+		p.Write(b)
+	case fieldTypeEnum:
+		n, err := enumIndex(c, v)
+		if err != nil {
+			return err
+		}
+		p.WriteUintBySize(length, uint64(n))
+	case fieldTypeSet:
+		n, err := setBits(c, v)
+		if err != nil {
+			return err
+		}
+		p.WriteUintBySizeBE(length, uint64(n))
+	case fieldTypeBit:
+		n, err := toUint64(v)
+		if err != nil {
+			return err
+		}
+		nbits := (meta>>8)*8 + meta&0xFF
+		p.WriteUintBySizeBE((int(nbits)+7)/8, n)
+	case fieldTypeBLOB, fieldTypeGeometry, fieldTypeJSON:
+		var b []byte
+		var err error
+		if typ == fieldTypeJSON {
+			b, err = encodeJSONBinary(v)
+		} else {
+			b, err = toBytes(v)
+		}
+		if err != nil {
+			return err
+		}
+		p.WriteUintBySize(int(meta), uint64(len(b)))
+		p.Write(b)
+	default:
+		return fmt.Errorf("unsupported column type %d", typ)
+	}
+	return nil
+}
+
+// writeDecimal writes the DECIMAL value in the binary format, it's the reverse of readDecimalString.
+func (p *binlogPacket) writeDecimal(meta uint16, v interface{}) error {
+	precision, scale := int(meta>>8), int(meta&0xFF)
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', scale, 64)
+	case *big.Rat:
+		s = v.FloatString(scale)
+	default:
+		return fmt.Errorf("unsupported DECIMAL value of %T", v)
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	digits, fraction := strings.TrimLeft(strings.TrimLeft(s, "+-"), "0"), ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		digits, fraction = digits[:i], digits[i+1:]
+	}
+	integral := precision - scale
+	if len(digits) > integral || strings.Trim(digits+fraction, "0123456789") != "" {
+		return fmt.Errorf("invalid DECIMAL(%d,%d) value %s", precision, scale, s)
+	}
+	if strings.Trim(digits+fraction, "0") == "" {
+		negative = false
+	}
+	digits = strings.Repeat("0", integral-len(digits)) + digits
+	if len(fraction) > scale {
+		fraction = fraction[:scale]
+	}
+	fraction += strings.Repeat("0", scale-len(fraction))
+
+	intgx, fracx := integral%digitsPerInteger, scale%digitsPerInteger
+	data := newBinlogPacket(nil)
+	// compressed integer part
+	data.WriteUintBySizeBE(compressedBytes[intgx], atou(digits[:intgx]))
+	// uncompressed integer part
+	for i := intgx; i < integral; i += digitsPerInteger {
+		data.WriteUintBySizeBE(4, atou(digits[i:i+digitsPerInteger]))
+	}
+	// uncompressed fractional part
+	for i := 0; i+digitsPerInteger <= scale; i += digitsPerInteger {
+		data.WriteUintBySizeBE(4, atou(fraction[i:i+digitsPerInteger]))
+	}
+	// compressed fractional part
+	data.WriteUintBySizeBE(compressedBytes[fracx], atou(fraction[scale-fracx:]))
+
+	b := data.Raw()
+	b[0] ^= 0x80 // the sign bit
+	if negative {
+		for i := range b {
+			b[i] ^= 0xFF
+		}
+	}
+	p.Write(b)
+	return nil
+}
+
+// atou parses the decimal digits, the empty string is 0.
+func atou(digits string) uint64 {
+	u, _ := strconv.ParseUint("0"+digits, 10, 64)
+	return u
+}
+
+// writeMicroSeconds writes the fractional part of TIMESTAMP/DATETIME/TIME values of dec digits.
+func (p *binlogPacket) writeMicroSeconds(dec int, usec int64) {
+	msecLen := (dec + 1) / 2
+	if msecLen > 0 {
+		p.WriteUintBySizeBE(msecLen, uint64(usec/int64(math.Pow(100, float64(3-msecLen)))))
+	}
+}
+
+func (p *binlogPacket) writeTimeV2(meta uint16, t temporal) {
+	msecLen := (int(meta) + 1) / 2
+	unit := int64(math.Pow(100, float64(3-msecLen)))
+	// the packed value of the time with the microseconds truncated to the precision
+	packed := (t.hour<<12|t.minute<<6|t.second)<<24 + t.usec - t.usec%unit
+	if t.negative {
+		packed = -packed
+	}
+	if msecLen == 3 {
+		p.WriteUintBySizeBE(6, uint64(packed+0x800000000000))
+		return
+	}
+	p.WriteUintBySizeBE(3, uint64(packed>>24+0x800000))
+	if msecLen > 0 {
+		p.WriteUintBySizeBE(msecLen, uint64(packed%(1<<24)/unit))
+	}
+}
+
+// temporal is the parts of a DATE, TIME or DATETIME value.
+type temporal struct {
+	year, month, day           int64
+	hour, minute, second, usec int64
+	negative                   bool
+}
+
+// parseTemporal parses the time.Time, the time.Duration of TIME or the string like "2006-01-02", "-15:04:05.000001" or "2006-01-02 15:04:05".
+func parseTemporal(v interface{}) (t temporal, err error) {
+	var s string
+	switch v := v.(type) {
+	case time.Time:
+		return temporal{
+			year: int64(v.Year()), month: int64(v.Month()), day: int64(v.Day()),
+			hour: int64(v.Hour()), minute: int64(v.Minute()), second: int64(v.Second()),
+			usec: int64(v.Nanosecond()) / int64(time.Microsecond),
+		}, nil
+	case string:
+		s = v
+	case InvalidTemporal:
+		s = string(v)
+	case time.Duration:
+		s = formatTimeDuration(v)
+	default:
+		return t, fmt.Errorf("unsupported temporal value of %T", v)
+	}
+
+	date, clock := s, ""
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		date, clock = s[:i], s[i+1:]
+	} else if strings.IndexByte(s, ':') >= 0 {
+		date, clock = "", s
+	}
+	if date != "" {
+		if _, err = fmt.Sscanf(date, "%d-%d-%d", &t.year, &t.month, &t.day); err != nil {
+			return t, fmt.Errorf("invalid date %q", s)
+		}
+	}
+	if clock != "" {
+		if t.negative = strings.HasPrefix(clock, "-"); t.negative {
+			clock = clock[1:]
+		}
+		fraction := ""
+		if i := strings.IndexByte(clock, '.'); i >= 0 {
+			clock, fraction = clock[:i], clock[i+1:]
+		}
+		if _, err = fmt.Sscanf(clock, "%d:%d:%d", &t.hour, &t.minute, &t.second); err != nil || len(fraction) > 6 {
+			return t, fmt.Errorf("invalid time %q", s)
+		}
+		t.usec = int64(atou(fraction + strings.Repeat("0", 6-len(fraction))))
+	}
+	return t, nil
+}
+
+func toUint64(v interface{}) (uint64, error) {
+	switch v := v.(type) {
+	case int:
+		return uint64(v), nil
+	case int8:
+		return uint64(v), nil
+	case int16:
+		return uint64(v), nil
+	case int32:
+		return uint64(v), nil
+	case int64:
+		return uint64(v), nil
+	case uint:
+		return uint64(v), nil
+	case uint8:
+		return uint64(v), nil
+	case uint16:
+		return uint64(v), nil
+	case uint32:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	case string:
+		// BIGINT UNSIGNED values decoded with UnsignedString
+		return strconv.ParseUint(v, 10, 64)
+	}
+	return 0, fmt.Errorf("unsupported integer value of %T", v)
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("unsupported float value of %T", v)
+}
+
+func toBytes(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("unsupported string value of %T", v)
+}
+
+// enumIndex returns the ordinal of the ENUM value, the member name is resolved by the column metadata.
+func enumIndex(c *Column, v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case EnumValue:
+		return v.Index, nil
+	case string:
+		if v == "" {
+			return 0, nil
+		}
+		if c != nil {
+			for i, name := range c.EnumValues {
+				if name == v {
+					return int64(i + 1), nil
+				}
+			}
+		}
+		return 0, fmt.Errorf("unknown ENUM member %q", v)
+	}
+	n, err := toUint64(v)
+	return int64(n), err
+}
+
+// setBits returns the bitmask of the SET value, the member names are resolved by the column metadata.
+func setBits(c *Column, v interface{}) (int64, error) {
+	switch v := v.(type) {
+	case SetValue:
+		return v.Bits, nil
+	case string:
+		var bits int64
+		if v == "" {
+			return bits, nil
+		}
+	members:
+		for _, member := range strings.Split(v, ",") {
+			if c != nil {
+				for i, name := range c.SetValues {
+					if name == member {
+						bits |= 1 << uint(i)
+						continue members
+					}
+				}
+			}
+			return 0, fmt.Errorf("unknown SET member %q", member)
+		}
+		return bits, nil
+	}
+	n, err := toUint64(v)
+	return int64(n), err
+}
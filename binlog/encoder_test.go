@@ -0,0 +1,109 @@
+package binlog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	header := func(typ EventType) *baseEvent {
+		return &baseEvent{header: &EventHeader{Timestamp: 1500000000, Type: typ, ServerID: 1}}
+	}
+	table := &TableMapEvent{
+		baseEvent:   header(TableMapEventType),
+		TableID:     42,
+		Database:    []byte("test"),
+		TableName:   []byte("t"),
+		ColumnCount: 13,
+		ColumnTypes: []byte{fieldTypeLong, fieldTypeVarChar, fieldTypeNewDecimal, fieldTypeDateTimeV2, fieldTypeTimeV2,
+			fieldTypeJSON, fieldTypeBLOB, fieldTypeTimestampV2, fieldTypeString, fieldTypeDate, fieldTypeYear,
+			fieldTypeDouble, fieldTypeString},
+		// the ENUM and SET columns are written as STRING columns with the real types
+		ColumnMeta:        []uint16{0, 40, 10<<8 | 2, 6, 6, 4, 2, 0, uint16(fieldTypeEnum)<<8 | 1, 0, 0, 8, uint16(fieldTypeSet)<<8 | 1},
+		ColumnNullability: []byte{0xff, 0x1f},
+	}
+	rows := [][]interface{}{
+		{int64(-5), "abc", "-12.50", "2020-01-02 03:04:05.123456", "-01:02:03.500000", `{"a": [1, true, null], "bb": "x"}`,
+			[]byte{1, 2}, int64(1500000000000000000), int64(2), "2020-02-29", 2021, 1.5, int64(5)},
+		{int64(7), nil, "0.00", nil, "00:00:00.000000", nil, nil, nil, int64(1), nil, nil, nil, nil},
+	}
+	events := []Event{
+		&FormatDescriptionEvent{
+			baseEvent:              header(FormatDescriptionEventType),
+			BinlogVersion:          4,
+			ServerVersion:          []byte("5.7.18-log"),
+			EventHeaderLength:      eventHeaderSize,
+			EventPostHeaderLengths: []byte{56, 13, 0, 8},
+			ChecksumAlgorithm:      ChecksumAlgorithmCRC32,
+		},
+		&GtidEvent{baseEvent: header(GtidEventType), sid: [16]byte{1, 2, 3}, gno: 5, LastCommitted: 1, SequenceNumber: 2},
+		&QueryEvent{baseEvent: header(QueryEventType), ThreadID: 3, StatusVars: []byte{0, 0, 0, 0, 0}, Database: []byte("test"), Query: []byte("BEGIN")},
+		table,
+		&RowsEvent{baseEvent: header(WriteRowsEventType), TableID: 42, Table: table, Flags: 1, ColumnCount: 13, Columns: []byte{0xff, 0x1f}, Rows: rows},
+		&RowsEvent{baseEvent: header(UpdateRowsEventType), TableID: 42, Table: table, ColumnCount: 13, Columns: []byte{0x01, 0}, UpdatedColumns: []byte{0x03, 0},
+			Rows: [][]interface{}{{int64(1)}, {int64(2), "b"}}},
+		&XIDEvent{baseEvent: header(XidEventType), TransactionID: 9},
+		&RotateEvent{baseEvent: header(RotateEventType), Position: 4, NextLogName: []byte("mysql-bin.000002")},
+	}
+
+	enc, reenc := &EventEncoder{Pos: 4}, &EventEncoder{Pos: 4}
+	dec := &EventDecoder{ChecksumPolicy: ChecksumFail, DecimalFormat: DecimalString, tables: make(map[uint64]*TableMapEvent)}
+	for _, ev := range events {
+		data, err := enc.Encode(ev)
+		if err != nil {
+			t.Fatalf("%s: %v", ev.Header().Type, err)
+		}
+		decoded, err := dec.decode(data)
+		if err != nil {
+			t.Fatalf("%s: %v", ev.Header().Type, err)
+		}
+		h := *decoded.Header()
+		// the file and the GTID are annotated by the decoder
+		h.packet, h.LogFile, h.GTID = nil, "", ""
+		if h != *ev.Header() {
+			t.Errorf("%s: expected header %+v, got %+v", ev.Header().Type, *ev.Header(), h)
+		}
+		if e, ok := decoded.(*RowsEvent); ok && !reflect.DeepEqual(e.Rows, ev.(*RowsEvent).Rows) {
+			t.Errorf("%s: expected rows %v, got %v", e.header.Type, ev.(*RowsEvent).Rows, e.Rows)
+		}
+
+		again, err := reenc.Encode(decoded)
+		if err != nil {
+			t.Fatalf("%s: %v", ev.Header().Type, err)
+		}
+		if !bytes.Equal(again, data) {
+			t.Errorf("%s: expected the decoded event encoded to %x, got %x", ev.Header().Type, data, again)
+		}
+	}
+	if enc.Pos != events[len(events)-1].Header().NextLogPos {
+		t.Errorf("expected the position advanced to %d, got %d", events[len(events)-1].Header().NextLogPos, enc.Pos)
+	}
+
+	if _, err := enc.Encode(&StopEvent{baseEvent: header(StopEventType)}); err == nil {
+		t.Error("expected the error of the unsupported event")
+	}
+}
+
+func TestEncodeDecimal(t *testing.T) {
+	for _, c := range []struct {
+		meta  uint16
+		value string
+	}{
+		{10<<8 | 2, "12345678.90"},
+		{10<<8 | 2, "-0.01"},
+		{30<<8 | 10, "-12345678901234567890.0123456789"},
+		{5<<8 | 5, "0.12345"},
+	} {
+		p := newBinlogPacket(nil)
+		if err := p.writeDecimal(c.meta, c.value); err != nil {
+			t.Fatal(err)
+		}
+		if s, err := newBinlogPacket(p.Raw()).readDecimalString(c.meta); err != nil || s != c.value {
+			t.Errorf("expected %s, got %s", c.value, s)
+		}
+	}
+	if err := newBinlogPacket(nil).writeDecimal(4<<8|2, "123.4"); err == nil {
+		t.Error("expected the error of the out of range value")
+	}
+}
@@ -2,10 +2,15 @@ package binlog
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"hash/crc32"
 	"io"
+	"math"
 	"time"
+
+	"github.com/LightKool/mysql-go"
 )
 
 const (
@@ -32,6 +37,29 @@ type EventHeader struct {
 	EventSize  uint32
 	NextLogPos uint32
 	Flags      uint16
+
+	// LogFile is the binlog file of the event tracked by the decoder from the RotateEvents,
+	// it's empty until the file is known.
+	LogFile string
+	// GTID is the GTID of the transaction which the event belongs to, it's empty if unknown.
+	GTID string
+}
+
+// Body returns the event data after the header without the checksum, which is decoded by the custom events
+// registered by RegisterEventType.
+func (h *EventHeader) Body() []byte {
+	if h.packet == nil {
+		return nil
+	}
+	return h.packet.Raw()[h.packet.Pos():]
+}
+
+// LogPos returns the start position of the event in LogFile, which is 0 for the artificial events.
+func (h *EventHeader) LogPos() uint32 {
+	if h.NextLogPos < h.EventSize {
+		return 0
+	}
+	return h.NextLogPos - h.EventSize
 }
 
 func (h *EventHeader) Decode(dec *EventDecoder) error {
@@ -49,14 +77,109 @@ func (h *EventHeader) Decode(dec *EventDecoder) error {
 		return fmt.Errorf("header event size: %d != actual event size: %d, maybe corrupted", h.EventSize, packet.Len())
 	}
 	// remove checksum part if the event type is not FormatDescriptionEventType
-	if h.Type != FormatDescriptionEventType && dec.format != nil && dec.format.checksumEnabled() {
-		h.packet.SliceRight(4)
+	if alg := dec.checksumAlgorithm(); h.Type != FormatDescriptionEventType && alg.size() > 0 {
+		checksum := h.packet.SliceRight(alg.size())
+		if dec.ChecksumPolicy != ChecksumSkip {
+			return h.verifyChecksum(alg, h.packet.Raw(), checksum)
+		}
+	}
+	return nil
+}
+
+// ChecksumPolicy controls how the checksums of events are handled.
+type ChecksumPolicy int
+
+const (
+	// ChecksumSkip strips the checksums without verification.
+	ChecksumSkip ChecksumPolicy = iota
+	// ChecksumVerify verifies the checksums if the master writes them,
+	// a *ChecksumMismatchError is returned for the corrupted events.
+	ChecksumVerify
+	// ChecksumFail verifies the checksums like ChecksumVerify, and fails if the master doesn't write checksums at all.
+	ChecksumFail
+)
+
+// ChecksumMismatchError is returned when the checksum of an event doesn't match its data.
+type ChecksumMismatchError struct {
+	Type       EventType
+	NextLogPos uint32
+	Expected   uint32
+	Actual     uint32
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch (next log position %d): expected %#08x, actual %#08x",
+		e.Type, e.NextLogPos, e.Expected, e.Actual)
+}
+
+// DecodeError is returned when the body of an event can't be decoded, e.g. it's truncated or corrupted.
+type DecodeError struct {
+	Type       EventType
+	NextLogPos uint32
+	Err        error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode %s (next log position %d): %v", e.Type, e.NextLogPos, e.Err)
+}
+
+// ChecksumAlgorithm is the checksum algorithm of events, refer to `binlog_checksum`.
+type ChecksumAlgorithm byte
+
+const (
+	ChecksumAlgorithmNone  ChecksumAlgorithm = 0
+	ChecksumAlgorithmCRC32 ChecksumAlgorithm = 1
+	// ChecksumAlgorithmUndef is used by the masters which don't support checksums (before 5.6.1).
+	ChecksumAlgorithmUndef ChecksumAlgorithm = 255
+)
+
+func (a ChecksumAlgorithm) String() string {
+	switch a {
+	case ChecksumAlgorithmNone:
+		return "NONE"
+	case ChecksumAlgorithmCRC32:
+		return "CRC32"
+	case ChecksumAlgorithmUndef:
+		return "UNDEF"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", byte(a))
+	}
+}
+
+// supported reports whether events written with this algorithm can be decoded.
+func (a ChecksumAlgorithm) supported() bool {
+	switch a {
+	case ChecksumAlgorithmNone, ChecksumAlgorithmCRC32, ChecksumAlgorithmUndef:
+		return true
+	}
+	return false
+}
+
+// size returns the size of the checksum part at the end of every event.
+func (a ChecksumAlgorithm) size() int {
+	if a == ChecksumAlgorithmCRC32 {
+		return 4
+	}
+	return 0
+}
+
+// verifyChecksum verifies the checksum of the event data excluding the checksum part.
+func (h *EventHeader) verifyChecksum(alg ChecksumAlgorithm, data []byte, checksum []byte) error {
+	if alg != ChecksumAlgorithmCRC32 {
+		return nil
+	}
+	expected := binary.LittleEndian.Uint32(checksum)
+	actual := crc32.ChecksumIEEE(data)
+	if expected != actual {
+		return &ChecksumMismatchError{Type: h.Type, NextLogPos: h.NextLogPos, Expected: expected, Actual: actual}
 	}
 	return nil
 }
 
 type baseEvent struct {
 	header *EventHeader
+	// buf is the pooled buffer of the event data, see Release.
+	buf *[]byte
 }
 
 func (e *baseEvent) Header() *EventHeader {
@@ -72,6 +195,9 @@ func (e *baseEvent) printHeader(w io.Writer) {
 
 type UnsupportedEvent struct {
 	*baseEvent
+	// Err is the error of the event which can't be decoded with DecodeErrorUnsupported,
+	// it's nil for the events of the unsupported types.
+	Err  error
 	data []byte
 }
 
@@ -83,6 +209,9 @@ func (e *UnsupportedEvent) Decode(dec *EventDecoder) error {
 
 func (e *UnsupportedEvent) Print(w io.Writer) {
 	e.printHeader(w)
+	if e.Err != nil {
+		fmt.Fprintf(w, "Error: %v\n", e.Err)
+	}
 	fmt.Fprintf(w, "Data:\n%s\n", hex.Dump(e.data))
 	fmt.Fprintln(w)
 }
@@ -101,8 +230,13 @@ func (e *RotateEvent) Decode(dec *EventDecoder) error {
 }
 
 func (e *RotateEvent) postDecode(dec *EventDecoder) error {
+	// the fake RotateEvent is sent at the beginning of every dump, keep the table maps
+	// so that a resumed dump can continue in the middle of a transaction
+	if e.header.Flags&logEventArtificialFlag != 0 {
+		return nil
+	}
 	// Refer to https://github.com/noplay/python-mysql-replication/blob/master/pymysqlreplication/binlogstream.py (lint 435)
-	dec.tables = make(map[uint64]*TableMapEvent)
+	dec.resetTables()
 	return nil
 }
 
@@ -113,6 +247,74 @@ func (e *RotateEvent) Print(w io.Writer) {
 	fmt.Fprintln(w)
 }
 
+// StopEvent is written when the master shuts down, the next binlog file starts after restart.
+type StopEvent struct {
+	*baseEvent
+}
+
+func (e *StopEvent) Decode(dec *EventDecoder) error {
+	return nil
+}
+
+func (e *StopEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintln(w)
+}
+
+// IncidentEvent incident types
+const (
+	IncidentNone uint16 = iota
+	// IncidentLostEvents means there may be lost events (a "gap") in the replication stream.
+	IncidentLostEvents
+)
+
+// IncidentEvent notifies the slaves of an incident on the master, e.g. the binlog cache is too small for a transaction.
+type IncidentEvent struct {
+	*baseEvent
+	Incident uint16
+	Message  []byte
+}
+
+func (e *IncidentEvent) Decode(dec *EventDecoder) error {
+	packet := e.header.packet
+	e.Incident = packet.readUint16()
+	if !packet.EOF() {
+		n := packet.readByte()
+		e.Message = packet.Read(int(n))
+	}
+	return nil
+}
+
+func (e *IncidentEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	switch e.Incident {
+	case IncidentLostEvents:
+		fmt.Fprintln(w, "Incident: LOST_EVENTS")
+	default:
+		fmt.Fprintf(w, "Incident: %d\n", e.Incident)
+	}
+	fmt.Fprintf(w, "Message: %s\n", e.Message)
+	fmt.Fprintln(w)
+}
+
+// HeartbeatEvent is sent by the master when there are no events for the heartbeat period,
+// the NextLogPos of its header is the current position of the master in LogName.
+type HeartbeatEvent struct {
+	*baseEvent
+	LogName []byte
+}
+
+func (e *HeartbeatEvent) Decode(dec *EventDecoder) error {
+	e.LogName = e.header.packet.Read(-1)
+	return nil
+}
+
+func (e *HeartbeatEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "Log name: %s\n", e.LogName)
+	fmt.Fprintln(w)
+}
+
 var (
 	checksumEnabledMysqlVersion = parseMysqlVersion("5.6.1")
 )
@@ -124,7 +326,7 @@ type FormatDescriptionEvent struct {
 	EventHeaderLength      uint8
 	EventPostHeaderLengths []byte
 
-	checksumAlg byte
+	ChecksumAlgorithm ChecksumAlgorithm
 }
 
 func (e *FormatDescriptionEvent) Decode(dec *EventDecoder) error {
@@ -133,9 +335,24 @@ func (e *FormatDescriptionEvent) Decode(dec *EventDecoder) error {
 	e.ServerVersion = bytes.Trim(packet.Read(50), "\x00")
 	packet.Skip(4)
 	e.EventHeaderLength = packet.readByte()
+	e.ChecksumAlgorithm = ChecksumAlgorithmUndef
 	if parseMysqlVersion(string(e.ServerVersion)).greaterOrEqual(checksumEnabledMysqlVersion) {
+		// FormatDescriptionEvent always ends with the checksum algorithm(1 byte) + checksum(4 bytes)
 		checksumPart := packet.SliceRight(5)
-		e.checksumAlg = checksumPart[0]
+		e.ChecksumAlgorithm = ChecksumAlgorithm(checksumPart[0])
+		if !e.ChecksumAlgorithm.supported() {
+			return fmt.Errorf("unsupported checksum algorithm %s", e.ChecksumAlgorithm)
+		}
+		// the checksum of FormatDescriptionEvent covers the checksum algorithm byte
+		if dec.ChecksumPolicy != ChecksumSkip {
+			data := append(packet.Raw()[:packet.Len():packet.Len()], checksumPart[0])
+			if err := e.header.verifyChecksum(e.ChecksumAlgorithm, data, checksumPart[1:]); err != nil {
+				return err
+			}
+		}
+	}
+	if !e.checksumEnabled() && dec.ChecksumPolicy == ChecksumFail {
+		return fmt.Errorf("checksums are not written by the master %s", e.ServerVersion)
 	}
 	e.EventPostHeaderLengths = packet.Read(-1)
 	return nil
@@ -143,6 +360,11 @@ func (e *FormatDescriptionEvent) Decode(dec *EventDecoder) error {
 
 func (e *FormatDescriptionEvent) postDecode(dec *EventDecoder) error {
 	dec.format = e
+	// the master resends the FormatDescriptionEvent with log position 0 when a dump starts in the middle
+	// of a file, otherwise it starts a new file whose table ids are new
+	if e.header.NextLogPos != 0 {
+		dec.resetTables()
+	}
 	return nil
 }
 
@@ -150,6 +372,7 @@ func (e *FormatDescriptionEvent) Print(w io.Writer) {
 	e.printHeader(w)
 	fmt.Fprintf(w, "Binlog Version: %d\n", e.BinlogVersion)
 	fmt.Fprintf(w, "Server version: %s\n", e.ServerVersion)
+	fmt.Fprintf(w, "Checksum algorithm: %s\n", e.ChecksumAlgorithm)
 	e.printEventPostHeaderLengths(w)
 	fmt.Fprintln(w)
 }
@@ -162,7 +385,7 @@ func (e *FormatDescriptionEvent) printEventPostHeaderLengths(w io.Writer) {
 }
 
 func (e *FormatDescriptionEvent) checksumEnabled() bool {
-	return e.checksumAlg == 1 // only support CRC checksum
+	return e.ChecksumAlgorithm.size() > 0
 }
 
 type QueryEvent struct {
@@ -171,8 +394,10 @@ type QueryEvent struct {
 	ExecutionTime uint32
 	ErrorCode     uint16
 	StatusVars    []byte
-	Database      []byte
-	Query         []byte
+	// Status is parsed from StatusVars.
+	Status   QueryStatus
+	Database []byte
+	Query    []byte
 }
 
 func (e *QueryEvent) Decode(dec *EventDecoder) error {
@@ -186,7 +411,9 @@ func (e *QueryEvent) Decode(dec *EventDecoder) error {
 	e.Database = packet.Read(int(databaseLen))
 	packet.Skip(1)
 	e.Query = packet.Read(-1)
-	return nil
+	var err error
+	e.Status, err = parseQueryStatus(e.StatusVars)
+	return err
 }
 
 func (e *QueryEvent) Print(w io.Writer) {
@@ -199,6 +426,84 @@ func (e *QueryEvent) Print(w io.Writer) {
 	fmt.Fprintln(w)
 }
 
+// ExecuteLoadQueryEvent duplicate handling of LOAD DATA INFILE
+const (
+	LoadDupError byte = iota
+	LoadDupIgnore
+	LoadDupReplace
+)
+
+// BeginLoadQueryEvent carries the first block of the file loaded by LOAD DATA INFILE.
+type BeginLoadQueryEvent struct {
+	*baseEvent
+	FileID    uint32
+	BlockData []byte
+}
+
+func (e *BeginLoadQueryEvent) Decode(dec *EventDecoder) error {
+	packet := e.header.packet
+	e.FileID = packet.readUint32()
+	e.BlockData = packet.Read(-1)
+	return nil
+}
+
+func (e *BeginLoadQueryEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "File ID: %d\n", e.FileID)
+	fmt.Fprintf(w, "Block data size: %d\n", len(e.BlockData))
+	fmt.Fprintln(w)
+}
+
+// ExecuteLoadQueryEvent executes the LOAD DATA INFILE statement with the file sent by the BeginLoadQueryEvent,
+// the file name in Query is between StartPos and EndPos.
+type ExecuteLoadQueryEvent struct {
+	QueryEvent
+	FileID      uint32
+	StartPos    uint32
+	EndPos      uint32
+	DupHandling byte
+}
+
+func (e *ExecuteLoadQueryEvent) Decode(dec *EventDecoder) error {
+	packet := e.header.packet
+	e.ThreadID = packet.readUint32()
+	e.ExecutionTime = packet.readUint32()
+	databaseLen := packet.readByte()
+	e.ErrorCode = packet.readUint16()
+	statusVarsLen := packet.readUint16()
+	e.FileID = packet.readUint32()
+	e.StartPos = packet.readUint32()
+	e.EndPos = packet.readUint32()
+	e.DupHandling = packet.readByte()
+	e.StatusVars = packet.Read(int(statusVarsLen))
+	e.Database = packet.Read(int(databaseLen))
+	packet.Skip(1)
+	e.Query = packet.Read(-1)
+	var err error
+	e.Status, err = parseQueryStatus(e.StatusVars)
+	return err
+}
+
+func (e *ExecuteLoadQueryEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "Thread ID: %d\n", e.ThreadID)
+	fmt.Fprintf(w, "Execution time: %d\n", e.ExecutionTime)
+	fmt.Fprintf(w, "Error code: %d\n", e.ErrorCode)
+	fmt.Fprintf(w, "File ID: %d\n", e.FileID)
+	fmt.Fprintf(w, "File name position: %d-%d\n", e.StartPos, e.EndPos)
+	switch e.DupHandling {
+	case LoadDupIgnore:
+		fmt.Fprintln(w, "Duplicate handling: IGNORE")
+	case LoadDupReplace:
+		fmt.Fprintln(w, "Duplicate handling: REPLACE")
+	default:
+		fmt.Fprintln(w, "Duplicate handling: ERROR")
+	}
+	fmt.Fprintf(w, "Database: %s\n", e.Database)
+	fmt.Fprintf(w, "Query: %s\n", e.Query)
+	fmt.Fprintln(w)
+}
+
 type XIDEvent struct {
 	*baseEvent
 	TransactionID uint64
@@ -216,40 +521,231 @@ func (e *XIDEvent) Print(w io.Writer) {
 	fmt.Fprintln(w)
 }
 
+// logicalClockTypeCode precedes the logical clock fields of GtidEvent since 5.7.6
+const logicalClockTypeCode = 2
+
 type GtidEvent struct {
 	*baseEvent
 	CommitFlag uint8
-	sid        []byte
+	sid        mysql.SID
 	gno        uint64
+	// LastCommitted and SequenceNumber are the logical clock of the transaction since 5.7.6,
+	// transactions can be applied in parallel if their LastCommitted are less than each other's SequenceNumber.
+	LastCommitted  int64
+	SequenceNumber int64
 }
 
 func (e *GtidEvent) Decode(dec *EventDecoder) error {
 	packet := e.header.packet
 	e.CommitFlag = packet.readByte()
-	e.sid = packet.Read(16)
+	copy(e.sid[:], packet.Read(16))
 	e.gno = packet.readUint64()
+	if packet.Len()-packet.Pos() >= 1+16 && packet.readByte() == logicalClockTypeCode {
+		e.LastCommitted = int64(packet.readUint64())
+		e.SequenceNumber = int64(packet.readUint64())
+	}
 	return nil
 }
 
 func (e *GtidEvent) Print(w io.Writer) {
+	e.print(w, e.GTID())
+}
+
+func (e *GtidEvent) print(w io.Writer, gtid string) {
 	e.printHeader(w)
 	fmt.Fprintf(w, "Commit flag: %d\n", e.CommitFlag)
-	fmt.Fprintf(w, "GTID: %s\n", e.GTID())
+	fmt.Fprintf(w, "GTID: %s\n", gtid)
+	fmt.Fprintf(w, "Last committed: %d\n", e.LastCommitted)
+	fmt.Fprintf(w, "Sequence number: %d\n", e.SequenceNumber)
 	fmt.Fprintln(w)
 }
 
 func (e *GtidEvent) GTID() string {
-	buf := make([]byte, 36)
-
-	hex.Encode(buf[0:8], e.sid[0:4])
-	buf[8] = '-'
-	hex.Encode(buf[9:13], e.sid[4:6])
-	buf[13] = '-'
-	hex.Encode(buf[14:18], e.sid[6:8])
-	buf[18] = '-'
-	hex.Encode(buf[19:23], e.sid[8:10])
-	buf[23] = '-'
-	hex.Encode(buf[24:], e.sid[10:])
-
-	return fmt.Sprintf("%s:%d", buf, e.gno)
+	return fmt.Sprintf("%s:%d", e.sid, e.gno)
+}
+
+// AnonymousGtidEvent starts a transaction without GTID when gtid_mode is OFF,
+// it carries the logical clock like GtidEvent.
+type AnonymousGtidEvent struct {
+	GtidEvent
+}
+
+func (e *AnonymousGtidEvent) Print(w io.Writer) {
+	e.print(w, e.GTID())
+}
+
+func (e *AnonymousGtidEvent) GTID() string {
+	return "ANONYMOUS"
+}
+
+type PreviousGtidsEvent struct {
+	*baseEvent
+	GTIDSet mysql.GTIDSet
+}
+
+func (e *PreviousGtidsEvent) Decode(dec *EventDecoder) (err error) {
+	packet := e.header.packet
+	e.GTIDSet, err = mysql.DecodeGTIDSet(packet.Read(-1))
+	return
+}
+
+func (e *PreviousGtidsEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "Previous GTIDs: %s\n", e.GTIDSet)
+	fmt.Fprintln(w)
+}
+
+// IntvarType is the type of the integer variable set by IntvarEvent.
+type IntvarType byte
+
+const (
+	IntvarTypeInvalid IntvarType = iota
+	// IntvarTypeLastInsertID is the value of LAST_INSERT_ID() used by the next statement.
+	IntvarTypeLastInsertID
+	// IntvarTypeInsertID is the first AUTO_INCREMENT value generated by the next statement.
+	IntvarTypeInsertID
+)
+
+func (t IntvarType) String() string {
+	switch t {
+	case IntvarTypeLastInsertID:
+		return "LAST_INSERT_ID"
+	case IntvarTypeInsertID:
+		return "INSERT_ID"
+	default:
+		return fmt.Sprintf("IntvarType(%d)", byte(t))
+	}
+}
+
+type IntvarEvent struct {
+	*baseEvent
+	Type  IntvarType
+	Value uint64
+}
+
+func (e *IntvarEvent) Decode(dec *EventDecoder) error {
+	packet := e.header.packet
+	e.Type = IntvarType(packet.readByte())
+	e.Value = packet.readUint64()
+	return nil
+}
+
+func (e *IntvarEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "Type: %s\n", e.Type)
+	fmt.Fprintf(w, "Value: %d\n", e.Value)
+	fmt.Fprintln(w)
+}
+
+type RandEvent struct {
+	*baseEvent
+	Seed1 uint64
+	Seed2 uint64
+}
+
+func (e *RandEvent) Decode(dec *EventDecoder) error {
+	packet := e.header.packet
+	e.Seed1 = packet.readUint64()
+	e.Seed2 = packet.readUint64()
+	return nil
+}
+
+func (e *RandEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "Seed1: %d\n", e.Seed1)
+	fmt.Fprintf(w, "Seed2: %d\n", e.Seed2)
+	fmt.Fprintln(w)
+}
+
+// UserVarEvent value types
+const (
+	StringResultType byte = iota
+	RealResultType
+	IntResultType
+	RowResultType
+	DecimalResultType
+)
+
+const userVarUnsignedFlag = 0x01
+
+type UserVarEvent struct {
+	*baseEvent
+	Name    []byte
+	IsNull  bool
+	Type    byte
+	Charset uint32
+	Flags   byte
+	Value   interface{}
+}
+
+func (e *UserVarEvent) Decode(dec *EventDecoder) (err error) {
+	packet := e.header.packet
+	nameLen := packet.readUint32()
+	e.Name = packet.Read(int(nameLen))
+	e.IsNull = packet.readByte() == 1
+	if e.IsNull {
+		return nil
+	}
+
+	e.Type = packet.readByte()
+	e.Charset = packet.readUint32()
+	valueLen := packet.readUint32()
+	data := packet.Read(int(valueLen))
+	if !packet.EOF() {
+		e.Flags = packet.readByte()
+	}
+
+	switch e.Type {
+	case StringResultType:
+		e.Value = string(data)
+	case RealResultType:
+		if len(data) != 8 {
+			return fmt.Errorf("invalid REAL user variable length: %d", len(data))
+		}
+		e.Value = math.Float64frombits(binary.LittleEndian.Uint64(data))
+	case IntResultType:
+		if len(data) != 8 {
+			return fmt.Errorf("invalid INT user variable length: %d", len(data))
+		}
+		u64 := binary.LittleEndian.Uint64(data)
+		if e.Flags&userVarUnsignedFlag != 0 {
+			e.Value = u64
+		} else {
+			e.Value = int64(u64)
+		}
+	case DecimalResultType:
+		// precision(1 byte) + scale(1 byte) + binary decimal
+		if len(data) < 2 {
+			return fmt.Errorf("invalid DECIMAL user variable length: %d", len(data))
+		}
+		meta := uint16(data[0])<<8 | uint16(data[1])
+		e.Value, err = newBinlogPacket(data[2:]).readDecimal(dec, meta)
+	default:
+		e.Value = data
+	}
+	return
+}
+
+func (e *UserVarEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "Name: %s\n", e.Name)
+	if e.IsNull {
+		fmt.Fprintln(w, "Value: NULL")
+	} else {
+		switch e.Type {
+		case StringResultType:
+			fmt.Fprintln(w, "Type: STRING")
+		case RealResultType:
+			fmt.Fprintln(w, "Type: REAL")
+		case IntResultType:
+			fmt.Fprintln(w, "Type: INT")
+		case DecimalResultType:
+			fmt.Fprintln(w, "Type: DECIMAL")
+		default:
+			fmt.Fprintf(w, "Type: %d\n", e.Type)
+		}
+		fmt.Fprintf(w, "Charset: %d\n", e.Charset)
+		fmt.Fprintf(w, "Value: %v\n", e.Value)
+	}
+	fmt.Fprintln(w)
 }
@@ -0,0 +1,280 @@
+package binlog
+
+import (
+	"encoding/json"
+)
+
+// eventJSON is the envelope of all the events in JSON: the header fields, the table and rows for
+// the rows events, and the fields specific to the event type in Data.
+type eventJSON struct {
+	Type       string      `json:"type"`
+	Timestamp  uint32      `json:"timestamp"`
+	ServerID   uint32      `json:"server_id"`
+	EventSize  uint32      `json:"event_size"`
+	NextLogPos uint32      `json:"next_log_pos"`
+	Flags      uint16      `json:"flags"`
+	LogFile    string      `json:"log_file,omitempty"`
+	GTID       string      `json:"gtid,omitempty"`
+	Schema     string      `json:"schema,omitempty"`
+	Table      string      `json:"table,omitempty"`
+	Rows       []RowChange `json:"rows,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+}
+
+func (e *baseEvent) envelope(data interface{}) *eventJSON {
+	return &eventJSON{
+		Type:       e.header.Type.String(),
+		Timestamp:  e.header.Timestamp,
+		ServerID:   e.header.ServerID,
+		EventSize:  e.header.EventSize,
+		NextLogPos: e.header.NextLogPos,
+		Flags:      e.header.Flags,
+		LogFile:    e.header.LogFile,
+		GTID:       e.header.GTID,
+		Data:       data,
+	}
+}
+
+func (e *baseEvent) marshalJSON(data interface{}) ([]byte, error) {
+	return json.Marshal(e.envelope(data))
+}
+
+func (e *UnsupportedEvent) MarshalJSON() ([]byte, error) {
+	data := map[string]interface{}{"data": e.data}
+	if e.Err != nil {
+		data["error"] = e.Err.Error()
+	}
+	return e.marshalJSON(data)
+}
+
+func (e *RotateEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{
+		"position":      e.Position,
+		"next_log_name": string(e.NextLogName),
+	})
+}
+
+func (e *StopEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(nil)
+}
+
+func (e *IncidentEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{
+		"incident": e.Incident,
+		"message":  string(e.Message),
+	})
+}
+
+func (e *HeartbeatEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{"log_name": string(e.LogName)})
+}
+
+func (e *FormatDescriptionEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{
+		"binlog_version":     e.BinlogVersion,
+		"server_version":     string(e.ServerVersion),
+		"checksum_algorithm": e.ChecksumAlgorithm.String(),
+	})
+}
+
+func (e *QueryEvent) data() map[string]interface{} {
+	return map[string]interface{}{
+		"thread_id":      e.ThreadID,
+		"execution_time": e.ExecutionTime,
+		"error_code":     e.ErrorCode,
+		"database":       string(e.Database),
+		"query":          string(e.Query),
+	}
+}
+
+func (e *QueryEvent) MarshalJSON() ([]byte, error) {
+	env := e.envelope(e.data())
+	env.Schema = string(e.Database)
+	return json.Marshal(env)
+}
+
+func (e *BeginLoadQueryEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{
+		"file_id":    e.FileID,
+		"block_data": e.BlockData,
+	})
+}
+
+func (e *ExecuteLoadQueryEvent) MarshalJSON() ([]byte, error) {
+	data := e.data()
+	data["file_id"] = e.FileID
+	data["start_pos"] = e.StartPos
+	data["end_pos"] = e.EndPos
+	data["dup_handling"] = e.DupHandling
+	env := e.envelope(data)
+	env.Schema = string(e.Database)
+	return json.Marshal(env)
+}
+
+func (e *XIDEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{"transaction_id": e.TransactionID})
+}
+
+func (e *GtidEvent) data(gtid string) map[string]interface{} {
+	return map[string]interface{}{
+		"commit_flag":     e.CommitFlag,
+		"gtid":            gtid,
+		"last_committed":  e.LastCommitted,
+		"sequence_number": e.SequenceNumber,
+	}
+}
+
+func (e *GtidEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(e.data(e.GTID()))
+}
+
+func (e *AnonymousGtidEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(e.data(e.GTID()))
+}
+
+func (e *PreviousGtidsEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{"gtid_set": e.GTIDSet.String()})
+}
+
+func (e *IntvarEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{
+		"type":  e.Type,
+		"value": e.Value,
+	})
+}
+
+func (e *RandEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{
+		"seed1": e.Seed1,
+		"seed2": e.Seed2,
+	})
+}
+
+func (e *UserVarEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{
+		"name":  string(e.Name),
+		"value": e.Value,
+	})
+}
+
+func (e *TransactionContextEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{
+		"server_uuid":      string(e.ServerUUID),
+		"thread_id":        e.ThreadID,
+		"gtid_specified":   e.GTIDSpecified,
+		"snapshot_version": e.SnapshotVersion.String(),
+		"write_set":        e.WriteSet,
+		"read_set":         e.ReadSet,
+	})
+}
+
+func (e *ViewChangeEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{
+		"view_id":         string(e.ViewID),
+		"sequence_number": e.SequenceNumber,
+		"cert_info":       e.CertInfo,
+	})
+}
+
+func (e *MariadbGtidEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{
+		"gtid":      e.GTID.String(),
+		"flags":     e.Flags,
+		"commit_id": e.CommitID,
+	})
+}
+
+func (e *MariadbGtidListEvent) MarshalJSON() ([]byte, error) {
+	gtids := make([]string, len(e.GTIDs))
+	for i, gtid := range e.GTIDs {
+		gtids[i] = gtid.String()
+	}
+	return e.marshalJSON(map[string]interface{}{
+		"flags": e.Flags,
+		"gtids": gtids,
+	})
+}
+
+func (e *MariadbAnnotateRowsEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{"query": string(e.Query)})
+}
+
+func (e *MariadbBinlogCheckpointEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{"log_name": string(e.LogName)})
+}
+
+func (e *XaPrepareLogEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{
+		"one_phase": e.OnePhase,
+		"xid":       e.XID.String(),
+	})
+}
+
+func (e *TableMapEvent) MarshalJSON() ([]byte, error) {
+	columns := make([]string, e.ColumnCount)
+	for i := range columns {
+		columns[i] = e.ColumnName(i)
+	}
+	env := e.envelope(map[string]interface{}{
+		"table_id":     e.TableID,
+		"column_types": e.ColumnTypes,
+		"column_names": columns,
+		"primary_key":  e.PrimaryKey,
+	})
+	env.Schema, env.Table = string(e.Database), string(e.TableName)
+	return json.Marshal(env)
+}
+
+func (e *RowsQueryEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{"query": string(e.Query)})
+}
+
+func (e *RowsEvent) MarshalJSON() ([]byte, error) {
+	data := map[string]interface{}{"table_id": e.TableID}
+	if e.Query != nil {
+		data["query"] = string(e.Query)
+	}
+	if e.ExtraInfo.PartitionID != nil {
+		data["partition_id"] = *e.ExtraInfo.PartitionID
+	}
+	if e.ExtraInfo.SourcePartitionID != nil {
+		data["source_partition_id"] = *e.ExtraInfo.SourcePartitionID
+	}
+	env := e.envelope(data)
+	if e.Table != nil {
+		env.Schema, env.Table = string(e.Table.Database), string(e.Table.TableName)
+		env.Rows = e.RowChanges()
+	}
+	return json.Marshal(env)
+}
+
+// MarshalJSON adds the source to the JSON of the event, e.g. {"source":"db1","type":"XidEvent",...}.
+func (e *SourceEvent) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(e.Event)
+	if err != nil {
+		return nil, err
+	}
+	source, err := json.Marshal(e.Source)
+	if err != nil {
+		return nil, err
+	}
+	buf := append([]byte(`{"source":`), source...)
+	if len(data) > 2 {
+		buf = append(buf, ',')
+	}
+	// the events are encoded as JSON objects
+	return append(buf, data[1:]...), nil
+}
+
+// MarshalJSON encodes the ENUM value as its member name.
+func (v EnumValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Name)
+}
+
+// MarshalJSON encodes the SET value as the array of its member names.
+func (v SetValue) MarshalJSON() ([]byte, error) {
+	if v.Members == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(v.Members)
+}
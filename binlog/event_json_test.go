@@ -0,0 +1,69 @@
+package binlog
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEventMarshalJSON(t *testing.T) {
+	header := func(typ EventType) *baseEvent {
+		return &baseEvent{header: &EventHeader{Type: typ, Timestamp: 1500000000, ServerID: 1, NextLogPos: 1000}}
+	}
+	table := &TableMapEvent{
+		baseEvent:   header(TableMapEventType),
+		TableID:     1,
+		Database:    []byte("db"),
+		TableName:   []byte("t"),
+		ColumnCount: 2,
+		ColumnTypes: []byte{fieldTypeLong, fieldTypeString},
+		columns:     []*Column{{Name: "id"}, {Name: "color"}},
+	}
+	rows := &RowsEvent{
+		baseEvent:      header(UpdateRowsEventType),
+		TableID:        1,
+		Table:          table,
+		ColumnCount:    2,
+		Columns:        []byte{0x03},
+		UpdatedColumns: []byte{0x03},
+		Rows: [][]interface{}{
+			{int64(1), EnumValue{1, "red"}},
+			{int64(1), EnumValue{2, "blue"}},
+		},
+	}
+
+	tests := []struct {
+		event    Event
+		expected string
+	}{
+		{
+			&QueryEvent{baseEvent: header(QueryEventType), Database: []byte("db"), Query: []byte("BEGIN")},
+			`{"type":"QueryEvent","timestamp":1500000000,"server_id":1,"event_size":0,"next_log_pos":1000,"flags":0,` +
+				`"schema":"db","data":{"database":"db","error_code":0,"execution_time":0,"query":"BEGIN","thread_id":0}}`,
+		},
+		{
+			&XIDEvent{baseEvent: header(XidEventType), TransactionID: 7},
+			`{"type":"XidEvent","timestamp":1500000000,"server_id":1,"event_size":0,"next_log_pos":1000,"flags":0,` +
+				`"data":{"transaction_id":7}}`,
+		},
+		{
+			rows,
+			`{"type":"UpdateRowsEventV2","timestamp":1500000000,"server_id":1,"event_size":0,"next_log_pos":1000,"flags":0,` +
+				`"schema":"db","table":"t","rows":[{"before":{"color":"red","id":1},"after":{"color":"blue","id":1}}],` +
+				`"data":{"table_id":1}}`,
+		},
+		{
+			&SourceEvent{Event: &XIDEvent{baseEvent: header(XidEventType), TransactionID: 7}, Source: "db1"},
+			`{"source":"db1","type":"XidEvent","timestamp":1500000000,"server_id":1,"event_size":0,"next_log_pos":1000,` +
+				`"flags":0,"data":{"transaction_id":7}}`,
+		},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(test.event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, data)
+		}
+	}
+}
@@ -2,25 +2,264 @@ package binlog
 
 import (
 	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+const defaultEventQueueSize = 128
+
+// ErrQueueClosed is returned by Pop after the EventQueue is closed.
+var ErrQueueClosed = errors.New("event queue closed")
+
+// OverflowPolicy controls what the producer does when the EventQueue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks the producer until the consumer pops events, which stops reading from the master.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest discards the oldest queued events to make room for the new ones.
+	OverflowDropOldest
 )
 
 type EventQueue struct {
-	ch    chan Event
-	errCh chan error
-	err   error
+	ch      chan Event
+	errCh   chan error
+	err     error
+	policy  OverflowPolicy
+	dropped uint64
+
+	cancel    context.CancelFunc
+	closed    chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+
+	// resumed is closed by Resume, it's nil if the queue is not paused
+	pauseMu sync.Mutex
+	resumed chan struct{}
+	// paused is closed by Pause to wake up the blocked producer, so that it buffers the events
+	paused chan struct{}
+	// buffer holds the events pushed while the queue is paused and full if bufferPaused is true,
+	// they follow the events in ch
+	bufferPaused bool
+	buffer       []Event
+}
+
+func newEventQueue(size int, policy OverflowPolicy) *EventQueue {
+	if size <= 0 {
+		size = defaultEventQueueSize
+	}
+	return &EventQueue{
+		ch:      make(chan Event, size),
+		errCh:   make(chan error, 1),
+		policy:  policy,
+		cancel:  func() {},
+		closed:  make(chan struct{}),
+		stopped: make(chan struct{}),
+		paused:  make(chan struct{}),
+	}
 }
 
 func (q *EventQueue) Pop(ctx context.Context) (Event, error) {
 	if q.err != nil {
+		if event, ok := q.next(); ok {
+			return event, nil
+		}
 		return nil, q.err
 	}
+	if err := q.waitResumed(ctx); err != nil {
+		return nil, err
+	}
+
+	// deliver the queued events before the error
+	if event, ok := q.next(); ok {
+		return event, nil
+	}
 
 	select {
 	case event := <-q.ch:
 		return event, nil
 	case q.err = <-q.errCh:
+		if event, ok := q.next(); ok {
+			return event, nil
+		}
 		return nil, q.err
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	}
 }
+
+// next returns the next queued event without blocking.
+func (q *EventQueue) next() (Event, bool) {
+	select {
+	case event := <-q.ch:
+		return event, true
+	default:
+	}
+	// the producer doesn't push into ch until the buffer is empty
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	if len(q.buffer) == 0 {
+		return nil, false
+	}
+	event := q.buffer[0]
+	q.buffer[0] = nil
+	if q.buffer = q.buffer[1:]; len(q.buffer) == 0 {
+		q.buffer = nil
+	}
+	return event, true
+}
+
+// Len returns the number of the queued events.
+func (q *EventQueue) Len() int {
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	return len(q.ch) + len(q.buffer)
+}
+
+// Dropped returns the number of events discarded by OverflowDropOldest.
+func (q *EventQueue) Dropped() uint64 {
+	return atomic.LoadUint64(&q.dropped)
+}
+
+// Pause makes Pop block until Resume. The producer of a Streamer keeps reading from the master meanwhile,
+// otherwise the master drops the dump once it can't write for net_write_timeout: the events beyond the queue
+// size are buffered in memory without limit, or spilled to SpillDir up to SpillMaxSize if it's set, so a long
+// pause of a busy master takes the memory or the disk instead of blocking. The other producers block according
+// to the OverflowPolicy once the queue is full. Pop returns after Close as usual.
+func (q *EventQueue) Pause() {
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	if q.resumed == nil {
+		q.resumed = make(chan struct{})
+		close(q.paused)
+	}
+}
+
+// Resume unblocks Pop after Pause.
+func (q *EventQueue) Resume() {
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	if q.resumed != nil {
+		close(q.resumed)
+		q.resumed = nil
+		q.paused = make(chan struct{})
+	}
+}
+
+// Paused reports whether the queue is paused.
+func (q *EventQueue) Paused() bool {
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	return q.resumed != nil
+}
+
+// waitResumed waits until the queue is resumed or closed if it's paused.
+func (q *EventQueue) waitResumed(ctx context.Context) error {
+	q.pauseMu.Lock()
+	resumed := q.resumed
+	q.pauseMu.Unlock()
+	if resumed == nil {
+		return nil
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-q.closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops the producer and waits for it to exit. If drain is true, the queued events
+// can still be popped before ErrQueueClosed, otherwise they are discarded.
+func (q *EventQueue) Close(drain bool) {
+	q.closeOnce.Do(func() {
+		close(q.closed)
+		q.cancel()
+		<-q.stopped
+		if drain {
+			return
+		}
+		q.pauseMu.Lock()
+		q.buffer = nil
+		q.pauseMu.Unlock()
+		for {
+			select {
+			case <-q.ch:
+			default:
+				return
+			}
+		}
+	})
+}
+
+// push pushes the event into the queue according to the overflow policy, it returns false if ctx is done.
+func (q *EventQueue) push(ctx context.Context, ev Event) bool {
+	paused, buffered := q.buffered(ev)
+	if buffered {
+		return true
+	}
+	if q.policy == OverflowDropOldest {
+		for {
+			select {
+			case q.ch <- ev:
+				return true
+			case <-ctx.Done():
+				q.fail(ctx.Err())
+				return false
+			default:
+			}
+			select {
+			case <-q.ch:
+				atomic.AddUint64(&q.dropped, 1)
+			default:
+			}
+		}
+	}
+
+	for {
+		select {
+		case q.ch <- ev:
+			return true
+		case <-paused:
+			// the queue is paused while the producer is blocked
+			if paused, buffered = q.buffered(ev); buffered {
+				return true
+			}
+		case <-ctx.Done():
+			q.fail(ctx.Err())
+			return false
+		}
+	}
+}
+
+// buffered appends the event to the buffer if bufferPaused is true and the queue is paused and full, or the
+// buffer isn't empty. Otherwise it returns the channel closed by Pause, which is nil if it won't buffer.
+func (q *EventQueue) buffered(ev Event) (<-chan struct{}, bool) {
+	if !q.bufferPaused {
+		return nil, false
+	}
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	if len(q.buffer) > 0 || q.resumed != nil && len(q.ch) == cap(q.ch) {
+		q.buffer = append(q.buffer, ev)
+		return nil, true
+	}
+	if q.resumed != nil {
+		// ch isn't full, there's no need to wake up
+		return nil, false
+	}
+	return q.paused, false
+}
+
+// fail delivers the error which stops the producer to the consumer, it must be called exactly once.
+func (q *EventQueue) fail(err error) {
+	select {
+	case <-q.closed:
+		err = ErrQueueClosed
+	default:
+	}
+	q.errCh <- err
+}
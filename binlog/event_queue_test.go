@@ -0,0 +1,135 @@
+package binlog
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// produce pushes n events into the queue like Streamer.run and waits for ctx to be done,
+// the returned channel is closed when all the events are pushed or ctx is done.
+func produce(q *EventQueue, n int) <-chan struct{} {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	pushed := make(chan struct{})
+	go func() {
+		defer close(q.stopped)
+		for i := 0; i < n; i++ {
+			ev := &XIDEvent{baseEvent: &baseEvent{header: &EventHeader{}}, TransactionID: uint64(i)}
+			if !q.push(ctx, ev) {
+				close(pushed)
+				return
+			}
+		}
+		close(pushed)
+		<-ctx.Done()
+		q.fail(ctx.Err())
+	}()
+	return pushed
+}
+
+func TestEventQueueDropOldest(t *testing.T) {
+	q := newEventQueue(2, OverflowDropOldest)
+	<-produce(q, 5)
+	q.Close(true)
+
+	for _, expected := range []uint64{3, 4} {
+		ev, err := q.Pop(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id := ev.(*XIDEvent).TransactionID; id != expected {
+			t.Errorf("expected transaction %d, got %d", expected, id)
+		}
+	}
+	if _, err := q.Pop(context.Background()); err != ErrQueueClosed {
+		t.Errorf("expected ErrQueueClosed, got %v", err)
+	}
+	if q.Dropped() != 3 {
+		t.Errorf("expected 3 dropped events, got %d", q.Dropped())
+	}
+}
+
+func TestEventQueueCloseDiscard(t *testing.T) {
+	q := newEventQueue(2, OverflowBlock)
+	produce(q, 5)
+	q.Close(false)
+
+	if _, err := q.Pop(context.Background()); err != ErrQueueClosed {
+		t.Errorf("expected ErrQueueClosed, got %v", err)
+	}
+	if q.Dropped() != 0 {
+		t.Errorf("expected no dropped events, got %d", q.Dropped())
+	}
+}
+
+func TestEventQueuePause(t *testing.T) {
+	q := newEventQueue(2, OverflowBlock)
+	pushed := produce(q, 3)
+	q.Pause()
+	if !q.Paused() {
+		t.Fatal("expected the queue paused")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := q.Pop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected Pop blocked while paused, got %v", err)
+	}
+	select {
+	case <-pushed:
+		t.Fatal("expected the producer blocked by the full queue")
+	default:
+	}
+
+	q.Resume()
+	for i := 0; i < 3; i++ {
+		ev, err := q.Pop(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id := ev.(*XIDEvent).TransactionID; id != uint64(i) {
+			t.Errorf("expected transaction %d, got %d", i, id)
+		}
+	}
+	<-pushed
+
+	// Close unblocks the paused Pop
+	q.Pause()
+	go q.Close(false)
+	if _, err := q.Pop(context.Background()); err != ErrQueueClosed {
+		t.Errorf("expected ErrQueueClosed, got %v", err)
+	}
+}
+
+func TestEventQueuePauseBuffer(t *testing.T) {
+	q := newEventQueue(2, OverflowBlock)
+	q.bufferPaused = true
+	pushed := produce(q, 5)
+	time.Sleep(20 * time.Millisecond)
+	// the blocked producer buffers the events once the queue is paused
+	q.Pause()
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the producer not blocked while paused")
+	}
+	if n := q.Len(); n != 5 {
+		t.Errorf("expected 5 queued events, got %d", n)
+	}
+
+	q.Resume()
+	for i := 0; i < 5; i++ {
+		ev, err := q.Pop(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id := ev.(*XIDEvent).TransactionID; id != uint64(i) {
+			t.Errorf("expected transaction %d, got %d", i, id)
+		}
+	}
+	q.Close(false)
+	if _, err := q.Pop(context.Background()); err != ErrQueueClosed {
+		t.Errorf("expected ErrQueueClosed, got %v", err)
+	}
+}
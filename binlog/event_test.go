@@ -0,0 +1,357 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"testing"
+
+	"github.com/LightKool/mysql-go"
+)
+
+// buildEvent builds the raw data of an event with the given type and body.
+func buildEvent(typ EventType, body []byte, checksum bool) []byte {
+	size := eventHeaderSize + len(body)
+	if checksum {
+		size += 4
+	}
+	data := make([]byte, eventHeaderSize, size)
+	binary.LittleEndian.PutUint32(data[0:], 1500000000)
+	data[4] = byte(typ)
+	binary.LittleEndian.PutUint32(data[5:], 1)
+	binary.LittleEndian.PutUint32(data[9:], uint32(size))
+	binary.LittleEndian.PutUint32(data[13:], 1000)
+	data = append(data, body...)
+	if checksum {
+		data = append(data, 0, 0, 0, 0)
+		binary.LittleEndian.PutUint32(data[len(data)-4:], crc32.ChecksumIEEE(data[:len(data)-4]))
+	}
+	return data
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	dec := &EventDecoder{ChecksumPolicy: ChecksumVerify, format: &FormatDescriptionEvent{ChecksumAlgorithm: ChecksumAlgorithmCRC32}}
+	data := buildEvent(XidEventType, []byte{1, 0, 0, 0, 0, 0, 0, 0}, true)
+	ev, err := dec.decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xid := ev.(*XIDEvent).TransactionID; xid != 1 {
+		t.Errorf("expected transaction id 1, got %d", xid)
+	}
+
+	data = buildEvent(XidEventType, []byte{1, 0, 0, 0, 0, 0, 0, 0}, true)
+	data[eventHeaderSize] = 2
+	_, err = dec.decode(data)
+	if e, ok := err.(*ChecksumMismatchError); !ok || e.Type != XidEventType {
+		t.Fatalf("expected *ChecksumMismatchError, got %v", err)
+	}
+
+	dec.ChecksumPolicy = ChecksumSkip
+	if _, err = dec.decode(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyFormatDescriptionChecksum(t *testing.T) {
+	body := make([]byte, 2+50+4+1)
+	binary.LittleEndian.PutUint16(body, 4)
+	copy(body[2:], "5.7.18-log")
+	body[56] = eventHeaderSize
+	body = append(body, 56, 13, 0, 8) // post header lengths
+	body = append(body, 1)            // checksum algorithm: CRC32
+
+	dec := &EventDecoder{ChecksumPolicy: ChecksumFail}
+	data := buildEvent(FormatDescriptionEventType, body, true)
+	if _, err := dec.decode(data); err != nil {
+		t.Fatal(err)
+	}
+	if !dec.format.checksumEnabled() {
+		t.Error("expected checksum enabled")
+	}
+
+	data[len(data)-1] ^= 0xff
+	if _, err := dec.decode(data); err == nil {
+		t.Fatal("expected checksum mismatch error")
+	}
+
+	// checksums disabled by the master
+	body[len(body)-1] = 0
+	data = buildEvent(FormatDescriptionEventType, body, true)
+	if _, err := dec.decode(data); err == nil {
+		t.Fatal("expected error for disabled checksums")
+	}
+	dec.ChecksumPolicy = ChecksumVerify
+	if _, err := dec.decode(data); err != nil {
+		t.Fatal(err)
+	}
+	if dec.format.ChecksumAlgorithm != ChecksumAlgorithmNone || dec.format.checksumEnabled() {
+		t.Errorf("expected checksum algorithm NONE, got %s", dec.format.ChecksumAlgorithm)
+	}
+
+	// unknown checksum algorithm
+	body[len(body)-1] = 2
+	if _, err := dec.decode(buildEvent(FormatDescriptionEventType, body, true)); err == nil {
+		t.Fatal("expected error for unknown checksum algorithm")
+	}
+}
+
+func TestDecodePreviousGtidsEvent(t *testing.T) {
+	body := make([]byte, 8+16+8+2*16)
+	binary.LittleEndian.PutUint64(body, 1)
+	copy(body[8:], []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62})
+	binary.LittleEndian.PutUint64(body[24:], 2)
+	binary.LittleEndian.PutUint64(body[32:], 1)
+	binary.LittleEndian.PutUint64(body[40:], 6)
+	binary.LittleEndian.PutUint64(body[48:], 7)
+	binary.LittleEndian.PutUint64(body[56:], 8)
+
+	dec := new(EventDecoder)
+	ev, err := dec.decode(buildEvent(PreviousGtidsEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7"
+	if s := ev.(*PreviousGtidsEvent).GTIDSet.String(); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+
+	if _, err = dec.decode(buildEvent(PreviousGtidsEventType, body[:40], false)); err == nil {
+		t.Error("expected error for truncated event")
+	}
+}
+
+func TestDecodeUserVarEvent(t *testing.T) {
+	dec := new(EventDecoder)
+	tests := []struct {
+		body     []byte
+		isNull   bool
+		expected interface{}
+	}{
+		{[]byte{1, 0, 0, 0, 'a', 1}, true, nil},
+		{[]byte{1, 0, 0, 0, 'a', 0, StringResultType, 33, 0, 0, 0, 3, 0, 0, 0, 'f', 'o', 'o'}, false, "foo"},
+		{[]byte{1, 0, 0, 0, 'a', 0, IntResultType, 63, 0, 0, 0, 8, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0}, false, int64(-1)},
+		{[]byte{1, 0, 0, 0, 'a', 0, IntResultType, 63, 0, 0, 0, 8, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 1}, false, uint64(1<<64 - 1)},
+		{[]byte{1, 0, 0, 0, 'a', 0, DecimalResultType, 63, 0, 0, 0, 4, 0, 0, 0, 4, 2, 0x80 | 12, 34}, false, 12.34},
+	}
+	for _, test := range tests {
+		ev, err := dec.decode(buildEvent(UserVarEventType, test.body, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := ev.(*UserVarEvent)
+		if string(e.Name) != "a" || e.IsNull != test.isNull || e.Value != test.expected {
+			t.Errorf("expected %v, got %#v", test.expected, e.Value)
+		}
+	}
+}
+
+func TestDecodeIntvarAndRandEvent(t *testing.T) {
+	dec := new(EventDecoder)
+	ev, err := dec.decode(buildEvent(IntvarEventType, []byte{byte(IntvarTypeInsertID), 42, 0, 0, 0, 0, 0, 0, 0}, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := ev.(*IntvarEvent); e.Type != IntvarTypeInsertID || e.Type.String() != "INSERT_ID" || e.Value != 42 {
+		t.Errorf("unexpected IntvarEvent %+v", e)
+	}
+
+	ev, err = dec.decode(buildEvent(RandEventType, []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0}, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := ev.(*RandEvent); e.Seed1 != 1 || e.Seed2 != 2 {
+		t.Errorf("unexpected RandEvent %+v", e)
+	}
+}
+
+func TestDecodeHeartbeatEvent(t *testing.T) {
+	dec := &EventDecoder{format: &FormatDescriptionEvent{ChecksumAlgorithm: ChecksumAlgorithmCRC32}}
+	ev, err := dec.decode(buildEvent(HeartbeatEventType, []byte("mysql-bin.000003"), true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hb, ok := ev.(*HeartbeatEvent)
+	if !ok {
+		t.Fatalf("expected *HeartbeatEvent, got %T", ev)
+	}
+	if string(hb.LogName) != "mysql-bin.000003" || hb.Header().NextLogPos != 1000 {
+		t.Errorf("unexpected heartbeat %s:%d", hb.LogName, hb.Header().NextLogPos)
+	}
+}
+
+func TestDecodeGtidEventLogicalClock(t *testing.T) {
+	body := make([]byte, 1+16+8+1+8+8)
+	body[0] = 1
+	body[1] = 0x3e
+	binary.LittleEndian.PutUint64(body[17:], 42)
+	body[25] = logicalClockTypeCode
+	binary.LittleEndian.PutUint64(body[26:], 5)
+	binary.LittleEndian.PutUint64(body[34:], 6)
+
+	dec := &EventDecoder{}
+	ev, err := dec.decode(buildEvent(GtidEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := ev.(*GtidEvent)
+	if gtid := e.GTID(); gtid != "3e000000-0000-0000-0000-000000000000:42" {
+		t.Errorf("unexpected gtid %s", gtid)
+	}
+	if e.LastCommitted != 5 || e.SequenceNumber != 6 {
+		t.Errorf("unexpected logical clock %d, %d", e.LastCommitted, e.SequenceNumber)
+	}
+
+	// 5.6 doesn't write the logical clock
+	ev, err = dec.decode(buildEvent(AnonymousGtidEventType, body[:25], false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := ev.(*AnonymousGtidEvent)
+	if a.GTID() != "ANONYMOUS" || a.LastCommitted != 0 {
+		t.Errorf("unexpected anonymous gtid event %s, %d", a.GTID(), a.LastCommitted)
+	}
+}
+
+func TestDecodeLoadQueryEvents(t *testing.T) {
+	dec := &EventDecoder{}
+	ev, err := dec.decode(buildEvent(BeginLoadQueryEventType, []byte{3, 0, 0, 0, '1', ',', '2', '\n'}, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	begin := ev.(*BeginLoadQueryEvent)
+	if begin.FileID != 3 || string(begin.BlockData) != "1,2\n" {
+		t.Errorf("unexpected begin load query event %d, %q", begin.FileID, begin.BlockData)
+	}
+
+	query := "LOAD DATA INFILE '/tmp/t.csv' REPLACE INTO TABLE t"
+	body := make([]byte, 13+13)
+	body[8] = 4 // database length
+	binary.LittleEndian.PutUint32(body[13:], 3)
+	binary.LittleEndian.PutUint32(body[17:], 17)
+	binary.LittleEndian.PutUint32(body[21:], 29)
+	body[25] = LoadDupReplace
+	body = append(body, "test\x00"+query...)
+	ev, err = dec.decode(buildEvent(ExecuteLoadQueryEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec := ev.(*ExecuteLoadQueryEvent)
+	if exec.FileID != 3 || exec.DupHandling != LoadDupReplace || string(exec.Database) != "test" || string(exec.Query) != query {
+		t.Errorf("unexpected execute load query event %+v", exec)
+	}
+	if name := exec.Query[exec.StartPos:exec.EndPos]; string(name) != "'/tmp/t.csv'" {
+		t.Errorf("unexpected file name %s", name)
+	}
+}
+
+func TestDecodeIncidentEvent(t *testing.T) {
+	body := append([]byte{1, 0, 11}, "LOST_EVENTS"...)
+	ev, err := (&EventDecoder{}).decode(buildEvent(IncidentEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := ev.(*IncidentEvent)
+	if e.Incident != IncidentLostEvents || string(e.Message) != "LOST_EVENTS" {
+		t.Errorf("unexpected incident %d: %s", e.Incident, e.Message)
+	}
+}
+
+func TestDecodeTruncatedEvent(t *testing.T) {
+	dec := &EventDecoder{}
+	_, err := dec.decode(buildEvent(XidEventType, []byte{1, 0, 0}, false))
+	if e, ok := err.(*DecodeError); !ok || e.Type != XidEventType || e.NextLogPos != 1000 || e.Err != mysql.ErrMalformPkt {
+		t.Fatalf("expected *DecodeError, got %v", err)
+	}
+
+	// a query event whose status variables are longer than the event
+	body := []byte{1, 0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 0xff, 0}
+	_, err = dec.decode(buildEvent(QueryEventType, body, false))
+	if _, ok := err.(*DecodeError); !ok {
+		t.Fatalf("expected *DecodeError, got %v", err)
+	}
+}
+
+func TestDecodeErrorPolicy(t *testing.T) {
+	data := buildEvent(XidEventType, []byte{1, 0, 0}, true)
+	var reported []byte
+	dec := &EventDecoder{
+		format:            &FormatDescriptionEvent{ChecksumAlgorithm: ChecksumAlgorithmCRC32},
+		DecodeErrorPolicy: DecodeErrorSkip,
+		OnDecodeError: func(err *DecodeError, data []byte) {
+			if err.Type != XidEventType {
+				t.Errorf("unexpected error %v", err)
+			}
+			reported = append([]byte(nil), data...)
+		},
+	}
+	if ev, err := dec.decode(data); ev != nil || err != nil {
+		t.Fatalf("expected the event skipped, got %v, %v", ev, err)
+	}
+	if !bytes.Equal(reported, data) {
+		t.Errorf("expected the raw event reported, got %x", reported)
+	}
+
+	dec.DecodeErrorPolicy = DecodeErrorUnsupported
+	ev, err := dec.decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := ev.(*UnsupportedEvent)
+	if !ok || e.Err == nil || e.Header().Type != XidEventType || !bytes.Equal(e.data, []byte{1, 0, 0}) {
+		t.Fatalf("unexpected event %#v", ev)
+	}
+
+	// the corrupted events are never skipped
+	data[len(data)-1]++
+	dec.ChecksumPolicy = ChecksumVerify
+	if _, err = dec.decode(data); err == nil {
+		t.Error("expected the checksum mismatch")
+	}
+}
+
+// customEvent is an event of a vendor extension decoded outside the package.
+type customEvent struct {
+	header *EventHeader
+	data   []byte
+}
+
+func (e *customEvent) Header() *EventHeader { return e.header }
+
+func (e *customEvent) Decode(dec *EventDecoder) error {
+	e.data = append([]byte{}, e.header.Body()...)
+	return nil
+}
+
+func (e *customEvent) Print(w io.Writer) {}
+
+func TestRegisterEventType(t *testing.T) {
+	const customType EventType = 200
+	RegisterEventType(customType, func(h *EventHeader) Event { return &customEvent{header: h} })
+	defer RegisterEventType(customType, nil)
+	// the types decoded by the package are not overridden
+	RegisterEventType(XidEventType, func(h *EventHeader) Event { return &customEvent{header: h} })
+	defer RegisterEventType(XidEventType, nil)
+
+	dec := &EventDecoder{format: &FormatDescriptionEvent{ChecksumAlgorithm: ChecksumAlgorithmCRC32}}
+	ev, err := dec.decode(buildEvent(customType, []byte("custom"), true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := ev.(*customEvent); !ok || string(e.data) != "custom" || e.header.Type != customType {
+		t.Errorf("expected the custom event, got %#v", ev)
+	}
+	if ev, err = dec.decode(buildEvent(XidEventType, []byte{7, 0, 0, 0, 0, 0, 0, 0}, true)); err != nil {
+		t.Fatal(err)
+	} else if _, ok := ev.(*XIDEvent); !ok {
+		t.Errorf("expected XIDEvent, got %T", ev)
+	}
+
+	RegisterEventType(customType, nil)
+	if ev, err = dec.decode(buildEvent(customType, []byte("custom"), true)); err != nil {
+		t.Fatal(err)
+	} else if _, ok := ev.(*UnsupportedEvent); !ok {
+		t.Errorf("expected UnsupportedEvent after the registration is removed, got %T", ev)
+	}
+}
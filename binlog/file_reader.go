@@ -0,0 +1,154 @@
+package binlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// FileReader reads the events from a local binlog file, e.g. the ones written by DumpTo.
+type FileReader struct {
+	// PoolBuffers reads the events into the buffers from a pool, see Streamer.PoolBuffers.
+	PoolBuffers bool
+	// Bounds limits the events read if not nil, Next returns ErrStopReached once a stop bound is reached.
+	// StopFile is compared with the base name of the file opened by OpenFile.
+	Bounds *Bounds
+
+	r       *bufio.Reader
+	c       io.Closer
+	name    string
+	dec     *EventDecoder
+	bounder *bounder
+}
+
+// NewFileReader returns a FileReader which reads the events from r with dec, the binlog magic header is verified.
+func NewFileReader(r io.Reader, dec *EventDecoder) (*FileReader, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(binlogMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, binlogMagic) {
+		return nil, fmt.Errorf("invalid binlog magic header %x", magic)
+	}
+	if dec.tables == nil {
+		dec.tables = make(map[uint64]*TableMapEvent)
+	}
+	return &FileReader{r: br, dec: dec}, nil
+}
+
+// OpenFile opens the binlog file with the name for reading.
+func OpenFile(name string, dec *EventDecoder) (*FileReader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewFileReader(f, dec)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.c, r.name = f, filepath.Base(name)
+	dec.logFile = r.name
+	return r, nil
+}
+
+// Next reads the next event, the events filtered out by the decoder or before the start bounds are skipped.
+// It returns io.EOF at the end of the file, or ErrStopReached at a stop bound.
+func (r *FileReader) Next() (Event, error) {
+	if r.Bounds != nil && r.bounder == nil {
+		r.bounder = newBounder(r.Bounds, r.name)
+	}
+	for {
+		if r.bounder != nil && r.bounder.stopped {
+			return nil, ErrStopReached
+		}
+		header := make([]byte, eventHeaderSize)
+		if _, err := io.ReadFull(r.r, header); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil, fmt.Errorf("truncated event header: %v", err)
+			}
+			return nil, err
+		}
+		size := binary.LittleEndian.Uint32(header[9:])
+		if size < eventHeaderSize {
+			return nil, fmt.Errorf("invalid event size %d", size)
+		}
+		if r.bounder != nil && r.bounder.reached(binary.LittleEndian.Uint32(header), size, binary.LittleEndian.Uint32(header[13:])) {
+			return nil, ErrStopReached
+		}
+		var buf *[]byte
+		var data []byte
+		if r.PoolBuffers {
+			buf = getBuffer()
+			if cap(*buf) < int(size) {
+				*buf = make([]byte, size)
+			}
+			*buf = (*buf)[:size]
+			data = *buf
+		} else {
+			data = make([]byte, size)
+		}
+		copy(data, header)
+		if _, err := io.ReadFull(r.r, data[eventHeaderSize:]); err != nil {
+			return nil, fmt.Errorf("truncated event: %v", err)
+		}
+
+		ev, err := r.dec.decode(data)
+		if err != nil {
+			return nil, err
+		}
+		if buf != nil {
+			holdBuffer(ev, EventType(header[4]), buf)
+		}
+		if ev == nil {
+			continue
+		}
+		if r.bounder != nil {
+			if r.bounder.skip(ev) {
+				Release(ev)
+				continue
+			}
+			r.bounder.replayed(ev)
+		}
+		return ev, nil
+	}
+}
+
+// Close closes the file opened by OpenFile.
+func (r *FileReader) Close() error {
+	if r.c == nil {
+		return nil
+	}
+	return r.c.Close()
+}
+
+// ReadAll reads the rest of the events until the end of the file or a stop bound.
+func (r *FileReader) ReadAll() ([]Event, error) {
+	var events []Event
+	for {
+		ev, err := r.Next()
+		if err == io.EOF || err == ErrStopReached {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, ev)
+	}
+}
+
+// ReadFile reads all the events of the binlog file with dec, e.g. the fixtures in testdata.
+// The events read before an error are returned with it.
+func ReadFile(name string, dec *EventDecoder) ([]Event, error) {
+	r, err := OpenFile(name, dec)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return r.ReadAll()
+}
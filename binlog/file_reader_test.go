@@ -0,0 +1,233 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFileReader(t *testing.T) {
+	fde := make([]byte, 2+50+4+1)
+	binary.LittleEndian.PutUint16(fde, 4)
+	copy(fde[2:], "5.7.18-log")
+	fde[56] = eventHeaderSize
+	fde = append(fde, 56, 13, 0, 8, 1)
+
+	data := append([]byte{}, binlogMagic...)
+	data = append(data, buildEvent(FormatDescriptionEventType, fde, true)...)
+	data = append(data, buildEvent(XidEventType, []byte{7, 0, 0, 0, 0, 0, 0, 0}, true)...)
+
+	r, err := NewFileReader(bytes.NewReader(data), &EventDecoder{ChecksumPolicy: ChecksumVerify})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev, err := r.Next(); err != nil {
+		t.Fatal(err)
+	} else if _, ok := ev.(*FormatDescriptionEvent); !ok {
+		t.Fatalf("expected FormatDescriptionEvent, got %T", ev)
+	}
+	if ev, err := r.Next(); err != nil {
+		t.Fatal(err)
+	} else if xid, ok := ev.(*XIDEvent); !ok || xid.TransactionID != 7 {
+		t.Fatalf("unexpected event %#v", ev)
+	}
+	if _, err = r.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+
+	if _, err = NewFileReader(bytes.NewReader([]byte("not a binlog")), &EventDecoder{}); err == nil {
+		t.Error("expected error for invalid magic header")
+	}
+
+	r, err = NewFileReader(bytes.NewReader(data[:len(data)-1]), &EventDecoder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Next()
+	if _, err = r.Next(); err == nil || err == io.EOF {
+		t.Errorf("expected error for truncated event, got %v", err)
+	}
+}
+
+func TestFileReaderPoolBuffers(t *testing.T) {
+	data := append([]byte{}, binlogMagic...)
+	for i := 1; i <= 3; i++ {
+		data = append(data, buildEvent(XidEventType, []byte{byte(i), 0, 0, 0, 0, 0, 0, 0}, false)...)
+	}
+
+	r, err := NewFileReader(bytes.NewReader(data), &EventDecoder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.PoolBuffers = true
+	for i := 1; i <= 3; i++ {
+		ev, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if xid := ev.(*XIDEvent); xid.TransactionID != uint64(i) || xid.buf == nil {
+			t.Fatalf("unexpected event %#v", ev)
+		}
+		Release(ev)
+		Release(ev)
+		if ev.(*XIDEvent).buf != nil {
+			t.Error("expected the buffer released")
+		}
+	}
+}
+
+func TestFileReaderBounds(t *testing.T) {
+	data := append([]byte{}, binlogMagic...)
+	for i := 1; i <= 5; i++ {
+		ev := buildEvent(XidEventType, []byte{byte(i), 0, 0, 0, 0, 0, 0, 0}, false)
+		binary.LittleEndian.PutUint32(ev, uint32(1500000000+i))
+		binary.LittleEndian.PutUint32(ev[13:], uint32(len(data)+len(ev)))
+		data = append(data, ev...)
+	}
+	// the offset of the 3rd event
+	pos := uint32(len(binlogMagic) + 2*(eventHeaderSize+8))
+
+	for _, c := range []struct {
+		bounds   Bounds
+		expected []uint64
+		err      error
+	}{
+		{Bounds{StopPos: pos}, []uint64{1, 2}, ErrStopReached},
+		{Bounds{StartPos: pos}, []uint64{3, 4, 5}, io.EOF},
+		{Bounds{StopEvents: 2}, []uint64{1, 2}, ErrStopReached},
+		{Bounds{StartTime: time.Unix(1500000002, 0), StopTime: time.Unix(1500000004, 0)}, []uint64{2, 3}, ErrStopReached},
+	} {
+		r, err := NewFileReader(bytes.NewReader(data), &EventDecoder{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Bounds = &c.bounds
+		var xids []uint64
+		for {
+			ev, err := r.Next()
+			if err != nil {
+				if err != c.err {
+					t.Errorf("%+v: expected %v, got %v", c.bounds, c.err, err)
+				}
+				break
+			}
+			xids = append(xids, ev.(*XIDEvent).TransactionID)
+		}
+		if !reflect.DeepEqual(xids, c.expected) {
+			t.Errorf("%+v: expected %v, got %v", c.bounds, c.expected, xids)
+		}
+		if _, err = r.Next(); err != c.err {
+			t.Errorf("%+v: expected %v again, got %v", c.bounds, c.err, err)
+		}
+	}
+}
+
+func TestFileReaderPositions(t *testing.T) {
+	f := fixtures[2]
+	dec := &EventDecoder{ChecksumPolicy: ChecksumVerify}
+	events, err := ReadFile(f.path(".bin"), dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pos, gtid := uint32(len(binlogMagic)), ""
+	for _, ev := range events {
+		h := ev.Header()
+		if h.LogFile != "mysql-5.7.bin" || h.LogPos() != pos {
+			t.Errorf("%s: expected position mysql-5.7.bin:%d, got %s:%d", h.Type, pos, h.LogFile, h.LogPos())
+		}
+		if e, ok := ev.(*GtidEvent); ok {
+			gtid = e.GTID()
+		}
+		if h.GTID != gtid {
+			t.Errorf("%s: expected GTID %q, got %q", h.Type, gtid, h.GTID)
+		}
+		if h.Type == XidEventType {
+			gtid = ""
+		}
+		pos = h.NextLogPos
+	}
+
+	// the events after the RotateEvent are in the next file
+	ev, err := dec.decode(buildEvent(XidEventType, []byte{7, 0, 0, 0, 0, 0, 0, 0}, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := ev.Header(); h.LogFile != "mysql-bin.000002" || h.GTID != "" {
+		t.Errorf("expected the event in mysql-bin.000002 without GTID, got %s %q", h.LogFile, h.GTID)
+	}
+}
+
+func TestEventDecoderTrackGTID(t *testing.T) {
+	header := func(typ EventType) *baseEvent { return &baseEvent{header: &EventHeader{Type: typ}} }
+	query := func(q string) Event {
+		return &QueryEvent{baseEvent: header(QueryEventType), StatusVars: []byte{}, Database: []byte("test"), Query: []byte(q)}
+	}
+	var sid [16]byte
+	sid[15] = 1
+	gtid := "00000000-0000-0000-0000-000000000001:3"
+	// a multi-statement transaction in STATEMENT format, followed by an XA transaction
+	events := []Event{
+		&GtidEvent{baseEvent: header(GtidEventType), sid: sid, gno: 3},
+		query("BEGIN"),
+		query("INSERT INTO t VALUES (1)"),
+		query("UPDATE t SET id = 2"),
+		query("COMMIT"),
+		query("XA START 'x'"),
+		query("INSERT INTO t VALUES (3)"),
+		query("XA END 'x'"),
+	}
+	expected := []string{gtid, gtid, gtid, gtid, gtid, "", "", ""}
+
+	enc, dec := &EventEncoder{}, &EventDecoder{}
+	for i, ev := range events {
+		packet, err := enc.Encode(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := dec.decode(packet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h := decoded.Header(); h.GTID != expected[i] {
+			t.Errorf("event %d: expected GTID %q, got %q", i, expected[i], h.GTID)
+		}
+	}
+	if !dec.boundary.begun {
+		t.Error("expected the XA transaction not to end at XA END")
+	}
+}
+
+func TestEventDecoderReset(t *testing.T) {
+	dec := NewEventDecoder()
+	r, err := OpenFile(fixtures[3].path(".bin"), dec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	for {
+		ev, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := ev.(*TableMapEvent); ok {
+			break
+		}
+	}
+	if format := dec.FormatDescription(); format == nil || string(format.ServerVersion) != "8.0.30" {
+		t.Errorf("unexpected FormatDescriptionEvent %v", format)
+	}
+	if table := dec.TableMap(108); table == nil || string(table.TableName) != "all_types" {
+		t.Errorf("unexpected TableMapEvent %v", table)
+	}
+
+	dec.Reset()
+	if dec.FormatDescription() != nil || dec.TableMap(108) != nil || dec.logFile != "" || dec.gtid != "" {
+		t.Error("expected the state cleared by Reset")
+	}
+	if dec.ChecksumPolicy != ChecksumVerify {
+		t.Errorf("expected the options kept, got %d", dec.ChecksumPolicy)
+	}
+}
@@ -0,0 +1,55 @@
+package binlog
+
+import (
+	"errors"
+	"regexp"
+)
+
+// errEventFiltered is returned by the decoding of events which are filtered out.
+var errEventFiltered = errors.New("event filtered")
+
+// EventFilter filters the events before they're fully decoded, the rows of the filtered tables are not parsed at all.
+// FormatDescriptionEvent, RotateEvent and HeartbeatEvent are never filtered.
+type EventFilter struct {
+	// EventTypes are the allowed event types, all types are allowed if it's empty.
+	EventTypes []EventType
+	// IncludeTables are the regexps matching "database.table" of the included tables, all tables are included
+	// if it's empty. ExcludeTables excludes the tables from them.
+	IncludeTables []*regexp.Regexp
+	ExcludeTables []*regexp.Regexp
+}
+
+func (f *EventFilter) allowType(typ EventType) bool {
+	if f == nil || len(f.EventTypes) == 0 || typ == FormatDescriptionEventType || typ == RotateEventType ||
+		typ == HeartbeatEventType || typ == TransactionPayloadEventType {
+		// the events embedded in TransactionPayloadEvent are filtered one by one
+		return true
+	}
+	for _, t := range f.EventTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *EventFilter) includeTable(database, table string) bool {
+	if f == nil || len(f.IncludeTables) == 0 && len(f.ExcludeTables) == 0 {
+		return true
+	}
+	name := database + "." + table
+	for _, re := range f.ExcludeTables {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	if len(f.IncludeTables) == 0 {
+		return true
+	}
+	for _, re := range f.IncludeTables {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
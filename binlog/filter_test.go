@@ -0,0 +1,53 @@
+package binlog
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestEventFilter(t *testing.T) {
+	dec := &EventDecoder{
+		tables: make(map[uint64]*TableMapEvent),
+		Filter: &EventFilter{
+			EventTypes:    []EventType{WriteRowsEventType, XidEventType},
+			IncludeTables: []*regexp.Regexp{regexp.MustCompile(`^test\.`)},
+			ExcludeTables: []*regexp.Regexp{regexp.MustCompile(`^test\.tmp_`)},
+		},
+	}
+	tableMap := func(id byte, table string) []byte {
+		body := []byte{id, 0, 0, 0, 0, 0, 0, 0, 4, 't', 'e', 's', 't', 0, byte(len(table))}
+		body = append(append(body, table...), 0, 1, fieldTypeLong, 0, 0)
+		return buildEvent(TableMapEventType, body, false)
+	}
+	rows := func(id byte) []byte {
+		// the row value is truncated, which fails if it's parsed
+		return buildEvent(WriteRowsEventType, []byte{id, 0, 0, 0, 0, 0, 0, 0, 2, 0, 1, 1, 0, 1}, false)
+	}
+
+	for i, test := range []struct {
+		data     []byte
+		filtered bool
+	}{
+		{buildEvent(QueryEventType, make([]byte, 14), false), true},
+		{buildEvent(XidEventType, make([]byte, 8), false), false},
+		{tableMap(1, "t"), true}, // TableMapEventType is not allowed
+		{tableMap(2, "tmp_t"), true},
+		{rows(2), true},
+	} {
+		ev, err := dec.decode(test.data)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if (ev == nil) != test.filtered {
+			t.Errorf("%d: expected filtered %t, got %v", i, test.filtered, ev)
+		}
+	}
+
+	// the table map of the included table is kept for its rows
+	if table := dec.tables[1]; table == nil || table.filtered {
+		t.Errorf("expected table map of test.t kept, got %v", table)
+	}
+	if dec.Filter.includeTable("other", "t") || !dec.Filter.includeTable("test", "t") {
+		t.Error("unexpected table inclusion")
+	}
+}
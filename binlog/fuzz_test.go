@@ -0,0 +1,91 @@
+//go:build go1.18
+// +build go1.18
+
+package binlog
+
+import (
+	"testing"
+)
+
+// fuzzFormat is the FormatDescriptionEvent of 5.7 without checksums, so that the post header lengths of
+// all the event types are known to the decoder.
+func fuzzFormat() []byte {
+	fde := make([]byte, 2+50+4+1)
+	fde[0] = 4
+	copy(fde[2:], "5.7.18-log")
+	fde[56] = eventHeaderSize
+	fde = append(fde, 56, 13, 0, 8, 0, 18, 0, 4, 4, 4, 4, 18, 0, 0, 95, 0, 4, 26, 8, 0, 0, 0, 8, 8, 8, 2, 0, 0, 0,
+		10, 10, 10, 42, 42, 0, 18, 52, 0, 0)
+	return buildEvent(FormatDescriptionEventType, fde, true)
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(buildEvent(XidEventType, []byte{7, 0, 0, 0, 0, 0, 0, 0}, false))
+	f.Add(buildEvent(QueryEventType, append([]byte{1, 0, 0, 0, 0, 0, 0, 0, 4, 0, 0, 0, 0}, "test\x00BEGIN"...), false))
+	f.Add(buildEvent(RotateEventType, append([]byte{4, 0, 0, 0, 0, 0, 0, 0}, "mysql-bin.000002"...), false))
+	f.Add(buildEvent(TableMapEventType, []byte{1, 0, 0, 0, 0, 0, 1, 0, 4, 't', 'e', 's', 't', 0, 1, 't', 0,
+		3, fieldTypeLong, fieldTypeVarChar, fieldTypeBLOB, 3, 40, 0, 2, 6}, false))
+	f.Add(buildEvent(WriteRowsEventType, []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 3, 7, 0, 7, 0, 0, 0, 1, 'a', 1, 0, 'b'}, false))
+	f.Add(buildEvent(UpdateRowsEventType, []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 3, 7, 7, 0, 7, 0, 0, 0, 1, 'a', 1, 0, 'b',
+		0, 8, 0, 0, 0, 1, 'c', 1, 0, 'd'}, false))
+	f.Add(buildEvent(GtidEventType, make([]byte, 42), false))
+	f.Add(buildEvent(PreviousGtidsEventType, []byte{0, 0, 0, 0, 0, 0, 0, 0}, false))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		dec := &EventDecoder{ParseJSON: true, ParseGeometry: true, ParseTime: true, DecimalFormat: DecimalRat,
+			tables: make(map[uint64]*TableMapEvent)}
+		if _, err := dec.decode(fuzzFormat()); err != nil {
+			t.Fatal(err)
+		}
+		// the table map decoded first makes the rows events decoded against it
+		dec.decode(buildEvent(TableMapEventType, []byte{1, 0, 0, 0, 0, 0, 1, 0, 4, 't', 'e', 's', 't', 0, 1, 't', 0,
+			3, fieldTypeLong, fieldTypeVarChar, fieldTypeBLOB, 3, 40, 0, 2, 6}, false))
+		ev, err := dec.decode(data)
+		// the decoder recovers the panics of the malformed events, which are bugs to report still
+		if de, ok := err.(*DecodeError); ok {
+			if pe, ok := de.Err.(*panicError); ok {
+				t.Fatalf("decoding panicked: %v\n%s", pe.value, pe.stack)
+			}
+		}
+		if err == nil && ev != nil {
+			ev.Print(discard{})
+		}
+	})
+}
+
+func FuzzReadTableColumnValue(f *testing.F) {
+	f.Add(fieldTypeLong, uint16(0), []byte{7, 0, 0, 0})
+	f.Add(fieldTypeVarChar, uint16(40), []byte{1, 'a'})
+	f.Add(fieldTypeNewDecimal, uint16(10<<8|2), []byte{0x80, 0, 0, 0, 0x0c, 0x32})
+	f.Add(fieldTypeDateTimeV2, uint16(6), []byte{0x99, 0x9c, 0xde, 0x95, 0x1e, 0, 0, 0})
+	f.Add(fieldTypeTimeV2, uint16(3), []byte{0x80, 0x10, 0x83, 0, 0})
+	f.Add(fieldTypeJSON, uint16(4), []byte{3, 0, 0, 0, 12, 1, 'a'})
+	f.Add(fieldTypeBLOB, uint16(2), []byte{2, 0, 1, 2})
+	f.Add(fieldTypeGeometry, uint16(4), []byte{25, 0, 0, 0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xf0, 0x3f,
+		0, 0, 0, 0, 0, 0, 0, 0x40})
+	f.Add(fieldTypeBit, uint16(1<<8|3), []byte{1, 2})
+	f.Add(fieldTypeString, uint16(fieldTypeEnum)<<8|1, []byte{1})
+
+	decoders := []*EventDecoder{nil, {ParseJSON: true, ParseGeometry: true, ParseTime: true, DecimalFormat: DecimalString,
+		InvalidTemporalPolicy: InvalidTemporalMarker}}
+	f.Fuzz(func(t *testing.T, typ byte, meta uint16, data []byte) {
+		for _, dec := range decoders {
+			newBinlogPacket(data).readTableColumnValue(dec, typ, meta, false)
+			newBinlogPacket(data).readTableColumnValue(dec, typ, meta, true)
+		}
+		// the sizes agree with the decoding
+		p := newBinlogPacket(data)
+		if err := p.skipTableColumnValue(typ, meta); err == nil && p.Err() == nil {
+			q := newBinlogPacket(data)
+			if _, err := q.readTableColumnValue(nil, typ, meta, false); err == nil && q.Err() == nil && q.Pos() != p.Pos() {
+				t.Errorf("type %d meta %d: skipped %d bytes, read %d bytes", typ, meta, p.Pos(), q.Pos())
+			}
+		}
+	})
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) {
+	return len(p), nil
+}
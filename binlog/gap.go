@@ -0,0 +1,68 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/LightKool/mysql-go"
+)
+
+// GapError reports the events missing from the dump, which is detected by Streamer.DetectGaps.
+type GapError struct {
+	// File is the binlog file of the gap, Pos is where the missing events start and NextPos is where
+	// the event after them starts. They are 0 for the GTID gaps.
+	File         string
+	Pos, NextPos uint32
+	// Missing are the transactions missing from the GTID sequence of a source server, nil for the position gaps.
+	Missing mysql.GTIDSet
+}
+
+func (e *GapError) Error() string {
+	if e.Missing != nil {
+		return fmt.Sprintf("binlog gap: transactions %s are missing", e.Missing)
+	}
+	return fmt.Sprintf("binlog gap: events between %s:%d and %s:%d are missing", e.File, e.Pos, e.File, e.NextPos)
+}
+
+// detectGap checks that the event packet starts where the last one ends in the file and the GTID of the event
+// follows the last one of its source server, ev is nil if it's filtered out. The position is checked from the
+// first event of every connection and file, and only by the position dump, since the master skips the consumed
+// transactions of the GTID dump.
+func (s *Streamer) detectGap(packet []byte, ev Event) *GapError {
+	var gap *GapError
+	size, next := binary.LittleEndian.Uint32(packet[9:]), binary.LittleEndian.Uint32(packet[13:])
+	flags := binary.LittleEndian.Uint16(packet[17:])
+	// NextLogPos is 0 for the artificial events and the FormatDescriptionEvent resent in the middle of a file
+	if !s.gtidMode && next > 0 && flags&logEventArtificialFlag == 0 {
+		if start := next - size; s.gapPos > 0 && start > s.gapPos {
+			gap = &GapError{File: s.file, Pos: s.gapPos, NextPos: start}
+		}
+		s.gapPos = next
+	}
+
+	if _, ok := ev.(*RotateEvent); ok {
+		// the events of the next file are checked from its first one
+		s.gapPos = 0
+	}
+	// the GtidEvents are decoded even if they're filtered out
+	if e := s.dec.txGtid; e != nil && EventType(packet[4]) == GtidEventType {
+		if s.gapGNOs == nil {
+			s.gapGNOs = make(map[mysql.SID]uint64)
+		}
+		last, ok := s.gapGNOs[e.sid]
+		if ok && e.gno > last+1 {
+			missing := mysql.GTIDSet{{SID: e.sid, Intervals: []mysql.GTIDInterval{{Start: int64(last + 1), Stop: int64(e.gno)}}}}
+			if s.gtidMode {
+				// the consumed transactions are skipped by the master
+				missing = missing.Subtract(s.gtids)
+			}
+			if len(missing) > 0 && gap == nil {
+				gap = &GapError{Missing: missing}
+			}
+		}
+		if e.gno > last {
+			s.gapGNOs[e.sid] = e.gno
+		}
+	}
+	return gap
+}
@@ -0,0 +1,301 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// WKB geometry types
+const (
+	wkbPoint uint32 = iota + 1
+	wkbLineString
+	wkbPolygon
+	wkbMultiPoint
+	wkbMultiLineString
+	wkbMultiPolygon
+	wkbGeometryCollection
+)
+
+var errGeometryTruncated = errors.New("geometry data truncated")
+
+// Geometry is a GEOMETRY value, which is stored by MySQL as SRID(4 bytes) + WKB.
+type Geometry struct {
+	SRID  uint32
+	Shape Shape
+}
+
+// String returns the WKT representation of the geometry.
+func (g *Geometry) String() string {
+	return g.Shape.WKT()
+}
+
+// Shape is one of Point, LineString, Polygon, MultiPoint, MultiLineString, MultiPolygon and GeometryCollection.
+type Shape interface {
+	// WKT returns the well-known text representation of the shape.
+	WKT() string
+}
+
+type Point struct {
+	X, Y float64
+}
+
+type LineString []Point
+
+type Polygon []LineString
+
+type MultiPoint []Point
+
+type MultiLineString []LineString
+
+type MultiPolygon []Polygon
+
+type GeometryCollection []Shape
+
+func (p Point) WKT() string {
+	var buf bytes.Buffer
+	buf.WriteString("POINT(")
+	p.writeCoordinates(&buf)
+	buf.WriteByte(')')
+	return buf.String()
+}
+
+func (p Point) writeCoordinates(buf *bytes.Buffer) {
+	buf.WriteString(strconv.FormatFloat(p.X, 'g', -1, 64))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatFloat(p.Y, 'g', -1, 64))
+}
+
+func (l LineString) WKT() string {
+	var buf bytes.Buffer
+	buf.WriteString("LINESTRING")
+	l.writeCoordinates(&buf)
+	return buf.String()
+}
+
+func (l LineString) writeCoordinates(buf *bytes.Buffer) {
+	buf.WriteByte('(')
+	for i, p := range l {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		p.writeCoordinates(buf)
+	}
+	buf.WriteByte(')')
+}
+
+func (p Polygon) WKT() string {
+	var buf bytes.Buffer
+	buf.WriteString("POLYGON")
+	p.writeCoordinates(&buf)
+	return buf.String()
+}
+
+func (p Polygon) writeCoordinates(buf *bytes.Buffer) {
+	buf.WriteByte('(')
+	for i, ring := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		ring.writeCoordinates(buf)
+	}
+	buf.WriteByte(')')
+}
+
+func (m MultiPoint) WKT() string {
+	var buf bytes.Buffer
+	buf.WriteString("MULTIPOINT")
+	LineString(m).writeCoordinates(&buf)
+	return buf.String()
+}
+
+func (m MultiLineString) WKT() string {
+	var buf bytes.Buffer
+	buf.WriteString("MULTILINESTRING")
+	Polygon(m).writeCoordinates(&buf)
+	return buf.String()
+}
+
+func (m MultiPolygon) WKT() string {
+	var buf bytes.Buffer
+	buf.WriteString("MULTIPOLYGON(")
+	for i, p := range m {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		p.writeCoordinates(&buf)
+	}
+	buf.WriteByte(')')
+	return buf.String()
+}
+
+func (c GeometryCollection) WKT() string {
+	var buf bytes.Buffer
+	buf.WriteString("GEOMETRYCOLLECTION(")
+	for i, s := range c {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(s.WKT())
+	}
+	buf.WriteByte(')')
+	return buf.String()
+}
+
+// ParseGeometry parses a GEOMETRY value in the MySQL internal format: SRID(4 bytes) + WKB.
+func ParseGeometry(data []byte) (*Geometry, error) {
+	if len(data) < 4 {
+		return nil, errGeometryTruncated
+	}
+	r := &wkbReader{data: data[4:]}
+	shape, err := r.readShape()
+	if err != nil {
+		return nil, err
+	}
+	return &Geometry{SRID: binary.LittleEndian.Uint32(data), Shape: shape}, nil
+}
+
+// wkbReader reads the well-known binary representation of geometries.
+type wkbReader struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+}
+
+func (r *wkbReader) readUint32() (uint32, error) {
+	if r.pos+4 > len(r.data) {
+		return 0, errGeometryTruncated
+	}
+	u := r.order.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return u, nil
+}
+
+func (r *wkbReader) readPoint() (p Point, err error) {
+	if r.pos+16 > len(r.data) {
+		return p, errGeometryTruncated
+	}
+	p.X = math.Float64frombits(r.order.Uint64(r.data[r.pos:]))
+	p.Y = math.Float64frombits(r.order.Uint64(r.data[r.pos+8:]))
+	r.pos += 16
+	return p, nil
+}
+
+func (r *wkbReader) readCount(elemSize int) (int, error) {
+	n, err := r.readUint32()
+	if err != nil {
+		return 0, err
+	}
+	// every element takes at least elemSize bytes, avoid allocating huge slices for corrupted data
+	if int(n) < 0 || int(n) > (len(r.data)-r.pos)/elemSize {
+		return 0, errGeometryTruncated
+	}
+	return int(n), nil
+}
+
+func (r *wkbReader) readLineString() (LineString, error) {
+	n, err := r.readCount(16)
+	if err != nil {
+		return nil, err
+	}
+	l := make(LineString, n)
+	for i := range l {
+		if l[i], err = r.readPoint(); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+func (r *wkbReader) readPolygon() (Polygon, error) {
+	n, err := r.readCount(4)
+	if err != nil {
+		return nil, err
+	}
+	p := make(Polygon, n)
+	for i := range p {
+		if p[i], err = r.readLineString(); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// readShape reads a geometry: byte order(1 byte) + type(4 bytes) + coordinates.
+func (r *wkbReader) readShape() (Shape, error) {
+	if r.pos >= len(r.data) {
+		return nil, errGeometryTruncated
+	}
+	switch r.data[r.pos] {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		return nil, fmt.Errorf("invalid WKB byte order: %d", r.data[r.pos])
+	}
+	r.pos++
+
+	typ, err := r.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case wkbPoint:
+		return r.readPoint()
+	case wkbLineString:
+		return r.readLineString()
+	case wkbPolygon:
+		return r.readPolygon()
+	case wkbMultiPoint, wkbMultiLineString, wkbMultiPolygon, wkbGeometryCollection:
+		// the elements of collections are complete geometries with their own byte orders and types
+		n, err := r.readCount(5)
+		if err != nil {
+			return nil, err
+		}
+		shapes := make([]Shape, n)
+		for i := range shapes {
+			if shapes[i], err = r.readShape(); err != nil {
+				return nil, err
+			}
+		}
+		return collectShapes(typ, shapes)
+	default:
+		return nil, fmt.Errorf("unknown WKB geometry type: %d", typ)
+	}
+}
+
+func collectShapes(typ uint32, shapes []Shape) (Shape, error) {
+	var ok bool
+	switch typ {
+	case wkbMultiPoint:
+		m := make(MultiPoint, len(shapes))
+		for i, s := range shapes {
+			if m[i], ok = s.(Point); !ok {
+				return nil, fmt.Errorf("unexpected %T in MULTIPOINT", s)
+			}
+		}
+		return m, nil
+	case wkbMultiLineString:
+		m := make(MultiLineString, len(shapes))
+		for i, s := range shapes {
+			if m[i], ok = s.(LineString); !ok {
+				return nil, fmt.Errorf("unexpected %T in MULTILINESTRING", s)
+			}
+		}
+		return m, nil
+	case wkbMultiPolygon:
+		m := make(MultiPolygon, len(shapes))
+		for i, s := range shapes {
+			if m[i], ok = s.(Polygon); !ok {
+				return nil, fmt.Errorf("unexpected %T in MULTIPOLYGON", s)
+			}
+		}
+		return m, nil
+	default:
+		return GeometryCollection(shapes), nil
+	}
+}
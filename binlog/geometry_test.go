@@ -0,0 +1,73 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func appendWKBHeader(data []byte, typ uint32) []byte {
+	data = append(data, 1, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(data[len(data)-4:], typ)
+	return data
+}
+
+func appendUint32(data []byte, u uint32) []byte {
+	data = append(data, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(data[len(data)-4:], u)
+	return data
+}
+
+func appendPoint(data []byte, x, y float64) []byte {
+	data = append(data, make([]byte, 16)...)
+	binary.LittleEndian.PutUint64(data[len(data)-16:], math.Float64bits(x))
+	binary.LittleEndian.PutUint64(data[len(data)-8:], math.Float64bits(y))
+	return data
+}
+
+func TestParseGeometry(t *testing.T) {
+	point := appendPoint(appendWKBHeader(appendUint32(nil, 4326), wkbPoint), 1, 2.5)
+
+	lineString := appendWKBHeader(appendUint32(nil, 0), wkbLineString)
+	lineString = appendPoint(appendPoint(appendUint32(lineString, 2), 0, 0), 1, 1)
+
+	polygon := appendWKBHeader(appendUint32(nil, 0), wkbPolygon)
+	polygon = appendUint32(appendUint32(polygon, 1), 4)
+	polygon = appendPoint(appendPoint(appendPoint(appendPoint(polygon, 0, 0), 1, 0), 1, 1), 0, 0)
+
+	multiPoint := appendWKBHeader(appendUint32(nil, 0), wkbMultiPoint)
+	multiPoint = appendUint32(multiPoint, 2)
+	multiPoint = appendPoint(appendWKBHeader(multiPoint, wkbPoint), 1, 2)
+	multiPoint = appendPoint(appendWKBHeader(multiPoint, wkbPoint), 3, 4)
+
+	collection := appendWKBHeader(appendUint32(nil, 0), wkbGeometryCollection)
+	collection = appendUint32(collection, 2)
+	collection = appendPoint(appendWKBHeader(collection, wkbPoint), 1, 2)
+	collection = appendWKBHeader(collection, wkbLineString)
+	collection = appendPoint(appendPoint(appendUint32(collection, 2), 0, 0), 1, 1)
+
+	tests := []struct {
+		data     []byte
+		srid     uint32
+		expected string
+	}{
+		{point, 4326, "POINT(1 2.5)"},
+		{lineString, 0, "LINESTRING(0 0,1 1)"},
+		{polygon, 0, "POLYGON((0 0,1 0,1 1,0 0))"},
+		{multiPoint, 0, "MULTIPOINT(1 2,3 4)"},
+		{collection, 0, "GEOMETRYCOLLECTION(POINT(1 2),LINESTRING(0 0,1 1))"},
+	}
+	for _, test := range tests {
+		g, err := ParseGeometry(test.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.SRID != test.srid || g.String() != test.expected {
+			t.Errorf("expected SRID %d %s, got SRID %d %s", test.srid, test.expected, g.SRID, g)
+		}
+	}
+
+	if _, err := ParseGeometry(point[:len(point)-1]); err == nil {
+		t.Error("expected error for truncated geometry")
+	}
+}
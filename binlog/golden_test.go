@@ -0,0 +1,70 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// fixture is a binlog in testdata captured from a MySQL version, which covers the supported field and event types.
+// The binlogs are captured by TestCaptureFixtures of binlog/integration from the servers run in Docker and never
+// written by the tests here, -update rewrites only the golden files, which are the events read back in JSON,
+// one per line.
+type fixture struct {
+	// name is the base name of the files in testdata
+	name    string
+	version string
+}
+
+var fixtures = []fixture{
+	{"mysql-5.5", "5.5.62"},
+	{"mysql-5.6", "5.6.51"},
+	{"mysql-5.7", "5.7.38"},
+	{"mysql-8.0", "8.0.30"},
+}
+
+func (f *fixture) path(ext string) string {
+	return filepath.Join("testdata", f.name+ext)
+}
+
+func TestGoldenFiles(t *testing.T) {
+	for _, f := range fixtures {
+		dec := &EventDecoder{ChecksumPolicy: ChecksumVerify, ParseJSON: true, ParseGeometry: true, DecimalFormat: DecimalString}
+		events, err := ReadFile(f.path(".bin"), dec)
+		if err != nil {
+			t.Fatalf("%s: %v", f.version, err)
+		}
+		var buf bytes.Buffer
+		for _, ev := range events {
+			if _, ok := ev.(*UnsupportedEvent); ok {
+				t.Errorf("%s: unexpected unsupported event %s", f.version, ev.Header().Type)
+			}
+			b, err := json.Marshal(ev)
+			if err != nil {
+				t.Fatalf("%s: %v", f.version, err)
+			}
+			buf.Write(b)
+			buf.WriteByte('\n')
+		}
+
+		golden := f.path(".golden")
+		if *update {
+			if err := ioutil.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		expected, err := ioutil.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Errorf("%s: the events differ from %s, rerun with -update if the change is expected:\n%s", f.version, golden, buf.Bytes())
+		}
+	}
+}
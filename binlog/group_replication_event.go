@@ -0,0 +1,129 @@
+package binlog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/LightKool/mysql-go"
+)
+
+var (
+	errTruncatedSet      = errors.New("read/write set truncated")
+	errTruncatedCertInfo = errors.New("certification info truncated")
+)
+
+// TransactionContextEvent carries the certification information of a transaction in Group Replication.
+type TransactionContextEvent struct {
+	*baseEvent
+	ServerUUID      []byte
+	ThreadID        uint32
+	GTIDSpecified   bool
+	SnapshotVersion mysql.GTIDSet
+	WriteSet        [][]byte
+	ReadSet         [][]byte
+}
+
+func (e *TransactionContextEvent) Decode(dec *EventDecoder) (err error) {
+	packet := e.header.packet
+	serverUUIDLen := packet.readByte()
+	e.ThreadID = packet.readUint32()
+	e.GTIDSpecified = packet.readByte() != 0
+	snapshotVersionLen := packet.readUint32()
+	writeSetLen := packet.readUint32()
+	readSetLen := packet.readUint32()
+
+	e.ServerUUID = packet.Read(int(serverUUIDLen))
+	if e.SnapshotVersion, err = mysql.DecodeGTIDSet(packet.Read(int(snapshotVersionLen))); err != nil {
+		return err
+	}
+	if e.WriteSet, err = readItems(packet, writeSetLen); err != nil {
+		return err
+	}
+	e.ReadSet, err = readItems(packet, readSetLen)
+	return
+}
+
+func (e *TransactionContextEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "Server UUID: %s\n", e.ServerUUID)
+	fmt.Fprintf(w, "Thread ID: %d\n", e.ThreadID)
+	fmt.Fprintf(w, "GTID specified: %t\n", e.GTIDSpecified)
+	fmt.Fprintf(w, "Snapshot version: %s\n", e.SnapshotVersion)
+	fmt.Fprintf(w, "Write set: %q\n", e.WriteSet)
+	fmt.Fprintf(w, "Read set: %q\n", e.ReadSet)
+	fmt.Fprintln(w)
+}
+
+// readItems reads n items prefixed with their 2 bytes length.
+func readItems(packet *binlogPacket, n uint32) ([][]byte, error) {
+	if uint64(n)*2 > uint64(packet.Len()-packet.Pos()) {
+		return nil, errTruncatedSet
+	}
+	items := make([][]byte, n)
+	for i := range items {
+		if packet.Len()-packet.Pos() < 2 {
+			return nil, errTruncatedSet
+		}
+		size := int(packet.readUint16())
+		if packet.Len()-packet.Pos() < size {
+			return nil, errTruncatedSet
+		}
+		items[i] = packet.Read(size)
+	}
+	return items, nil
+}
+
+// ViewChangeEvent is written when the membership of the group changes in Group Replication.
+type ViewChangeEvent struct {
+	*baseEvent
+	ViewID         []byte
+	SequenceNumber uint64
+	CertInfo       map[string][]byte
+}
+
+func (e *ViewChangeEvent) Decode(dec *EventDecoder) error {
+	packet := e.header.packet
+	e.ViewID = bytes.TrimRight(packet.Read(40), "\x00")
+	e.SequenceNumber = packet.readUint64()
+	n := packet.readUint32()
+	// every entry takes at least 6 bytes of the lengths
+	if uint64(n)*6 > uint64(packet.Len()-packet.Pos()) {
+		return errTruncatedCertInfo
+	}
+
+	e.CertInfo = make(map[string][]byte, n)
+	for i := uint32(0); i < n; i++ {
+		if packet.Len()-packet.Pos() < 2 {
+			return errTruncatedCertInfo
+		}
+		keyLen := int(packet.readUint16())
+		if packet.Len()-packet.Pos() < keyLen+4 {
+			return errTruncatedCertInfo
+		}
+		key := packet.Read(keyLen)
+		valueLen := int(packet.readUint32())
+		if packet.Len()-packet.Pos() < valueLen {
+			return errTruncatedCertInfo
+		}
+		e.CertInfo[string(key)] = packet.Read(valueLen)
+	}
+	return nil
+}
+
+func (e *ViewChangeEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "View ID: %s\n", e.ViewID)
+	fmt.Fprintf(w, "Sequence number: %d\n", e.SequenceNumber)
+	keys := make([]string, 0, len(e.CertInfo))
+	for key := range e.CertInfo {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "Certification info: %s=%s\n", key, e.CertInfo[key])
+	}
+	fmt.Fprintln(w)
+}
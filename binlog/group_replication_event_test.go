@@ -0,0 +1,54 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeTransactionContextEvent(t *testing.T) {
+	uuid := "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	body := make([]byte, 18)
+	body[0] = byte(len(uuid))
+	binary.LittleEndian.PutUint32(body[1:], 9)
+	body[5] = 1
+	binary.LittleEndian.PutUint32(body[6:], 8) // empty gtid set
+	binary.LittleEndian.PutUint32(body[10:], 2)
+	binary.LittleEndian.PutUint32(body[14:], 0)
+	body = append(body, uuid...)
+	body = append(body, 0, 0, 0, 0, 0, 0, 0, 0)
+	body = append(body, 2, 0, 'k', '1', 3, 0, 'k', '2', '2')
+
+	ev, err := (&EventDecoder{}).decode(buildEvent(TransactionContextEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := ev.(*TransactionContextEvent)
+	if string(e.ServerUUID) != uuid || e.ThreadID != 9 || !e.GTIDSpecified || len(e.SnapshotVersion) != 0 {
+		t.Errorf("unexpected transaction context %+v", e)
+	}
+	if len(e.WriteSet) != 2 || string(e.WriteSet[0]) != "k1" || string(e.WriteSet[1]) != "k22" || len(e.ReadSet) != 0 {
+		t.Errorf("unexpected write set %q, read set %q", e.WriteSet, e.ReadSet)
+	}
+
+	_, err = (&EventDecoder{}).decode(buildEvent(TransactionContextEventType, body[:len(body)-1], false))
+	if e, ok := err.(*DecodeError); !ok || e.Err != errTruncatedSet {
+		t.Errorf("expected errTruncatedSet, got %v", err)
+	}
+}
+
+func TestDecodeViewChangeEvent(t *testing.T) {
+	body := make([]byte, 52)
+	copy(body, "15000000000:1")
+	binary.LittleEndian.PutUint64(body[40:], 3)
+	binary.LittleEndian.PutUint32(body[48:], 1)
+	body = append(body, 3, 0, 'k', 'e', 'y', 5, 0, 0, 0, 'v', 'a', 'l', 'u', 'e')
+
+	ev, err := (&EventDecoder{}).decode(buildEvent(ViewChangeEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := ev.(*ViewChangeEvent)
+	if string(e.ViewID) != "15000000000:1" || e.SequenceNumber != 3 || string(e.CertInfo["key"]) != "value" {
+		t.Errorf("unexpected view change %+v", e)
+	}
+}
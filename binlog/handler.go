@@ -0,0 +1,100 @@
+package binlog
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrSkip is returned by the methods of EventHandler to skip the rest of the handling of the event.
+var ErrSkip = errors.New("skip event")
+
+// EventHandler handles the events pushed by HandleEvents. An error returned by any method stops the handling
+// and is returned by HandleEvents, except for ErrSkip.
+type EventHandler interface {
+	// OnRawEvent is called first for every event including the ones embedded in TransactionPayloadEvent,
+	// ErrSkip skips the other methods for the event.
+	OnRawEvent(ev Event) error
+	// OnRotate is called when the binlog file is rotated.
+	OnRotate(e *RotateEvent) error
+	// OnTableChanged is called for every table created, altered, dropped, truncated or renamed by the
+	// DDL query before OnQuery, ErrSkip skips OnQuery.
+	OnTableChanged(database, table string) error
+	// OnRow is called for every rows event.
+	OnRow(e *RowsEvent) error
+	// OnGTID is called for GtidEvent, AnonymousGtidEvent and MariadbGtidEvent which start the transactions.
+	OnGTID(ev Event) error
+	// OnXID is called when a transaction is committed.
+	OnXID(e *XIDEvent) error
+	// OnQuery is called for every QueryEvent including BEGIN and COMMIT.
+	OnQuery(e *QueryEvent) error
+}
+
+// DummyEventHandler implements EventHandler doing nothing, embed it to implement part of the methods.
+type DummyEventHandler struct{}
+
+func (DummyEventHandler) OnRawEvent(Event) error                      { return nil }
+func (DummyEventHandler) OnRotate(*RotateEvent) error                 { return nil }
+func (DummyEventHandler) OnTableChanged(database, table string) error { return nil }
+func (DummyEventHandler) OnRow(*RowsEvent) error                      { return nil }
+func (DummyEventHandler) OnGTID(Event) error                          { return nil }
+func (DummyEventHandler) OnXID(*XIDEvent) error                       { return nil }
+func (DummyEventHandler) OnQuery(*QueryEvent) error                   { return nil }
+
+// Run is like Start but drives h with the events instead of returning the EventQueue,
+// it blocks until ctx is canceled or an error occurs and the dump is stopped then.
+func (s *Streamer) Run(ctx context.Context, dsn string, serverID uint32, file string, pos uint32, h EventHandler) error {
+	q, err := s.Start(ctx, dsn, serverID, file, pos)
+	if err != nil {
+		return err
+	}
+	defer q.Close(false)
+	return HandleEvents(ctx, q, h)
+}
+
+// HandleEvents pops the events from q and dispatches them to h until ctx is done or an error occurs.
+func HandleEvents(ctx context.Context, q *EventQueue, h EventHandler) error {
+	for {
+		ev, err := q.Pop(ctx)
+		if err != nil {
+			return err
+		}
+		if err = dispatch(h, ev); err != nil && err != ErrSkip {
+			return err
+		}
+	}
+}
+
+func dispatch(h EventHandler, ev Event) error {
+	if err := h.OnRawEvent(ev); err != nil {
+		return err
+	}
+	switch e := ev.(type) {
+	case *RotateEvent:
+		return h.OnRotate(e)
+	case *RowsEvent:
+		return h.OnRow(e)
+	case *GtidEvent, *AnonymousGtidEvent, *MariadbGtidEvent:
+		return h.OnGTID(ev)
+	case *XIDEvent:
+		return h.OnXID(e)
+	case *QueryEvent:
+		for _, name := range ddlTables(string(e.Query)) {
+			database := name[0]
+			if database == "" {
+				database = string(e.Database)
+			}
+			if err := h.OnTableChanged(database, name[1]); err != nil {
+				return err
+			}
+		}
+		return h.OnQuery(e)
+	case *TransactionPayloadEvent:
+		// ErrSkip skips the embedded event only
+		for _, inner := range e.Events {
+			if err := dispatch(h, inner); err != nil && err != ErrSkip {
+				return err
+			}
+		}
+	}
+	return nil
+}
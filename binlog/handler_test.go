@@ -0,0 +1,40 @@
+package binlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+type recordingHandler struct {
+	DummyEventHandler
+	calls []string
+}
+
+func (h *recordingHandler) OnTableChanged(database, table string) error {
+	h.calls = append(h.calls, "table "+database+"."+table)
+	return ErrSkip
+}
+
+func (h *recordingHandler) OnQuery(e *QueryEvent) error {
+	h.calls = append(h.calls, "query "+string(e.Query))
+	return nil
+}
+
+func TestDispatch(t *testing.T) {
+	h := &recordingHandler{}
+	header := &baseEvent{header: &EventHeader{}}
+	events := []Event{
+		&QueryEvent{baseEvent: header, Database: []byte("db"), Query: []byte("BEGIN")},
+		&QueryEvent{baseEvent: header, Database: []byte("db"), Query: []byte("ALTER TABLE t ADD c int")},
+	}
+	for _, ev := range events {
+		if err := dispatch(h, ev); err != nil && err != ErrSkip {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{"query BEGIN", "table db.t"}
+	if !reflect.DeepEqual(h.calls, expected) {
+		t.Errorf("expected %v, got %v", expected, h.calls)
+	}
+}
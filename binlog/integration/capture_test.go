@@ -0,0 +1,118 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"database/sql"
+	"flag"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/LightKool/mysql-go"
+)
+
+var capture = flag.Bool("capture", false, "capture the binlog fixtures of binlog/testdata from the servers")
+
+// fixtureServer is a server version whose binlog is captured into testdata/<name>.bin of the binlog package.
+type fixtureServer struct {
+	name    string
+	version string
+	flags   []string
+	// temporal are the definitions of the TIME and DATETIME columns, which have no fractional seconds before 5.6
+	temporal [2]string
+	// extended adds the JSON and GEOMETRY columns of 5.7
+	extended bool
+}
+
+var fixtureServers = []fixtureServer{
+	{"mysql-5.5", "5.5.62", nil, [2]string{"TIME", "DATETIME"}, false},
+	{"mysql-5.6", "5.6.51", gtidFlags, [2]string{"TIME(3)", "DATETIME(6)"}, false},
+	{"mysql-5.7", "5.7.38", gtidFlags, [2]string{"TIME(3)", "DATETIME(6)"}, true},
+	{"mysql-8.0", "8.0.30", append([]string{"--binlog-row-metadata=FULL"}, gtidFlags...),
+		[2]string{"TIME(3)", "DATETIME(6)"}, true},
+}
+
+// TestCaptureFixtures rewrites the binlog fixtures with the binlogs of the servers, which create the table
+// all_types covering the supported field types, then insert 2 rows into it, update the primary key and the
+// column cn of one of them and delete the other in a transaction. The golden files are rewritten then by
+//
+//	go test ./binlog -run TestGoldenFiles -update
+func TestCaptureFixtures(t *testing.T) {
+	if !*capture {
+		t.Skip("run with -capture to capture the binlog fixtures")
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not available")
+	}
+	for _, f := range fixtureServers {
+		f := f
+		t.Run(f.version, func(t *testing.T) {
+			c := startMySQL(t, f.version, f.flags...)
+			defer c.stop()
+			captureFixture(t, c, f)
+		})
+	}
+}
+
+func captureFixture(t *testing.T, c *container, f fixtureServer) {
+	db, err := sql.Open("mysql", c.dsn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	definitions := []string{"ca TINYINT PRIMARY KEY", "cb SMALLINT", "cc MEDIUMINT", "cd INT", "ce BIGINT UNSIGNED",
+		"cf FLOAT", "cg DOUBLE", "ch DECIMAL(10,2)", "ci YEAR", "cj DATE", "ck " + f.temporal[0], "cl " + f.temporal[1],
+		"cm TIMESTAMP NULL", "cn VARCHAR(20)", "co VARCHAR(300)", "cp CHAR(10)", "cq ENUM('a','b')",
+		"cr SET('a','b','c')", "cs BIT(10)", "ct BLOB"}
+	values := []string{"-128", "-32768", "-8388608", "-2147483648", "18446744073709551615", "1.5", "2.25",
+		"-12345678.90", "2021", "'2020-02-29'", "'-12:34:56.789'", "'2020-01-02 03:04:05.123456'",
+		"'2017-07-14 02:40:00'", "'abc'", "'a long varchar'", "'char'", "'b'", "'a,c'", "b'1010100101'", "x'000102'"}
+	if f.extended {
+		definitions = append(definitions, "cu JSON", "cv GEOMETRY")
+		values = append(values, `'{"a": [1, 2.5, true, null], "b": {"c": "d"}}'`, "ST_GeomFromText('POINT(1 2)')")
+	}
+	nulls := []string{"0"}
+	for range values[1:] {
+		nulls = append(nulls, "NULL")
+	}
+
+	// the binlog file begins with the DDL and ends with the rotation to the next one
+	run := func(execute func(string, ...interface{}) (sql.Result, error), queries ...string) {
+		for _, query := range queries {
+			if _, err := execute(query); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+		}
+	}
+	run(db.Exec, "FLUSH LOGS", "CREATE TABLE all_types ("+strings.Join(definitions, ", ")+") DEFAULT CHARSET=utf8mb4")
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(tx.Exec, "INSERT INTO all_types VALUES ("+strings.Join(values, ", ")+"), ("+strings.Join(nulls, ", ")+")",
+		"UPDATE all_types SET ca = 1, cn = 'xyz' WHERE ca = -128",
+		"DELETE FROM all_types WHERE ca = 0")
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	wr := mysql.NewConnWrapper()
+	if err = wr.Connect(c.dsn()); err != nil {
+		t.Fatal(err)
+	}
+	file, _, _, err := wr.MasterStatus()
+	wr.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	run(db.Exec, "FLUSH LOGS")
+
+	dst := filepath.Join("..", "testdata", f.name+".bin")
+	if out, err := exec.Command("docker", "cp", c.id+":/var/lib/mysql/"+file, dst).CombinedOutput(); err != nil {
+		t.Fatalf("docker cp %s: %v %s", file, err, out)
+	}
+}
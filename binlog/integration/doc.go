@@ -0,0 +1,8 @@
+// Package integration tests the binlog replication end-to-end against the MySQL servers of multiple versions
+// run in Docker containers. The tests are built with the integration tag only:
+//
+//	go test -tags integration ./binlog/integration
+//
+// MYSQL_INTEGRATION_VERSIONS selects the image tags of the official mysql image, default is "5.6,5.7,8.0".
+// The tests are skipped if docker is not available.
+package integration
@@ -0,0 +1,291 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LightKool/mysql-go"
+	"github.com/LightKool/mysql-go/binlog"
+)
+
+const password = "binlog"
+
+// container is a MySQL server run in Docker with the binlog enabled.
+type container struct {
+	id      string
+	version string
+	addr    string
+}
+
+// gtidFlags are the server options of the CRC32 checksums and GTIDs, which are available since 5.6.
+var gtidFlags = []string{"--binlog-checksum=CRC32", "--gtid-mode=ON", "--enforce-gtid-consistency=ON", "--log-slave-updates=ON"}
+
+// startMySQL runs the mysql image of the version with the row-based binlog and the extra server options in flags,
+// and waits until the server accepts the connections.
+func startMySQL(t *testing.T, version string, flags ...string) *container {
+	args := []string{"run", "-d", "--rm", "-e", "MYSQL_ROOT_PASSWORD=" + password, "-e", "MYSQL_DATABASE=test",
+		"-p", "127.0.0.1::3306", "mysql:" + version, "--server-id=1", "--log-bin=mysql-bin", "--binlog-format=ROW"}
+	out, err := exec.Command("docker", append(args, flags...)...).Output()
+	if err != nil {
+		t.Fatalf("docker run mysql:%s: %v", version, err)
+	}
+	c := &container{id: strings.TrimSpace(string(out)), version: version}
+
+	out, err = exec.Command("docker", "port", c.id, "3306/tcp").Output()
+	if err != nil {
+		c.stop()
+		t.Fatalf("docker port: %v", err)
+	}
+	c.addr = strings.TrimSpace(strings.Split(string(out), "\n")[0])
+
+	// the server is restarted after the initialization, which takes a while for the first run of the image
+	deadline := time.Now().Add(3 * time.Minute)
+	for {
+		db, err := sql.Open("mysql", c.dsn())
+		if err == nil {
+			err = db.Ping()
+			db.Close()
+		}
+		if err == nil {
+			return c
+		}
+		if time.Now().After(deadline) {
+			c.stop()
+			t.Fatalf("mysql:%s is not ready: %v", version, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// dsn returns the DSN of root, the public key is retrieved from the server for caching_sha2_password,
+// which is the default authentication plugin of 8.0, as the connections don't use TLS.
+func (c *container) dsn() string {
+	return fmt.Sprintf("root:%s@tcp(%s)/test?allowPublicKeyRetrieval=true&time_zone=%%27%%2B00%%3A00%%27", password, c.addr)
+}
+
+func (c *container) stop() {
+	exec.Command("docker", "stop", c.id).Run()
+}
+
+func TestReplication(t *testing.T) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker is not available")
+	}
+	versions := os.Getenv("MYSQL_INTEGRATION_VERSIONS")
+	if versions == "" {
+		versions = "5.6,5.7,8.0"
+	}
+	for _, version := range strings.Split(versions, ",") {
+		version := strings.TrimSpace(version)
+		t.Run(version, func(t *testing.T) {
+			c := startMySQL(t, version, gtidFlags...)
+			defer c.stop()
+			testReplication(t, c)
+		})
+	}
+}
+
+// column is a column of the table covering the data types, value is the literal inserted and expected
+// is the decoded value formatted by fmt.Sprint.
+type column struct {
+	name       string
+	definition string
+	value      string
+	expected   string
+}
+
+var columns = []column{
+	{"c_tinyint", "TINYINT", "-5", "-5"},
+	{"c_smallint", "SMALLINT UNSIGNED", "65535", "65535"},
+	{"c_mediumint", "MEDIUMINT", "8388607", "8388607"},
+	{"c_int", "INT", "-2147483648", "-2147483648"},
+	{"c_bigint", "BIGINT UNSIGNED", "18446744073709551615", "18446744073709551615"},
+	{"c_float", "FLOAT", "1.5", "1.5"},
+	{"c_double", "DOUBLE", "2.25", "2.25"},
+	{"c_decimal", "DECIMAL(10,2)", "12345.67", "12345.67"},
+	{"c_year", "YEAR", "2021", "2021"},
+	{"c_date", "DATE", "'2020-02-29'", "2020-02-29"},
+	{"c_time", "TIME(3)", "'-12:34:56.789'", "-12:34:56.789"},
+	{"c_datetime", "DATETIME(6)", "'2020-01-02 03:04:05.123456'", "2020-01-02 03:04:05.123456"},
+	// TIMESTAMP values are decoded as UnixNano without Location, the session time zone is UTC
+	{"c_timestamp", "TIMESTAMP NULL", "'2020-01-02 03:04:05'", "1577934245000000000"},
+	{"c_varchar", "VARCHAR(80)", "'abc'", "abc"},
+	{"c_char", "CHAR(10)", "'char'", "char"},
+	{"c_enum", "ENUM('a','b','c')", "'b'", "b"},
+	{"c_set", "SET('x','y','z')", "'x,z'", "x,z"},
+	{"c_bit", "BIT(10)", "b'1010100101'", "677"},
+	{"c_blob", "BLOB", "x'000102'", "[0 1 2]"},
+}
+
+// jsonColumn is available since 5.7, the expected value is compared after unmarshalling.
+var jsonColumn = column{"c_json", "JSON", `'{"a": [1, 2.5, true, null], "b": {"c": "d"}}'`, `{"a": [1, 2.5, true, null], "b": {"c": "d"}}`}
+
+func testReplication(t *testing.T, c *container) {
+	cols := columns
+	if c.version != "5.6" {
+		cols = append(cols[:len(cols):len(cols)], jsonColumn)
+	}
+
+	db, err := sql.Open("mysql", c.dsn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// the start position is taken by ConnWrapper before the changes
+	wr := mysql.NewConnWrapper()
+	if err = wr.Connect(c.dsn()); err != nil {
+		t.Fatal(err)
+	}
+	file, pos, gtidSet, err := wr.MasterStatus()
+	wr.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	definitions := []string{"id INT PRIMARY KEY"}
+	names, values := []string{"id"}, []string{"1"}
+	for _, col := range cols {
+		definitions = append(definitions, col.name+" "+col.definition)
+		names = append(names, col.name)
+		values = append(values, col.value)
+	}
+	run := func(queries ...string) {
+		for _, query := range queries {
+			if _, err := db.Exec(query); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+		}
+	}
+	run("CREATE TABLE all_types ("+strings.Join(definitions, ", ")+")",
+		"INSERT INTO all_types ("+strings.Join(names, ", ")+") VALUES ("+strings.Join(values, ", ")+")",
+		"UPDATE all_types SET c_varchar = 'xyz' WHERE id = 1",
+		"DELETE FROM all_types WHERE id = 1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	s := &binlog.Streamer{DB: db, ChecksumPolicy: binlog.ChecksumVerify}
+	q, err := s.Start(ctx, c.dsn(), 1001, file, pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var rows []*binlog.RowsEvent
+	var ddl []string
+	var checksum binlog.ChecksumAlgorithm
+	pop := func(n int) {
+		for len(rows) < n {
+			ev, err := q.Pop(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch e := ev.(type) {
+			case *binlog.FormatDescriptionEvent:
+				checksum = e.ChecksumAlgorithm
+			case *binlog.QueryEvent:
+				if query := string(e.Query); query != "BEGIN" {
+					ddl = append(ddl, query)
+				}
+			case *binlog.RowsEvent:
+				rows = append(rows, e)
+			}
+		}
+	}
+	// the rows are decoded before the DDL, so that the column metadata retrieved from DB matches them
+	pop(3)
+	run("ALTER TABLE all_types ADD COLUMN c_extra INT", "INSERT INTO all_types (id, c_extra) VALUES (2, 42)")
+	pop(4)
+
+	if checksum != binlog.ChecksumAlgorithmCRC32 {
+		t.Errorf("expected the CRC32 checksums negotiated, got %s", checksum)
+	}
+	if len(ddl) != 2 || !strings.HasPrefix(ddl[0], "CREATE TABLE") || !strings.HasPrefix(ddl[1], "ALTER TABLE") {
+		t.Errorf("unexpected DDL %q", ddl)
+	}
+
+	insert, update, del := rows[0].RowChanges(), rows[1].RowChanges(), rows[2].RowChanges()
+	if len(insert) != 1 || len(update) != 1 || len(del) != 1 {
+		t.Fatalf("expected a row of each change, got %d, %d and %d", len(insert), len(update), len(del))
+	}
+	for _, col := range cols {
+		checkValue(t, col, insert[0].After[col.name])
+		checkValue(t, col, update[0].Before[col.name])
+		checkValue(t, col, del[0].Before[col.name])
+		if col.name != "c_varchar" {
+			checkValue(t, col, update[0].After[col.name])
+		}
+	}
+	if v := update[0].After["c_varchar"]; v != "xyz" {
+		t.Errorf("expected the updated value xyz, got %v", v)
+	}
+
+	// the column metadata is retrieved again after the DDL
+	after := rows[3].RowChanges()
+	if len(after) != 1 || fmt.Sprint(after[0].After["id"]) != "2" || fmt.Sprint(after[0].After["c_extra"]) != "42" {
+		t.Errorf("unexpected row after the DDL %v", after)
+	}
+
+	testReplicationGTID(t, c, db, gtidSet)
+}
+
+func checkValue(t *testing.T, col column, v interface{}) {
+	if col.definition == "JSON" {
+		var expected, actual interface{}
+		s, _ := v.(string)
+		if err := json.Unmarshal([]byte(s), &actual); err != nil {
+			t.Errorf("%s: invalid JSON %v: %v", col.name, v, err)
+			return
+		}
+		json.Unmarshal([]byte(col.expected), &expected)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: expected %s, got %s", col.name, col.expected, s)
+		}
+		return
+	}
+	if s := fmt.Sprint(v); s != col.expected {
+		t.Errorf("%s: expected %s, got %s of %T", col.name, col.expected, s, v)
+	}
+}
+
+// testReplicationGTID dumps the same changes by GTID from the executed set before them.
+func testReplicationGTID(t *testing.T, c *container, db *sql.DB, gtidSet mysql.GTIDSet) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	s := &binlog.Streamer{DB: db, ChecksumPolicy: binlog.ChecksumVerify}
+	q, err := s.StartGTID(ctx, c.dsn(), 1002, gtidSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	var gtid string
+	for {
+		ev, err := q.Pop(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch e := ev.(type) {
+		case *binlog.GtidEvent:
+			gtid = e.GTID()
+		case *binlog.QueryEvent:
+			if strings.HasPrefix(string(e.Query), "CREATE TABLE") {
+				if gtid == "" {
+					t.Error("expected the GTID of the DDL")
+				}
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,559 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MySQL binary JSON value types
+// refer to https://github.com/mysql/mysql-server/blob/5.7/sql/json_binary.h
+const (
+	jsonbSmallObject byte = iota
+	jsonbLargeObject
+	jsonbSmallArray
+	jsonbLargeArray
+	jsonbLiteral
+	jsonbInt16
+	jsonbUint16
+	jsonbInt32
+	jsonbUint32
+	jsonbInt64
+	jsonbUint64
+	jsonbDouble
+	jsonbString
+	jsonbOpaque byte = 0x0f
+)
+
+const (
+	jsonbLiteralNull byte = iota
+	jsonbLiteralTrue
+	jsonbLiteralFalse
+)
+
+var errJSONTruncated = errors.New("JSON binary data truncated")
+
+// jsonBinaryDecoder decodes the MySQL binary JSON format stored in the binlog.
+type jsonBinaryDecoder struct{}
+
+// jsonObject keeps the keys of a JSON object in the stored order, which is the canonical order of MySQL.
+type jsonObject struct {
+	keys   []string
+	values []interface{}
+}
+
+// decodeJSONBinary decodes the binary JSON document. It returns the JSON text formatted as MySQL does,
+// or a tree of map[string]interface{} and []interface{} if asTree is true.
+func decodeJSONBinary(data []byte, asTree bool) (interface{}, error) {
+	var v interface{}
+	// an empty value is written for JSON null in some circumstances
+	if len(data) > 0 {
+		var err error
+		d := new(jsonBinaryDecoder)
+		if v, err = d.decodeValue(data[0], data[1:]); err != nil {
+			return nil, err
+		}
+	}
+	if asTree {
+		return jsonTree(v), nil
+	}
+	var buf bytes.Buffer
+	writeJSON(&buf, v)
+	return buf.String(), nil
+}
+
+func (d *jsonBinaryDecoder) decodeValue(typ byte, data []byte) (interface{}, error) {
+	switch typ {
+	case jsonbSmallObject:
+		return d.decodeObjectOrArray(data, false, true)
+	case jsonbLargeObject:
+		return d.decodeObjectOrArray(data, true, true)
+	case jsonbSmallArray:
+		return d.decodeObjectOrArray(data, false, false)
+	case jsonbLargeArray:
+		return d.decodeObjectOrArray(data, true, false)
+	case jsonbLiteral:
+		if len(data) < 1 {
+			return nil, errJSONTruncated
+		}
+		return d.decodeLiteral(data[0])
+	case jsonbInt16:
+		if len(data) < 2 {
+			return nil, errJSONTruncated
+		}
+		return int64(int16(binary.LittleEndian.Uint16(data))), nil
+	case jsonbUint16:
+		if len(data) < 2 {
+			return nil, errJSONTruncated
+		}
+		return uint64(binary.LittleEndian.Uint16(data)), nil
+	case jsonbInt32:
+		if len(data) < 4 {
+			return nil, errJSONTruncated
+		}
+		return int64(int32(binary.LittleEndian.Uint32(data))), nil
+	case jsonbUint32:
+		if len(data) < 4 {
+			return nil, errJSONTruncated
+		}
+		return uint64(binary.LittleEndian.Uint32(data)), nil
+	case jsonbInt64:
+		if len(data) < 8 {
+			return nil, errJSONTruncated
+		}
+		return int64(binary.LittleEndian.Uint64(data)), nil
+	case jsonbUint64:
+		if len(data) < 8 {
+			return nil, errJSONTruncated
+		}
+		return binary.LittleEndian.Uint64(data), nil
+	case jsonbDouble:
+		if len(data) < 8 {
+			return nil, errJSONTruncated
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case jsonbString:
+		str, _, err := d.decodeVariableLengthData(data)
+		if err != nil {
+			return nil, err
+		}
+		return string(str), nil
+	case jsonbOpaque:
+		return d.decodeOpaque(data)
+	default:
+		return nil, fmt.Errorf("unknown JSON value type: %d", typ)
+	}
+}
+
+func (d *jsonBinaryDecoder) decodeLiteral(b byte) (interface{}, error) {
+	switch b {
+	case jsonbLiteralNull:
+		return nil, nil
+	case jsonbLiteralTrue:
+		return true, nil
+	case jsonbLiteralFalse:
+		return false, nil
+	default:
+		return nil, fmt.Errorf("unknown JSON literal: %d", b)
+	}
+}
+
+// readOffsetOrSize reads an offset or size field, which is 2 bytes in the small format and 4 bytes in the large one.
+func readOffsetOrSize(data []byte, large bool) int {
+	if large {
+		return int(binary.LittleEndian.Uint32(data))
+	}
+	return int(binary.LittleEndian.Uint16(data))
+}
+
+// isInlinedType reports whether a value of type typ is stored directly in its value entry.
+func isInlinedType(typ byte, large bool) bool {
+	switch typ {
+	case jsonbLiteral, jsonbInt16, jsonbUint16:
+		return true
+	case jsonbInt32, jsonbUint32:
+		return large
+	}
+	return false
+}
+
+/*
+Layout of an object or an array:
+
+	element-count    (2 bytes for small, 4 bytes for large)
+	size             (2 bytes for small, 4 bytes for large)
+	key-entry*       (objects only: key-offset + key-length(2 bytes))
+	value-entry*     (type(1 byte) + value-offset or inlined value)
+	key*             (objects only)
+	value*
+
+All offsets are relative to the beginning of the object or array.
+*/
+func (d *jsonBinaryDecoder) decodeObjectOrArray(data []byte, large, isObject bool) (interface{}, error) {
+	offsetSize := 2
+	if large {
+		offsetSize = 4
+	}
+	if len(data) < 2*offsetSize {
+		return nil, errJSONTruncated
+	}
+	count := readOffsetOrSize(data, large)
+	size := readOffsetOrSize(data[offsetSize:], large)
+	if size > len(data) {
+		return nil, fmt.Errorf("JSON document size %d exceeds data length %d", size, len(data))
+	}
+	data = data[:size]
+
+	keyEntrySize := offsetSize + 2
+	valueEntrySize := 1 + offsetSize
+	headerSize := 2 * offsetSize
+	if isObject {
+		headerSize += count * keyEntrySize
+	}
+	headerSize += count * valueEntrySize
+	if headerSize > size {
+		return nil, fmt.Errorf("JSON header size %d exceeds document size %d", headerSize, size)
+	}
+
+	var keys []string
+	if isObject {
+		keys = make([]string, count)
+		for i := 0; i < count; i++ {
+			entry := 2*offsetSize + i*keyEntrySize
+			keyOffset := readOffsetOrSize(data[entry:], large)
+			keyLength := int(binary.LittleEndian.Uint16(data[entry+offsetSize:]))
+			if keyOffset < headerSize || keyOffset+keyLength > size {
+				return nil, errJSONTruncated
+			}
+			keys[i] = string(data[keyOffset : keyOffset+keyLength])
+		}
+	}
+
+	values := make([]interface{}, count)
+	for i := 0; i < count; i++ {
+		entry := 2*offsetSize + i*valueEntrySize
+		if isObject {
+			entry += count * keyEntrySize
+		}
+		typ := data[entry]
+		var err error
+		if isInlinedType(typ, large) {
+			values[i], err = d.decodeValue(typ, data[entry+1:entry+valueEntrySize])
+		} else {
+			valueOffset := readOffsetOrSize(data[entry+1:], large)
+			if valueOffset < headerSize || valueOffset >= size {
+				return nil, errJSONTruncated
+			}
+			values[i], err = d.decodeValue(typ, data[valueOffset:])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !isObject {
+		return values, nil
+	}
+	return &jsonObject{keys: keys, values: values}, nil
+}
+
+// decodeVariableLengthData reads data prefixed with a variable length, which uses the high bit of each byte
+// as a continuation flag and the other 7 bits as part of the length.
+func (d *jsonBinaryDecoder) decodeVariableLengthData(data []byte) ([]byte, int, error) {
+	var length, n int
+	for shift := uint(0); ; shift += 7 {
+		if n >= len(data) || n >= 5 {
+			return nil, 0, errJSONTruncated
+		}
+		b := data[n]
+		n++
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if n+length > len(data) {
+		return nil, 0, errJSONTruncated
+	}
+	return data[n : n+length], n + length, nil
+}
+
+// decodeOpaque decodes an opaque value: field type(1 byte) + variable length data.
+func (d *jsonBinaryDecoder) decodeOpaque(data []byte) (interface{}, error) {
+	if len(data) < 1 {
+		return nil, errJSONTruncated
+	}
+	typ := data[0]
+	data, _, err := d.decodeVariableLengthData(data[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case fieldTypeNewDecimal:
+		if len(data) < 2 {
+			return nil, errJSONTruncated
+		}
+		meta := uint16(data[0])<<8 | uint16(data[1])
+		return newBinlogPacket(data[2:]).readNewDecimal(meta)
+	case fieldTypeTime:
+		if len(data) < 8 {
+			return nil, errJSONTruncated
+		}
+		return formatJSONTime(int64(binary.LittleEndian.Uint64(data))), nil
+	case fieldTypeDate, fieldTypeDateTime, fieldTypeTimestamp:
+		if len(data) < 8 {
+			return nil, errJSONTruncated
+		}
+		return formatJSONDateTime(typ, int64(binary.LittleEndian.Uint64(data))), nil
+	default:
+		return fmt.Sprintf("base64:type%d:%s", typ, base64.StdEncoding.EncodeToString(data)), nil
+	}
+}
+
+// formatJSONTime formats a TIME value packed as an int64,
+// refer to https://github.com/mysql/mysql-server/blob/5.7/sql-common/my_time.c (TIME_to_longlong_time_packed())
+func formatJSONTime(packed int64) string {
+	var sign string
+	if packed < 0 {
+		packed = -packed
+		sign = "-"
+	}
+	hms := packed >> 24
+	frac := packed % (1 << 24)
+	hour := (hms >> 12) % (1 << 10)
+	minute := (hms >> 6) % (1 << 6)
+	second := hms % (1 << 6)
+	return fmt.Sprintf("%s%02d:%02d:%02d.%06d", sign, hour, minute, second, frac)
+}
+
+// formatJSONDateTime formats a DATE/DATETIME/TIMESTAMP value packed as an int64,
+// refer to https://github.com/mysql/mysql-server/blob/5.7/sql-common/my_time.c (TIME_to_longlong_datetime_packed())
+func formatJSONDateTime(typ byte, packed int64) string {
+	if packed < 0 {
+		packed = -packed
+	}
+	ymdhms := packed >> 24
+	frac := packed % (1 << 24)
+	ymd := ymdhms >> 17
+	ym := ymd >> 5
+	hms := ymdhms % (1 << 17)
+
+	year, month, day := ym/13, ym%13, ymd%(1<<5)
+	hour, minute, second := hms>>12, (hms>>6)%(1<<6), hms%(1<<6)
+	if typ == fieldTypeDate {
+		return fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d.%06d", year, month, day, hour, minute, second, frac)
+}
+
+// jsonTree converts the decoded JSON value to a tree of map[string]interface{} and []interface{}.
+func jsonTree(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *jsonObject:
+		m := make(map[string]interface{}, len(v.keys))
+		for i, k := range v.keys {
+			m[k] = jsonTree(v.values[i])
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonTree(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// writeJSON writes the decoded JSON value as text in the same format as MySQL, e.g. `{"a": 1, "b": [true, null]}`.
+func writeJSON(buf *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case uint64:
+		buf.WriteString(strconv.FormatUint(v, 10))
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEIN") {
+			s += ".0"
+		}
+		buf.WriteString(s)
+	case string:
+		writeJSONString(buf, v)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeJSON(buf, e)
+		}
+		buf.WriteByte(']')
+	case *jsonObject:
+		buf.WriteByte('{')
+		for i, k := range v.keys {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			writeJSONString(buf, k)
+			buf.WriteString(": ")
+			writeJSON(buf, v.values[i])
+		}
+		buf.WriteByte('}')
+	default:
+		fmt.Fprintf(buf, "%v", v)
+	}
+}
+
+const hexDigits = "0123456789abcdef"
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		b := s[i]
+		if b >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			buf.WriteRune(r)
+			i += size
+			continue
+		}
+		switch b {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(b)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		default:
+			if b < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[b>>4])
+				buf.WriteByte(hexDigits[b&0xf])
+			} else {
+				buf.WriteByte(b)
+			}
+		}
+		i++
+	}
+	buf.WriteByte('"')
+}
+
+// encodeJSONBinary encodes the JSON document into the MySQL binary JSON format. v is the JSON text as a string
+// or []byte, or a tree of map[string]interface{} and []interface{} like the one decoded with ParseJSON.
+// The objects and arrays are always written in the large format.
+func encodeJSONBinary(v interface{}) ([]byte, error) {
+	var text []byte
+	switch t := v.(type) {
+	case string:
+		text = []byte(t)
+	case []byte:
+		text = t
+	}
+	if text != nil {
+		d := json.NewDecoder(bytes.NewReader(text))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return nil, fmt.Errorf("invalid JSON text: %v", err)
+		}
+	}
+	typ, data, err := encodeJSONValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{typ}, data...), nil
+}
+
+func encodeJSONValue(v interface{}) (byte, []byte, error) {
+	var buf [8]byte
+	switch v := v.(type) {
+	case nil:
+		return jsonbLiteral, []byte{jsonbLiteralNull}, nil
+	case bool:
+		if v {
+			return jsonbLiteral, []byte{jsonbLiteralTrue}, nil
+		}
+		return jsonbLiteral, []byte{jsonbLiteralFalse}, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return encodeJSONValue(i)
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return encodeJSONValue(u)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid JSON number %s", v)
+		}
+		return encodeJSONValue(f)
+	case int:
+		return encodeJSONValue(int64(v))
+	case int64:
+		binary.LittleEndian.PutUint64(buf[:], uint64(v))
+		return jsonbInt64, buf[:], nil
+	case uint64:
+		binary.LittleEndian.PutUint64(buf[:], v)
+		return jsonbUint64, buf[:], nil
+	case float64:
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+		return jsonbDouble, buf[:], nil
+	case string:
+		return jsonbString, append(appendUvarint(nil, uint64(len(v))), v...), nil
+	case []interface{}:
+		data, err := encodeJSONContainer(nil, v)
+		return jsonbLargeArray, data, err
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// the canonical order of MySQL: shorter keys first, then by bytes
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		values := make([]interface{}, len(keys))
+		for i, k := range keys {
+			values[i] = v[k]
+		}
+		data, err := encodeJSONContainer(keys, values)
+		return jsonbLargeObject, data, err
+	default:
+		return 0, nil, fmt.Errorf("unsupported JSON value of %T", v)
+	}
+}
+
+// encodeJSONContainer encodes an object with keys or an array if keys is nil, in the large format.
+func encodeJSONContainer(keys []string, values []interface{}) ([]byte, error) {
+	headerSize := 8 + len(values)*5
+	if keys != nil {
+		headerSize += len(keys) * 6
+	}
+	data := make([]byte, headerSize)
+	binary.LittleEndian.PutUint32(data, uint32(len(values)))
+
+	entry := 8
+	for _, k := range keys {
+		binary.LittleEndian.PutUint32(data[entry:], uint32(len(data)))
+		binary.LittleEndian.PutUint16(data[entry+4:], uint16(len(k)))
+		data = append(data, k...)
+		entry += 6
+	}
+	for _, v := range values {
+		typ, value, err := encodeJSONValue(v)
+		if err != nil {
+			return nil, err
+		}
+		data[entry] = typ
+		if typ == jsonbLiteral {
+			data[entry+1] = value[0]
+		} else {
+			binary.LittleEndian.PutUint32(data[entry+1:], uint32(len(data)))
+			data = append(data, value...)
+		}
+		entry += 5
+	}
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)))
+	return data, nil
+}
@@ -0,0 +1,79 @@
+package binlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeJSONBinary(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{[]byte{}, "null"},
+		{[]byte{jsonbLiteral, jsonbLiteralTrue}, "true"},
+		{[]byte{jsonbInt16, 0xff, 0xff}, "-1"},
+		{[]byte{jsonbString, 3, 'f', 'o', 'o'}, `"foo"`},
+		// {"a": 1, "b": "x"}
+		{[]byte{jsonbSmallObject,
+			2, 0, 22, 0, // count, size
+			18, 0, 1, 0, 19, 0, 1, 0, // key entries
+			jsonbInt16, 1, 0, jsonbString, 20, 0, // value entries
+			'a', 'b', // keys
+			1, 'x', // values
+		}, `{"a": 1, "b": "x"}`},
+		// [null, [false]]
+		{[]byte{jsonbLargeArray,
+			2, 0, 0, 0, 25, 0, 0, 0, // count, size
+			jsonbLiteral, jsonbLiteralNull, 0, 0, 0, jsonbSmallArray, 18, 0, 0, 0, // value entries
+			1, 0, 7, 0, jsonbLiteral, jsonbLiteralFalse, 0, // nested small array
+		}, `[null, [false]]`},
+	}
+	for _, test := range tests {
+		v, err := decodeJSONBinary(test.data, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, v)
+		}
+	}
+}
+
+func TestDecodeJSONBinaryTruncated(t *testing.T) {
+	_, err := decodeJSONBinary([]byte{jsonbSmallObject, 1, 0, 20, 0}, false)
+	if err == nil {
+		t.Fatal("expected error for truncated document")
+	}
+}
+
+func TestDecodeJSONBinaryTree(t *testing.T) {
+	// {"a": [1.5, "x\n"]}
+	data := []byte{jsonbSmallObject,
+		1, 0, 28, 0, // count, size
+		11, 0, 1, 0, // key entries
+		jsonbSmallArray, 12, 0, // value entries
+		'a',                                                 // keys
+		2, 0, 16, 0, jsonbDouble, 10, 0, jsonbString, 18, 0, // nested small array
+	}
+	data = append(data, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f, 2, 'x', '\n')
+	data[3] = byte(len(data) - 1)
+	data[15] = byte(len(data) - 13)
+
+	v, err := decodeJSONBinary(data, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"a": []interface{}{1.5, "x\n"}}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("expected %v, got %v", expected, v)
+	}
+
+	v, err = decodeJSONBinary(data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != `{"a": [1.5, "x\n"]}` {
+		t.Errorf("unexpected JSON text %s", v)
+	}
+}
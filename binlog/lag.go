@@ -0,0 +1,72 @@
+package binlog
+
+import (
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/LightKool/mysql-go"
+)
+
+// Lag is the replication lag of Streamer.
+type Lag struct {
+	// Delay is the same as Streamer.Delay.
+	Delay time.Duration
+	// Master is the position of the last event written by the master, with the GTID set executed by it.
+	Master Position
+	// Received is the position after the last event received from the master.
+	Received Position
+}
+
+// Delay returns the time between the last event is executed on the master and received by Streamer,
+// it's 0 if no event has been received or the master has sent a heartbeat since then.
+func (s *Streamer) Delay() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delay
+}
+
+// Position returns the position after the last event received from the master, GTIDSet is set only
+// after StartGTID.
+func (s *Streamer) Position() Position {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pos := Position{File: s.file, Pos: s.pos}
+	if s.gtidMode {
+		pos.GTIDSet = s.gtids.String()
+	}
+	return pos
+}
+
+// Lag queries the master position by SHOW MASTER STATUS and returns it with the received position and delay.
+func (s *Streamer) Lag(ctx context.Context) (*Lag, error) {
+	s.mu.Lock()
+	dsn := s.dsn
+	s.mu.Unlock()
+
+	conn := mysql.NewConnWrapper()
+	if err := conn.ConnectContext(ctx, dsn, s.TLSConfig); err != nil {
+		return nil, err
+	}
+	file, pos, gtidSet, err := conn.MasterStatus()
+	conn.Close()
+	if err != nil {
+		return nil, err
+	}
+	master := Position{File: file, Pos: pos, GTIDSet: gtidSet.String()}
+	return &Lag{Delay: s.Delay(), Master: master, Received: s.Position()}, nil
+}
+
+// trackDelay updates the delay by the timestamp of the event packet, it must be called with mu held.
+// The artificial events are skipped since they carry the time of the binlog file rather than the execution.
+func (s *Streamer) trackDelay(packet []byte) (time.Duration, bool) {
+	timestamp := binary.LittleEndian.Uint32(packet)
+	if timestamp == 0 || binary.LittleEndian.Uint32(packet[13:]) == 0 {
+		return 0, false
+	}
+	s.delay = time.Since(time.Unix(int64(timestamp), 0))
+	if s.delay < 0 {
+		s.delay = 0
+	}
+	return s.delay, true
+}
@@ -0,0 +1,166 @@
+package binlog
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LightKool/mysql-go"
+)
+
+// StartFromTime is like Start but dumps from the first transaction executed at or after t. The position is
+// located by reading the first event of the binlog files listed by SHOW BINARY LOGS to find the file which
+// covers t, then scanning the file for the first transaction at or after t.
+func (s *Streamer) StartFromTime(ctx context.Context, dsn string, serverID uint32, t time.Time) (*EventQueue, error) {
+	if err := s.setServerID(serverID); err != nil {
+		return nil, err
+	}
+	s.dsn = dsn
+	file, pos, err := s.locate(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	return s.Start(ctx, dsn, s.ServerID(), file, pos)
+}
+
+// locate returns the position of the first transaction at or after t,
+// or the end of the last binlog file if there is no such transaction.
+func (s *Streamer) locate(ctx context.Context, t time.Time) (string, uint32, error) {
+	conn := mysql.NewConnWrapper()
+	if err := conn.ConnectContext(ctx, s.dsn, s.TLSConfig); err != nil {
+		return "", 0, err
+	}
+	logs, err := conn.BinaryLogs()
+	conn.Close()
+	if err != nil {
+		return "", 0, err
+	}
+	if len(logs) == 0 {
+		return "", 0, fmt.Errorf("no binary logs found, binlog may be disabled")
+	}
+
+	// the last file which starts at or before t
+	var searchErr error
+	i := sort.Search(len(logs), func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		var start time.Time
+		start, searchErr = s.firstEventTime(ctx, logs[i].Name)
+		return start.After(t)
+	})
+	if searchErr != nil {
+		return "", 0, searchErr
+	}
+	if i > 0 {
+		i--
+	}
+
+	for _, log := range logs[i:] {
+		pos, err := s.scanTransactionAt(ctx, log, t)
+		if err != nil {
+			return "", 0, err
+		}
+		if pos > 0 {
+			return log.Name, pos, nil
+		}
+	}
+	last := logs[len(logs)-1]
+	return last.Name, uint32(last.Size), nil
+}
+
+// firstEventTime returns the time of the first event of the binlog file, which is when it was created.
+func (s *Streamer) firstEventTime(ctx context.Context, file string) (time.Time, error) {
+	var ts time.Time
+	err := s.scan(ctx, file, 0, func(ev Event) bool {
+		// skip the artificial events
+		if ev.Header().NextLogPos == 0 {
+			return true
+		}
+		ts = time.Unix(int64(ev.Header().Timestamp), 0)
+		return false
+	})
+	return ts, err
+}
+
+// scanTransactionAt returns the start position of the first transaction at or after t in the binlog file,
+// or 0 if there is no such transaction.
+func (s *Streamer) scanTransactionAt(ctx context.Context, log mysql.LogInfo, t time.Time) (uint32, error) {
+	var pos uint32
+	gtidMode := false
+	err := s.scan(ctx, log.Name, uint32(log.Size), func(ev Event) bool {
+		h := ev.Header()
+		start := false
+		switch e := ev.(type) {
+		case *GtidEvent, *AnonymousGtidEvent, *MariadbGtidEvent:
+			gtidMode, start = true, true
+		case *QueryEvent:
+			// the transactions begin with BEGIN or are standalone statements without GTIDs
+			query := strings.ToUpper(strings.TrimSpace(string(e.Query)))
+			start = !gtidMode && query != "COMMIT" && query != "ROLLBACK"
+		}
+		if start && !time.Unix(int64(h.Timestamp), 0).Before(t) {
+			pos = h.NextLogPos - h.EventSize
+			return false
+		}
+		return true
+	})
+	return pos, err
+}
+
+// scan dumps the binlog file from the beginning and calls fn for the events until it returns false,
+// the end of the file is reached or the file size is reached if it's not 0. The file is dumped by a probe
+// Streamer of its own, so that the position and the decoder of s are left untouched.
+func (s *Streamer) scan(ctx context.Context, file string, size uint32, fn func(ev Event) bool) error {
+	probe := &Streamer{
+		TLSConfig:       s.TLSConfig,
+		Flavor:          s.Flavor,
+		HeartbeatPeriod: s.HeartbeatPeriod,
+		ReadTimeout:     s.ReadTimeout,
+		KeepAlivePeriod: s.KeepAlivePeriod,
+		Log:             s.Log,
+		dsn:             s.dsn,
+		serverID:        s.serverID,
+		file:            file,
+		pos:             uint32(len(binlogMagic)),
+		dec: &EventDecoder{
+			ChecksumPolicy: s.ChecksumPolicy,
+			Flavor:         s.Flavor,
+			Filter: &EventFilter{EventTypes: []EventType{
+				QueryEventType, GtidEventType, AnonymousGtidEventType, MariadbGtidEventType,
+			}},
+			tables: make(map[uint64]*TableMapEvent),
+		},
+	}
+	conn, err := probe.dump(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for {
+		packet, err := conn.ReadPacketContext(ctx)
+		if err != nil {
+			return err
+		}
+		ev, err := probe.dec.decode(packet)
+		if err != nil {
+			return err
+		}
+		if ev != nil {
+			// the RotateEvent at the end of the file, the artificial one at the beginning has no position
+			if _, ok := ev.(*RotateEvent); ok && ev.Header().NextLogPos > 0 {
+				return nil
+			}
+			if !fn(ev) {
+				return nil
+			}
+		}
+		if size > 0 && binary.LittleEndian.Uint32(packet[13:]) >= size {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package binlog
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LightKool/mysql-go"
+)
+
+func TestStreamerScan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	header := func(typ EventType, timestamp uint32) *baseEvent {
+		return &baseEvent{header: &EventHeader{Timestamp: timestamp, Type: typ, ServerID: 1}}
+	}
+	begin := func(timestamp uint32) Event {
+		return &QueryEvent{baseEvent: header(QueryEventType, timestamp), StatusVars: []byte{}, Database: []byte("test"),
+			Query: []byte("BEGIN")}
+	}
+	path := filepath.Join(dir, "mysql-bin.000001")
+	writeBinlogFile(t, path, &FormatDescriptionEvent{
+		baseEvent:              header(FormatDescriptionEventType, 1500000000),
+		BinlogVersion:          4,
+		ServerVersion:          []byte("5.7.18-log"),
+		EventHeaderLength:      eventHeaderSize,
+		EventPostHeaderLengths: []byte{56, 13, 0, 8},
+	}, begin(1500000100), &XIDEvent{baseEvent: header(XidEventType, 1500000100), TransactionID: 1},
+		begin(1500000200), &XIDEvent{baseEvent: header(XidEventType, 1500000200), TransactionID: 2})
+	events, err := ReadFile(path, NewEventDecoder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondBegin := events[3].Header().NextLogPos - events[3].Header().EventSize
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go (&Server{Source: &DirSource{Dir: dir, PollInterval: 10 * time.Millisecond}, AllowAnyUser: true}).Serve(ctx, ln)
+
+	dec := NewEventDecoder()
+	s := &Streamer{dsn: "root@tcp(" + ln.Addr().String() + ")/", serverID: 100, file: "mysql-bin.000009", pos: 1234, dec: dec}
+	ts, err := s.firstEventTime(ctx, "mysql-bin.000001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Unix() != 1500000000 {
+		t.Errorf("expected the time of the FormatDescriptionEvent, got %v", ts)
+	}
+	pos, err := s.scanTransactionAt(ctx, mysql.LogInfo{Name: "mysql-bin.000001", Size: uint64(fi.Size())},
+		time.Unix(1500000150, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos != secondBegin {
+		t.Errorf("expected the position %d of the second transaction, got %d", secondBegin, pos)
+	}
+
+	// the probes don't touch the state of the Streamer
+	if s.file != "mysql-bin.000009" || s.pos != 1234 || s.dec != dec || s.gtidMode {
+		t.Errorf("unexpected state changed by the probes: %s:%d %p", s.file, s.pos, s.dec)
+	}
+}
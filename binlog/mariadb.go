@@ -0,0 +1,168 @@
+package binlog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Flavor selects the semantics of the binlog protocol of MySQL or MariaDB.
+type Flavor int
+
+const (
+	MySQLFlavor Flavor = iota
+	MariaDBFlavor
+)
+
+func (f Flavor) String() string {
+	switch f {
+	case MySQLFlavor:
+		return "mysql"
+	case MariaDBFlavor:
+		return "mariadb"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(f))
+	}
+}
+
+// mariadbSlaveCapabilityGTID tells the MariaDB master to send the GTID events
+// instead of converting them to the BEGIN QueryEvents.
+const mariadbSlaveCapabilityGTID = 4
+
+// MariadbGtidEvent flags
+const (
+	MariadbGtidStandaloneFlag    uint8 = 0x01
+	MariadbGtidGroupCommitIDFlag uint8 = 0x02
+	MariadbGtidTransactionalFlag uint8 = 0x04
+	MariadbGtidAllowParallelFlag uint8 = 0x08
+	MariadbGtidWaitedFlag        uint8 = 0x10
+	MariadbGtidDDLFlag           uint8 = 0x20
+)
+
+// the flags of MariadbGtidListEvent are stored in the high 4 bits of the count
+const mariadbGtidListFlagsBitOffset = 28
+
+// newMariadbEvent returns the MariaDB specific event of the type, or nil if there isn't one.
+func newMariadbEvent(be *baseEvent) Event {
+	switch be.header.Type {
+	case MariadbAnnotateRowsEventType:
+		return &MariadbAnnotateRowsEvent{baseEvent: be}
+	case MariadbBinlogCheckpointEventType:
+		return &MariadbBinlogCheckpointEvent{baseEvent: be}
+	case MariadbGtidEventType:
+		return &MariadbGtidEvent{baseEvent: be}
+	case MariadbGtidListEventType:
+		return &MariadbGtidListEvent{baseEvent: be}
+	}
+	return nil
+}
+
+// MariadbGTID is the global transaction identifier of MariaDB in the form of domain-server-sequence.
+type MariadbGTID struct {
+	DomainID       uint32
+	ServerID       uint32
+	SequenceNumber uint64
+}
+
+func (g MariadbGTID) String() string {
+	return fmt.Sprintf("%d-%d-%d", g.DomainID, g.ServerID, g.SequenceNumber)
+}
+
+// MariadbGtidEvent starts a transaction or a standalone DDL statement.
+type MariadbGtidEvent struct {
+	*baseEvent
+	GTID     MariadbGTID
+	Flags    uint8
+	CommitID uint64
+}
+
+func (e *MariadbGtidEvent) Decode(dec *EventDecoder) error {
+	packet := e.header.packet
+	e.GTID.ServerID = e.header.ServerID
+	e.GTID.SequenceNumber = packet.readUint64()
+	e.GTID.DomainID = packet.readUint32()
+	e.Flags = packet.readByte()
+	if e.Flags&MariadbGtidGroupCommitIDFlag != 0 {
+		e.CommitID = packet.readUint64()
+	}
+	return nil
+}
+
+func (e *MariadbGtidEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "GTID: %s\n", e.GTID)
+	fmt.Fprintf(w, "Flags: %d\n", e.Flags)
+	fmt.Fprintf(w, "Commit ID: %d\n", e.CommitID)
+	fmt.Fprintln(w)
+}
+
+// MariadbGtidListEvent is written at the beginning of every binlog file with the last GTIDs of each replication domain.
+type MariadbGtidListEvent struct {
+	*baseEvent
+	Flags uint8
+	GTIDs []MariadbGTID
+}
+
+func (e *MariadbGtidListEvent) Decode(dec *EventDecoder) error {
+	packet := e.header.packet
+	n := packet.readUint32()
+	e.Flags = uint8(n >> mariadbGtidListFlagsBitOffset)
+	n &= 1<<mariadbGtidListFlagsBitOffset - 1
+	if packet.Len()-packet.Pos() < int(n)*16 {
+		return fmt.Errorf("gtid list size %d too short for %d gtids", packet.Len()-packet.Pos(), n)
+	}
+	e.GTIDs = make([]MariadbGTID, n)
+	for i := range e.GTIDs {
+		e.GTIDs[i].DomainID = packet.readUint32()
+		e.GTIDs[i].ServerID = packet.readUint32()
+		e.GTIDs[i].SequenceNumber = packet.readUint64()
+	}
+	return nil
+}
+
+func (e *MariadbGtidListEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	gtids := make([]string, len(e.GTIDs))
+	for i, gtid := range e.GTIDs {
+		gtids[i] = gtid.String()
+	}
+	fmt.Fprintf(w, "GTIDs: %s\n", strings.Join(gtids, ","))
+	fmt.Fprintln(w)
+}
+
+// MariadbAnnotateRowsEvent carries the statement of the following rows events if binlog_annotate_row_events is on.
+type MariadbAnnotateRowsEvent struct {
+	*baseEvent
+	Query []byte
+}
+
+func (e *MariadbAnnotateRowsEvent) Decode(dec *EventDecoder) error {
+	e.Query = e.header.packet.Read(-1)
+	dec.rowsQuery = e.Query
+	return nil
+}
+
+func (e *MariadbAnnotateRowsEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "Query: %s\n", e.Query)
+	fmt.Fprintln(w)
+}
+
+// MariadbBinlogCheckpointEvent records the oldest binlog file still needed for the crash recovery.
+type MariadbBinlogCheckpointEvent struct {
+	*baseEvent
+	LogName []byte
+}
+
+func (e *MariadbBinlogCheckpointEvent) Decode(dec *EventDecoder) error {
+	packet := e.header.packet
+	n := packet.readUint32()
+	e.LogName = packet.Read(int(n))
+	return nil
+}
+
+func (e *MariadbBinlogCheckpointEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "Log name: %s\n", e.LogName)
+	fmt.Fprintln(w)
+}
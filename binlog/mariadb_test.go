@@ -0,0 +1,57 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeMariadbGtidEvent(t *testing.T) {
+	body := make([]byte, 8+4+1+8)
+	binary.LittleEndian.PutUint64(body, 100)
+	binary.LittleEndian.PutUint32(body[8:], 2)
+	body[12] = MariadbGtidGroupCommitIDFlag
+	binary.LittleEndian.PutUint64(body[13:], 7)
+	data := buildEvent(MariadbGtidEventType, body, false)
+
+	ev, err := (&EventDecoder{}).decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ev.(*UnsupportedEvent); !ok {
+		t.Errorf("expected *UnsupportedEvent for MySQLFlavor, got %T", ev)
+	}
+
+	ev, err = (&EventDecoder{Flavor: MariaDBFlavor}).decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := ev.(*MariadbGtidEvent)
+	if !ok {
+		t.Fatalf("expected *MariadbGtidEvent, got %T", ev)
+	}
+	if gtid := e.GTID.String(); gtid != "2-1-100" {
+		t.Errorf("expected gtid 2-1-100, got %s", gtid)
+	}
+	if e.CommitID != 7 {
+		t.Errorf("expected commit id 7, got %d", e.CommitID)
+	}
+}
+
+func TestDecodeMariadbGtidListEvent(t *testing.T) {
+	body := make([]byte, 4+2*16)
+	binary.LittleEndian.PutUint32(body, 2)
+	for i := 0; i < 2; i++ {
+		binary.LittleEndian.PutUint32(body[4+i*16:], uint32(i))
+		binary.LittleEndian.PutUint32(body[8+i*16:], 1)
+		binary.LittleEndian.PutUint64(body[12+i*16:], uint64(10+i))
+	}
+
+	ev, err := (&EventDecoder{Flavor: MariaDBFlavor}).decode(buildEvent(MariadbGtidListEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := ev.(*MariadbGtidListEvent)
+	if len(e.GTIDs) != 2 || e.GTIDs[0].String() != "0-1-10" || e.GTIDs[1].String() != "1-1-11" {
+		t.Errorf("unexpected gtids %v", e.GTIDs)
+	}
+}
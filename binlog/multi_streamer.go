@@ -0,0 +1,109 @@
+package binlog
+
+import (
+	"context"
+	"fmt"
+)
+
+// Source is a master dumped by MultiStreamer.
+type Source struct {
+	// Name tags the events of the source, e.g. the name of the shard.
+	Name     string
+	DSN      string
+	ServerID uint32
+	File     string
+	Pos      uint32
+	// Streamer dumps the events of the source with its options, a default one is used if it's nil.
+	Streamer *Streamer
+}
+
+// SourceEvent is an event dumped from a source by MultiStreamer.
+type SourceEvent struct {
+	Event
+	Source string
+}
+
+// MultiStreamer dumps the binlog events from multiple masters concurrently and merges them into one EventQueue,
+// every event is wrapped in a SourceEvent tagged with the name of its source. The events of a source are kept
+// in order, while there's no order between the sources.
+type MultiStreamer struct {
+	// QueueSize is the buffer size of the merged EventQueue, default is 128.
+	QueueSize int
+	// OverflowPolicy controls what to do when the merged EventQueue is full, default is OverflowBlock.
+	OverflowPolicy OverflowPolicy
+}
+
+// Start starts dumping from all the sources. The merged EventQueue fails with the first error of any source,
+// and all the sources are stopped then.
+func (m *MultiStreamer) Start(ctx context.Context, sources []*Source) (*EventQueue, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	queues := make([]*EventQueue, 0, len(sources))
+	names := make([]string, 0, len(sources))
+	for _, src := range sources {
+		s := src.Streamer
+		if s == nil {
+			s = &Streamer{}
+		}
+		sq, err := s.Start(ctx, src.DSN, src.ServerID, src.File, src.Pos)
+		if err != nil {
+			cancel()
+			for _, sq := range queues {
+				sq.Close(false)
+			}
+			return nil, fmt.Errorf("%s: %v", src.Name, err)
+		}
+		queues = append(queues, sq)
+		names = append(names, src.Name)
+	}
+
+	q := newEventQueue(m.QueueSize, m.OverflowPolicy)
+	q.cancel = cancel
+	go mergeQueues(ctx, q, queues, names)
+	return q, nil
+}
+
+// mergeQueues pops the events from the queues into q until ctx is done or any of them fails.
+func mergeQueues(ctx context.Context, q *EventQueue, queues []*EventQueue, names []string) {
+	defer func() {
+		q.cancel()
+		for _, sq := range queues {
+			sq.Close(false)
+		}
+		close(q.stopped)
+	}()
+
+	merged := make(chan Event)
+	errs := make(chan error, len(queues))
+	for i, sq := range queues {
+		go func(sq *EventQueue, name string) {
+			for {
+				ev, err := sq.Pop(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						err = fmt.Errorf("%s: %v", name, err)
+					}
+					errs <- err
+					return
+				}
+				select {
+				case merged <- &SourceEvent{Event: ev, Source: name}:
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
+			}
+		}(sq, names[i])
+	}
+
+	for {
+		select {
+		case ev := <-merged:
+			if !q.push(ctx, ev) {
+				return
+			}
+		case err := <-errs:
+			q.fail(err)
+			return
+		}
+	}
+}
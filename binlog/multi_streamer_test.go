@@ -0,0 +1,39 @@
+package binlog
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMergeQueues(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := newEventQueue(0, OverflowBlock)
+	q.cancel = cancel
+
+	queues := []*EventQueue{newEventQueue(0, OverflowBlock), newEventQueue(0, OverflowBlock)}
+	for _, sq := range queues {
+		produce(sq, 3)
+	}
+	go mergeQueues(ctx, q, queues, []string{"a", "b"})
+
+	next := map[string]uint64{}
+	for i := 0; i < 6; i++ {
+		ev, err := q.Pop(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		se := ev.(*SourceEvent)
+		if id := se.Event.(*XIDEvent).TransactionID; id != next[se.Source] {
+			t.Errorf("expected transaction %d of %s, got %d", next[se.Source], se.Source, id)
+		}
+		next[se.Source]++
+	}
+	if next["a"] != 3 || next["b"] != 3 {
+		t.Errorf("expected 3 events of each source, got %v", next)
+	}
+
+	q.Close(false)
+	if _, err := q.Pop(context.Background()); err != ErrQueueClosed {
+		t.Errorf("expected ErrQueueClosed, got %v", err)
+	}
+}
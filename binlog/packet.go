@@ -1,10 +1,11 @@
 package binlog
 
 import (
-	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"strconv"
 	"time"
 
@@ -20,7 +21,10 @@ func newBinlogPacket(data []byte) *binlogPacket {
 }
 
 func (p *binlogPacket) readByte() byte {
-	return p.Read(1)[0]
+	if b := p.Read(1); len(b) > 0 {
+		return b[0]
+	}
+	return 0
 }
 
 func (p *binlogPacket) readUint16() uint16 {
@@ -39,6 +43,25 @@ func (p *binlogPacket) readUint64() uint64 {
 	return p.ReadUintBySize(8)
 }
 
+func (p *binlogPacket) writeByte(b byte) {
+	p.WriteUintBySize(1, uint64(b))
+}
+
+func (p *binlogPacket) writeUint16(u uint16) {
+	p.WriteUintBySize(2, uint64(u))
+}
+
+func (p *binlogPacket) writeUint32(u uint32) {
+	p.WriteUintBySize(4, uint64(u))
+}
+
+func (p *binlogPacket) writeUint64(u uint64) {
+	p.WriteUintBySize(8, u)
+}
+
+// errShortColumnMeta is returned for the column metadata of a table map shorter than its column types.
+var errShortColumnMeta = errors.New("column metadata is too short for the column types")
+
 func (p *binlogPacket) readTableColumnMeta(columnTypes []byte) ([]uint16, error) {
 	data, err := p.ReadPackedString()
 	if err != nil {
@@ -49,22 +72,29 @@ func (p *binlogPacket) readTableColumnMeta(columnTypes []byte) ([]uint16, error)
 	pos := 0
 	for i, v := range columnTypes {
 		switch v {
-		case fieldTypeFloat, fieldTypeDouble, fieldTypeBLOB, fieldTypeJSON, fieldTypeGeometry:
+		case fieldTypeFloat, fieldTypeDouble, fieldTypeBLOB, fieldTypeJSON, fieldTypeGeometry,
+			fieldTypeTimestampV2, fieldTypeDateTimeV2, fieldTypeTimeV2:
+			if pos+1 > len(data) {
+				return nil, errShortColumnMeta
+			}
 			meta[i] = uint16(data[pos])
 			pos++
 		case fieldTypeBit, fieldTypeVarChar, fieldTypeVarString:
 			// - fieldTypeBit: {length of the field}/8, {length of the field} % 8
 			// - fieldTypeVarChar | fieldTypeVarChar: {length of the field}(2 bytes)
+			if pos+2 > len(data) {
+				return nil, errShortColumnMeta
+			}
 			meta[i] = binary.LittleEndian.Uint16(data[pos:])
 			pos += 2
 		case fieldTypeString, fieldTypeNewDecimal:
 			// - fieldTypeString: {real type}, {pack of field length}
 			// - fieldTypeNewDecimal: {precision}, {scale}
+			if pos+2 > len(data) {
+				return nil, errShortColumnMeta
+			}
 			meta[i] = binary.BigEndian.Uint16(data[pos:])
 			pos += 2
-		case fieldTypeTimestampV2, fieldTypeDateTimeV2, fieldTypeTimeV2:
-			meta[i] = uint16(data[pos])
-			pos++
 		default:
 			meta[i] = 0
 		}
@@ -72,7 +102,37 @@ func (p *binlogPacket) readTableColumnMeta(columnTypes []byte) ([]uint16, error)
 	return meta, nil
 }
 
-func (p *binlogPacket) readTableColumnValue(typ byte, meta uint16) (v interface{}, err error) {
+// UnsignedFormat selects the Go type of the decoded unsigned integer values.
+type UnsignedFormat int
+
+const (
+	// UnsignedDefault decodes BIGINT UNSIGNED values to uint64 and the smaller unsigned integers to int64
+	// like the signed ones, since int64 holds all their values.
+	UnsignedDefault UnsignedFormat = iota
+	// UnsignedUint64 decodes all the unsigned integer values to uint64.
+	UnsignedUint64
+	// UnsignedString decodes BIGINT UNSIGNED values to decimal strings for the consumers which can't take
+	// the values beyond MaxInt64, e.g. JSON numbers of JavaScript. The smaller ones are decoded to int64.
+	UnsignedString
+)
+
+// unsignedValue returns the unsigned integer value in the type of dec.UnsignedFormat.
+func unsignedValue(dec *EventDecoder, u uint64, bigint bool) interface{} {
+	format := UnsignedDefault
+	if dec != nil {
+		format = dec.UnsignedFormat
+	}
+	switch {
+	case format == UnsignedUint64 || format == UnsignedDefault && bigint:
+		return u
+	case format == UnsignedString && bigint:
+		return strconv.FormatUint(u, 10)
+	default:
+		return int64(u)
+	}
+}
+
+func (p *binlogPacket) readTableColumnValue(dec *EventDecoder, typ byte, meta uint16, unsigned bool) (v interface{}, err error) {
 	var length int
 	if typ == fieldTypeString {
 		if meta >= 256 {
@@ -88,21 +148,45 @@ func (p *binlogPacket) readTableColumnValue(typ byte, meta uint16) (v interface{
 			length = int(meta)
 		}
 	}
+	if err = checkFractionalPrecision(typ, meta); err != nil {
+		return
+	}
 
 	switch typ {
 	case fieldTypeTiny:
 		b := p.readByte()
-		v = int64(b)
+		if unsigned {
+			v = unsignedValue(dec, uint64(b), false)
+		} else {
+			v = int64(int8(b))
+		}
 	case fieldTypeShort:
-		v = int64(p.readUint16())
+		u16 := p.readUint16()
+		if unsigned {
+			v = unsignedValue(dec, uint64(u16), false)
+		} else {
+			v = int64(int16(u16))
+		}
 	case fieldTypeInt24:
-		v = int64(p.readUint24())
+		u32 := p.readUint24()
+		if unsigned {
+			v = unsignedValue(dec, uint64(u32), false)
+		} else if u32&0x800000 == 0 {
+			v = int64(u32)
+		} else {
+			v = int64(u32) - 1<<24
+		}
 	case fieldTypeLong:
-		v = int64(p.readUint32())
+		u32 := p.readUint32()
+		if unsigned {
+			v = unsignedValue(dec, uint64(u32), false)
+		} else {
+			v = int64(int32(u32))
+		}
 	case fieldTypeLongLong:
 		u64 := p.readUint64()
-		if u64 > math.MaxInt64 {
-			v = fmt.Sprintln(u64)
+		if unsigned {
+			v = unsignedValue(dec, u64, true)
 		} else {
 			v = int64(u64)
 		}
@@ -111,31 +195,35 @@ func (p *binlogPacket) readTableColumnValue(typ byte, meta uint16) (v interface{
 	case fieldTypeDouble:
 		v = math.Float64frombits(p.readUint64())
 	case fieldTypeNewDecimal:
-		v, err = p.readNewDecimal(meta)
+		v, err = p.readDecimal(dec, meta)
 	case fieldTypeYear:
 		v = 1900 + int(p.readByte())
 	case fieldTypeDate:
 		u32 := uint32(p.ReadUintBySize(3))
-		v = fmt.Sprintf("%04d-%02d-%02d", u32>>9, (u32>>5)%16, u32%32)
+		s := fmt.Sprintf("%04d-%02d-%02d", u32>>9, (u32>>5)%16, u32%32)
+		v, err = checkTemporal(dec, s, validDate(s), s)
 	case fieldTypeTime:
 		u32 := uint32(p.ReadUintBySize(3))
-		v = fmt.Sprintf("%02d:%02d:%02d", u32/10000, (u32%10000)/100, u32%100)
+		v, err = timeDuration(dec, fmt.Sprintf("%02d:%02d:%02d", u32/10000, (u32%10000)/100, u32%100))
 	case fieldTypeTimeV2:
-		v = p.readTimeV2(meta)
+		v, err = timeDuration(dec, p.readTimeV2(meta))
 	case fieldTypeDateTime:
 		// a number like YYYYMMDDhhmmss
 		u64 := p.readUint64()
 		d := u64 / 1000000
 		t := u64 % 1000000
-		v = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", d/10000, (d%10000)/100, d%100, t/10000, (t%10000)/100, t%100)
+		s := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", d/10000, (d%10000)/100, d%100, t/10000, (t%10000)/100, t%100)
+		v, err = checkTemporal(dec, s, validDate(s), localDateTime(dec, s))
 	case fieldTypeDateTimeV2:
-		v = p.readDateTimeV2(meta)
+		s := p.readDateTimeV2(meta)
+		v, err = checkTemporal(dec, s, validDate(s), localDateTime(dec, s))
 	case fieldTypeTimestamp:
-		v = time.Unix(int64(p.readUint32()), 0).UnixNano()
+		sec := int64(p.readUint32())
+		v, err = checkTemporal(dec, zeroDateTime, sec != 0, timestamp(dec, sec, 0))
 	case fieldTypeTimestampV2:
 		sec := int64(p.ReadUintBySizeBE(4))
 		msec := p.readMicroSeconds(int(meta), false)
-		v = time.Unix(sec, msec*1000).UnixNano()
+		v, err = checkTemporal(dec, zeroDateTime, sec != 0 || msec != 0, timestamp(dec, sec, msec))
 	case fieldTypeVarChar, fieldTypeVarString:
 		length = int(meta)
 		fallthrough
@@ -166,61 +254,379 @@ func (p *binlogPacket) readTableColumnValue(typ byte, meta uint16) (v interface{
 		} else {
 			err = fmt.Errorf("Unknown BIT pack length: %d", length)
 		}
-	case fieldTypeBLOB, fieldTypeGeometry: // MySQL saves Geometry as Blob in binlog
-		length = int(meta)
-		blobLen := p.ReadUintBySize(length)
-		v = p.Read(int(blobLen))
+	case fieldTypeBLOB:
+		if length, err = p.readBlobLength(meta); err == nil {
+			v = p.Read(length)
+		}
+	case fieldTypeGeometry: // MySQL saves Geometry as Blob in binlog
+		if length, err = p.readBlobLength(meta); err != nil {
+			break
+		}
+		data := p.Read(length)
+		if dec != nil && dec.ParseGeometry {
+			v, err = ParseGeometry(data)
+		} else {
+			v = data
+		}
 	case fieldTypeJSON:
-		// TODO
-		length = int(meta)
-		blobLen := p.ReadUintBySize(length)
-		v = p.Read(int(blobLen))
+		if length, err = p.readBlobLength(meta); err == nil {
+			v, err = decodeJSONBinary(p.Read(length), dec != nil && dec.ParseJSON)
+		}
 	}
 	return
 }
 
+// skipTableColumnValue skips the column value without decoding it, which is used to find the boundaries
+// of the rows for the parallel decoding. It must agree with readTableColumnValue on the sizes.
+func (p *binlogPacket) skipTableColumnValue(typ byte, meta uint16) error {
+	var length int
+	if typ == fieldTypeString {
+		if meta >= 256 {
+			realType := byte(meta >> 8)
+			if realType&0x30 != 0x30 {
+				length = int(uint16(meta&0xFF) | uint16((realType&0x30)^0x30)<<4)
+				typ = realType | 0x30
+			} else {
+				length = int(meta & 0xFF)
+				typ = realType
+			}
+		} else {
+			length = int(meta)
+		}
+	}
+	if err := checkFractionalPrecision(typ, meta); err != nil {
+		return err
+	}
+
+	size := 0
+	switch typ {
+	case fieldTypeTiny, fieldTypeYear:
+		size = 1
+	case fieldTypeShort:
+		size = 2
+	case fieldTypeInt24, fieldTypeDate, fieldTypeTime:
+		size = 3
+	case fieldTypeLong, fieldTypeFloat, fieldTypeTimestamp:
+		size = 4
+	case fieldTypeLongLong, fieldTypeDouble, fieldTypeDateTime:
+		size = 8
+	case fieldTypeNewDecimal:
+		var err error
+		if size, err = decimalSize(meta); err != nil {
+			return err
+		}
+	case fieldTypeTimeV2:
+		size = 3 + (int(meta)+1)/2
+	case fieldTypeDateTimeV2:
+		size = 5 + (int(meta)+1)/2
+	case fieldTypeTimestampV2:
+		size = 4 + (int(meta)+1)/2
+	case fieldTypeVarChar, fieldTypeVarString:
+		length = int(meta)
+		fallthrough
+	case fieldTypeString:
+		if length < 256 {
+			size = int(p.readByte())
+		} else {
+			size = int(p.readUint16())
+		}
+	case fieldTypeEnum, fieldTypeSet:
+		size = length
+	case fieldTypeBit:
+		nbits := (meta>>8)*8 + meta&0xFF
+		size = (int(nbits) + 7) / 8
+	case fieldTypeBLOB, fieldTypeGeometry, fieldTypeJSON:
+		var err error
+		if size, err = p.readBlobLength(meta); err != nil {
+			return err
+		}
+	}
+	if size > p.Len()-p.Pos() {
+		return fmt.Errorf("column value of type %d exceeds the event", typ)
+	}
+	p.Skip(size)
+	return nil
+}
+
+// checkFractionalPrecision validates the fractional seconds precision of the TIME2, DATETIME2 and TIMESTAMP2 values.
+func checkFractionalPrecision(typ byte, meta uint16) error {
+	switch typ {
+	case fieldTypeTimeV2, fieldTypeDateTimeV2, fieldTypeTimestampV2:
+		if meta > 6 {
+			return fmt.Errorf("invalid fractional seconds precision %d of type %d", meta, typ)
+		}
+	}
+	return nil
+}
+
+// readBlobLength reads the length of the BLOB alike value which is packed in meta bytes.
+func (p *binlogPacket) readBlobLength(meta uint16) (int, error) {
+	if meta < 1 || meta > 4 {
+		return 0, fmt.Errorf("Unknown BLOB pack length: %d", meta)
+	}
+	return int(p.ReadUintBySize(int(meta))), nil
+}
+
 var digitsPerInteger = 9
 var compressedBytes = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
 
-// Refer to https://github.com/mysql/mysql-server/blob/5.6/strings/decimal.c (line 1341: decimal2bin())
+// DecimalFormat selects the Go type of the decoded DECIMAL values.
+type DecimalFormat int
+
+const (
+	// DecimalFloat64 decodes DECIMAL values to float64, which loses precision beyond 15 significant digits.
+	DecimalFloat64 DecimalFormat = iota
+	// DecimalString decodes DECIMAL values to the exact strings with all the digits of the scale, e.g. "-12.50".
+	DecimalString
+	// DecimalRat decodes DECIMAL values to *big.Rat.
+	DecimalRat
+)
+
+func (p *binlogPacket) readDecimal(dec *EventDecoder, meta uint16) (interface{}, error) {
+	s, err := p.readDecimalString(meta)
+	if err != nil {
+		return nil, err
+	}
+	format := DecimalFloat64
+	if dec != nil {
+		format = dec.DecimalFormat
+	}
+	switch format {
+	case DecimalString:
+		return s, nil
+	case DecimalRat:
+		r, ok := new(big.Rat).SetString(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid decimal %s", s)
+		}
+		return r, nil
+	default:
+		return strconv.ParseFloat(s, 64)
+	}
+}
+
 func (p *binlogPacket) readNewDecimal(meta uint16) (float64, error) {
+	s, err := p.readDecimalString(meta)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// decimalSize returns the size of the binary DECIMAL value of the precision and scale in meta.
+func decimalSize(meta uint16) (int, error) {
+	precision, scale := int(meta>>8), int(meta&0xFF)
+	if precision == 0 || scale > precision {
+		return 0, fmt.Errorf("invalid DECIMAL precision %d and scale %d", precision, scale)
+	}
+	integral := precision - scale
+	return compressedBytes[integral%digitsPerInteger] + integral/digitsPerInteger*4 +
+		scale/digitsPerInteger*4 + compressedBytes[scale%digitsPerInteger], nil
+}
+
+// readDecimalString reads the binary DECIMAL value as the exact string.
+// Refer to https://github.com/mysql/mysql-server/blob/5.6/strings/decimal.c (line 1341: decimal2bin())
+func (p *binlogPacket) readDecimalString(meta uint16) (string, error) {
+	size, err := decimalSize(meta)
+	if err != nil {
+		return "", err
+	}
 	precision, scale := int(meta>>8), int(meta&0xFF)
 	integral := precision - scale // digits number to the left of the decimal point
 	intg, frac := integral/digitsPerInteger, scale/digitsPerInteger
 	intgx, fracx := integral%digitsPerInteger, scale%digitsPerInteger
-	size := compressedBytes[intgx] + intg*4 + frac*4 + compressedBytes[fracx]
-	data := p.Read(size)
+	// copy the data as it's modified for the negative values
+	var array [32]byte
+	data := append(array[:0], p.Read(size)...)
+	if len(data) < size {
+		return "", fmt.Errorf("DECIMAL value needs %d bytes", size)
+	}
 
-	var buf bytes.Buffer
 	negative := data[0]&0x80 == 0
 	if negative {
 		for i := range data {
 			data[i] ^= 0xFF // if negative, convert to positive
 		}
-		buf.WriteString("-")
 	}
 	data[0] ^= 0x80 // remove the sign bit
+	pos := 0
+	readUint := func(length int) int64 {
+		var u uint32
+		for _, b := range data[pos : pos+length] {
+			u = u<<8 | uint32(b)
+		}
+		pos += length
+		return int64(u)
+	}
 
-	var length int
-	packet := mysql.NewPacket(data)
+	buf := make([]byte, 0, precision+3)
+	if negative {
+		buf = append(buf, '-')
+	}
+	start := len(buf)
 	// compressed integer part
-	length = compressedBytes[intgx]
-	buf.WriteString(fmt.Sprintf("%d", packet.ReadUintBySizeBE(length)))
+	buf = strconv.AppendInt(buf, readUint(compressedBytes[intgx]), 10)
 	// uncompressed integer part
 	for i := 0; i < intg; i++ {
-		buf.WriteString(fmt.Sprintf("%09d", packet.ReadUintBySizeBE(4)))
+		buf = appendPadded(buf, readUint(4), 9)
+	}
+	// trim the leading zeros but the last one
+	zeros := 0
+	for zeros < len(buf)-start-1 && buf[start+zeros] == '0' {
+		zeros++
+	}
+	buf = append(buf[:start], buf[start+zeros:]...)
+	if scale == 0 {
+		return string(buf), nil
 	}
 	// decimal point
-	buf.WriteString(".")
+	buf = append(buf, '.')
 	// uncompressed fractional part
 	for i := 0; i < frac; i++ {
-		buf.WriteString(fmt.Sprintf("%09d", packet.ReadUintBySizeBE(4)))
+		buf = appendPadded(buf, readUint(4), 9)
 	}
 	// compressed fractional part
-	length = compressedBytes[fracx]
-	buf.WriteString(fmt.Sprintf("%0*d", fracx, packet.ReadUintBySizeBE(length)))
+	if length := compressedBytes[fracx]; length > 0 {
+		buf = appendPadded(buf, readUint(length), fracx)
+	}
+	return string(buf), nil
+}
 
-	return strconv.ParseFloat(buf.String(), 64)
+// appendPadded appends n in decimal padded with zeros to width like fmt's %0*d.
+func appendPadded(b []byte, n int64, width int) []byte {
+	if n < 0 {
+		b = append(b, '-')
+		n = -n
+		width--
+	}
+	digits := 1
+	for m := n; m >= 10; m /= 10 {
+		digits++
+	}
+	for ; digits < width; digits++ {
+		b = append(b, '0')
+	}
+	return strconv.AppendInt(b, n, 10)
+}
+
+// InvalidTemporalPolicy controls how the invalid DATE, DATETIME and TIMESTAMP values are decoded, which are
+// the zero values like 0000-00-00 and the dates with zero or out of range parts like 2020-00-15 or 2020-02-31,
+// allowed by the sql_mode without NO_ZERO_DATE, NO_ZERO_IN_DATE or with ALLOW_INVALID_DATES.
+type InvalidTemporalPolicy int
+
+const (
+	// InvalidTemporalRaw decodes the invalid values like the valid ones, i.e. the strings of the digits as they are
+	// for DATE and DATETIME, and 0 or time.Time{} for TIMESTAMP.
+	InvalidTemporalRaw InvalidTemporalPolicy = iota
+	// InvalidTemporalMarker decodes the invalid values to InvalidTemporal.
+	InvalidTemporalMarker
+	// InvalidTemporalNil decodes the invalid values to nil like NULL.
+	InvalidTemporalNil
+	// InvalidTemporalError fails the decoding of the rows events with the invalid values.
+	InvalidTemporalError
+)
+
+// InvalidTemporal is an invalid DATE, DATETIME or TIMESTAMP value decoded with InvalidTemporalMarker,
+// it's the text of the value like "0000-00-00 00:00:00".
+type InvalidTemporal string
+
+func (v InvalidTemporal) String() string {
+	return string(v)
+}
+
+// zeroDateTime is the text of the zero TIMESTAMP value
+const zeroDateTime = "0000-00-00 00:00:00"
+
+// validDate reports whether the date part of the DATE or DATETIME text is a valid date.
+func validDate(s string) bool {
+	_, err := time.Parse("2006-01-02", s[:10])
+	return err == nil
+}
+
+// checkTemporal applies dec.InvalidTemporalPolicy to the value v decoded from the text s if it's invalid.
+func checkTemporal(dec *EventDecoder, s string, valid bool, v interface{}) (interface{}, error) {
+	if valid || dec == nil {
+		return v, nil
+	}
+	switch dec.InvalidTemporalPolicy {
+	case InvalidTemporalMarker:
+		return InvalidTemporal(s), nil
+	case InvalidTemporalNil:
+		return nil, nil
+	case InvalidTemporalError:
+		return nil, fmt.Errorf("invalid temporal value %q", s)
+	}
+	return v, nil
+}
+
+// location returns the location of the time.Time values, nil if they are not decoded to time.Time.
+func (dec *EventDecoder) location() *time.Location {
+	switch {
+	case dec == nil:
+		return nil
+	case dec.Location != nil:
+		return dec.Location
+	case dec.ParseTime:
+		return time.UTC
+	}
+	return nil
+}
+
+// timestamp returns the TIMESTAMP value as time.Time in dec.Location if it's set, the zero value
+// 0000-00-00 00:00:00 is returned as time.Time{}. Otherwise the value is returned as UnixNano.
+func timestamp(dec *EventDecoder, sec, usec int64) interface{} {
+	loc := dec.location()
+	if loc == nil {
+		return time.Unix(sec, usec*1000).UnixNano()
+	}
+	if sec == 0 && usec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, usec*1000).In(loc)
+}
+
+// localDateTime parses the DATETIME value as the wall clock in dec.Location if it's set. The string is returned
+// unchanged if Location is not set or the value can't be represented by time.Time, e.g. 0000-00-00 00:00:00.
+func localDateTime(dec *EventDecoder, s string) interface{} {
+	loc := dec.location()
+	if loc == nil {
+		return s
+	}
+	t, err := time.ParseInLocation(dateTimeFormat, s, loc)
+	if err != nil {
+		return s
+	}
+	return t
+}
+
+// timeDuration returns the TIME value like "-838:59:59.000001" as time.Duration if dec.ParseTime is set.
+func timeDuration(dec *EventDecoder, s string) (interface{}, error) {
+	if dec == nil || !dec.ParseTime {
+		return s, nil
+	}
+	t, err := parseTemporal(s)
+	if err != nil {
+		return nil, err
+	}
+	d := time.Duration(t.hour)*time.Hour + time.Duration(t.minute)*time.Minute +
+		time.Duration(t.second)*time.Second + time.Duration(t.usec)*time.Microsecond
+	if t.negative {
+		d = -d
+	}
+	return d, nil
+}
+
+// formatTimeDuration formats the TIME value decoded as time.Duration like "-838:59:59.000001".
+func formatTimeDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second)
+	if usec := d % time.Second / time.Microsecond; usec != 0 {
+		s += fmt.Sprintf(".%06d", usec)
+	}
+	return s
 }
 
 // readMicroSeconds reads fractional part of MySQL timestamp/datetime/time fields
@@ -238,7 +644,7 @@ func (p *binlogPacket) readMicroSeconds(dec int, negative bool) int64 {
 	return msec
 }
 
-func (p *binlogPacket) readDateTimeV2(meta uint16) (v string) {
+func (p *binlogPacket) readDateTimeV2(meta uint16) string {
 	/*
 	    1 bit  sign            (1 = positive, 0 = negative ignored) the negative value of a datetime doesn't make much sense
 	   17 bits year*13+month   (year 0-9999, month 0-12)
@@ -264,15 +670,32 @@ func (p *binlogPacket) readDateTimeV2(meta uint16) (v string) {
 	minute := datetime >> (40 - 28 - 6) & (1<<6 - 1)
 	sec := datetime >> (40 - 34 - 6) & (1<<6 - 1)
 
+	var array [32]byte
+	b := appendDateTime(array[:0], int64(year), int64(month), int64(day), int64(hour), int64(minute), int64(sec))
 	if dec > 0 {
-		v = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d.%0*d", year, month, day, hour, minute, sec, dec, msec)
-	} else {
-		v = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, minute, sec)
+		// msec is in microseconds, keep the first dec digits
+		b = append(b, '.')
+		b = appendPadded(b, msec, 6)[:len(b)+dec]
 	}
-	return
+	return string(b)
 }
 
-func (p *binlogPacket) readTimeV2(meta uint16) (v string) {
+// appendDateTime appends the date and time like "2006-01-02 15:04:05".
+func appendDateTime(b []byte, year, month, day, hour, minute, sec int64) []byte {
+	b = appendPadded(b, year, 4)
+	b = appendPadded(append(b, '-'), month, 2)
+	b = appendPadded(append(b, '-'), day, 2)
+	return appendClock(append(b, ' '), hour, minute, sec)
+}
+
+// appendClock appends the time of the day like "15:04:05".
+func appendClock(b []byte, hour, minute, sec int64) []byte {
+	b = appendPadded(b, hour, 2)
+	b = appendPadded(append(b, ':'), minute, 2)
+	return appendPadded(append(b, ':'), sec, 2)
+}
+
+func (p *binlogPacket) readTimeV2(meta uint16) string {
 	/*
 	   1 bit sign (1 = positive, 0 = negative)
 	   1 bit unused (reserved for future extensions)
@@ -289,7 +712,8 @@ func (p *binlogPacket) readTimeV2(meta uint16) (v string) {
 	dec := int(meta)
 	msec := p.readMicroSeconds(dec, negative)
 
-	var sign string
+	var array [24]byte
+	b := array[:0]
 	if negative {
 		if msec != 0 {
 			time++
@@ -298,17 +722,18 @@ func (p *binlogPacket) readTimeV2(meta uint16) (v string) {
 		time = -time
 		msec = time % (1 << 24)
 		time = time >> 24
-		sign = "-"
+		b = append(b, '-')
 	}
 
 	hour := time >> (24 - 2 - 10) & (1<<10 - 1)
 	minute := time >> (24 - 12 - 6) & (1<<6 - 1)
 	sec := time >> (24 - 18 - 6) & (1<<6 - 1)
 
+	b = appendClock(b, hour, minute, sec)
 	if dec > 0 {
-		v = fmt.Sprintf("%s%02d:%02d:%02d.%0*d", sign, hour, minute, sec, dec, msec)
-	} else {
-		v = fmt.Sprintf("%s%02d:%02d:%02d", sign, hour, minute, sec)
+		// msec is in microseconds, keep the first dec digits
+		b = append(b, '.')
+		b = appendPadded(b, msec, 6)[:len(b)+dec]
 	}
-	return
+	return string(b)
 }
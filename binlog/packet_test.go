@@ -0,0 +1,348 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReadIntegerSignedness(t *testing.T) {
+	tests := []struct {
+		typ      byte
+		data     []byte
+		unsigned bool
+		expected interface{}
+	}{
+		{fieldTypeTiny, []byte{0xff}, false, int64(-1)},
+		{fieldTypeTiny, []byte{0xff}, true, int64(255)},
+		{fieldTypeShort, []byte{0x00, 0x80}, false, int64(-32768)},
+		{fieldTypeShort, []byte{0x00, 0x80}, true, int64(32768)},
+		{fieldTypeInt24, []byte{0xff, 0xff, 0xff}, false, int64(-1)},
+		{fieldTypeInt24, []byte{0xff, 0xff, 0xff}, true, int64(1<<24 - 1)},
+		{fieldTypeLong, []byte{0xfe, 0xff, 0xff, 0xff}, false, int64(-2)},
+		{fieldTypeLong, []byte{0xfe, 0xff, 0xff, 0xff}, true, int64(1<<32 - 2)},
+	}
+	for _, test := range tests {
+		v, err := newBinlogPacket(test.data).readTableColumnValue(nil, test.typ, 0, test.unsigned)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != test.expected {
+			t.Errorf("type %d unsigned %v: expected %v, got %v", test.typ, test.unsigned, test.expected, v)
+		}
+	}
+}
+
+func TestReadUnsignedLongLong(t *testing.T) {
+	for _, u64 := range []uint64{0, math.MaxInt64, math.MaxInt64 + 1, math.MaxUint64} {
+		data := make([]byte, 8)
+		binary.LittleEndian.PutUint64(data, u64)
+		v, err := newBinlogPacket(data).readTableColumnValue(nil, fieldTypeLongLong, 0, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != u64 {
+			t.Errorf("expected %d, got %#v", u64, v)
+		}
+	}
+
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint64(data, math.MaxUint64)
+	v, err := newBinlogPacket(data).readTableColumnValue(nil, fieldTypeLongLong, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != int64(-1) {
+		t.Errorf("expected -1, got %#v", v)
+	}
+}
+
+func TestReadUnsignedFormat(t *testing.T) {
+	tests := []struct {
+		format   UnsignedFormat
+		typ      byte
+		data     []byte
+		expected interface{}
+	}{
+		{UnsignedDefault, fieldTypeTiny, []byte{0xff}, int64(255)},
+		{UnsignedDefault, fieldTypeLong, []byte{0xff, 0xff, 0xff, 0xff}, int64(math.MaxUint32)},
+		{UnsignedDefault, fieldTypeLongLong, bytes.Repeat([]byte{0xff}, 8), uint64(math.MaxUint64)},
+		{UnsignedUint64, fieldTypeTiny, []byte{0xff}, uint64(255)},
+		{UnsignedUint64, fieldTypeShort, []byte{0xff, 0xff}, uint64(math.MaxUint16)},
+		{UnsignedUint64, fieldTypeInt24, []byte{0xff, 0xff, 0xff}, uint64(1<<24 - 1)},
+		{UnsignedUint64, fieldTypeLong, []byte{0xff, 0xff, 0xff, 0xff}, uint64(math.MaxUint32)},
+		{UnsignedUint64, fieldTypeLongLong, bytes.Repeat([]byte{0xff}, 8), uint64(math.MaxUint64)},
+		{UnsignedString, fieldTypeLong, []byte{0xff, 0xff, 0xff, 0xff}, int64(math.MaxUint32)},
+		{UnsignedString, fieldTypeLongLong, bytes.Repeat([]byte{0xff}, 8), "18446744073709551615"},
+	}
+	for _, test := range tests {
+		dec := &EventDecoder{UnsignedFormat: test.format}
+		v, err := newBinlogPacket(test.data).readTableColumnValue(dec, test.typ, 0, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != test.expected {
+			t.Errorf("format %d type %d: expected %#v, got %#v", test.format, test.typ, test.expected, v)
+		}
+	}
+
+	// the strings are encoded back
+	p := newBinlogPacket(nil)
+	if err := p.writeTableColumnValue(nil, fieldTypeLongLong, 0, "18446744073709551615"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p.Raw(), bytes.Repeat([]byte{0xff}, 8)) {
+		t.Errorf("unexpected encoded value %x", p.Raw())
+	}
+}
+
+func TestReadDecimal(t *testing.T) {
+	tests := []struct {
+		meta     uint16
+		data     []byte
+		expected string
+	}{
+		{14<<8 | 4, []byte{0x81, 0x0d, 0xfb, 0x38, 0xd2, 0x04, 0xd2}, "1234567890.1234"},
+		{14<<8 | 4, []byte{0x7e, 0xf2, 0x04, 0xc7, 0x2d, 0xfb, 0x2d}, "-1234567890.1234"},
+		{30<<8 | 10, []byte{0x8c, 0x14, 0x9a, 0xa4, 0x35, 0x0d, 0xfb, 0x38, 0xd2, 0x00, 0xbc, 0x61, 0x4e, 0x09}, "12345678901234567890.0123456789"},
+		{5<<8 | 0, []byte{0x80, 0x00, 0x07}, "7"},
+		{4<<8 | 2, []byte{0x80, 0x00}, "0.00"},
+	}
+	for _, test := range tests {
+		data := append([]byte(nil), test.data...)
+		v, err := newBinlogPacket(data).readTableColumnValue(&EventDecoder{DecimalFormat: DecimalString}, fieldTypeNewDecimal, test.meta, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != test.expected {
+			t.Errorf("expected %s, got %v", test.expected, v)
+		}
+		if !bytes.Equal(data, test.data) {
+			t.Errorf("%s: data modified", test.expected)
+		}
+
+		v, err = newBinlogPacket(data).readTableColumnValue(&EventDecoder{DecimalFormat: DecimalRat}, fieldTypeNewDecimal, test.meta, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r, _ := new(big.Rat).SetString(test.expected); v.(*big.Rat).Cmp(r) != 0 {
+			t.Errorf("expected %s, got %v", test.expected, v)
+		}
+
+		v, err = newBinlogPacket(data).readTableColumnValue(nil, fieldTypeNewDecimal, test.meta, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f, _ := strconv.ParseFloat(test.expected, 64); v != f {
+			t.Errorf("expected %v, got %v", f, v)
+		}
+	}
+}
+
+func TestReadTimeValuesInLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	dec := &EventDecoder{Location: loc}
+
+	// DATETIME(3) 2017-06-15 10:20:30.123
+	u64 := uint64(1)<<39 | uint64(2017*13+6)<<22 | 15<<17 | 10<<12 | 20<<6 | 30
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, u64<<24)
+	data = append(data[:5], 0x04, 0xce) // 1230
+	v, err := newBinlogPacket(data).readTableColumnValue(nil, fieldTypeDateTimeV2, 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "2017-06-15 10:20:30.123" {
+		t.Errorf("unexpected datetime %v", v)
+	}
+	v, err = newBinlogPacket(data).readTableColumnValue(dec, fieldTypeDateTimeV2, 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Date(2017, 6, 15, 10, 20, 30, 123000000, loc); !v.(time.Time).Equal(expected) || v.(time.Time).Location() != loc {
+		t.Errorf("expected %v, got %v", expected, v)
+	}
+
+	// TIMESTAMP(0) 2017-06-15 02:20:30 UTC
+	data = make([]byte, 4)
+	binary.BigEndian.PutUint32(data, 1497493230)
+	v, err = newBinlogPacket(data).readTableColumnValue(dec, fieldTypeTimestampV2, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts := v.(time.Time); ts.Unix() != 1497493230 || ts.Hour() != 10 {
+		t.Errorf("unexpected timestamp %v", ts)
+	}
+	v, err = newBinlogPacket([]byte{0, 0, 0, 0}).readTableColumnValue(dec, fieldTypeTimestampV2, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.(time.Time).IsZero() {
+		t.Errorf("expected zero time, got %v", v)
+	}
+}
+
+func TestReadTimeV2Fraction(t *testing.T) {
+	// TIME(1), TIME(3) and TIME(5) keep the first fsp digits of the microseconds like DATETIME2
+	for _, test := range []struct {
+		meta     uint16
+		value    string
+		expected string
+	}{
+		{1, "12:34:56.7", "12:34:56.7"},
+		{3, "12:34:56.789", "12:34:56.789"},
+		{3, "-12:34:56.789", "-12:34:56.789"},
+		{3, "12:34:56.001", "12:34:56.001"},
+		{5, "838:59:59.12345", "838:59:59.12345"},
+		{3, "-00:00:00.5", "-00:00:00.500"},
+	} {
+		p := newBinlogPacket(nil)
+		if err := p.writeTableColumnValue(nil, fieldTypeTimeV2, test.meta, test.value); err != nil {
+			t.Fatal(err)
+		}
+		v, err := newBinlogPacket(p.Raw()).readTableColumnValue(nil, fieldTypeTimeV2, test.meta, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != test.expected {
+			t.Errorf("TIME(%d) %s: expected %s, got %v", test.meta, test.value, test.expected, v)
+		}
+	}
+
+	// TIME(3) 12:34:56.789 in the binary form
+	u32 := uint32(1)<<23 | 12<<12 | 34<<6 | 56
+	data := []byte{byte(u32 >> 16), byte(u32 >> 8), byte(u32), 0x1e, 0xd2} // 7890
+	v, err := newBinlogPacket(data).readTableColumnValue(nil, fieldTypeTimeV2, 3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "12:34:56.789" {
+		t.Errorf("unexpected time %v", v)
+	}
+}
+
+func TestReadInvalidTemporalValues(t *testing.T) {
+	// DATE 2020-00-15, DATETIME(0) 0000-00-00 00:00:00 and TIMESTAMP(0) 0
+	date := uint32(2020<<9 | 0<<5 | 15)
+	values := []struct {
+		typ  byte
+		data []byte
+		text string
+	}{
+		{fieldTypeDate, []byte{byte(date), byte(date >> 8), byte(date >> 16)}, "2020-00-15"},
+		{fieldTypeDateTimeV2, []byte{0x80, 0, 0, 0, 0}, "0000-00-00 00:00:00"},
+		{fieldTypeTimestampV2, []byte{0, 0, 0, 0}, "0000-00-00 00:00:00"},
+	}
+	for _, policy := range []InvalidTemporalPolicy{InvalidTemporalRaw, InvalidTemporalMarker, InvalidTemporalNil, InvalidTemporalError} {
+		dec := &EventDecoder{InvalidTemporalPolicy: policy}
+		for _, value := range values {
+			v, err := newBinlogPacket(value.data).readTableColumnValue(dec, value.typ, 0, false)
+			switch policy {
+			case InvalidTemporalRaw:
+				if value.typ != fieldTypeTimestampV2 && v != value.text || value.typ == fieldTypeTimestampV2 && v != int64(0) {
+					t.Errorf("%d: unexpected raw value %#v of %q", policy, v, value.text)
+				}
+			case InvalidTemporalMarker:
+				if v != InvalidTemporal(value.text) {
+					t.Errorf("%d: unexpected marker %#v of %q", policy, v, value.text)
+				}
+			case InvalidTemporalNil:
+				if v != nil || err != nil {
+					t.Errorf("%d: expected nil for %q, got %#v, %v", policy, value.text, v, err)
+				}
+			case InvalidTemporalError:
+				if err == nil {
+					t.Errorf("%d: expected error for %q", policy, value.text)
+				}
+			}
+		}
+	}
+
+	// the valid values are not affected
+	dec := &EventDecoder{InvalidTemporalPolicy: InvalidTemporalError}
+	if v, err := newBinlogPacket([]byte{0x21, 0x44, 0x0f}).readTableColumnValue(dec, fieldTypeDate, 0, false); err != nil {
+		t.Errorf("unexpected error %v for %v", err, v)
+	}
+}
+
+func TestReadTimeValuesParseTime(t *testing.T) {
+	dec := &EventDecoder{ParseTime: true}
+	for _, d := range []time.Duration{
+		-(time.Hour + 2*time.Minute + 3*time.Second + 500*time.Millisecond),
+		838*time.Hour + 59*time.Minute + 59*time.Second,
+		time.Microsecond,
+	} {
+		p := newBinlogPacket(nil)
+		if err := p.writeTableColumnValue(nil, fieldTypeTimeV2, 6, d); err != nil {
+			t.Fatal(err)
+		}
+		v, err := newBinlogPacket(p.Raw()).readTableColumnValue(dec, fieldTypeTimeV2, 6, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != d {
+			t.Errorf("expected %v, got %v", d, v)
+		}
+	}
+
+	// DATETIME(0) 2017-06-15 10:20:30 in UTC without Location
+	u64 := uint64(1)<<39 | uint64(2017*13+6)<<22 | 15<<17 | 10<<12 | 20<<6 | 30
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, u64<<24)
+	v, err := newBinlogPacket(data[:5]).readTableColumnValue(dec, fieldTypeDateTimeV2, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Date(2017, 6, 15, 10, 20, 30, 0, time.UTC); v != expected {
+		t.Errorf("expected %v, got %v", expected, v)
+	}
+
+	if s := formatTimeDuration(-(time.Hour + 500*time.Millisecond)); s != "-01:00:00.500000" {
+		t.Errorf("unexpected formatted TIME %q", s)
+	}
+}
+
+func TestReadMalformedColumnValues(t *testing.T) {
+	tests := []struct {
+		typ  byte
+		meta uint16
+		data []byte
+	}{
+		{fieldTypeNewDecimal, 2<<8 | 10, []byte{0x80}},
+		{fieldTypeNewDecimal, 10<<8 | 2, []byte{0x80}},
+		{fieldTypeDateTimeV2, 68, []byte{0x99, 0x9c, 0xde, 0x95, 0x1e}},
+		{fieldTypeBLOB, 9, []byte{1, 0, 0, 0, 0, 0, 0, 0, 0, 'a'}},
+		{fieldTypeJSON, 0, []byte{}},
+	}
+	for _, test := range tests {
+		if _, err := newBinlogPacket(test.data).readTableColumnValue(nil, test.typ, test.meta, false); err == nil {
+			t.Errorf("type %d meta %d: expected an error", test.typ, test.meta)
+		}
+		if err := newBinlogPacket(test.data).skipTableColumnValue(test.typ, test.meta); err == nil {
+			t.Errorf("type %d meta %d: expected an error skipping", test.typ, test.meta)
+		}
+	}
+	// no panic for an empty value
+	newBinlogPacket(nil).readTableColumnValue(nil, fieldTypeVarChar, 10, false)
+}
+
+func TestAppendPadded(t *testing.T) {
+	for _, n := range []int64{0, 5, -5, 42, 789, 1000, 123456, 999999999, 1234567890, -123} {
+		for width := 0; width <= 10; width++ {
+			if s, expected := string(appendPadded(nil, n, width)), fmt.Sprintf("%0*d", width, n); s != expected {
+				t.Errorf("%d of width %d: expected %s, got %s", n, width, expected, s)
+			}
+		}
+	}
+	if s := string(appendDateTime(nil, 2020, 1, 2, 3, 4, 5)); s != "2020-01-02 03:04:05" {
+		t.Errorf("unexpected datetime %s", s)
+	}
+	if s := string(appendClock([]byte("-"), 838, 59, 59)); s != "-838:59:59" {
+		t.Errorf("unexpected time %s", s)
+	}
+}
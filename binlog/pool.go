@@ -0,0 +1,73 @@
+package binlog
+
+import "sync"
+
+// maxPooledBufferSize is the capacity above which the buffers are not returned to the pool,
+// so that the rare huge events don't hold the memory.
+const maxPooledBufferSize = 1 << 20
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 4096)
+		return &buf
+	},
+}
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(buf *[]byte) {
+	if cap(*buf) > maxPooledBufferSize {
+		return
+	}
+	*buf = (*buf)[:0]
+	bufferPool.Put(buf)
+}
+
+// poolable reports whether the buffer of the event type can be reused after the event is released.
+// TableMapEvent and FormatDescriptionEvent are kept by the EventDecoder to decode the following events.
+func poolable(typ EventType) bool {
+	return typ != TableMapEventType && typ != FormatDescriptionEventType
+}
+
+// holdBuffer attaches the buffer of the event data to the event to be released later,
+// or returns it to the pool at once if the event is filtered out.
+func holdBuffer(ev Event, typ EventType, buf *[]byte) {
+	switch {
+	case !poolable(typ):
+	case ev == nil:
+		putBuffer(buf)
+	default:
+		if h, ok := ev.(interface {
+			hold(buf *[]byte)
+		}); ok {
+			h.hold(buf)
+		}
+	}
+}
+
+// Release returns the buffer of the event read with Streamer.PoolBuffers or FileReader.PoolBuffers to
+// the pool, the event and the []byte values of it must not be used afterwards. It does nothing for
+// the other events, and it's safe to be called more than once.
+func Release(ev Event) {
+	if se, ok := ev.(*SourceEvent); ok {
+		ev = se.Event
+	}
+	if r, ok := ev.(interface {
+		release()
+	}); ok {
+		r.release()
+	}
+}
+
+func (e *baseEvent) release() {
+	if e.buf != nil {
+		putBuffer(e.buf)
+		e.buf = nil
+	}
+}
+
+func (e *baseEvent) hold(buf *[]byte) {
+	e.buf = buf
+}
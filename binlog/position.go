@@ -0,0 +1,138 @@
+package binlog
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Position is a position in the binlog.
+type Position struct {
+	File string
+	Pos  uint32
+	// GTIDSet is the textual form of the GTID set executed up to the position, empty if GTIDs are not enabled.
+	GTIDSet string
+}
+
+func (p Position) String() string {
+	if p.GTIDSet == "" {
+		return fmt.Sprintf("%s:%d", p.File, p.Pos)
+	}
+	return fmt.Sprintf("%s:%d(%s)", p.File, p.Pos, p.GTIDSet)
+}
+
+// PositionStore persists the position of the consumed transactions, so that the application can resume from
+// where it left off after restart.
+type PositionStore interface {
+	// Load returns the saved position, or the zero Position if nothing is saved.
+	Load() (Position, error)
+	Save(pos Position) error
+}
+
+// FilePositionStore saves the position into a JSON file.
+type FilePositionStore struct {
+	Path string
+}
+
+type positionJSON struct {
+	File    string `json:"file"`
+	Pos     uint32 `json:"pos"`
+	GTIDSet string `json:"gtid_set,omitempty"`
+}
+
+func (s *FilePositionStore) Load() (Position, error) {
+	data, err := ioutil.ReadFile(s.Path)
+	if os.IsNotExist(err) {
+		return Position{}, nil
+	}
+	if err != nil {
+		return Position{}, err
+	}
+	var p positionJSON
+	if err = json.Unmarshal(data, &p); err != nil {
+		return Position{}, fmt.Errorf("invalid position file %s: %v", s.Path, err)
+	}
+	return Position{File: p.File, Pos: p.Pos, GTIDSet: p.GTIDSet}, nil
+}
+
+// Save writes the position into a temporary file and renames it to Path,
+// so that a crash never leaves a partially written file.
+func (s *FilePositionStore) Save(pos Position) error {
+	data, err := json.Marshal(positionJSON{File: pos.File, Pos: pos.Pos, GTIDSet: pos.GTIDSet})
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.Path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// SQLPositionStore saves the position into a row of a MySQL table, which can be updated in the same database
+// as the replicated data. The table is created by CreateTable:
+//
+//	CREATE TABLE IF NOT EXISTS <Table> (
+//	    name VARCHAR(255) NOT NULL PRIMARY KEY,
+//	    file VARCHAR(255) NOT NULL,
+//	    pos INT UNSIGNED NOT NULL,
+//	    gtid_set TEXT NOT NULL
+//	)
+type SQLPositionStore struct {
+	DB *sql.DB
+	// Table is the name of the table, default is binlog_position.
+	Table string
+	// Name identifies the row of the position, which allows multiple consumers to share the table.
+	Name string
+}
+
+func (s *SQLPositionStore) table() string {
+	if s.Table == "" {
+		return "binlog_position"
+	}
+	return s.Table
+}
+
+// CreateTable creates the table if it doesn't exist.
+func (s *SQLPositionStore) CreateTable() error {
+	_, err := s.DB.Exec("CREATE TABLE IF NOT EXISTS " + s.table() + ` (
+		name VARCHAR(255) NOT NULL PRIMARY KEY,
+		file VARCHAR(255) NOT NULL,
+		pos INT UNSIGNED NOT NULL,
+		gtid_set TEXT NOT NULL
+	)`)
+	return err
+}
+
+func (s *SQLPositionStore) Load() (Position, error) {
+	var pos Position
+	err := s.DB.QueryRow("SELECT file, pos, gtid_set FROM "+s.table()+" WHERE name = ?", s.Name).
+		Scan(&pos.File, &pos.Pos, &pos.GTIDSet)
+	if err == sql.ErrNoRows {
+		return Position{}, nil
+	}
+	return pos, err
+}
+
+func (s *SQLPositionStore) Save(pos Position) error {
+	_, err := s.DB.Exec("INSERT INTO "+s.table()+" (name, file, pos, gtid_set) VALUES (?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE file = VALUES(file), pos = VALUES(pos), gtid_set = VALUES(gtid_set)",
+		s.Name, pos.File, pos.Pos, pos.GTIDSet)
+	return err
+}
@@ -0,0 +1,237 @@
+package binlog
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// the codes of the status variables of QueryEvent
+const (
+	qFlags2Code                   = 0
+	qSQLModeCode                  = 1
+	qCatalogCode                  = 2
+	qAutoIncrement                = 3
+	qCharsetCode                  = 4
+	qTimeZoneCode                 = 5
+	qCatalogNZCode                = 6
+	qLCTimeNamesCode              = 7
+	qCharsetDatabaseCode          = 8
+	qTableMapForUpdateCode        = 9
+	qMasterDataWrittenCode        = 10
+	qInvoker                      = 11
+	qUpdatedDBNames               = 12
+	qMicroseconds                 = 13
+	qExplicitDefaultsForTimestamp = 16
+	qDDLLoggedWithXID             = 17
+	qDefaultCollationForUtf8mb4   = 18
+	qSQLRequirePrimaryKey         = 19
+	qDefaultTableEncryption       = 20
+	qMariadbHRNow                 = 128
+	qMariadbXID                   = 129
+	overMaxDBsInEventMTS          = 254
+)
+
+// The bits of QueryStatus.Flags2.
+const (
+	QueryFlags2AutoIsNull          uint32 = 1 << 14
+	QueryFlags2NotAutocommit       uint32 = 1 << 19
+	QueryFlags2NoForeignKeyChecks  uint32 = 1 << 26
+	QueryFlags2RelaxedUniqueChecks uint32 = 1 << 27
+)
+
+// SQLMode is the bitmap of `sql_mode`.
+type SQLMode uint64
+
+var sqlModeNames = []string{
+	"REAL_AS_FLOAT", "PIPES_AS_CONCAT", "ANSI_QUOTES", "IGNORE_SPACE", "NOT_USED", "ONLY_FULL_GROUP_BY",
+	"NO_UNSIGNED_SUBTRACTION", "NO_DIR_IN_CREATE", "POSTGRESQL", "ORACLE", "MSSQL", "DB2", "MAXDB",
+	"NO_KEY_OPTIONS", "NO_TABLE_OPTIONS", "NO_FIELD_OPTIONS", "MYSQL323", "MYSQL40", "ANSI",
+	"NO_AUTO_VALUE_ON_ZERO", "NO_BACKSLASH_ESCAPES", "STRICT_TRANS_TABLES", "STRICT_ALL_TABLES",
+	"NO_ZERO_IN_DATE", "NO_ZERO_DATE", "ALLOW_INVALID_DATES", "ERROR_FOR_DIVISION_BY_ZERO", "TRADITIONAL",
+	"NO_AUTO_CREATE_USER", "HIGH_NOT_PRECEDENCE", "NO_ENGINE_SUBSTITUTION", "PAD_CHAR_TO_FULL_LENGTH",
+	"TIME_TRUNCATE_FRACTIONAL",
+}
+
+// String returns the comma separated mode names like the value of `sql_mode`.
+func (m SQLMode) String() string {
+	var names []string
+	for i, name := range sqlModeNames {
+		if m&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// QueryStatus is the session state of the statement of QueryEvent, parsed from the status variables.
+// The fields are zero if the variables are not written, the ones whose zero values are meaningful are pointers.
+type QueryStatus struct {
+	// Flags2 is the bitmap of the session options, see QueryFlags2AutoIsNull etc.
+	Flags2  *uint32
+	SQLMode *SQLMode
+	Catalog string
+	// AutoIncrementIncrement and AutoIncrementOffset are written if either is not 1.
+	AutoIncrementIncrement uint16
+	AutoIncrementOffset    uint16
+	// CharsetClient is the charset of `character_set_client`, the others are collations, all are the ids.
+	CharsetClient       uint16
+	CollationConnection uint16
+	CollationServer     uint16
+	CollationDatabase   uint16
+	TimeZone            string
+	LCTimeNames         uint16
+	TableMapForUpdate   uint64
+	MasterDataWritten   uint32
+	InvokerUser         string
+	InvokerHost         string
+	// UpdatedDBNames are the databases updated by the statement, nil if there are too many to be written.
+	UpdatedDBNames []string
+	// Microseconds is the fraction of the start time of the statement.
+	Microseconds                 uint32
+	ExplicitDefaultsForTimestamp *bool
+	// DDLXID is the XID of the DDL statement written by the atomic DDL of 8.0.
+	DDLXID                     uint64
+	DefaultCollationForUtf8mb4 uint16
+	SQLRequirePrimaryKey       *bool
+	DefaultTableEncryption     *bool
+	// MariadbXID is the XID of the DDL statement written by MariaDB.
+	MariadbXID uint64
+}
+
+// parseQueryStatus parses the status variables. The parsing stops at an unknown code since the lengths of
+// the values are implied by the codes, like the replicas do.
+func parseQueryStatus(data []byte) (QueryStatus, error) {
+	var s QueryStatus
+	p := newBinlogPacket(data)
+	for !p.EOF() && p.Err() == nil {
+		code := p.readByte()
+		switch code {
+		case qFlags2Code:
+			v := p.readUint32()
+			s.Flags2 = &v
+		case qSQLModeCode:
+			v := SQLMode(p.readUint64())
+			s.SQLMode = &v
+		case qCatalogCode:
+			s.Catalog = string(p.Read(int(p.readByte())))
+			// terminated by NUL
+			p.Skip(1)
+		case qAutoIncrement:
+			s.AutoIncrementIncrement = p.readUint16()
+			s.AutoIncrementOffset = p.readUint16()
+		case qCharsetCode:
+			s.CharsetClient = p.readUint16()
+			s.CollationConnection = p.readUint16()
+			s.CollationServer = p.readUint16()
+		case qTimeZoneCode:
+			s.TimeZone = string(p.Read(int(p.readByte())))
+		case qCatalogNZCode:
+			s.Catalog = string(p.Read(int(p.readByte())))
+		case qLCTimeNamesCode:
+			s.LCTimeNames = p.readUint16()
+		case qCharsetDatabaseCode:
+			s.CollationDatabase = p.readUint16()
+		case qTableMapForUpdateCode:
+			s.TableMapForUpdate = p.readUint64()
+		case qMasterDataWrittenCode:
+			s.MasterDataWritten = p.readUint32()
+		case qInvoker:
+			s.InvokerUser = string(p.Read(int(p.readByte())))
+			s.InvokerHost = string(p.Read(int(p.readByte())))
+		case qUpdatedDBNames:
+			count := int(p.readByte())
+			if count == overMaxDBsInEventMTS {
+				break
+			}
+			s.UpdatedDBNames = make([]string, 0, count)
+			for i := 0; i < count && p.Err() == nil; i++ {
+				rest := p.Raw()[p.Pos():]
+				end := bytes.IndexByte(rest, 0)
+				if end < 0 {
+					return s, fmt.Errorf("unterminated updated database name")
+				}
+				s.UpdatedDBNames = append(s.UpdatedDBNames, string(p.Read(end)))
+				p.Skip(1)
+			}
+		case qMicroseconds, qMariadbHRNow:
+			s.Microseconds = p.readUint24()
+		case qExplicitDefaultsForTimestamp:
+			v := p.readByte() != 0
+			s.ExplicitDefaultsForTimestamp = &v
+		case qDDLLoggedWithXID:
+			s.DDLXID = p.readUint64()
+		case qDefaultCollationForUtf8mb4:
+			s.DefaultCollationForUtf8mb4 = p.readUint16()
+		case qSQLRequirePrimaryKey:
+			v := p.readByte() != 0
+			s.SQLRequirePrimaryKey = &v
+		case qDefaultTableEncryption:
+			v := p.readByte() != 0
+			s.DefaultTableEncryption = &v
+		case qMariadbXID:
+			s.MariadbXID = p.readUint64()
+		default:
+			return s, nil
+		}
+	}
+	return s, p.Err()
+}
+
+// SetStatement returns the SET statement restoring the session variables of the status to replay the query
+// like mysqlbinlog does, it's empty if there are none.
+func (s *QueryStatus) SetStatement() string {
+	var vars []string
+	set := func(name string, value interface{}) {
+		vars = append(vars, fmt.Sprintf("@@session.%s=%v", name, value))
+	}
+	bit := func(b bool) int {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	if s.Flags2 != nil {
+		set("foreign_key_checks", bit(*s.Flags2&QueryFlags2NoForeignKeyChecks == 0))
+		set("sql_auto_is_null", bit(*s.Flags2&QueryFlags2AutoIsNull != 0))
+		set("unique_checks", bit(*s.Flags2&QueryFlags2RelaxedUniqueChecks == 0))
+		set("autocommit", bit(*s.Flags2&QueryFlags2NotAutocommit == 0))
+	}
+	if s.SQLMode != nil {
+		set("sql_mode", uint64(*s.SQLMode))
+	}
+	if s.AutoIncrementIncrement != 0 {
+		set("auto_increment_increment", s.AutoIncrementIncrement)
+		set("auto_increment_offset", s.AutoIncrementOffset)
+	}
+	if s.CharsetClient != 0 {
+		set("character_set_client", s.CharsetClient)
+		set("collation_connection", s.CollationConnection)
+		set("collation_server", s.CollationServer)
+	}
+	if s.TimeZone != "" {
+		set("time_zone", fmt.Sprintf("'%s'", strings.Replace(s.TimeZone, "'", "''", -1)))
+	}
+	if s.LCTimeNames != 0 {
+		set("lc_time_names", s.LCTimeNames)
+	}
+	if s.CollationDatabase != 0 {
+		set("collation_database", s.CollationDatabase)
+	}
+	if s.ExplicitDefaultsForTimestamp != nil {
+		set("explicit_defaults_for_timestamp", bit(*s.ExplicitDefaultsForTimestamp))
+	}
+	if s.DefaultCollationForUtf8mb4 != 0 {
+		set("default_collation_for_utf8mb4", s.DefaultCollationForUtf8mb4)
+	}
+	if s.SQLRequirePrimaryKey != nil {
+		set("sql_require_primary_key", bit(*s.SQLRequirePrimaryKey))
+	}
+	if s.DefaultTableEncryption != nil {
+		set("default_table_encryption", bit(*s.DefaultTableEncryption))
+	}
+	if len(vars) == 0 {
+		return ""
+	}
+	return "SET " + strings.Join(vars, ", ")
+}
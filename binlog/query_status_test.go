@@ -0,0 +1,66 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestQueryStatus(t *testing.T) {
+	status := []byte{0, 0, 0, 0, 0}
+	status = append(status, 1, 0x20, 0, 0xa0, 0x55, 0, 0, 0, 0)
+	status = append(status, 6, 3, 's', 't', 'd')
+	status = append(status, 4, 33, 0, 33, 0, 8, 0)
+	status = append(status, 5, 6, '+', '0', '8', ':', '0', '0')
+	status = append(status, 12, 2, 'a', 0, 'b', 0)
+	status = append(status, 13, 0x40, 0xe2, 0x01)
+	status = append(status, 16, 1)
+	// an unknown code stops the parsing
+	status = append(status, 200, 1, 2, 3)
+
+	// thread id, execution time, database length, error code, status vars length
+	body := make([]byte, 13)
+	body[8] = 4
+	binary.LittleEndian.PutUint16(body[11:], uint16(len(status)))
+	body = append(body, status...)
+	body = append(body, "test\x00CREATE TABLE t (id int)"...)
+	ev, err := new(EventDecoder).decode(buildEvent(QueryEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := ev.(*QueryEvent)
+	if string(e.Database) != "test" || string(e.Query) != "CREATE TABLE t (id int)" {
+		t.Fatalf("unexpected query %q of %q", e.Query, e.Database)
+	}
+
+	s := e.Status
+	if s.Flags2 == nil || *s.Flags2 != 0 || s.SQLMode == nil || *s.SQLMode != 1436549152 {
+		t.Errorf("unexpected flags2 %v and sql_mode %v", s.Flags2, s.SQLMode)
+	}
+	if s.Catalog != "std" || s.CharsetClient != 33 || s.CollationConnection != 33 || s.CollationServer != 8 ||
+		s.TimeZone != "+08:00" || s.Microseconds != 123456 {
+		t.Errorf("unexpected status %+v", s)
+	}
+	if !reflect.DeepEqual(s.UpdatedDBNames, []string{"a", "b"}) {
+		t.Errorf("unexpected updated databases %v", s.UpdatedDBNames)
+	}
+	if s.ExplicitDefaultsForTimestamp == nil || !*s.ExplicitDefaultsForTimestamp {
+		t.Errorf("expected explicit_defaults_for_timestamp")
+	}
+
+	expected := "ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION"
+	if s.SQLMode.String() != expected {
+		t.Errorf("expected sql_mode %s, got %s", expected, s.SQLMode)
+	}
+	expected = "SET @@session.foreign_key_checks=1, @@session.sql_auto_is_null=0, @@session.unique_checks=1, " +
+		"@@session.autocommit=1, @@session.sql_mode=1436549152, @@session.character_set_client=33, " +
+		"@@session.collation_connection=33, @@session.collation_server=8, @@session.time_zone='+08:00', " +
+		"@@session.explicit_defaults_for_timestamp=1"
+	if stmt := s.SetStatement(); stmt != expected {
+		t.Errorf("expected %s, got %s", expected, stmt)
+	}
+
+	if _, err = parseQueryStatus([]byte{1, 0, 0}); err == nil {
+		t.Error("expected the error of the truncated sql_mode")
+	}
+}
@@ -0,0 +1,55 @@
+package binlog
+
+import (
+	"context"
+	"time"
+)
+
+// tokenBucket limits the rate of the tokens taken, the burst is the tokens of a second. The tokens more than
+// available are borrowed from the future, so that a packet larger than the burst is delayed instead of blocked.
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// take takes n tokens and returns how long to wait until they're available.
+func (b *tokenBucket) take(now time.Time, n float64) time.Duration {
+	if b.last.IsZero() {
+		b.tokens = b.rate
+	} else if b.tokens += now.Sub(b.last).Seconds() * b.rate; b.tokens > b.rate {
+		b.tokens = b.rate
+	}
+	b.last = now
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// throttle waits for the packet according to MaxEventsPerSecond and MaxBytesPerSecond,
+// it returns ctx.Err() if ctx is done before.
+func (s *Streamer) throttle(ctx context.Context, size int) error {
+	now := time.Now()
+	var wait time.Duration
+	if s.eventBucket != nil {
+		wait = s.eventBucket.take(now, 1)
+	}
+	if s.byteBucket != nil {
+		if d := s.byteBucket.take(now, float64(size)); d > wait {
+			wait = d
+		}
+	}
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
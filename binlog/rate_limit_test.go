@@ -0,0 +1,50 @@
+package binlog
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := &tokenBucket{rate: 10}
+	now := time.Unix(1500000000, 0)
+	// the burst of a second
+	for i := 0; i < 10; i++ {
+		if wait := b.take(now, 1); wait != 0 {
+			t.Fatalf("expected token %d available, got wait %v", i, wait)
+		}
+	}
+	if wait := b.take(now, 1); wait != 100*time.Millisecond {
+		t.Errorf("expected wait 100ms, got %v", wait)
+	}
+	// the tokens are refilled up to the burst
+	now = now.Add(time.Hour)
+	if wait := b.take(now, 10); wait != 0 {
+		t.Errorf("expected the burst available, got wait %v", wait)
+	}
+	// a take larger than the burst is delayed
+	if wait := b.take(now, 25); wait != 2500*time.Millisecond {
+		t.Errorf("expected wait 2.5s, got %v", wait)
+	}
+}
+
+func TestStreamerThrottle(t *testing.T) {
+	s := &Streamer{byteBucket: &tokenBucket{rate: 1000}}
+	if err := s.throttle(context.Background(), 1000); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := s.throttle(context.Background(), 20); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("expected the packet delayed about 20ms, got %v", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.throttle(ctx, 1000); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
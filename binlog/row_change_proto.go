@@ -0,0 +1,272 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+)
+
+// RowOp is the operation of a row change.
+type RowOp int32
+
+const (
+	RowOpUnknown RowOp = iota
+	RowOpInsert
+	RowOpUpdate
+	RowOpDelete
+)
+
+func (op RowOp) String() string {
+	switch op {
+	case RowOpInsert:
+		return "INSERT"
+	case RowOpUpdate:
+		return "UPDATE"
+	case RowOpDelete:
+		return "DELETE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ColumnValue is a column value of RowChangeMessage, the columns are kept in the table order.
+type ColumnValue struct {
+	Name  string
+	Value interface{}
+}
+
+// RowChangeMessage is a row change with a stable schema for the CDC pipelines,
+// it's encoded as the RowChange message defined in row_change.proto.
+type RowChangeMessage struct {
+	Database   string
+	Table      string
+	Op         RowOp
+	PrimaryKey []string
+	Before     []ColumnValue
+	After      []ColumnValue
+	Timestamp  uint32
+	ServerID   uint32
+	NextLogPos uint32
+}
+
+// Op returns the operation of the rows event.
+func (e *RowsEvent) Op() RowOp {
+	switch {
+	case e.isUpdate():
+		return RowOpUpdate
+	case e.isDelete():
+		return RowOpDelete
+	default:
+		return RowOpInsert
+	}
+}
+
+// RowChangeMessages converts the rows of the event into RowChangeMessages, the rows of a Deferred event are
+// decoded like RowMaps.
+func (e *RowsEvent) RowChangeMessages() []*RowChangeMessage {
+	op := e.Op()
+	primaryKey := e.Table.PrimaryKeyColumns()
+	rows, _ := e.allRows()
+
+	var messages []*RowChangeMessage
+	for i := 0; i < len(rows); i++ {
+		m := &RowChangeMessage{
+			Database:   string(e.Table.Database),
+			Table:      string(e.Table.TableName),
+			Op:         op,
+			PrimaryKey: primaryKey,
+			Timestamp:  e.header.Timestamp,
+			ServerID:   e.header.ServerID,
+			NextLogPos: e.header.NextLogPos,
+		}
+		switch op {
+		case RowOpUpdate:
+			m.Before = e.columnValues(e.Columns, rows[i])
+			i++
+			m.After = e.columnValues(e.UpdatedColumns, rows[i])
+		case RowOpDelete:
+			m.Before = e.columnValues(e.Columns, rows[i])
+		default:
+			m.After = e.columnValues(e.Columns, rows[i])
+		}
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+func (e *RowsEvent) columnValues(includedColumns []byte, row []interface{}) []ColumnValue {
+	values := make([]ColumnValue, 0, len(row))
+	for j := 0; j < int(e.ColumnCount); j++ {
+		if isBitSet(includedColumns, j) {
+			values = append(values, ColumnValue{Name: e.Table.ColumnName(j), Value: row[len(values)]})
+		}
+	}
+	return values
+}
+
+// PrimaryKeyColumns returns the names of the primary key columns, nil if they are unknown.
+func (e *TableMapEvent) PrimaryKeyColumns() []string {
+	var names []string
+	for _, i := range e.primaryKey() {
+		names = append(names, e.ColumnName(i))
+	}
+	return names
+}
+
+// primaryKey returns the indexes of the primary key columns from the optional metadata or the DB.
+func (e *TableMapEvent) primaryKey() []int {
+	if e.PrimaryKey != nil {
+		return e.PrimaryKey
+	}
+	var indexes []int
+	for i, c := range e.columns {
+		if c.IsPrimary {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// MarshalProto encodes the message in the protobuf wire format.
+func (m *RowChangeMessage) MarshalProto() ([]byte, error) {
+	var buf []byte
+	buf = appendProtoString(buf, 1, m.Database)
+	buf = appendProtoString(buf, 2, m.Table)
+	buf = appendProtoVarint(buf, 3, uint64(m.Op))
+	for _, name := range m.PrimaryKey {
+		buf = appendProtoBytes(buf, 4, []byte(name))
+	}
+	for _, field := range []struct {
+		num    int
+		values []ColumnValue
+	}{{5, m.Before}, {6, m.After}} {
+		for _, v := range field.values {
+			column, err := v.marshalProto()
+			if err != nil {
+				return nil, err
+			}
+			buf = appendProtoBytes(buf, field.num, column)
+		}
+	}
+	buf = appendProtoVarint(buf, 7, uint64(m.Timestamp))
+	buf = appendProtoVarint(buf, 8, uint64(m.ServerID))
+	buf = appendProtoVarint(buf, 9, uint64(m.NextLogPos))
+	return buf, nil
+}
+
+func (v ColumnValue) marshalProto() ([]byte, error) {
+	buf := appendProtoString(nil, 1, v.Name)
+	// the fields of oneof are always written even if they are zero values
+	switch value := v.Value.(type) {
+	case nil:
+		buf = appendProtoTag(buf, 2, 0)
+		buf = appendUvarint(buf, 1)
+	case int:
+		buf = appendProtoSint(buf, 3, int64(value))
+	case int8:
+		buf = appendProtoSint(buf, 3, int64(value))
+	case int16:
+		buf = appendProtoSint(buf, 3, int64(value))
+	case int32:
+		buf = appendProtoSint(buf, 3, int64(value))
+	case int64:
+		buf = appendProtoSint(buf, 3, value)
+	case uint8:
+		buf = appendProtoUint(buf, 4, uint64(value))
+	case uint16:
+		buf = appendProtoUint(buf, 4, uint64(value))
+	case uint32:
+		buf = appendProtoUint(buf, 4, uint64(value))
+	case uint64:
+		buf = appendProtoUint(buf, 4, value)
+	case float32:
+		buf = appendProtoDouble(buf, 5, float64(value))
+	case float64:
+		buf = appendProtoDouble(buf, 5, value)
+	case string:
+		buf = appendProtoBytes(buf, 6, []byte(value))
+	case []byte:
+		buf = appendProtoBytes(buf, 7, value)
+	case time.Time:
+		buf = appendProtoBytes(buf, 6, []byte(value.Format(time.RFC3339Nano)))
+	case time.Duration:
+		buf = appendProtoBytes(buf, 6, []byte(formatTimeDuration(value)))
+	case *big.Rat:
+		buf = appendProtoBytes(buf, 6, []byte(value.FloatString(ratScale(value))))
+	case fmt.Stringer:
+		buf = appendProtoBytes(buf, 6, []byte(value.String()))
+	default:
+		// the parsed JSON values
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", v.Name, err)
+		}
+		buf = appendProtoBytes(buf, 6, data)
+	}
+	return buf, nil
+}
+
+// ratScale returns the number of decimal digits needed to represent the DECIMAL value r exactly,
+// which is at most 30.
+func ratScale(r *big.Rat) int {
+	scale := 0
+	for pow, ten, mod := big.NewInt(1), big.NewInt(10), new(big.Int); scale < 30; scale++ {
+		if mod.Mod(pow, r.Denom()).Sign() == 0 {
+			break
+		}
+		pow.Mul(pow, ten)
+	}
+	return scale
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	return append(buf, b[:n]...)
+}
+
+func appendProtoTag(buf []byte, num int, wireType byte) []byte {
+	return appendUvarint(buf, uint64(num)<<3|uint64(wireType))
+}
+
+// appendProtoVarint appends a varint field, it's omitted if v is zero as proto3 does.
+func appendProtoVarint(buf []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return buf
+	}
+	return appendProtoUint(buf, num, v)
+}
+
+func appendProtoUint(buf []byte, num int, v uint64) []byte {
+	buf = appendProtoTag(buf, num, 0)
+	return appendUvarint(buf, v)
+}
+
+func appendProtoSint(buf []byte, num int, v int64) []byte {
+	// zigzag encoding
+	return appendProtoUint(buf, num, uint64(v<<1)^uint64(v>>63))
+}
+
+func appendProtoDouble(buf []byte, num int, v float64) []byte {
+	buf = appendProtoTag(buf, num, 1)
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+	return append(buf, b[:]...)
+}
+
+// appendProtoString appends a string field, it's omitted if s is empty as proto3 does.
+func appendProtoString(buf []byte, num int, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	return appendProtoBytes(buf, num, []byte(s))
+}
+
+func appendProtoBytes(buf []byte, num int, data []byte) []byte {
+	buf = appendProtoTag(buf, num, 2)
+	buf = appendUvarint(buf, uint64(len(data)))
+	return append(buf, data...)
+}
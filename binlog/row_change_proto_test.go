@@ -0,0 +1,222 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRowChangeMessages(t *testing.T) {
+	table := &TableMapEvent{
+		Database:    []byte("db"),
+		TableName:   []byte("t"),
+		ColumnCount: 2,
+		ColumnTypes: []byte{fieldTypeLong, fieldTypeVarChar},
+		columns:     []*Column{{Name: "id", IsPrimary: true}, {Name: "v"}},
+	}
+	e := &RowsEvent{
+		baseEvent:      &baseEvent{header: &EventHeader{Type: UpdateRowsEventType, ServerID: 1}},
+		Table:          table,
+		ColumnCount:    2,
+		Columns:        []byte{0x03},
+		UpdatedColumns: []byte{0x03},
+		Rows:           [][]interface{}{{int64(-1), nil}, {int64(-1), "a"}},
+	}
+
+	messages := e.RowChangeMessages()
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+	m := messages[0]
+	if m.Op != RowOpUpdate || len(m.PrimaryKey) != 1 || m.PrimaryKey[0] != "id" || len(m.Before) != 2 || len(m.After) != 2 {
+		t.Fatalf("unexpected message %+v", m)
+	}
+
+	data, err := m.MarshalProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		0x0a, 2, 'd', 'b', // database
+		0x12, 1, 't', // table
+		0x18, 2, // op
+		0x22, 2, 'i', 'd', // primary_key
+		0x2a, 6, 0x0a, 2, 'i', 'd', 0x18, 1, // before id: sint64 -1
+		0x2a, 5, 0x0a, 1, 'v', 0x10, 1, // before v: null
+		0x32, 6, 0x0a, 2, 'i', 'd', 0x18, 1, // after id
+		0x32, 6, 0x0a, 1, 'v', 0x32, 1, 'a', // after v: string
+		0x40, 1, // server_id
+	}
+	if !bytes.Equal(data, expected) {
+		t.Errorf("expected %x, got %x", expected, data)
+	}
+}
+
+func TestColumnValueMarshalProtoDecimal(t *testing.T) {
+	data, err := ColumnValue{Value: big.NewRat(-5, 4)}.marshalProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := append([]byte{0x32, 5}, "-1.25"...); !bytes.Equal(data, expected) {
+		t.Errorf("expected %x, got %x", expected, data)
+	}
+}
+
+// unmarshalProto decodes the RowChange message of row_change.proto back into a RowChangeMessage,
+// the values of the string fields are decoded as strings.
+func unmarshalProto(data []byte) (*RowChangeMessage, error) {
+	m := &RowChangeMessage{}
+	err := walkProto(data, func(num int, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			m.Database = string(b)
+		case 2:
+			m.Table = string(b)
+		case 3:
+			m.Op = RowOp(v)
+		case 4:
+			m.PrimaryKey = append(m.PrimaryKey, string(b))
+		case 5, 6:
+			column, err := unmarshalColumnProto(b)
+			if err != nil {
+				return err
+			}
+			if num == 5 {
+				m.Before = append(m.Before, column)
+			} else {
+				m.After = append(m.After, column)
+			}
+		case 7:
+			m.Timestamp = uint32(v)
+		case 8:
+			m.ServerID = uint32(v)
+		case 9:
+			m.NextLogPos = uint32(v)
+		default:
+			return fmt.Errorf("unknown field %d", num)
+		}
+		return nil
+	})
+	return m, err
+}
+
+func unmarshalColumnProto(data []byte) (ColumnValue, error) {
+	var c ColumnValue
+	err := walkProto(data, func(num int, v uint64, b []byte) error {
+		switch num {
+		case 1:
+			c.Name = string(b)
+		case 2:
+			c.Value = nil
+		case 3:
+			c.Value = int64(v>>1) ^ -int64(v&1)
+		case 4:
+			c.Value = v
+		case 5:
+			c.Value = math.Float64frombits(v)
+		case 6:
+			c.Value = string(b)
+		case 7:
+			c.Value = b
+		default:
+			return fmt.Errorf("unknown column field %d", num)
+		}
+		return nil
+	})
+	return c, err
+}
+
+// walkProto calls fn with the number and the value of every field, v is the value of the varint and fixed64
+// fields and b is the value of the length-delimited ones.
+func walkProto(data []byte, fn func(num int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("bad tag")
+		}
+		data = data[n:]
+		var v uint64
+		var b []byte
+		switch tag & 7 {
+		case 0:
+			if v, n = binary.Uvarint(data); n <= 0 {
+				return fmt.Errorf("bad varint of field %d", tag>>3)
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return fmt.Errorf("short fixed64 of field %d", tag>>3)
+			}
+			v, data = binary.LittleEndian.Uint64(data), data[8:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return fmt.Errorf("bad length of field %d", tag>>3)
+			}
+			b, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return fmt.Errorf("unexpected wire type %d of field %d", tag&7, tag>>3)
+		}
+		if err := fn(int(tag>>3), v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRowChangeMessageRoundTrip(t *testing.T) {
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	m := &RowChangeMessage{
+		Database:   "db",
+		Table:      "t",
+		Op:         RowOpUpdate,
+		PrimaryKey: []string{"id", "k"},
+		Before: []ColumnValue{
+			{Name: "id", Value: int64(math.MinInt64)},
+			{Name: "k", Value: uint64(math.MaxUint64)},
+			{Name: "n", Value: nil},
+		},
+		After: []ColumnValue{
+			{Name: "id", Value: int32(-7)},
+			{Name: "k", Value: uint8(0)},
+			{Name: "f", Value: 1.5},
+			{Name: "s", Value: ""},
+			{Name: "b", Value: []byte{0, 0xff}},
+			{Name: "d", Value: big.NewRat(-5, 4)},
+			{Name: "ts", Value: ts},
+			{Name: "tm", Value: -90 * time.Minute},
+		},
+		Timestamp:  1577934245,
+		ServerID:   2,
+		NextLogPos: 4096,
+	}
+	data, err := m.MarshalProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := unmarshalProto(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the other types than int64, uint64, float64, string and []byte are decoded by the proto types
+	expected := *m
+	expected.After = []ColumnValue{
+		{Name: "id", Value: int64(-7)},
+		{Name: "k", Value: uint64(0)},
+		{Name: "f", Value: 1.5},
+		{Name: "s", Value: ""},
+		{Name: "b", Value: []byte{0, 0xff}},
+		{Name: "d", Value: "-1.25"},
+		{Name: "ts", Value: "2020-01-02T03:04:05.6Z"},
+		{Name: "tm", Value: "-01:30:00"},
+	}
+	if !reflect.DeepEqual(decoded, &expected) {
+		t.Errorf("expected %+v, got %+v", &expected, decoded)
+	}
+}
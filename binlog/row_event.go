@@ -1,9 +1,11 @@
 package binlog
 
 import (
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
+	"sync"
 )
 
 type TableMapEvent struct {
@@ -16,12 +18,26 @@ type TableMapEvent struct {
 	ColumnTypes       []byte
 	ColumnMeta        []uint16
 	ColumnNullability []byte
+
+	// The optional metadata written by 8.0 if binlog_row_metadata is set, the column names, ENUM/SET values
+	// and primary key are written only if it's FULL. The slices are indexed by column, nil if absent.
+	UnsignedColumns []bool
+	ColumnCharsets  []uint64
+	ColumnNames     []string
+	EnumValues      [][]string
+	SetValues       [][]string
+	PrimaryKey      []int
+
+	// optionalMetadata is the raw optional metadata written back by Encode
+	optionalMetadata []byte
+	columns          []*Column
+	// filtered is true if the table is excluded by EventFilter
+	filtered bool
 }
 
 func (e *TableMapEvent) Decode(dec *EventDecoder) error {
 	packet := e.header.packet
-	data := append(packet.Read(6), 0, 0)
-	e.TableID = binary.LittleEndian.Uint64(data)
+	e.TableID = packet.ReadUintBySize(dec.tableIDSize(e.header.Type))
 	e.Flags = packet.readUint16()
 
 	databaseLen := packet.readByte()
@@ -40,18 +56,59 @@ func (e *TableMapEvent) Decode(dec *EventDecoder) error {
 		return err
 	}
 	e.ColumnMeta = columnMeta
-	e.ColumnNullability = packet.Read(-1)
-	if len(e.ColumnNullability) != int(e.ColumnCount+7)>>3 {
+	if packet.Len()-packet.Pos() < int(e.ColumnCount+7)>>3 {
 		return io.ErrUnexpectedEOF
 	}
+	e.ColumnNullability = packet.Read(int(e.ColumnCount+7) >> 3)
+	e.optionalMetadata = packet.Read(-1)
+	if err = e.decodeOptionalMetadata(e.optionalMetadata); err != nil {
+		return err
+	}
+	e.columns = e.metadataColumns()
 	return nil
 }
 
 func (e *TableMapEvent) postDecode(dec *EventDecoder) error {
-	dec.tables[e.TableID] = e
+	dec.putTable(e)
+	if !dec.Filter.includeTable(string(e.Database), string(e.TableName)) {
+		e.filtered = true
+		return errEventFiltered
+	}
+	if e.columns == nil && (dec.DB != nil || dec.Schema != nil) {
+		columns, err := dec.tableColumns(string(e.Database), string(e.TableName))
+		if err != nil {
+			return err
+		}
+		// the table may have been altered since this event was written,
+		// fall back to positional decoding if the columns don't match
+		if len(columns) == int(e.ColumnCount) {
+			e.columns = columns
+		}
+	}
 	return nil
 }
 
+// Columns returns the metadata of the columns from the optional metadata, the DB or the Schema of the EventDecoder,
+// nil if it's unknown.
+func (e *TableMapEvent) Columns() []*Column {
+	return e.columns
+}
+
+// ColumnName returns the name of the i-th column, or its position like "@1" if the name is unknown.
+func (e *TableMapEvent) ColumnName(i int) string {
+	if e.columns != nil {
+		return e.columns[i].Name
+	}
+	return "@" + strconv.Itoa(i+1)
+}
+
+func (e *TableMapEvent) isUnsigned(i int) bool {
+	if e.UnsignedColumns != nil {
+		return e.UnsignedColumns[i]
+	}
+	return e.columns != nil && e.columns[i].Unsigned
+}
+
 func (e *TableMapEvent) Print(w io.Writer) {
 	e.printHeader(w)
 	fmt.Fprintf(w, "TableID: %d\n", e.TableID)
@@ -62,6 +119,9 @@ func (e *TableMapEvent) Print(w io.Writer) {
 	fmt.Fprintf(w, "Column types: \n%v\n", e.ColumnTypes)
 	fmt.Fprintf(w, "Column meta: \n%v\n", e.ColumnMeta)
 	fmt.Fprintf(w, "Column nullability: \n%v\n", e.ColumnNullability)
+	if e.ColumnNames != nil {
+		fmt.Fprintf(w, "Column names: \n%v\n", e.ColumnNames)
+	}
 	fmt.Fprintln(w)
 }
 
@@ -74,6 +134,7 @@ func (e *RowsQueryEvent) Decode(dec *EventDecoder) error {
 	packet := e.header.packet
 	packet.Skip(1)
 	e.Query = packet.Read(-1)
+	dec.rowsQuery = e.Query
 	return nil
 }
 
@@ -85,45 +146,365 @@ func (e *RowsQueryEvent) Print(w io.Writer) {
 
 type RowsEvent struct {
 	*baseEvent
-	TableID        uint64
-	Table          *TableMapEvent
-	Flags          uint16
-	ExtraData      []byte
+	TableID   uint64
+	Table     *TableMapEvent
+	Flags     uint16
+	ExtraData []byte
+	// ExtraInfo is parsed from the ExtraData of the V2 events.
+	ExtraInfo      RowsExtraInfo
 	ColumnCount    uint64
 	Columns        []byte
 	UpdatedColumns []byte
 	Rows           [][]interface{}
+	// Query is the original statement of the rows from the preceding RowsQueryEvent or MariadbAnnotateRowsEvent,
+	// which are written with binlog_rows_query_log_events=ON or binlog_annotate_row_events=ON. It's nil otherwise.
+	Query []byte
+	// Deferred is true if the rows exceed EventDecoder.MaxRowsEventSize or MaxRows, Rows is nil then and
+	// the rows are decoded one by one with Iter or EachRow, before the event is released. The accessors like
+	// RowChanges and ToSQL decode all the rows at once from the event data.
+	Deferred bool
+
+	dec *EventDecoder
+	// rowsPos is the position of the first row in the event data
+	rowsPos int
+}
+
+// errEmptyRow is returned for the rows of no columns, which would make the decoding loop forever.
+var errEmptyRow = errors.New("empty row image")
+
+// rowsEventStmtEndFlag is set for the last rows event of a statement.
+const rowsEventStmtEndFlag = 0x0001
+
+// the types of the extra row info
+const (
+	rowsExtraInfoNDB       = 0
+	rowsExtraInfoPartition = 1
+)
+
+// RowsExtraInfo is the extra row info of the V2 rows events, which is written by NDB Cluster and for the
+// partitioned tables since 8.0.16.
+type RowsExtraInfo struct {
+	// NDBFormat and NDBData are the extra info of NDB Cluster, NDBData is nil without it.
+	NDBFormat byte
+	NDBData   []byte
+	// PartitionID is the partition of the rows, and SourcePartitionID is the partition of the before images
+	// of the updates, they are nil if the table is not partitioned.
+	PartitionID       *uint16
+	SourcePartitionID *uint16
+}
+
+// parseRowsExtraInfo parses the extra data which is a list of the types followed by the values,
+// the parsing stops at an unknown type like the replicas do.
+func parseRowsExtraInfo(data []byte, update bool) (RowsExtraInfo, error) {
+	var info RowsExtraInfo
+	p := newBinlogPacket(data)
+	for !p.EOF() && p.Err() == nil {
+		switch p.readByte() {
+		case rowsExtraInfoNDB:
+			// the length includes itself and the format
+			length := int(p.readByte())
+			info.NDBFormat = p.readByte()
+			if length < 2 {
+				return info, fmt.Errorf("invalid NDB extra row info length %d", length)
+			}
+			info.NDBData = p.Read(length - 2)
+		case rowsExtraInfoPartition:
+			id := p.readUint16()
+			info.PartitionID = &id
+			if update {
+				source := p.readUint16()
+				info.SourcePartitionID = &source
+			}
+		default:
+			return info, nil
+		}
+	}
+	return info, p.Err()
 }
 
 func (e *RowsEvent) Decode(dec *EventDecoder) error {
 	packet := e.header.packet
 
-	e.TableID = packet.ReadUintBySize(6)
-	e.Table = dec.tables[e.TableID]
-	e.Flags = packet.readUint16() // reserved
+	e.TableID = packet.ReadUintBySize(dec.tableIDSize(e.header.Type))
+	e.Table = dec.table(e.TableID)
+	if e.Table == nil {
+		return fmt.Errorf("table map of table id %d not found", e.TableID)
+	}
+	e.Flags = packet.readUint16()
+	e.Query = dec.rowsQuery
+	if e.Flags&rowsEventStmtEndFlag != 0 {
+		// the query doesn't apply to the next statement
+		dec.rowsQuery = nil
+		dec.endStatement()
+	}
+	if e.Table.filtered {
+		return errEventFiltered
+	}
 
-	extraDataLen := packet.readUint16()
-	e.ExtraData = packet.Read(int(extraDataLen) - 2)
+	// only the V2 events have the extra data
+	if e.version() == 2 {
+		extraDataLen := packet.readUint16()
+		e.ExtraData = packet.Read(int(extraDataLen) - 2)
+		var err error
+		if e.ExtraInfo, err = parseRowsExtraInfo(e.ExtraData, e.isUpdate()); err != nil {
+			return err
+		}
+	}
 
 	e.ColumnCount = packet.ReadPackedInteger()
 	e.Columns = packet.Read(int(e.ColumnCount+7) >> 3)
-	if e.header.Type == UpdateRowsEventType {
+	if e.isUpdate() {
 		e.UpdatedColumns = packet.Read(int(e.ColumnCount+7) >> 3)
 	}
+	if e.ColumnCount > uint64(len(e.Table.ColumnTypes)) {
+		return fmt.Errorf("column count %d exceeds %d of the table map", e.ColumnCount, len(e.Table.ColumnTypes))
+	}
 
+	deferred, err := e.deferRows(dec)
+	if err != nil {
+		return err
+	}
+	if deferred {
+		dec.log().Debug("deferred the rows", "table_id", e.TableID, "event_size", e.header.EventSize)
+		e.Deferred, e.dec, e.rowsPos = true, dec, packet.Pos()
+		return nil
+	}
+	if dec.DecodeWorkers > 1 {
+		return e.decodeRowsParallel(dec)
+	}
 	e.Rows = make([][]interface{}, 0)
 	for !packet.EOF() {
-		e.decodeOneRow(e.Columns)
-		if e.header.Type == UpdateRowsEventType {
-			e.decodeOneRow(e.UpdatedColumns)
+		pos := packet.Pos()
+		row, err := e.decodeRow(dec, packet, e.rowColumns(len(e.Rows)))
+		if err != nil {
+			return err
+		}
+		if packet.Pos() == pos {
+			return errEmptyRow
 		}
+		e.Rows = append(e.Rows, row)
 	}
 	return nil
 }
 
-func (e *RowsEvent) decodeOneRow(includedColumns []byte) (err error) {
+// deferRows reports whether the rows are too many or too large to decode into Rows.
+// The rows are counted like Rows, i.e. an update counts as 2 rows of the before and after images.
+func (e *RowsEvent) deferRows(dec *EventDecoder) (bool, error) {
+	if dec.MaxRowsEventSize > 0 && int(e.header.EventSize) > dec.MaxRowsEventSize {
+		return true, nil
+	}
+	if dec.MaxRows <= 0 {
+		return false, nil
+	}
+	p := newBinlogPacket(e.header.packet.Raw())
+	p.Skip(e.header.packet.Pos())
+	for n := 0; !p.EOF(); n++ {
+		if n == dec.MaxRows {
+			return true, nil
+		}
+		pos := p.Pos()
+		if err := e.skipRow(p, e.rowColumns(n)); err != nil {
+			return false, err
+		}
+		if p.Pos() == pos {
+			return false, errEmptyRow
+		}
+	}
+	return false, p.Err()
+}
+
+// EachRow calls fn with the rows in order, which are decoded one by one from the event data if Deferred,
+// so that only a row is held in memory at a time. It stops at the first error of the decoding or fn.
+func (e *RowsEvent) EachRow(fn func(i int, row []interface{}) error) error {
+	it := e.Iter()
+	for it.Next() {
+		if err := fn(it.Index(), it.Row()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// allRows returns Rows, or all the rows decoded from the event data if Deferred.
+func (e *RowsEvent) allRows() ([][]interface{}, error) {
+	if !e.Deferred {
+		return e.Rows, nil
+	}
+	var rows [][]interface{}
+	err := e.EachRow(func(i int, row []interface{}) error {
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+// rowCount returns the number of the rows like len(Rows), the rows of a Deferred event are counted
+// by skipping them without decoding.
+func (e *RowsEvent) rowCount() (int, error) {
+	if !e.Deferred {
+		return len(e.Rows), nil
+	}
+	p := newBinlogPacket(e.header.packet.Raw())
+	p.Skip(e.rowsPos)
+	n := 0
+	for ; !p.EOF(); n++ {
+		pos := p.Pos()
+		if err := e.skipRow(p, e.rowColumns(n)); err != nil {
+			return n, err
+		}
+		if p.Pos() == pos {
+			return n, errEmptyRow
+		}
+	}
+	return n, p.Err()
+}
+
+// RowIterator iterates the rows of a RowsEvent, see RowsEvent.Iter.
+type RowIterator struct {
+	e *RowsEvent
+	// p reads the rows of the deferred event
+	p   *binlogPacket
+	i   int
+	row []interface{}
+	err error
+}
+
+// Iter returns an iterator of the rows in the order of Rows. The rows of a Deferred event are decoded on demand
+// by Next, and the ones which have been iterated can be released by the caller. Every iterator of the event
+// decodes the rows again.
+func (e *RowsEvent) Iter() *RowIterator {
+	it := &RowIterator{e: e, i: -1}
+	if e.Deferred {
+		it.p = newBinlogPacket(e.header.packet.Raw())
+		it.p.Skip(e.rowsPos)
+	}
+	return it
+}
+
+// Next advances to the next row, it returns false at the end of the rows or if the row can't be decoded.
+func (it *RowIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	it.row = nil
+	if it.p == nil {
+		if it.i+1 >= len(it.e.Rows) {
+			return false
+		}
+		it.i++
+		it.row = it.e.Rows[it.i]
+		return true
+	}
+	if it.p.EOF() {
+		return false
+	}
+	it.i++
+	it.row, it.err = it.e.readRow(it.p, it.i)
+	return it.err == nil
+}
+
+// Row returns the current row, whose values are the ones of the included columns like Rows.
+func (it *RowIterator) Row() []interface{} {
+	return it.row
+}
+
+// Index returns the index of the current row, the before and after images of an update are 2 rows.
+func (it *RowIterator) Index() int {
+	return it.i
+}
+
+// Err returns the error which stops the iteration, it's nil at the end of the rows.
+func (it *RowIterator) Err() error {
+	return it.err
+}
+
+// readRow decodes the i-th row of the deferred rows at the position of p.
+func (e *RowsEvent) readRow(p *binlogPacket, i int) (row []interface{}, err error) {
+	defer func() {
+		// the rows are decoded after the event, the panics of the malformed ones are not recovered by the decoder
+		if r := recover(); r != nil {
+			row, err = nil, newPanicError(r)
+		}
+	}()
+	pos := p.Pos()
+	if row, err = e.decodeRow(e.dec, p, e.rowColumns(i)); err != nil {
+		return nil, err
+	}
+	if err = p.Err(); err != nil {
+		return nil, err
+	}
+	if p.Pos() == pos {
+		return nil, errEmptyRow
+	}
+	return row, nil
+}
+
+// rowColumns returns the bitmap of the columns included in the i-th row image.
+func (e *RowsEvent) rowColumns(i int) []byte {
+	if e.isUpdate() && i%2 == 1 {
+		return e.UpdatedColumns
+	}
+	return e.Columns
+}
+
+// decodeRowsParallel finds the boundaries of the rows by skipping the values first,
+// then decodes the rows in dec.DecodeWorkers goroutines, each of which takes a consecutive range of the rows.
+func (e *RowsEvent) decodeRowsParallel(dec *EventDecoder) error {
 	packet := e.header.packet
+	var starts []int
+	for !packet.EOF() {
+		pos := packet.Pos()
+		starts = append(starts, pos)
+		if err := e.skipRow(packet, e.rowColumns(len(starts)-1)); err != nil {
+			return err
+		}
+		if packet.Pos() == pos {
+			return errEmptyRow
+		}
+	}
 
+	e.Rows = make([][]interface{}, len(starts))
+	workers := dec.DecodeWorkers
+	if workers > len(starts) {
+		workers = len(starts)
+	}
+	errs := make([]error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			defer func() {
+				// the panics can't be recovered by the decoder in the other goroutines
+				if r := recover(); r != nil {
+					errs[w] = newPanicError(r)
+				}
+			}()
+			// the rows of [lo, hi) are decoded with a packet of its own over the same data
+			lo, hi := len(starts)*w/workers, len(starts)*(w+1)/workers
+			p := newBinlogPacket(packet.Raw())
+			for i := lo; i < hi; i++ {
+				p.Skip(starts[i] - p.Pos())
+				row, err := e.decodeRow(dec, p, e.rowColumns(i))
+				if err != nil {
+					errs[w] = err
+					return
+				}
+				e.Rows[i] = row
+			}
+			errs[w] = p.Err()
+		}(w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *RowsEvent) skipRow(packet *binlogPacket, includedColumns []byte) error {
 	var includedColumnsCount int
 	for i := 0; i < int(e.ColumnCount); i++ {
 		if isBitSet(includedColumns, i) {
@@ -132,7 +513,60 @@ func (e *RowsEvent) decodeOneRow(includedColumns []byte) (err error) {
 	}
 	nullColumns := packet.Read((includedColumnsCount + 7) >> 3)
 
-	row, skipped, index := make([]interface{}, includedColumnsCount), 0, 0
+	index := 0
+	for i := 0; i < int(e.ColumnCount); i++ {
+		if !isBitSet(includedColumns, i) {
+			continue
+		}
+		if !isBitSet(nullColumns, index) {
+			if err := packet.skipTableColumnValue(e.Table.ColumnTypes[i], e.Table.ColumnMeta[i]); err != nil {
+				return err
+			}
+		}
+		index++
+	}
+	return nil
+}
+
+// version returns the version of the rows event, V0 is written by 5.1.0 - 5.1.15, V1 by 5.1.16 - 5.6.
+func (e *RowsEvent) version() int {
+	switch e.header.Type {
+	case PreGaWriteRowsEventType, PreGaUpdateRowsEventType, PreGaDeleteRowsEventType:
+		return 0
+	case OldWriteRowsEventType, OldUpdateRowsEventType, OldDeleteRowsEventType:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (e *RowsEvent) isUpdate() bool {
+	switch e.header.Type {
+	case UpdateRowsEventType, OldUpdateRowsEventType, PreGaUpdateRowsEventType:
+		return true
+	}
+	return false
+}
+
+func (e *RowsEvent) isDelete() bool {
+	switch e.header.Type {
+	case DeleteRowsEventType, OldDeleteRowsEventType, PreGaDeleteRowsEventType:
+		return true
+	}
+	return false
+}
+
+func (e *RowsEvent) decodeRow(dec *EventDecoder, packet *binlogPacket, includedColumns []byte) (row []interface{}, err error) {
+	var includedColumnsCount int
+	for i := 0; i < int(e.ColumnCount); i++ {
+		if isBitSet(includedColumns, i) {
+			includedColumnsCount++
+		}
+	}
+	nullColumns := packet.Read((includedColumnsCount + 7) >> 3)
+
+	row = make([]interface{}, includedColumnsCount)
+	skipped, index := 0, 0
 	for i := 0; i < int(e.ColumnCount); i++ {
 		if !isBitSet(includedColumns, i) {
 			skipped++
@@ -140,21 +574,124 @@ func (e *RowsEvent) decodeOneRow(includedColumns []byte) (err error) {
 		}
 		index = i - skipped
 		if !isBitSet(nullColumns, index) {
-			row[index], err = packet.readTableColumnValue(e.Table.ColumnTypes[i], e.Table.ColumnMeta[i])
+			row[index], err = packet.readTableColumnValue(dec, e.Table.ColumnTypes[i], e.Table.ColumnMeta[i], e.Table.isUnsigned(i))
 			if err != nil {
 				return
 			}
+			if e.Table.columns != nil {
+				row[index] = e.Table.columns[i].resolve(row[index], dec != nil && dec.ParseEnumSet)
+			}
+			if row[index], err = e.Table.mapValue(dec, i, row[index]); err != nil {
+				return
+			}
 		}
 	}
-	e.Rows = append(e.Rows, row)
 	return
 }
 
+// RowMaps returns the rows keyed by column names.
+// For UpdateRowsEvent, the rows are pairs of before and after images just like Rows.
+// The rows of a Deferred event are decoded up to the first one which can't be, see EachRow for the error.
+func (e *RowsEvent) RowMaps() []map[string]interface{} {
+	rows, _ := e.allRows()
+	maps := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		includedColumns := e.Columns
+		if e.isUpdate() && i%2 == 1 {
+			includedColumns = e.UpdatedColumns
+		}
+		m, index := make(map[string]interface{}, len(row)), 0
+		for j := 0; j < int(e.ColumnCount); j++ {
+			if !isBitSet(includedColumns, j) {
+				continue
+			}
+			m[e.Table.ColumnName(j)] = row[index]
+			index++
+		}
+		maps[i] = m
+	}
+	return maps
+}
+
+// RowChange is a changed row with the column values keyed by column names, the columns absent from
+// the row images are left out while the NULL ones are nil.
+type RowChange struct {
+	// Before is the row before the change, it's nil for WriteRowsEvent.
+	Before map[string]interface{} `json:"before,omitempty"`
+	// After is the row after the change, it's nil for DeleteRowsEvent.
+	After map[string]interface{} `json:"after,omitempty"`
+}
+
+// RowChanges returns the changed rows with the before and after images.
+func (e *RowsEvent) RowChanges() []RowChange {
+	maps := e.RowMaps()
+	if e.isUpdate() {
+		changes := make([]RowChange, len(maps)/2)
+		for i := range changes {
+			changes[i] = RowChange{Before: maps[2*i], After: maps[2*i+1]}
+		}
+		return changes
+	}
+
+	changes := make([]RowChange, len(maps))
+	for i, m := range maps {
+		if e.isDelete() {
+			changes[i].Before = m
+		} else {
+			changes[i].After = m
+		}
+	}
+	return changes
+}
+
+// Key returns the values of the columns identifying the changed row. They're taken from the after image, and
+// from the before image for the deleted rows and the columns not in the minimal after image.
+// It's nil if columns is empty or any of them is in neither image.
+func (c RowChange) Key(columns []string) map[string]interface{} {
+	if len(columns) == 0 {
+		return nil
+	}
+	key := make(map[string]interface{}, len(columns))
+	for _, name := range columns {
+		v, ok := c.After[name]
+		if !ok {
+			if v, ok = c.Before[name]; !ok {
+				return nil
+			}
+		}
+		key[name] = v
+	}
+	return key
+}
+
+// PrimaryKeys returns the primary key values of the changed rows in the order of RowChanges, for the sinks to
+// build the upserts, deletes and message keys. The primary key is from the optional metadata of 8.0 or the
+// columns retrieved from the DB, PrimaryKeys returns nil if it's unknown. An element is nil if the primary key
+// is not in the row images. For the updates changing the primary key, the old values are in the Before images.
+func (e *RowsEvent) PrimaryKeys() []map[string]interface{} {
+	pk := e.Table.PrimaryKeyColumns()
+	if len(pk) == 0 {
+		return nil
+	}
+	changes := e.RowChanges()
+	keys := make([]map[string]interface{}, len(changes))
+	for i, change := range changes {
+		keys[i] = change.Key(pk)
+	}
+	return keys
+}
+
 func (e *RowsEvent) Print(w io.Writer) {
 	e.printHeader(w)
 	fmt.Fprintf(w, "TableID: %d\n", e.TableID)
 	fmt.Fprintf(w, "Table: %s.%s\n", e.Table.Database, e.Table.TableName)
 	fmt.Fprintf(w, "Flags: %d\n", e.Flags)
+	if e.Query != nil {
+		fmt.Fprintf(w, "Query: %s\n", e.Query)
+	}
+	if id := e.ExtraInfo.PartitionID; id != nil {
+		fmt.Fprintf(w, "Partition: %d\n", *id)
+	}
 	fmt.Fprintf(w, "Column count: %d\n", e.ColumnCount)
 	fmt.Fprintf(w, "Columns: %v\n", e.Columns)
 	e.printRows(w)
@@ -162,6 +699,10 @@ func (e *RowsEvent) Print(w io.Writer) {
 }
 
 func (e *RowsEvent) printRows(w io.Writer) {
+	if e.Deferred {
+		fmt.Fprintf(w, "Rows: deferred (%d bytes)\n", e.header.packet.Len()-e.rowsPos)
+		return
+	}
 	fmt.Fprintln(w, "Rows:")
 	for _, row := range e.Rows {
 		fmt.Fprintf(w, "%v\n", row)
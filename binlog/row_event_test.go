@@ -0,0 +1,521 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDecodeRowsEventVersions(t *testing.T) {
+	dec := &EventDecoder{tables: map[uint64]*TableMapEvent{
+		1: {TableID: 1, Database: []byte("test"), TableName: []byte("t"), ColumnCount: 1, ColumnTypes: []byte{fieldTypeLong}, ColumnMeta: []uint16{0}},
+	}}
+	// table id, flags, [extra data], column count, columns
+	header := []byte{1, 0, 0, 0, 0, 0, 0, 0}
+	row := []byte{0, 7, 0, 0, 0}
+	for typ, extra := range map[EventType][]byte{
+		WriteRowsEventType:      {2, 0},
+		OldWriteRowsEventType:   nil,
+		PreGaWriteRowsEventType: nil,
+	} {
+		body := append(append(append([]byte{}, header...), extra...), 1, 1)
+		body = append(body, row...)
+		ev, err := dec.decode(buildEvent(typ, body, false))
+		if err != nil {
+			t.Fatalf("%s: %v", typ, err)
+		}
+		e := ev.(*RowsEvent)
+		if len(e.Rows) != 1 || e.Rows[0][0] != int64(7) {
+			t.Errorf("%s: unexpected rows %v", typ, e.Rows)
+		}
+	}
+
+	body := append(append([]byte{}, header...), 1, 1, 1)
+	body = append(append(body, row...), 0, 8, 0, 0, 0)
+	ev, err := dec.decode(buildEvent(OldUpdateRowsEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := ev.(*RowsEvent).Rows; len(rows) != 2 || rows[1][0] != int64(8) {
+		t.Errorf("unexpected update rows %v", rows)
+	}
+}
+
+func TestRowsExtraInfo(t *testing.T) {
+	dec := &EventDecoder{tables: map[uint64]*TableMapEvent{
+		1: {TableID: 1, Database: []byte("test"), TableName: []byte("t"), ColumnCount: 1, ColumnTypes: []byte{fieldTypeLong}, ColumnMeta: []uint16{0}},
+	}}
+	// the partition info of the 8.0 partitioned tables
+	body := []byte{1, 0, 0, 0, 0, 0, 0, 0, 5, 0, rowsExtraInfoPartition, 3, 0, 1, 1, 0, 7, 0, 0, 0}
+	ev, err := dec.decode(buildEvent(WriteRowsEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := ev.(*RowsEvent).ExtraInfo; info.PartitionID == nil || *info.PartitionID != 3 || info.SourcePartitionID != nil {
+		t.Errorf("unexpected extra info %+v", info)
+	}
+
+	info, err := parseRowsExtraInfo([]byte{rowsExtraInfoPartition, 1, 0, 2, 0}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PartitionID == nil || *info.PartitionID != 1 || info.SourcePartitionID == nil || *info.SourcePartitionID != 2 {
+		t.Errorf("unexpected update extra info %+v", info)
+	}
+
+	info, err = parseRowsExtraInfo([]byte{rowsExtraInfoNDB, 4, 1, 'a', 'b', 9}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.NDBFormat != 1 || string(info.NDBData) != "ab" || info.PartitionID != nil {
+		t.Errorf("unexpected NDB extra info %+v", info)
+	}
+
+	if _, err = parseRowsExtraInfo([]byte{rowsExtraInfoNDB, 4, 1}, false); err == nil {
+		t.Error("expected error for truncated extra info")
+	}
+}
+
+func TestRowsEventQuery(t *testing.T) {
+	dec := &EventDecoder{
+		Filter: &EventFilter{EventTypes: []EventType{WriteRowsEventType}},
+		tables: map[uint64]*TableMapEvent{
+			1: {TableID: 1, Database: []byte("test"), TableName: []byte("t"), ColumnCount: 1, ColumnTypes: []byte{fieldTypeLong}, ColumnMeta: []uint16{0}},
+		},
+	}
+	// the RowsQueryEvent is filtered out but its query is still attached
+	ev, err := dec.decode(buildEvent(RowsQueryEventType, append([]byte{1}, "INSERT INTO t VALUES (7)"...), false))
+	if err != nil || ev != nil {
+		t.Fatalf("expected the RowsQueryEvent filtered out, got %v, %v", ev, err)
+	}
+	rows := func(flags byte) *RowsEvent {
+		body := []byte{1, 0, 0, 0, 0, 0, flags, 0, 2, 0, 1, 1, 0, 7, 0, 0, 0}
+		ev, err := dec.decode(buildEvent(WriteRowsEventType, body, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ev.(*RowsEvent)
+	}
+	if e := rows(0); string(e.Query) != "INSERT INTO t VALUES (7)" {
+		t.Errorf("unexpected query %q", e.Query)
+	}
+	if e := rows(rowsEventStmtEndFlag); string(e.Query) != "INSERT INTO t VALUES (7)" {
+		t.Errorf("unexpected query %q of the last rows event of the statement", e.Query)
+	}
+	if e := rows(rowsEventStmtEndFlag); e.Query != nil {
+		t.Errorf("expected no query for the next statement, got %q", e.Query)
+	}
+}
+
+func TestRowChanges(t *testing.T) {
+	table := &TableMapEvent{ColumnCount: 2, columns: []*Column{{Name: "id"}, {Name: "name"}}}
+	rows := [][]interface{}{{int64(1), "a"}, {int64(1), "b"}}
+	for typ, expected := range map[EventType][]RowChange{
+		WriteRowsEventType: {
+			{After: map[string]interface{}{"id": int64(1), "name": "a"}},
+			{After: map[string]interface{}{"id": int64(1), "name": "b"}},
+		},
+		DeleteRowsEventType: {
+			{Before: map[string]interface{}{"id": int64(1), "name": "a"}},
+			{Before: map[string]interface{}{"id": int64(1), "name": "b"}},
+		},
+		UpdateRowsEventType: {
+			{Before: map[string]interface{}{"id": int64(1), "name": "a"}, After: map[string]interface{}{"id": int64(1), "name": "b"}},
+		},
+	} {
+		e := &RowsEvent{
+			baseEvent:      &baseEvent{header: &EventHeader{Type: typ}},
+			Table:          table,
+			ColumnCount:    2,
+			Columns:        []byte{3},
+			UpdatedColumns: []byte{3},
+			Rows:           rows,
+		}
+		if changes := e.RowChanges(); !reflect.DeepEqual(changes, expected) {
+			t.Errorf("%s: expected %v, got %v", typ, expected, changes)
+		}
+	}
+}
+
+func TestRowsEventPrimaryKeys(t *testing.T) {
+	table := &TableMapEvent{ColumnCount: 2, columns: []*Column{{Name: "id", IsPrimary: true}, {Name: "name"}}}
+	e := &RowsEvent{
+		baseEvent:   &baseEvent{header: &EventHeader{Type: UpdateRowsEventType}},
+		Table:       table,
+		ColumnCount: 2,
+		Columns:     []byte{3},
+		// the minimal after image without the primary key
+		UpdatedColumns: []byte{2},
+		Rows:           [][]interface{}{{int64(1), "a"}, {"b"}},
+	}
+	expected := []map[string]interface{}{{"id": int64(1)}}
+	if keys := e.PrimaryKeys(); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+
+	// the primary key from the optional metadata takes precedence
+	table.PrimaryKey = []int{1}
+	e.baseEvent.header.Type = DeleteRowsEventType
+	e.Rows = [][]interface{}{{int64(1), "a"}, {int64(2), "b"}}
+	expected = []map[string]interface{}{{"name": "a"}, {"name": "b"}}
+	if keys := e.PrimaryKeys(); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+
+	table.PrimaryKey, table.columns = nil, []*Column{{Name: "id"}, {Name: "name"}}
+	if keys := e.PrimaryKeys(); keys != nil {
+		t.Errorf("expected no keys without primary key, got %v", keys)
+	}
+}
+
+func TestDecodeRowsEventUnsigned(t *testing.T) {
+	types := []byte{fieldTypeTiny, fieldTypeShort, fieldTypeInt24, fieldTypeLong, fieldTypeLongLong}
+	table := &TableMapEvent{TableID: 1, ColumnCount: 5, ColumnTypes: types, ColumnMeta: make([]uint16, 5)}
+	for i := 0; i < 5; i++ {
+		table.columns = append(table.columns, &Column{Name: strconv.Itoa(i), Unsigned: true})
+	}
+	dec := &EventDecoder{tables: map[uint64]*TableMapEvent{1: table}}
+
+	body := []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 5, 0x1f, 0}
+	body = append(body, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	body = append(body, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	ev, err := dec.decode(buildEvent(WriteRowsEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{int64(0xff), int64(0xffff), int64(0xffffff), int64(0xffffffff), uint64(0xffffffffffffffff)}
+	if rows := ev.(*RowsEvent).Rows; len(rows) != 1 || !reflect.DeepEqual(rows[0], expected) {
+		t.Errorf("expected %v, got %v", expected, rows)
+	}
+}
+
+func TestDecodeTableMapEventOptionalMetadata(t *testing.T) {
+	body := []byte{1, 0, 0, 0, 0, 0, 0, 0, 4, 't', 'e', 's', 't', 0, 1, 't', 0}
+	body = append(body, 3, fieldTypeLong, fieldTypeVarChar, fieldTypeString)
+	body = append(body, 4, 40, 0, fieldTypeEnum, 1) // column meta
+	body = append(body, 0x06)                       // nullability
+	body = append(body, signednessMetadata, 1, 0x80)
+	body = append(body, defaultCharsetMetadata, 1, 33)
+	body = append(body, columnNameMetadata, 15, 2, 'i', 'd', 4, 'n', 'a', 'm', 'e', 6, 's', 't', 'a', 't', 'u', 's')
+	body = append(body, enumStrValueMetadata, 5, 2, 1, 'a', 1, 'b')
+	body = append(body, simplePrimaryKeyMetadata, 1, 0)
+
+	dec := &EventDecoder{tables: make(map[uint64]*TableMapEvent)}
+	ev, err := dec.decode(buildEvent(TableMapEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := ev.(*TableMapEvent)
+	if !reflect.DeepEqual(e.ColumnNames, []string{"id", "name", "status"}) {
+		t.Errorf("unexpected column names %v", e.ColumnNames)
+	}
+	if !reflect.DeepEqual(e.UnsignedColumns, []bool{true, false, false}) {
+		t.Errorf("unexpected unsigned columns %v", e.UnsignedColumns)
+	}
+	if !reflect.DeepEqual(e.ColumnCharsets, []uint64{0, 33, 0}) {
+		t.Errorf("unexpected column charsets %v", e.ColumnCharsets)
+	}
+	if !reflect.DeepEqual(e.EnumValues, [][]string{nil, nil, {"a", "b"}}) || !reflect.DeepEqual(e.PrimaryKey, []int{0}) {
+		t.Errorf("unexpected enum values %v or primary key %v", e.EnumValues, e.PrimaryKey)
+	}
+
+	// rows are decoded with the metadata without information_schema
+	rows := []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 3, 0x07, 0, 0xff, 0xff, 0xff, 0xff, 1, 'x', 2}
+	ev, err = dec.decode(buildEvent(WriteRowsEventType, rows, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []map[string]interface{}{{"id": int64(0xffffffff), "name": "x", "status": "b"}}
+	if maps := ev.(*RowsEvent).RowMaps(); !reflect.DeepEqual(maps, expected) {
+		t.Errorf("expected %v, got %v", expected, maps)
+	}
+}
+
+func TestValueMapper(t *testing.T) {
+	table := &TableMapEvent{TableID: 1, ColumnCount: 2, ColumnTypes: []byte{fieldTypeLong, fieldTypeBLOB}, ColumnMeta: []uint16{0, 1}}
+	var columns []ColumnInfo
+	dec := &EventDecoder{
+		tables: map[uint64]*TableMapEvent{1: table},
+		ValueMapper: ValueMapperFunc(func(column ColumnInfo, value interface{}) (interface{}, error) {
+			columns = append(columns, column)
+			if b, ok := value.([]byte); ok && column.Type == ColumnTypeBLOB {
+				return string(b), nil
+			}
+			return value, nil
+		}),
+	}
+
+	body := []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, 2, 0x03, 0, 7, 0, 0, 0, 2, 'h', 'i'}
+	body = append(body, 0x02, 8, 0, 0, 0) // NULL BLOB
+	ev, err := dec.decode(buildEvent(WriteRowsEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]interface{}{{int64(7), "hi"}, {int64(8), nil}}
+	if rows := ev.(*RowsEvent).Rows; !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected %v, got %v", expected, rows)
+	}
+	if len(columns) != 3 || columns[1].Name != "@2" || columns[1].Index != 1 || columns[1].Table != table {
+		t.Errorf("unexpected columns %v", columns)
+	}
+}
+
+// buildWideRowsEvent builds an UpdateRowsEvent of the table with the columns of various types,
+// every other row has NULL values.
+func buildWideRowsEvent(rows int) (*TableMapEvent, []byte) {
+	columns := []struct {
+		typ   byte
+		meta  uint16
+		value []byte
+	}{
+		{fieldTypeTiny, 0, []byte{1}},
+		{fieldTypeShort, 0, []byte{1, 2}},
+		{fieldTypeInt24, 0, []byte{1, 2, 3}},
+		{fieldTypeLong, 0, []byte{1, 2, 3, 4}},
+		{fieldTypeLongLong, 0, []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{fieldTypeFloat, 0, []byte{0, 0, 0x80, 0x3f}},
+		{fieldTypeDouble, 0, []byte{0, 0, 0, 0, 0, 0, 0xf0, 0x3f}},
+		{fieldTypeNewDecimal, 10<<8 | 2, []byte{0x80, 0, 0x30, 0x39, 5}},
+		{fieldTypeYear, 0, []byte{120}},
+		{fieldTypeDate, 0, []byte{0x21, 0x44, 0x0f}},
+		{fieldTypeTimestampV2, 3, []byte{0x5e, 0, 0, 0, 0x01, 0x02}},
+		{fieldTypeVarChar, 255, append([]byte{11}, "hello world"...)},
+		{fieldTypeVarChar, 1000, append([]byte{5, 0}, "hello"...)},
+		{fieldTypeString, uint16(fieldTypeString)<<8 | 10, append([]byte{3}, "abc"...)},
+		{fieldTypeString, uint16(fieldTypeEnum)<<8 | 1, []byte{2}},
+		{fieldTypeString, uint16(fieldTypeSet)<<8 | 1, []byte{3}},
+		{fieldTypeBit, 1 << 8, []byte{0xff}},
+		{fieldTypeBLOB, 2, append([]byte{4, 0}, "blob"...)},
+	}
+
+	n := len(columns)
+	table := &TableMapEvent{TableID: 1, ColumnCount: uint64(n), ColumnTypes: make([]byte, n), ColumnMeta: make([]uint16, n)}
+	for i, c := range columns {
+		table.ColumnTypes[i], table.ColumnMeta[i] = c.typ, c.meta
+	}
+
+	bitmap := make([]byte, (n+7)/8)
+	for i := range bitmap {
+		bitmap[i] = 0xff
+	}
+	body := []byte{1, 0, 0, 0, 0, 0, 0, 0, 2, 0, byte(n)}
+	body = append(append(body, bitmap...), bitmap...)
+	for i := 0; i < rows*2; i++ {
+		nulls := make([]byte, (n+7)/8)
+		if i%4 >= 2 {
+			nulls[0] = 0x55
+		}
+		body = append(body, nulls...)
+		for j, c := range columns {
+			if !isBitSet(nulls, j) {
+				body = append(body, c.value...)
+			}
+		}
+	}
+	return table, buildEvent(UpdateRowsEventType, body, false)
+}
+
+func TestDecodeRowsParallel(t *testing.T) {
+	table, data := buildWideRowsEvent(50)
+	var results [][][]interface{}
+	for _, workers := range []int{0, 4} {
+		dec := &EventDecoder{DecodeWorkers: workers, DecimalFormat: DecimalString, tables: map[uint64]*TableMapEvent{1: table}}
+		ev, err := dec.decode(data)
+		if err != nil {
+			t.Fatalf("%d workers: %v", workers, err)
+		}
+		results = append(results, ev.(*RowsEvent).Rows)
+	}
+	if len(results[0]) != 100 {
+		t.Fatalf("expected 100 rows, got %d", len(results[0]))
+	}
+	if !reflect.DeepEqual(results[0], results[1]) {
+		t.Errorf("expected the same rows decoded in parallel")
+	}
+}
+
+func TestDeferredRows(t *testing.T) {
+	table, data := buildWideRowsEvent(50)
+	dec := &EventDecoder{DecimalFormat: DecimalString, tables: map[uint64]*TableMapEvent{1: table}}
+	ev, err := dec.decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ev.(*RowsEvent).Rows
+	changes := ev.(*RowsEvent).RowChanges()
+
+	for _, limits := range []struct{ size, rows int }{{len(data) - 1, 0}, {0, 99}} {
+		dec := &EventDecoder{DecimalFormat: DecimalString, MaxRowsEventSize: limits.size, MaxRows: limits.rows,
+			tables: map[uint64]*TableMapEvent{1: table}}
+		ev, err := dec.decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := ev.(*RowsEvent)
+		if !e.Deferred || e.Rows != nil {
+			t.Fatalf("%v: expected the rows deferred", limits)
+		}
+		var rows [][]interface{}
+		err = e.EachRow(func(i int, row []interface{}) error {
+			if i != len(rows) {
+				t.Errorf("unexpected row index %d", i)
+			}
+			rows = append(rows, row)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(rows, expected) {
+			t.Errorf("%v: expected the same rows decoded one by one", limits)
+		}
+		// the accessors decode the deferred rows
+		if c := e.RowChanges(); len(c) != 50 || !reflect.DeepEqual(c, changes) {
+			t.Errorf("%v: expected the same row changes, got %d", limits, len(c))
+		}
+		if n, err := e.rowCount(); err != nil || n != 100 {
+			t.Errorf("%v: expected 100 rows counted, got %d, %v", limits, n, err)
+		}
+	}
+
+	// the rows within the limits are decoded as usual
+	dec = &EventDecoder{MaxRowsEventSize: len(data), MaxRows: 100, tables: map[uint64]*TableMapEvent{1: table}}
+	if ev, err = dec.decode(data); err != nil {
+		t.Fatal(err)
+	}
+	if e := ev.(*RowsEvent); e.Deferred || len(e.Rows) != 100 {
+		t.Errorf("expected 100 rows decoded, got %d", len(e.Rows))
+	}
+	stop := errors.New("stop")
+	if err = ev.(*RowsEvent).EachRow(func(i int, row []interface{}) error { return stop }); err != stop {
+		t.Errorf("expected the error of fn, got %v", err)
+	}
+}
+
+func TestRowIterator(t *testing.T) {
+	table, data := buildWideRowsEvent(3)
+	for _, maxRows := range []int{0, 1} {
+		dec := &EventDecoder{MaxRows: maxRows, tables: map[uint64]*TableMapEvent{1: table}}
+		ev, err := dec.decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := ev.(*RowsEvent)
+		it, n := e.Iter(), 0
+		for it.Next() {
+			if it.Index() != n || len(it.Row()) != len(table.ColumnTypes) {
+				t.Errorf("unexpected row %d: %v", it.Index(), it.Row())
+			}
+			n++
+		}
+		if it.Err() != nil || n != 6 {
+			t.Errorf("deferred %v: expected 6 rows, got %d, %v", e.Deferred, n, it.Err())
+		}
+		if it.Next() || it.Row() != nil {
+			t.Error("expected no row after the end")
+		}
+	}
+
+	// the truncated row stops the iteration with the error
+	dec := &EventDecoder{MaxRows: 1, tables: map[uint64]*TableMapEvent{1: table}}
+	truncated := append([]byte{}, data[:len(data)-3]...)
+	binary.LittleEndian.PutUint32(truncated[9:], uint32(len(truncated)))
+	ev, err := dec.decode(truncated)
+	if err == nil {
+		it := ev.(*RowsEvent).Iter()
+		for it.Next() {
+		}
+		err = it.Err()
+	}
+	if err == nil {
+		t.Error("expected the error of the truncated row")
+	}
+}
+
+func benchmarkDecodeRows(b *testing.B, workers int) {
+	table, data := buildWideRowsEvent(5000)
+	dec := &EventDecoder{DecodeWorkers: workers, tables: map[uint64]*TableMapEvent{1: table}}
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := dec.decode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeRows(b *testing.B)         { benchmarkDecodeRows(b, 0) }
+func BenchmarkDecodeRowsParallel(b *testing.B) { benchmarkDecodeRows(b, 4) }
+
+func TestTableMapLifecycle(t *testing.T) {
+	tableMap := func(dec *EventDecoder, id byte, name string) {
+		body := append([]byte{id, 0, 0, 0, 0, 0, 0, 0, 4, 't', 'e', 's', 't', 0, byte(len(name))}, name...)
+		body = append(body, 0, 1, fieldTypeLong, 0, 0)
+		if _, err := dec.decode(buildEvent(TableMapEventType, body, false)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tableName := func(dec *EventDecoder, id uint64) string {
+		if e := dec.TableMap(id); e != nil {
+			return string(e.TableName)
+		}
+		return ""
+	}
+
+	dec := NewEventDecoder()
+	tableMap(dec, 1, "a")
+	tableMap(dec, 2, "b")
+	// the table id is changed by DDL
+	tableMap(dec, 3, "a")
+	if tableName(dec, 1) != "" || tableName(dec, 3) != "a" {
+		t.Errorf("expected the table map of the old table id evicted")
+	}
+	// the table id is reused by another table
+	tableMap(dec, 2, "c")
+	tableMap(dec, 4, "b")
+	if tableName(dec, 2) != "c" || tableName(dec, 4) != "b" || len(dec.tables) != 3 {
+		t.Errorf("unexpected table maps %v", dec.tables)
+	}
+
+	// a new file starts with the FormatDescriptionEvent
+	fde := make([]byte, 2+50+4+1)
+	fde[0], fde[56] = 4, eventHeaderSize
+	if _, err := dec.decode(buildEvent(FormatDescriptionEventType, append(fde, 56, 13, 0, 8, 0), false)); err != nil {
+		t.Fatal(err)
+	}
+	if len(dec.tables) != 0 {
+		t.Errorf("expected the table maps cleared, got %v", dec.tables)
+	}
+
+	dec = &EventDecoder{TableMapCacheSize: 2}
+	tableMap(dec, 1, "a")
+	tableMap(dec, 2, "b")
+	// the rows event ending the statement makes the table 1 used recently
+	if _, err := dec.decode(buildEvent(WriteRowsEventType, []byte{1, 0, 0, 0, 0, 0, 1, 0, 2, 0, 1, 1, 0, 7, 0, 0, 0}, false)); err != nil {
+		t.Fatal(err)
+	}
+	tableMap(dec, 3, "c")
+	if tableName(dec, 1) != "a" || tableName(dec, 2) != "" || tableName(dec, 3) != "c" {
+		t.Errorf("expected the least recently used table map evicted, got %v", dec.tables)
+	}
+
+	// the table maps of a statement are kept until it ends even if they are more than TableMapCacheSize
+	dec = &EventDecoder{TableMapCacheSize: 2}
+	for id := byte(1); id <= 4; id++ {
+		tableMap(dec, id, string('a'+id-1))
+	}
+	for id := byte(1); id <= 4; id++ {
+		var flags byte
+		if id == 4 {
+			flags = rowsEventStmtEndFlag
+		}
+		if _, err := dec.decode(buildEvent(WriteRowsEventType, []byte{id, 0, 0, 0, 0, 0, flags, 0, 2, 0, 1, 1, 0, 7, 0, 0, 0}, false)); err != nil {
+			t.Fatalf("rows event of table id %d: %v", id, err)
+		}
+	}
+	if len(dec.tables) != 2 || tableName(dec, 3) != "c" || tableName(dec, 4) != "d" {
+		t.Errorf("expected the least recently used table maps evicted after the statement, got %v", dec.tables)
+	}
+}
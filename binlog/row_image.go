@@ -0,0 +1,136 @@
+package binlog
+
+// ColumnPresence tells a column which is absent from a row image from the NULL one.
+type ColumnPresence int
+
+const (
+	// ColumnAbsent is a column not in the row image, e.g. it's not changed with binlog_row_image=MINIMAL.
+	ColumnAbsent ColumnPresence = iota
+	// ColumnNull is a NULL column in the row image.
+	ColumnNull
+	// ColumnPresent is a non-NULL column in the row image.
+	ColumnPresent
+)
+
+func (p ColumnPresence) String() string {
+	switch p {
+	case ColumnNull:
+		return "NULL"
+	case ColumnPresent:
+		return "PRESENT"
+	default:
+		return "ABSENT"
+	}
+}
+
+// RowImage is the `binlog_row_image` mode of a rows event, which is not written in the binlog but inferred
+// from the columns included in the row images.
+type RowImage int
+
+const (
+	// RowImageFull includes all the columns in the row images.
+	RowImageFull RowImage = iota
+	// RowImageNoblob leaves out the BLOB, TEXT, JSON and GEOMETRY columns which are not needed.
+	RowImageNoblob
+	// RowImageMinimal includes only the columns identifying the rows in the before images,
+	// and the columns changed in the after images.
+	RowImageMinimal
+)
+
+func (m RowImage) String() string {
+	switch m {
+	case RowImageNoblob:
+		return "NOBLOB"
+	case RowImageMinimal:
+		return "MINIMAL"
+	default:
+		return "FULL"
+	}
+}
+
+// RowImage infers the row image mode of the event. It's RowImageNoblob if the columns left out are all BLOBs,
+// which is also the case of MINIMAL for the tables whose other columns are all needed, e.g. have no primary key.
+func (e *RowsEvent) RowImage() RowImage {
+	image := RowImageFull
+	for _, bitmap := range [][]byte{e.Columns, e.UpdatedColumns} {
+		if bitmap == nil {
+			continue
+		}
+		for i := 0; i < int(e.ColumnCount); i++ {
+			if isBitSet(bitmap, i) {
+				continue
+			}
+			if !isBlobType(e.Table.ColumnTypes[i]) {
+				return RowImageMinimal
+			}
+			image = RowImageNoblob
+		}
+	}
+	return image
+}
+
+func isBlobType(typ byte) bool {
+	switch typ {
+	case fieldTypeTinyBLOB, fieldTypeMediumBLOB, fieldTypeLongBLOB, fieldTypeBLOB, fieldTypeJSON, fieldTypeGeometry:
+		return true
+	}
+	return false
+}
+
+// Value returns the value of the column j in the i-th row of Rows and its presence, the value is nil
+// unless it's ColumnPresent.
+func (e *RowsEvent) Value(i, j int) (interface{}, ColumnPresence) {
+	bitmap := e.rowColumns(i)
+	if !isBitSet(bitmap, j) {
+		return nil, ColumnAbsent
+	}
+	// the rows hold the included columns only
+	index := 0
+	for k := 0; k < j; k++ {
+		if isBitSet(bitmap, k) {
+			index++
+		}
+	}
+	if v := e.Rows[i][index]; v != nil {
+		return v, ColumnPresent
+	}
+	return nil, ColumnNull
+}
+
+// Presence returns the presence of the columns in the i-th row of Rows in the table order.
+func (e *RowsEvent) Presence(i int) []ColumnPresence {
+	presence := make([]ColumnPresence, e.ColumnCount)
+	bitmap, index := e.rowColumns(i), 0
+	for j := range presence {
+		if !isBitSet(bitmap, j) {
+			continue
+		}
+		if e.Rows[i][index] != nil {
+			presence[j] = ColumnPresent
+		} else {
+			presence[j] = ColumnNull
+		}
+		index++
+	}
+	return presence
+}
+
+// Merged returns the after image completed with the columns absent from it but present in the before image,
+// which is the row after an update with binlog_row_image=MINIMAL or NOBLOB as far as the images tell.
+// It's the only image for WriteRowsEvent and DeleteRowsEvent.
+func (c RowChange) Merged() map[string]interface{} {
+	if c.After == nil || c.Before == nil {
+		if c.After != nil {
+			return c.After
+		}
+		return c.Before
+	}
+	merged := make(map[string]interface{}, len(c.Before))
+	for name, v := range c.Before {
+		merged[name] = v
+	}
+	for name, v := range c.After {
+		merged[name] = v
+	}
+	return merged
+}
@@ -0,0 +1,52 @@
+package binlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowImage(t *testing.T) {
+	table := &TableMapEvent{
+		ColumnCount: 3,
+		ColumnTypes: []byte{fieldTypeLong, fieldTypeVarChar, fieldTypeBLOB},
+		columns:     []*Column{{Name: "id"}, {Name: "name"}, {Name: "data"}},
+	}
+	e := &RowsEvent{
+		baseEvent:      &baseEvent{header: &EventHeader{Type: UpdateRowsEventType}},
+		Table:          table,
+		ColumnCount:    3,
+		Columns:        []byte{7},
+		UpdatedColumns: []byte{7},
+		Rows:           [][]interface{}{{int64(1), nil, []byte("a")}, {int64(1), "b", []byte("a")}},
+	}
+	if image := e.RowImage(); image != RowImageFull {
+		t.Errorf("expected FULL, got %s", image)
+	}
+
+	e.Columns, e.UpdatedColumns = []byte{3}, []byte{3}
+	e.Rows = [][]interface{}{{int64(1), nil}, {int64(1), "b"}}
+	if image := e.RowImage(); image != RowImageNoblob {
+		t.Errorf("expected NOBLOB, got %s", image)
+	}
+	expected := []ColumnPresence{ColumnPresent, ColumnNull, ColumnAbsent}
+	if presence := e.Presence(0); !reflect.DeepEqual(presence, expected) {
+		t.Errorf("expected %v, got %v", expected, presence)
+	}
+
+	// the minimal images of the primary key and the changed column
+	e.Columns, e.UpdatedColumns = []byte{1}, []byte{2}
+	e.Rows = [][]interface{}{{int64(1)}, {"b"}}
+	if image := e.RowImage(); image != RowImageMinimal {
+		t.Errorf("expected MINIMAL, got %s", image)
+	}
+	if v, p := e.Value(1, 1); v != "b" || p != ColumnPresent {
+		t.Errorf("unexpected value %v (%s)", v, p)
+	}
+	if v, p := e.Value(1, 0); v != nil || p != ColumnAbsent {
+		t.Errorf("unexpected value %v (%s)", v, p)
+	}
+	merged := e.RowChanges()[0].Merged()
+	if !reflect.DeepEqual(merged, map[string]interface{}{"id": int64(1), "name": "b"}) {
+		t.Errorf("unexpected merged row %v", merged)
+	}
+}
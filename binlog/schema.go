@@ -0,0 +1,605 @@
+package binlog
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ColumnSchema is the definition of a column tracked by SchemaTracker.
+type ColumnSchema struct {
+	Name string
+	// Type is the column type like COLUMN_TYPE of information_schema, e.g. "int(10) unsigned" or "enum('a','b')".
+	Type string
+	// Charset is empty for the non-textual columns, or if it's inherited from the database.
+	Charset string
+	Primary bool
+}
+
+// TableSchema is a version of the definition of a table.
+type TableSchema struct {
+	Database string
+	Table    string
+	// Columns are nil if the table is dropped or its definition is unknown.
+	Columns []ColumnSchema
+	// Position is where the definition takes effect, it's zero for the ones loaded from information_schema.
+	Position Position
+
+	// columns are converted from Columns for the decoder
+	columns []*Column
+}
+
+func (t *TableSchema) decoderColumns() []*Column {
+	if t.columns == nil && t.Columns != nil {
+		t.columns = make([]*Column, len(t.Columns))
+		for i, c := range t.Columns {
+			col := &Column{Name: c.Name, Position: i + 1, DataType: baseType(c.Type), ColumnType: c.Type,
+				Charset: c.Charset, IsPrimary: c.Primary}
+			col.Unsigned = strings.Contains(c.Type, "unsigned")
+			col.parseColumnType(c.Type)
+			t.columns[i] = col
+		}
+	}
+	return t.columns
+}
+
+// SchemaTracker keeps the definitions of the tables in step with the binlog by applying the DDL statements of
+// the QueryEvents, so that the rows events are decoded with the columns in effect when they were written
+// instead of the current ones in information_schema. Set it to EventDecoder.Schema or Streamer.Schema.
+//
+// CREATE TABLE, ALTER TABLE, DROP TABLE, RENAME TABLE and DROP DATABASE are tracked. The definitions are versioned
+// by the positions of the DDL, applying a DDL again at or before the position of a later version discards them.
+type SchemaTracker struct {
+	// DB loads the definitions of the tables which are not created in the binlog yet from information_schema
+	// when they are first seen, optional. The dump should start from a position where the definitions are current.
+	DB *sql.DB
+
+	mu sync.Mutex
+	// file is the current binlog file taken from the RotateEvents
+	file string
+	// tables are the versions of the definitions keyed by "database.table" in the order of the positions
+	tables map[string][]*TableSchema
+}
+
+func schemaKey(database, table string) string {
+	return database + "." + table
+}
+
+// comparePositions compares the binlog file names and then the offsets.
+func comparePositions(a, b Position) int {
+	switch {
+	case a.File < b.File:
+		return -1
+	case a.File > b.File:
+		return 1
+	case a.Pos < b.Pos:
+		return -1
+	case a.Pos > b.Pos:
+		return 1
+	}
+	return 0
+}
+
+// Table returns the latest definition of the table, nil if the table doesn't exist or its definition is unknown.
+func (t *SchemaTracker) Table(database, table string) *TableSchema {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return present(t.latest(database, table))
+}
+
+// TableAt returns the definition of the table in effect at pos, nil if the table doesn't exist
+// or its definition is unknown.
+func (t *SchemaTracker) TableAt(database, table string, pos Position) *TableSchema {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	versions := t.tables[schemaKey(database, table)]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if comparePositions(versions[i].Position, pos) <= 0 {
+			return present(versions[i])
+		}
+	}
+	return nil
+}
+
+func present(ts *TableSchema) *TableSchema {
+	if ts == nil || ts.Columns == nil {
+		return nil
+	}
+	return ts
+}
+
+// latest returns the latest version of the table, which is loaded from DB if the table is not seen yet.
+func (t *SchemaTracker) latest(database, table string) *TableSchema {
+	key := schemaKey(database, table)
+	if versions := t.tables[key]; len(versions) > 0 {
+		return versions[len(versions)-1]
+	}
+	if t.DB == nil {
+		return nil
+	}
+	ts := &TableSchema{Database: database, Table: table}
+	columns, err := RetrieveColumns(t.DB, database, table)
+	if err != nil || len(columns) == 0 {
+		return nil
+	}
+	for _, c := range columns {
+		ts.Columns = append(ts.Columns, ColumnSchema{Name: c.Name, Type: c.ColumnType, Charset: c.Charset, Primary: c.IsPrimary})
+	}
+	t.put(ts)
+	return ts
+}
+
+// before returns the version of the table in effect right before pos, which the DDL at pos applies to.
+func (t *SchemaTracker) before(database, table string, pos Position) *TableSchema {
+	versions := t.tables[schemaKey(database, table)]
+	if len(versions) == 0 {
+		return t.latest(database, table)
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		if pos == (Position{}) || comparePositions(versions[i].Position, pos) < 0 {
+			return versions[i]
+		}
+	}
+	return nil
+}
+
+// put adds the version, the versions at or after its position are discarded.
+func (t *SchemaTracker) put(ts *TableSchema) {
+	if t.tables == nil {
+		t.tables = make(map[string][]*TableSchema)
+	}
+	key := schemaKey(ts.Database, ts.Table)
+	versions := t.tables[key]
+	for len(versions) > 0 && ts.Position != (Position{}) &&
+		comparePositions(versions[len(versions)-1].Position, ts.Position) >= 0 {
+		versions = versions[:len(versions)-1]
+	}
+	t.tables[key] = append(versions, ts)
+}
+
+// columns returns the columns of the latest definition of the table for the decoder.
+func (t *SchemaTracker) columns(database, table string) []*Column {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ts := t.latest(database, table); ts != nil {
+		return ts.decoderColumns()
+	}
+	return nil
+}
+
+// track follows the binlog file by the RotateEvents and applies the DDL of the QueryEvents.
+func (t *SchemaTracker) track(ev Event) error {
+	switch e := ev.(type) {
+	case *RotateEvent:
+		t.mu.Lock()
+		t.file = string(e.NextLogName)
+		t.mu.Unlock()
+	case *QueryEvent:
+		if e.ErrorCode != 0 || e.header.NextLogPos == 0 {
+			return nil
+		}
+		t.mu.Lock()
+		pos := Position{File: t.file, Pos: e.header.NextLogPos}
+		t.mu.Unlock()
+		return t.Apply(pos, string(e.Database), string(e.Query))
+	}
+	return nil
+}
+
+// Apply applies the DDL statement executed in the database at pos, the other statements are ignored.
+// The definition of the table becomes unknown if the DDL can't be applied.
+func (t *SchemaTracker) Apply(pos Position, database, query string) error {
+	p := newDDLParser(query)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case p.keyword("CREATE"):
+		if p.keyword("TEMPORARY") || !p.keyword("TABLE") {
+			return nil
+		}
+		return t.createTable(p, pos, database)
+	case p.keyword("ALTER"):
+		p.keyword("ONLINE", "OFFLINE")
+		p.keyword("IGNORE")
+		if !p.keyword("TABLE") {
+			return nil
+		}
+		return t.alterTable(p, pos, database)
+	case p.keyword("DROP"):
+		switch {
+		case p.keyword("TABLE", "TABLES"):
+			if p.keyword("IF") {
+				p.keyword("EXISTS")
+			}
+			for p.err == nil {
+				db, table := p.tableName(database)
+				if p.err == nil {
+					t.put(&TableSchema{Database: db, Table: table, Position: pos})
+				}
+				if !p.punct(",") {
+					break
+				}
+			}
+		case p.keyword("DATABASE", "SCHEMA"):
+			if p.keyword("IF") {
+				p.keyword("EXISTS")
+			}
+			db := p.ident()
+			for _, versions := range t.tables {
+				if last := versions[len(versions)-1]; p.err == nil && last.Database == db && last.Columns != nil {
+					t.put(&TableSchema{Database: db, Table: last.Table, Position: pos})
+				}
+			}
+		}
+	case p.keyword("RENAME"):
+		if !p.keyword("TABLE", "TABLES") {
+			return nil
+		}
+		for p.err == nil {
+			db, table := p.tableName(database)
+			if !p.keyword("TO") {
+				p.fail("expected TO")
+			}
+			newDB, newTable := p.tableName(database)
+			if p.err == nil {
+				t.rename(pos, db, table, newDB, newTable)
+			}
+			if !p.punct(",") {
+				break
+			}
+		}
+	}
+	if p.err != nil {
+		return fmt.Errorf("parse DDL %q: %v", query, p.err)
+	}
+	return nil
+}
+
+func (t *SchemaTracker) rename(pos Position, db, table, newDB, newTable string) {
+	var columns []ColumnSchema
+	if ts := t.before(db, table, pos); ts != nil {
+		columns = ts.Columns
+	}
+	t.put(&TableSchema{Database: db, Table: table, Position: pos})
+	t.put(&TableSchema{Database: newDB, Table: newTable, Columns: columns, Position: pos})
+}
+
+func (t *SchemaTracker) createTable(p *ddlParser, pos Position, database string) error {
+	ifNotExists := false
+	if p.keyword("IF") {
+		p.keyword("NOT")
+		p.keyword("EXISTS")
+		ifNotExists = true
+	}
+	db, table := p.tableName(database)
+	if p.err != nil {
+		return fmt.Errorf("parse DDL %q: %v", p.query, p.err)
+	}
+	if ifNotExists && present(t.before(db, table, pos)) != nil {
+		return nil
+	}
+
+	ts := &TableSchema{Database: db, Table: table, Position: pos}
+	parens := p.punct("(")
+	switch {
+	case p.keyword("LIKE"):
+		srcDB, srcTable := p.tableName(database)
+		if src := t.before(srcDB, srcTable, pos); src != nil {
+			ts.Columns = src.Columns
+		}
+	case parens:
+		var primary []string
+		for p.err == nil {
+			primary = append(primary, p.createDefinition(ts)...)
+			if !p.punct(",") {
+				break
+			}
+		}
+		p.expect(")")
+		// the default charset of the table applies to the textual columns without their own
+		charset := p.tableCharset()
+		for i := range ts.Columns {
+			if c := &ts.Columns[i]; c.Charset == "" && textTypes[baseType(c.Type)] {
+				c.Charset = charset
+			}
+		}
+		setPrimary(ts.Columns, primary)
+	}
+	// the definition is unknown for CREATE TABLE ... SELECT without the columns
+	t.put(ts)
+	if p.err != nil {
+		return fmt.Errorf("parse DDL %q: %v", p.query, p.err)
+	}
+	return nil
+}
+
+// createDefinition parses a column or an index of CREATE TABLE, it returns the columns of the primary key.
+func (p *ddlParser) createDefinition(ts *TableSchema) []string {
+	if p.keyword("CONSTRAINT") {
+		if t := p.peek(); !(t.kind == ddlWord && (strings.EqualFold(t.text, "PRIMARY") ||
+			strings.EqualFold(t.text, "UNIQUE") || strings.EqualFold(t.text, "FOREIGN") || strings.EqualFold(t.text, "CHECK"))) {
+			// the constraint name
+			p.ident()
+		}
+	}
+	switch {
+	case p.keyword("PRIMARY"):
+		p.keyword("KEY")
+		return p.primaryKey()
+	case p.keyword("INDEX", "KEY", "UNIQUE", "FULLTEXT", "SPATIAL", "FOREIGN", "CHECK"):
+		p.skip()
+		return nil
+	}
+	c, primary := p.columnDef()
+	ts.Columns = append(ts.Columns, c)
+	if primary {
+		return []string{c.Name}
+	}
+	return nil
+}
+
+// primaryKey parses the rest of PRIMARY KEY [USING type] (key parts) [options].
+func (p *ddlParser) primaryKey() []string {
+	if p.keyword("USING") {
+		p.ident()
+	}
+	names := p.keyParts()
+	p.skip()
+	return names
+}
+
+// tableCharset parses the table options for the default charset.
+func (p *ddlParser) tableCharset() string {
+	charset := ""
+	for p.err == nil {
+		t := p.peek()
+		if t.kind == ddlEOF || t.kind == ddlPunct && t.text == ";" {
+			break
+		}
+		switch {
+		case p.keyword("CHARACTER"):
+			p.keyword("SET")
+			p.punct("=")
+			charset = strings.ToLower(p.ident())
+		case p.keyword("CHARSET"):
+			p.punct("=")
+			charset = strings.ToLower(p.ident())
+		case p.keyword("COLLATE"):
+			p.punct("=")
+			if collation := strings.ToLower(p.ident()); charset == "" {
+				charset = strings.SplitN(collation, "_", 2)[0]
+			}
+		case p.keyword("AS", "SELECT", "PARTITION", "IGNORE", "REPLACE"):
+			// the rest is not about the table options
+			return charset
+		default:
+			p.take()
+		}
+	}
+	return charset
+}
+
+func baseType(typ string) string {
+	if i := strings.IndexAny(typ, "( "); i >= 0 {
+		return typ[:i]
+	}
+	return typ
+}
+
+func setPrimary(columns []ColumnSchema, primary []string) {
+	for i := range columns {
+		for _, name := range primary {
+			if strings.EqualFold(columns[i].Name, name) {
+				columns[i].Primary = true
+			}
+		}
+	}
+}
+
+func columnIndex(columns []ColumnSchema, name string) int {
+	for i, c := range columns {
+		if strings.EqualFold(c.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *SchemaTracker) alterTable(p *ddlParser, pos Position, database string) error {
+	db, table := p.tableName(database)
+	if p.err != nil {
+		return fmt.Errorf("parse DDL %q: %v", p.query, p.err)
+	}
+	cur := present(t.before(db, table, pos))
+	if cur == nil {
+		return fmt.Errorf("the definition of table %s.%s is unknown to apply %q", db, table, p.query)
+	}
+	ts := &TableSchema{Database: db, Table: table, Position: pos}
+	ts.Columns = append([]ColumnSchema(nil), cur.Columns...)
+	renamed := ""
+
+	var err error
+	for p.err == nil && err == nil {
+		switch {
+		case p.keyword("ADD"):
+			err = p.alterAdd(ts)
+		case p.keyword("DROP"):
+			err = p.alterDrop(ts)
+		case p.keyword("MODIFY"):
+			p.keyword("COLUMN")
+			c, primary := p.columnDef()
+			err = ts.replaceColumn(p, c.Name, c, primary)
+		case p.keyword("CHANGE"):
+			p.keyword("COLUMN")
+			old := p.ident()
+			c, primary := p.columnDef()
+			err = ts.replaceColumn(p, old, c, primary)
+		case p.keyword("RENAME"):
+			switch {
+			case p.keyword("COLUMN"):
+				old := p.ident()
+				p.keyword("TO")
+				name := p.ident()
+				if i := columnIndex(ts.Columns, old); i >= 0 {
+					ts.Columns[i].Name = name
+				} else {
+					err = fmt.Errorf("column %s not found", old)
+				}
+			case p.keyword("INDEX", "KEY"):
+				p.skip()
+			default:
+				p.keyword("TO", "AS")
+				newDB, newTable := p.tableName(database)
+				renamed = schemaKey(newDB, newTable)
+				ts.Database, ts.Table = newDB, newTable
+			}
+		case p.keyword("CONVERT"):
+			p.keyword("TO")
+			if p.keyword("CHARACTER") {
+				p.keyword("SET")
+			} else {
+				p.keyword("CHARSET")
+			}
+			charset := strings.ToLower(p.ident())
+			for i := range ts.Columns {
+				if textTypes[baseType(ts.Columns[i].Type)] {
+					ts.Columns[i].Charset = charset
+				}
+			}
+			p.skip()
+		default:
+			// the table options and the other alterations which don't change the columns
+			p.skip()
+		}
+		if !p.punct(",") {
+			break
+		}
+	}
+	if err == nil && p.err != nil {
+		err = p.err
+	}
+	if err != nil {
+		// the definition becomes unknown
+		t.put(&TableSchema{Database: db, Table: table, Position: pos})
+		return fmt.Errorf("apply DDL %q: %v", p.query, err)
+	}
+	if renamed != "" && renamed != schemaKey(db, table) {
+		t.put(&TableSchema{Database: db, Table: table, Position: pos})
+	}
+	t.put(ts)
+	return nil
+}
+
+func (p *ddlParser) alterAdd(ts *TableSchema) error {
+	if p.keyword("CONSTRAINT") {
+		if t := p.peek(); !(t.kind == ddlWord && strings.EqualFold(t.text, "PRIMARY")) {
+			if p.keyword("UNIQUE", "FOREIGN", "CHECK") {
+				p.skip()
+				return nil
+			}
+			p.ident()
+		}
+	}
+	switch {
+	case p.keyword("PRIMARY"):
+		p.keyword("KEY")
+		setPrimary(ts.Columns, p.primaryKey())
+		return nil
+	case p.keyword("INDEX", "KEY", "UNIQUE", "FULLTEXT", "SPATIAL", "FOREIGN", "CHECK", "PARTITION"):
+		p.skip()
+		return nil
+	}
+	p.keyword("COLUMN")
+	if p.punct("(") {
+		for p.err == nil {
+			c, primary := p.columnDef()
+			c.Primary = c.Primary || primary
+			ts.Columns = append(ts.Columns, c)
+			if !p.punct(",") {
+				break
+			}
+		}
+		p.expect(")")
+		return nil
+	}
+	c, primary := p.columnDef()
+	c.Primary = primary
+	if columnIndex(ts.Columns, c.Name) >= 0 {
+		return fmt.Errorf("duplicate column %s", c.Name)
+	}
+	return ts.insertColumn(p, c)
+}
+
+func (p *ddlParser) alterDrop(ts *TableSchema) error {
+	switch {
+	case p.keyword("PRIMARY"):
+		p.keyword("KEY")
+		for i := range ts.Columns {
+			ts.Columns[i].Primary = false
+		}
+		return nil
+	case p.keyword("INDEX", "KEY", "FOREIGN", "CHECK", "CONSTRAINT", "PARTITION"):
+		p.skip()
+		return nil
+	}
+	p.keyword("COLUMN")
+	ifExists := false
+	if p.keyword("IF") {
+		p.keyword("EXISTS")
+		ifExists = true
+	}
+	name := p.ident()
+	i := columnIndex(ts.Columns, name)
+	if i < 0 {
+		if ifExists {
+			return nil
+		}
+		return fmt.Errorf("column %s not found", name)
+	}
+	ts.Columns = append(ts.Columns[:i], ts.Columns[i+1:]...)
+	p.skip()
+	return nil
+}
+
+// replaceColumn replaces the column of MODIFY or CHANGE, which may be moved by FIRST or AFTER.
+func (ts *TableSchema) replaceColumn(p *ddlParser, old string, c ColumnSchema, primary bool) error {
+	i := columnIndex(ts.Columns, old)
+	if i < 0 {
+		return fmt.Errorf("column %s not found", old)
+	}
+	c.Primary = ts.Columns[i].Primary || primary
+	if c.Charset == "" && textTypes[baseType(c.Type)] && textTypes[baseType(ts.Columns[i].Type)] {
+		c.Charset = ts.Columns[i].Charset
+	}
+	first, after := p.columnPosition()
+	if !first && after == "" {
+		ts.Columns[i] = c
+		return nil
+	}
+	ts.Columns = append(ts.Columns[:i], ts.Columns[i+1:]...)
+	return ts.placeColumn(c, first, after)
+}
+
+// insertColumn adds the column of ADD at the end or the position of FIRST or AFTER.
+func (ts *TableSchema) insertColumn(p *ddlParser, c ColumnSchema) error {
+	first, after := p.columnPosition()
+	return ts.placeColumn(c, first, after)
+}
+
+func (ts *TableSchema) placeColumn(c ColumnSchema, first bool, after string) error {
+	i := len(ts.Columns)
+	switch {
+	case first:
+		i = 0
+	case after != "":
+		if i = columnIndex(ts.Columns, after); i < 0 {
+			return fmt.Errorf("column %s not found", after)
+		}
+		i++
+	}
+	ts.Columns = append(ts.Columns, ColumnSchema{})
+	copy(ts.Columns[i+1:], ts.Columns[i:])
+	ts.Columns[i] = c
+	return nil
+}
@@ -0,0 +1,139 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func schemaColumns(ts *TableSchema) []string {
+	if ts == nil {
+		return nil
+	}
+	var columns []string
+	for _, c := range ts.Columns {
+		s := c.Name + " " + c.Type
+		if c.Charset != "" {
+			s += " " + c.Charset
+		}
+		if c.Primary {
+			s += " PK"
+		}
+		columns = append(columns, s)
+	}
+	return columns
+}
+
+func TestDDLTables(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected [][2]string
+	}{
+		{"CREATE TABLE IF NOT EXISTS `db`.`t``1` (id int)", [][2]string{{"db", "t`1"}}},
+		{"alter table t add column c int", [][2]string{{"", "t"}}},
+		{"DROP TABLE IF EXISTS a, db.b", [][2]string{{"", "a"}, {"db", "b"}}},
+		{"RENAME TABLE a TO b, `c` TO `d`", [][2]string{{"", "a"}, {"", "b"}, {"", "c"}, {"", "d"}}},
+		{"TRUNCATE t", [][2]string{{"", "t"}}},
+		{"/* comment */ DROP TEMPORARY TABLES `a`,`b`", [][2]string{{"", "a"}, {"", "b"}}},
+		{"CREATE TABLE t LIKE db.s", [][2]string{{"", "t"}}},
+		{"CREATE DATABASE db", nil},
+		{"INSERT INTO t VALUES (1)", nil},
+		{"BEGIN", nil},
+	}
+	for _, test := range tests {
+		if names := ddlTables(test.query); !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.query, test.expected, names)
+		}
+	}
+}
+
+func TestSchemaTracker(t *testing.T) {
+	tracker := new(SchemaTracker)
+	at := func(pos uint32) Position { return Position{File: "mysql-bin.000001", Pos: pos} }
+	apply := func(pos uint32, query string) {
+		if err := tracker.Apply(at(pos), "test", query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(ts *TableSchema, expected ...string) {
+		if columns := schemaColumns(ts); !reflect.DeepEqual(columns, expected) {
+			t.Errorf("expected columns %q, got %q", expected, columns)
+		}
+	}
+
+	apply(100, "/* comment */ CREATE TABLE IF NOT EXISTS `t` (\n"+
+		"  `id` int(10) unsigned NOT NULL AUTO_INCREMENT,\n"+
+		"  `name` varchar(32) DEFAULT 'a,b' COMMENT 'the (name)',\n"+
+		"  `state` enum('on','it''s off') COLLATE latin1_bin,\n"+
+		"  PRIMARY KEY (`id`),\n"+
+		"  KEY `idx_name` (`name`(10))\n"+
+		") ENGINE=InnoDB /*!50100 DEFAULT CHARSET=utf8mb4 */")
+	check(tracker.Table("test", "t"), "id int(10) unsigned PK", "name varchar(32) utf8mb4", "state enum('on','it''s off') latin1")
+
+	apply(200, "ALTER TABLE test.t ADD COLUMN age tinyint AFTER id, DROP COLUMN state, "+
+		"CHANGE name full_name varchar(64) FIRST, ENGINE=InnoDB")
+	check(tracker.Table("test", "t"), "full_name varchar(64) utf8mb4", "id int(10) unsigned PK", "age tinyint")
+	check(tracker.TableAt("test", "t", at(150)), "id int(10) unsigned PK", "name varchar(32) utf8mb4", "state enum('on','it''s off') latin1")
+
+	apply(300, "RENAME TABLE t TO t2")
+	if tracker.Table("test", "t") != nil {
+		t.Error("expected t renamed")
+	}
+	check(tracker.Table("test", "t2"), "full_name varchar(64) utf8mb4", "id int(10) unsigned PK", "age tinyint")
+	apply(400, "DROP TABLE IF EXISTS `t2` /* generated by server */")
+	if tracker.Table("test", "t2") != nil || tracker.TableAt("test", "t2", at(399)) == nil {
+		t.Error("expected t2 dropped at 400")
+	}
+
+	// applied again from an earlier position
+	apply(250, "ALTER TABLE t DROP PRIMARY KEY, MODIFY id bigint")
+	check(tracker.Table("test", "t"), "full_name varchar(64) utf8mb4", "id bigint", "age tinyint")
+	if tracker.Table("test", "t2") != nil {
+		t.Error("expected t2 kept dropped")
+	}
+
+	if err := tracker.Apply(at(500), "test", "ALTER TABLE t DROP COLUMN missing"); err == nil {
+		t.Error("expected the error of the missing column")
+	}
+	if tracker.Table("test", "t") != nil {
+		t.Error("expected the definition unknown after the failed DDL")
+	}
+	apply(600, "INSERT INTO t VALUES (1)")
+}
+
+func TestDecodeWithSchemaTracker(t *testing.T) {
+	tracker := new(SchemaTracker)
+	dec := &EventDecoder{Schema: tracker, Filter: &EventFilter{EventTypes: []EventType{WriteRowsEventType}},
+		tables: make(map[uint64]*TableMapEvent)}
+	query := func(q string) []byte {
+		// thread id, execution time, database length, error code, status vars length
+		body := make([]byte, 13)
+		body[8] = 4
+		return append(body, "test\x00"+q...)
+	}
+	decode := func(typ EventType, body []byte) Event {
+		ev, err := dec.decode(buildEvent(typ, body, false))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ev
+	}
+	rotate := make([]byte, 8)
+	binary.LittleEndian.PutUint64(rotate, 4)
+	decode(RotateEventType, append(rotate, "mysql-bin.000001"...))
+	if ev := decode(QueryEventType, query("CREATE TABLE t (id int unsigned PRIMARY KEY, v varchar(10))")); ev != nil {
+		t.Errorf("expected the QueryEvent filtered out, got %v", ev)
+	}
+	if ts := tracker.TableAt("test", "t", Position{File: "mysql-bin.000001", Pos: 1000}); ts == nil || len(ts.Columns) != 2 {
+		t.Fatalf("expected the table tracked at the position of the QueryEvent, got %+v", ts)
+	}
+
+	// table id, flags, database, table, column count, types, metadata, nullability
+	tableMap := []byte{1, 0, 0, 0, 0, 0, 0, 0, 4, 't', 'e', 's', 't', 0, 1, 't', 0, 2, fieldTypeLong, fieldTypeVarChar, 2, 10, 0, 0}
+	decode(TableMapEventType, tableMap)
+	rows := []byte{1, 0, 0, 0, 0, 0, 1, 0, 2, 0, 2, 3, 0, 0xff, 0xff, 0xff, 0xff, 1, 'x'}
+	e := decode(WriteRowsEventType, rows).(*RowsEvent)
+	if e.Table.ColumnName(1) != "v" || e.Rows[0][0] != int64(0xffffffff) {
+		t.Errorf("unexpected columns %s and row %v", e.Table.ColumnName(1), e.Rows[0])
+	}
+}
@@ -0,0 +1,390 @@
+package binlog
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/LightKool/mysql-go"
+)
+
+// EventSource provides the raw events served by Server.
+type EventSource interface {
+	// Dump sends the raw events from the position of the binlog file by send, the events include the headers
+	// and the checksums. It waits for the new events at the end of the binlog until ctx is done or send fails,
+	// unless nonBlock is true where it returns nil at the end.
+	Dump(ctx context.Context, file string, pos uint32, nonBlock bool, send func(event []byte) error) error
+}
+
+// Server serves the replication protocol like a master, so that the replicas, i.e. MySQL servers and Streamers,
+// can dump the events of Source, e.g. to proxy or filter the binlog, or to test without a real MySQL server.
+//
+// It supports COM_REGISTER_SLAVE and COM_BINLOG_DUMP, and answers the queries issued by the replicas before
+// dumping with Variables: `SELECT @@name`, `SELECT @user_var`, `SELECT UNIX_TIMESTAMP()` and
+// `SHOW [GLOBAL] VARIABLES LIKE 'name'`. The other statements are acknowledged with OK packets.
+type Server struct {
+	// Source provides the events to dump, required.
+	Source EventSource
+	// ServerID is the server id of the artificial events, default is 1.
+	ServerID uint32
+	// ServerVersion is sent to the clients in the handshake, default is "5.7.0-log".
+	ServerVersion string
+	// Users are the passwords of the users who can connect, no user can connect if it's empty
+	// unless AllowAnyUser is true.
+	Users map[string]string
+	// AllowAnyUser accepts any user without password, e.g. in the tests.
+	AllowAnyUser bool
+	// Variables are the global variables queried by the replicas in lower case, they override the defaults like
+	// binlog_checksum which must match the events of Source, e.g. "CRC32".
+	Variables map[string]string
+	// WriteTimeout drops the dump if a replica doesn't read an event for the timeout like net_write_timeout,
+	// default is no timeout.
+	WriteTimeout time.Duration
+	// Log receives the connections and the errors if not nil.
+	Log mysql.LeveledLogger
+
+	connectionID uint32
+}
+
+func (s *Server) log() mysql.LeveledLogger {
+	if s.Log != nil {
+		return s.Log
+	}
+	return mysql.NopLogger
+}
+
+func (s *Server) serverVersion() string {
+	if s.ServerVersion != "" {
+		return s.ServerVersion
+	}
+	return "5.7.0-log"
+}
+
+// variable returns the value of the global variable, or false if it's unknown.
+func (s *Server) variable(name string) (string, bool) {
+	name = strings.ToLower(name)
+	if v, ok := s.Variables[name]; ok {
+		return v, true
+	}
+	switch name {
+	case "version":
+		return s.serverVersion(), true
+	case "server_id":
+		serverID := s.ServerID
+		if serverID == 0 {
+			serverID = 1
+		}
+		return strconv.FormatUint(uint64(serverID), 10), true
+	case "binlog_checksum":
+		return ChecksumAlgorithmNone.String(), true
+	case "max_allowed_packet":
+		return "67108864", true
+	case "gtid_mode":
+		return "OFF", true
+	}
+	return "", false
+}
+
+// Serve accepts the connections from ln and serves them until ctx is done or ln fails.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.ServeConn(ctx, conn); err != nil && err != io.EOF {
+				s.log().Warn("replica connection failed", "addr", conn.RemoteAddr(), "error", err)
+			}
+		}()
+	}
+}
+
+// ServeConn serves a connection until the client quits, ctx is done or an error occurs.
+func (s *Server) ServeConn(ctx context.Context, conn net.Conn) error {
+	sc := mysql.NewServerConn(conn)
+	sc.SetWriteTimeout(s.WriteTimeout)
+	defer sc.Close()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		// unblock the reads and writes
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	err := sc.Handshake(s.serverVersion(), atomic.AddUint32(&s.connectionID, 1), func(user string) (string, bool) {
+		if s.AllowAnyUser {
+			return "", true
+		}
+		password, ok := s.Users[user]
+		return password, ok
+	})
+	if err != nil {
+		return err
+	}
+	s.log().Info("replica connected", "addr", conn.RemoteAddr(), "user", sc.User)
+
+	userVars := make(map[string]string)
+	for {
+		command, data, err := sc.ReadCommand()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		switch command {
+		case mysql.ComQuit:
+			return nil
+		case mysql.ComQuery:
+			err = s.query(sc, string(data), userVars)
+		case mysql.ComPing, mysql.ComInitDB, mysql.ComRegisterSlave:
+			err = sc.WriteOK()
+		case mysql.ComBinlogDump:
+			return s.dump(ctx, sc, data, userVars["rpl_semi_sync_slave"] == "1")
+		default:
+			err = sc.WriteError(1047, "08S01", fmt.Sprintf("Unknown command %d", command))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+var (
+	setUserVar       = regexp.MustCompile(`(?i)^\s*SET\s+@(\w+)\s*=\s*(.*?)\s*$`)
+	selectExprs      = regexp.MustCompile(`(?i)^\s*SELECT\s+(.*?)\s*(?:LIMIT\s+\d+\s*)?$`)
+	showVariables    = regexp.MustCompile(`(?i)^\s*SHOW\s+(?:GLOBAL\s+|SESSION\s+)?VARIABLES\s+LIKE\s+'([^']*)'\s*$`)
+	systemVar        = regexp.MustCompile(`(?i)^@@(?:GLOBAL\.|SESSION\.)?(\w+)$`)
+	userVar          = regexp.MustCompile(`^@(\w+)$`)
+	unixTimestampFun = regexp.MustCompile(`(?i)^UNIX_TIMESTAMP\(\s*\)$`)
+)
+
+// query answers the query issued by the replica before dumping.
+func (s *Server) query(sc *mysql.ServerConn, query string, userVars map[string]string) error {
+	if m := setUserVar.FindStringSubmatch(query); m != nil {
+		value := strings.Trim(m[2], `'"`)
+		if vm := systemVar.FindStringSubmatch(value); vm != nil {
+			value, _ = s.variable(vm[1])
+		}
+		userVars[strings.ToLower(m[1])] = value
+		return sc.WriteOK()
+	}
+	if m := showVariables.FindStringSubmatch(query); m != nil {
+		var rows [][]interface{}
+		if v, ok := s.variable(m[1]); ok {
+			rows = append(rows, []interface{}{strings.ToLower(m[1]), v})
+		}
+		return sc.WriteResultSet([]string{"Variable_name", "Value"}, rows)
+	}
+	if m := selectExprs.FindStringSubmatch(query); m != nil {
+		var columns []string
+		var row []interface{}
+		for _, expr := range strings.Split(m[1], ",") {
+			expr = strings.TrimSpace(expr)
+			var value interface{}
+			if vm := systemVar.FindStringSubmatch(expr); vm != nil {
+				if v, ok := s.variable(vm[1]); ok {
+					value = v
+				}
+			} else if vm := userVar.FindStringSubmatch(expr); vm != nil {
+				if v, ok := userVars[strings.ToLower(vm[1])]; ok {
+					value = v
+				}
+			} else if unixTimestampFun.MatchString(expr) {
+				value = time.Now().Unix()
+			} else if _, err := strconv.ParseFloat(expr, 64); err == nil {
+				value = expr
+			}
+			columns = append(columns, expr)
+			row = append(row, value)
+		}
+		return sc.WriteResultSet(columns, [][]interface{}{row})
+	}
+	return sc.WriteOK()
+}
+
+// dump serves COM_BINLOG_DUMP: the artificial RotateEvent of the position, then the events of Source.
+func (s *Server) dump(ctx context.Context, sc *mysql.ServerConn, data []byte, semiSync bool) error {
+	if len(data) < 4+2+4 {
+		sc.WriteError(1064, "42000", "Malformed COM_BINLOG_DUMP")
+		return mysql.ErrMalformPkt
+	}
+	pos := binary.LittleEndian.Uint32(data)
+	flags := binary.LittleEndian.Uint16(data[4:])
+	serverID := binary.LittleEndian.Uint32(data[6:])
+	file := string(data[10:])
+	if pos < uint32(len(binlogMagic)) {
+		pos = uint32(len(binlogMagic))
+	}
+	s.log().Info("replica dumping binlog", "server_id", serverID, "file", file, "pos", pos)
+
+	checksum, _ := s.variable("binlog_checksum")
+	enc := &EventEncoder{format: &FormatDescriptionEvent{ChecksumAlgorithm: ChecksumAlgorithmNone}}
+	if strings.EqualFold(checksum, ChecksumAlgorithmCRC32.String()) {
+		enc.format.ChecksumAlgorithm = ChecksumAlgorithmCRC32
+	}
+	serverIDVar, _ := s.variable("server_id")
+	masterID, _ := strconv.ParseUint(serverIDVar, 10, 32)
+	rotate := &RotateEvent{
+		baseEvent: &baseEvent{header: &EventHeader{
+			Type:     RotateEventType,
+			ServerID: uint32(masterID),
+			Flags:    logEventArtificialFlag,
+		}},
+		Position:    uint64(pos),
+		NextLogName: []byte(file),
+	}
+	event, err := rotate.Encode(enc)
+	if err != nil {
+		return err
+	}
+	if err = sc.WriteEvent(event, semiSync); err != nil {
+		return err
+	}
+
+	nonBlock := flags&mysql.BinlogDumpNonBlock != 0
+	err = s.Source.Dump(ctx, file, pos, nonBlock, func(event []byte) error {
+		return sc.WriteEvent(event, semiSync)
+	})
+	if err == nil && nonBlock {
+		return sc.WriteEOF()
+	}
+	return err
+}
+
+// DirSource serves the binlog files in Dir, e.g. the ones written by DumpTo. It follows the RotateEvents
+// to the next files and waits for the new events appended to the last one.
+type DirSource struct {
+	Dir string
+	// PollInterval is the interval to check the new events at the end of the binlog, default is 1s.
+	PollInterval time.Duration
+}
+
+// Dump implements EventSource.
+func (d *DirSource) Dump(ctx context.Context, file string, pos uint32, nonBlock bool, send func(event []byte) error) error {
+	if file == "" {
+		return fmt.Errorf("binlog file is required")
+	}
+	interval := d.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	// only the FormatDescriptionEvent and RotateEvent are decoded to follow the files
+	dec := &EventDecoder{tables: make(map[uint64]*TableMapEvent)}
+
+	for {
+		f, err := os.Open(filepath.Join(d.Dir, filepath.Base(file)))
+		if err != nil {
+			return err
+		}
+		next, err := d.dumpFile(ctx, f, pos, nonBlock, interval, dec, send)
+		f.Close()
+		if err != nil || next == "" {
+			return err
+		}
+		file, pos = next, uint32(len(binlogMagic))
+	}
+}
+
+// dumpFile sends the events of the file from pos, it returns the name of the next file if it's rotated.
+// The FormatDescriptionEvent is always sent first like the master does.
+func (d *DirSource) dumpFile(ctx context.Context, f *os.File, pos uint32, nonBlock bool, interval time.Duration,
+	dec *EventDecoder, send func(event []byte) error) (string, error) {
+	magic := make([]byte, len(binlogMagic))
+	if _, err := f.ReadAt(magic, 0); err != nil || string(magic) != string(binlogMagic) {
+		return "", fmt.Errorf("invalid binlog file %s", f.Name())
+	}
+	off := int64(len(binlogMagic))
+	if int64(pos) > off {
+		event, err := readEventAt(f, off)
+		if err != nil || event == nil || EventType(event[4]) != FormatDescriptionEventType {
+			return "", fmt.Errorf("format description event not found in %s: %v", f.Name(), err)
+		}
+		if _, err = dec.decode(event); err != nil {
+			return "", err
+		}
+		if err = send(event); err != nil {
+			return "", err
+		}
+		off = int64(pos)
+	}
+
+	for {
+		event, err := readEventAt(f, off)
+		if err != nil {
+			return "", err
+		}
+		if event == nil {
+			if nonBlock {
+				return "", nil
+			}
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(interval):
+			}
+			continue
+		}
+		if err = send(event); err != nil {
+			return "", err
+		}
+		off += int64(len(event))
+
+		switch EventType(event[4]) {
+		case FormatDescriptionEventType, RotateEventType:
+			ev, err := dec.decode(event)
+			if err != nil {
+				return "", err
+			}
+			if e, ok := ev.(*RotateEvent); ok {
+				return string(e.NextLogName), nil
+			}
+		}
+	}
+}
+
+// readEventAt reads the event at the offset of the file, it returns nil if the event is not written completely.
+func readEventAt(f *os.File, off int64) ([]byte, error) {
+	header := make([]byte, eventHeaderSize)
+	if n, err := f.ReadAt(header, off); n < len(header) {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[9:])
+	if size < eventHeaderSize {
+		return nil, fmt.Errorf("invalid event size %d at %d of %s", size, off, f.Name())
+	}
+	event := make([]byte, size)
+	if n, err := f.ReadAt(event, off); n < len(event) {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return event, nil
+}
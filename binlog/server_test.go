@@ -0,0 +1,119 @@
+package binlog
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/LightKool/mysql-go"
+)
+
+func writeBinlogFile(t *testing.T, path string, events ...Event) {
+	enc := &EventEncoder{Pos: uint32(len(binlogMagic))}
+	data := append([]byte(nil), binlogMagic...)
+	for _, ev := range events {
+		b, err := enc.Encode(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, b...)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	header := func(typ EventType) *baseEvent {
+		return &baseEvent{header: &EventHeader{Timestamp: 1500000000, Type: typ, ServerID: 1}}
+	}
+	fde := func() Event {
+		return &FormatDescriptionEvent{
+			baseEvent:              header(FormatDescriptionEventType),
+			BinlogVersion:          4,
+			ServerVersion:          []byte("5.7.18-log"),
+			EventHeaderLength:      eventHeaderSize,
+			EventPostHeaderLengths: []byte{56, 13, 0, 8},
+			ChecksumAlgorithm:      ChecksumAlgorithmCRC32,
+		}
+	}
+	writeBinlogFile(t, filepath.Join(dir, "mysql-bin.000001"), fde(),
+		&QueryEvent{baseEvent: header(QueryEventType), StatusVars: []byte{}, Database: []byte("test"), Query: []byte("BEGIN")},
+		&XIDEvent{baseEvent: header(XidEventType), TransactionID: 1},
+		&RotateEvent{baseEvent: header(RotateEventType), Position: 4, NextLogName: []byte("mysql-bin.000002")})
+	writeBinlogFile(t, filepath.Join(dir, "mysql-bin.000002"), fde(),
+		&XIDEvent{baseEvent: header(XidEventType), TransactionID: 2})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	server := &Server{
+		Source:    &DirSource{Dir: dir, PollInterval: 10 * time.Millisecond},
+		Users:     map[string]string{"repl": "secret"},
+		Variables: map[string]string{"binlog_checksum": "CRC32"},
+	}
+	go server.Serve(ctx, ln)
+
+	s := &Streamer{ChecksumPolicy: ChecksumFail}
+	dsn := "repl:secret@tcp(" + ln.Addr().String() + ")/"
+	if _, err = s.Start(ctx, "bad:secret@tcp("+ln.Addr().String()+")/", 100, "mysql-bin.000001", 4); err == nil {
+		t.Error("expected the error of the unknown user")
+	}
+	q, err := s.Start(ctx, dsn, 100, "mysql-bin.000001", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close(false)
+
+	var xids []uint64
+	for len(xids) < 2 {
+		ev, err := q.Pop(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e, ok := ev.(*XIDEvent); ok {
+			xids = append(xids, e.TransactionID)
+		}
+	}
+	if xids[0] != 1 || xids[1] != 2 {
+		t.Errorf("expected the transactions 1 and 2, got %v", xids)
+	}
+	if pos := s.Position(); pos.File != "mysql-bin.000002" || pos.Pos <= 4 {
+		t.Errorf("unexpected position %+v", pos)
+	}
+}
+
+func TestServerWithoutUsers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// no user can connect unless AllowAnyUser is set
+	for _, allowAnyUser := range []bool{false, true} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go (&Server{Source: &DirSource{Dir: os.TempDir()}, AllowAnyUser: allowAnyUser}).Serve(ctx, ln)
+
+		conn := mysql.NewConnWrapper()
+		err = conn.Connect("root@tcp(" + ln.Addr().String() + ")/?maxAllowedPacket=4194304")
+		if err == nil {
+			conn.Close()
+		}
+		if allowAnyUser != (err == nil) {
+			t.Errorf("expected the user accepted %v, got %v", allowAnyUser, err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package binlog
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/LightKool/mysql-go"
+)
+
+// errMasterFatalReadingBinlog is ER_MASTER_FATAL_ERROR_READING_BINLOG, which is sent when the dump thread is
+// killed because another replica has registered with the same server ID.
+const errMasterFatalReadingBinlog = 1236
+
+// ServerIDConflictError is returned when the master drops the dump because another replica
+// has connected with the same server ID.
+type ServerIDConflictError struct {
+	ServerID uint32
+	Err      error
+}
+
+func (e *ServerIDConflictError) Error() string {
+	return fmt.Sprintf("server ID %d is used by another replica: %v", e.ServerID, e.Err)
+}
+
+// isServerIDConflict reports whether err is sent by the master for the collision of the server ID,
+// e.g. "A slave with the same server_uuid/server_id as this slave has connected to the master".
+func isServerIDConflict(err error) bool {
+	me, ok := err.(*mysql.MySQLError)
+	return ok && me.Number == errMasterFatalReadingBinlog && strings.Contains(me.Message, "with the same server_")
+}
+
+// randomServerID returns a random server ID in the upper half of the range, which is unlikely to be
+// configured for the real servers.
+func randomServerID() (uint32, error) {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b[:]) | 0x80000000, nil
+}
+
+// ServerID returns the server ID the Streamer registers with, which is the generated one if AutoServerID is set.
+func (s *Streamer) ServerID() uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.serverID
+}
+
+// setServerID sets the server ID to register with, a random one is generated for 0 if AutoServerID is set.
+func (s *Streamer) setServerID(serverID uint32) error {
+	if serverID == 0 && s.AutoServerID {
+		var err error
+		if serverID, err = randomServerID(); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.serverID = serverID
+	s.mu.Unlock()
+	return nil
+}
+
+// serverIDConflict checks whether the dump is dropped for the collision of the server ID. A new random server ID
+// is generated to reconnect with if AutoServerID is set, otherwise the error is returned as *ServerIDConflictError.
+func (s *Streamer) serverIDConflict(err error) (renewed bool, _ error) {
+	if !isServerIDConflict(err) {
+		return false, err
+	}
+	old := s.ServerID()
+	if !s.AutoServerID {
+		return false, &ServerIDConflictError{ServerID: old, Err: err}
+	}
+	if err := s.setServerID(0); err != nil {
+		return false, err
+	}
+	s.log().Warn("server ID is used by another replica, renewed", "old", old, "new", s.ServerID())
+	return true, err
+}
@@ -0,0 +1,135 @@
+package binlog
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/LightKool/mysql-go"
+)
+
+// SnapshotRow is a row of a table dumped by Snapshot, the values are in the text form or nil for NULL.
+type SnapshotRow struct {
+	Database string
+	Table    string
+	Columns  []string
+	Values   [][]byte
+}
+
+// Snapshot dumps the rows of tables consistent with a binlog position, so that the binlog can be streamed
+// from the position afterwards without losing or duplicating any change.
+//
+// The tables are read in a transaction started by START TRANSACTION WITH CONSISTENT SNAPSHOT, while the tables
+// are locked by FLUSH TABLES WITH READ LOCK only until the position is recorded. The consistency is guaranteed
+// for the transactional tables like InnoDB.
+type Snapshot struct {
+	// Tables are the tables to dump in the form of "database.table".
+	Tables []string
+	// TLSConfig overrides the `tls` parameter of the DSN if not nil.
+	TLSConfig *tls.Config
+	// SkipLock skips FLUSH TABLES WITH READ LOCK which requires the RELOAD privilege, the position may be
+	// inconsistent with the snapshot if any transaction commits while it's being recorded.
+	SkipLock bool
+}
+
+// Run dumps the rows of the tables into fn and returns the binlog position of the snapshot.
+func (s *Snapshot) Run(ctx context.Context, dsn string, fn func(row *SnapshotRow) error) (Position, error) {
+	conn := mysql.NewConnWrapper()
+	if err := conn.ConnectContext(ctx, dsn, s.TLSConfig); err != nil {
+		return Position{}, err
+	}
+	defer conn.Close()
+
+	pos, err := s.begin(conn)
+	if err != nil {
+		return Position{}, err
+	}
+	for _, name := range s.Tables {
+		parts := strings.SplitN(name, ".", 2)
+		if len(parts) != 2 {
+			return Position{}, fmt.Errorf("invalid table name %q, expect database.table", name)
+		}
+		if err = dumpTable(conn, parts[0], parts[1], fn); err != nil {
+			return Position{}, err
+		}
+	}
+	if _, err = conn.Exec("COMMIT", nil); err != nil {
+		return Position{}, err
+	}
+	return pos, nil
+}
+
+// begin starts the consistent snapshot and records the binlog position.
+func (s *Snapshot) begin(conn *mysql.ConnWrapper) (Position, error) {
+	queries := []string{"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ", "START TRANSACTION WITH CONSISTENT SNAPSHOT"}
+	if !s.SkipLock {
+		queries = append([]string{"FLUSH TABLES WITH READ LOCK"}, queries...)
+	}
+	for _, query := range queries {
+		if _, err := conn.Exec(query, nil); err != nil {
+			return Position{}, err
+		}
+	}
+
+	file, pos, gtidSet, err := conn.MasterStatus()
+	if err != nil {
+		return Position{}, err
+	}
+	if !s.SkipLock {
+		if _, err = conn.Exec("UNLOCK TABLES", nil); err != nil {
+			return Position{}, err
+		}
+	}
+	return Position{File: file, Pos: pos, GTIDSet: gtidSet.String()}, nil
+}
+
+func dumpTable(conn *mysql.ConnWrapper, database, table string, fn func(row *SnapshotRow) error) error {
+	rows, err := conn.Query("SELECT * FROM "+quoteIdentifier(database)+"."+quoteIdentifier(table), nil)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns := rows.Columns()
+	dest := make([]driver.Value, len(columns))
+	for {
+		if err = rows.Next(dest); err != nil {
+			break
+		}
+		row := &SnapshotRow{Database: database, Table: table, Columns: columns, Values: make([][]byte, len(dest))}
+		for i, v := range dest {
+			// the buffer is reused by the next row
+			if b, ok := v.([]byte); ok {
+				row.Values[i] = append([]byte{}, b...)
+			}
+		}
+		if err = fn(row); err != nil {
+			return err
+		}
+	}
+	if err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// StartWithSnapshot runs the snapshot and then starts dumping the binlog events from the position of it,
+// or by its GTID set with StartGTID if GTIDs are enabled on the master.
+func (s *Streamer) StartWithSnapshot(ctx context.Context, dsn string, serverID uint32, snapshot *Snapshot,
+	fn func(row *SnapshotRow) error) (*EventQueue, error) {
+	pos, err := snapshot.Run(ctx, dsn, fn)
+	if err != nil {
+		return nil, err
+	}
+	if pos.GTIDSet != "" {
+		gtidSet, err := mysql.ParseGTIDSet(pos.GTIDSet)
+		if err != nil {
+			return nil, err
+		}
+		return s.StartGTID(ctx, dsn, serverID, gtidSet)
+	}
+	return s.Start(ctx, dsn, serverID, pos.File, pos.Pos)
+}
@@ -0,0 +1,173 @@
+package binlog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LightKool/mysql-go"
+)
+
+// fakeResult is the result set answering the queries with a prefix.
+type fakeResult struct {
+	prefix  string
+	columns []string
+	rows    [][]interface{}
+}
+
+// serveFake serves the connections like a master, the queries with the prefixes of results are answered with
+// them and the other statements with OK packets. The queries and the other commands like "command 30" are sent
+// to the returned channel, the connection is closed after the dump command.
+func serveFake(t *testing.T, ctx context.Context, results ...fakeResult) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	commands := make(chan string, 64)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeConn(conn, results, commands)
+		}
+	}()
+	return ln.Addr().String(), commands
+}
+
+func serveFakeConn(conn net.Conn, results []fakeResult, commands chan<- string) {
+	sc := mysql.NewServerConn(conn)
+	defer sc.Close()
+	if err := sc.Handshake("5.7.30-log", 1, func(user string) (string, bool) { return "", true }); err != nil {
+		return
+	}
+	for {
+		command, data, err := sc.ReadCommand()
+		if err != nil {
+			return
+		}
+		switch command {
+		case mysql.ComQuery:
+			query := string(data)
+			commands <- query
+			err = writeFakeResult(sc, query, results)
+		case mysql.ComQuit:
+			return
+		case mysql.ComRegisterSlave:
+			commands <- fmt.Sprintf("command %d", command)
+			err = sc.WriteOK()
+		default:
+			commands <- fmt.Sprintf("command %d %x", command, data)
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func writeFakeResult(sc *mysql.ServerConn, query string, results []fakeResult) error {
+	for _, r := range results {
+		if strings.HasPrefix(query, r.prefix) {
+			return sc.WriteResultSet(r.columns, r.rows)
+		}
+	}
+	return sc.WriteOK()
+}
+
+func TestSnapshot(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	addr, commands := serveFake(t, ctx,
+		fakeResult{"SHOW MASTER STATUS", []string{"File", "Position", "Executed_Gtid_Set"},
+			[][]interface{}{{"mysql-bin.000003", "1234", ""}}},
+		fakeResult{"SELECT * FROM", []string{"id", "name"}, [][]interface{}{{"1", "a"}, {"2", nil}}})
+
+	var rows []string
+	snapshot := &Snapshot{Tables: []string{"db.t"}}
+	pos, err := snapshot.Run(ctx, "root@tcp("+addr+")/?maxAllowedPacket=4194304", func(row *SnapshotRow) error {
+		rows = append(rows, fmt.Sprintf("%s.%s %q", row.Database, row.Table, row.Values))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.File != "mysql-bin.000003" || pos.Pos != 1234 || pos.GTIDSet != "" {
+		t.Errorf("unexpected position %+v", pos)
+	}
+	if expected := []string{`db.t ["1" "a"]`, `db.t ["2" ""]`}; !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected rows %v, got %v", expected, rows)
+	}
+
+	// the tables are locked only until the position is recorded in the snapshot
+	expected := []string{
+		"FLUSH TABLES WITH READ LOCK",
+		"SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ",
+		"START TRANSACTION WITH CONSISTENT SNAPSHOT",
+		"SHOW MASTER STATUS",
+		"UNLOCK TABLES",
+		"SELECT * FROM `db`.`t`",
+		"COMMIT",
+	}
+	for _, query := range expected {
+		if got := <-commands; got != query {
+			t.Fatalf("expected %q, got %q", query, got)
+		}
+	}
+}
+
+func TestStartWithSnapshotGTID(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	const gtidSet = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-23"
+	addr, commands := serveFake(t, ctx,
+		fakeResult{"SHOW MASTER STATUS", []string{"File", "Position", "Executed_Gtid_Set"},
+			[][]interface{}{{"mysql-bin.000003", "1234", gtidSet}}},
+		fakeResult{"SELECT @@global.binlog_checksum", []string{"@@global.binlog_checksum"}, [][]interface{}{{"NONE"}}},
+		fakeResult{"SELECT @@GLOBAL.gtid_executed", []string{"@@GLOBAL.gtid_executed", "@@GLOBAL.gtid_purged"},
+			[][]interface{}{{gtidSet, ""}}})
+
+	s := &Streamer{}
+	q, err := s.StartWithSnapshot(ctx, "root@tcp("+addr+")/?maxAllowedPacket=4194304", 100, &Snapshot{},
+		func(row *SnapshotRow) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close(false)
+
+	// the binlog is dumped by the GTID set of the snapshot
+	for {
+		var command string
+		select {
+		case command = <-commands:
+		case <-ctx.Done():
+			t.Fatal("expected the dump command")
+		}
+		if !strings.HasPrefix(command, "command ") || command == fmt.Sprintf("command %d", mysql.ComRegisterSlave) {
+			continue
+		}
+		var typ byte
+		var data []byte
+		if _, err = fmt.Sscanf(command, "command %d %x", &typ, &data); err != nil {
+			t.Fatal(err)
+		}
+		if typ != mysql.ComBinlogDumpGTID {
+			t.Fatalf("expected COM_BINLOG_DUMP_GTID, got %s", command)
+		}
+		// the flags, server id, empty file name and position precede the GTID data
+		set, err := mysql.DecodeGTIDSet(data[2+4+4+8+4:])
+		if err != nil || set.String() != gtidSet {
+			t.Errorf("expected the GTID set %s, got %v, %v", gtidSet, set, err)
+		}
+		return
+	}
+}
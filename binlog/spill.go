@@ -0,0 +1,250 @@
+package binlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/LightKool/mysql-go"
+)
+
+const defaultSpillSegmentSize = 64 << 20
+
+var errSpillClosed = errors.New("spill closed")
+
+// spill passes the packets read from the master by a pump goroutine to Streamer.run, so that reading from
+// the master doesn't stall when the consumer is slow. The packets are kept in memory up to memSize, then
+// spilled to the segment files in dir until the consumer catches up, the segments are removed once read.
+// Reading from the master blocks when the unread segments reach maxSize bytes or the oldest one is older
+// than maxAge.
+type spill struct {
+	dir         string
+	memSize     int
+	segmentSize int64
+	maxSize     int64
+	maxAge      time.Duration
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	mem  [][]byte
+	// segments are the segment files from the one being read, the last one is being written
+	segments []*segment
+	r        *bufio.Reader
+	rf       *os.File
+	// unread and size are the number and the bytes of the packets in the segments not read yet
+	unread int
+	size   int64
+	seq    int
+	// err stops the pump and is popped after the packets
+	err    error
+	closed bool
+	done   chan struct{}
+}
+
+type segment struct {
+	path    string
+	f       *os.File
+	size    int64
+	count   int
+	read    int
+	created time.Time
+}
+
+// newSpill creates a directory in dir to hold the segment files.
+func newSpill(dir string, memSize int, segmentSize, maxSize int64, maxAge time.Duration) (*spill, error) {
+	dir, err := ioutil.TempDir(dir, "spill")
+	if err != nil {
+		return nil, err
+	}
+	if memSize <= 0 {
+		memSize = defaultEventQueueSize
+	}
+	if segmentSize <= 0 {
+		segmentSize = defaultSpillSegmentSize
+	}
+	s := &spill{dir: dir, memSize: memSize, segmentSize: segmentSize, maxSize: maxSize, maxAge: maxAge}
+	s.cond = sync.NewCond(&s.mu)
+	return s, nil
+}
+
+// start starts the pump reading from conn, the previous one must have exited with an error popped.
+func (s *spill) start(conn *mysql.ConnWrapper) {
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.done = done
+	s.mu.Unlock()
+	go func() {
+		defer close(done)
+		for {
+			packet, err := conn.ReadPacket()
+			if err == nil {
+				err = s.push(packet)
+			}
+			if err != nil {
+				s.fail(err)
+				return
+			}
+		}
+	}()
+}
+
+func (s *spill) push(packet []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for !s.closed && s.full() {
+		s.cond.Wait()
+	}
+	if s.closed {
+		return errSpillClosed
+	}
+	if s.unread == 0 && len(s.mem) < s.memSize {
+		s.mem = append(s.mem, packet)
+		s.cond.Broadcast()
+		return nil
+	}
+	if err := s.write(packet); err != nil {
+		return fmt.Errorf("spill packet: %v", err)
+	}
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *spill) full() bool {
+	if s.unread == 0 {
+		return false
+	}
+	return s.maxSize > 0 && s.size >= s.maxSize || s.maxAge > 0 && time.Since(s.segments[0].created) >= s.maxAge
+}
+
+func (s *spill) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.cond.Broadcast()
+}
+
+// write appends the packet to the last segment, which is rolled when it reaches segmentSize.
+func (s *spill) write(packet []byte) error {
+	var seg *segment
+	if n := len(s.segments); n > 0 && s.segments[n-1].f != nil && s.segments[n-1].size < s.segmentSize {
+		seg = s.segments[n-1]
+	} else {
+		if n > 0 && s.segments[n-1].f != nil {
+			s.segments[n-1].f.Close()
+			s.segments[n-1].f = nil
+		}
+		s.seq++
+		path := filepath.Join(s.dir, fmt.Sprintf("%016d.spill", s.seq))
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		seg = &segment{path: path, f: f, created: time.Now()}
+		s.segments = append(s.segments, seg)
+	}
+
+	var header [4]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(len(packet)))
+	if _, err := seg.f.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := seg.f.Write(packet); err != nil {
+		return err
+	}
+	n := int64(len(header) + len(packet))
+	seg.size += n
+	seg.count++
+	s.size += n
+	s.unread++
+	return nil
+}
+
+// pop returns the next packet, or the error of the pump after all the packets.
+func (s *spill) pop() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.mem) == 0 && s.unread == 0 && s.err == nil {
+		s.cond.Wait()
+	}
+	if len(s.mem) > 0 {
+		packet := s.mem[0]
+		s.mem[0] = nil
+		s.mem = s.mem[1:]
+		return packet, nil
+	}
+	if s.unread > 0 {
+		packet, err := s.read()
+		s.cond.Broadcast()
+		return packet, err
+	}
+	err := s.err
+	s.err = nil
+	return nil, err
+}
+
+// read reads the next packet from the first segment, which is removed once all its packets are read.
+func (s *spill) read() ([]byte, error) {
+	seg := s.segments[0]
+	if s.r == nil {
+		f, err := os.Open(seg.path)
+		if err != nil {
+			return nil, fmt.Errorf("read spilled packet: %v", err)
+		}
+		s.rf, s.r = f, bufio.NewReader(f)
+	}
+	var header [4]byte
+	if _, err := io.ReadFull(s.r, header[:]); err != nil {
+		return nil, fmt.Errorf("read spilled packet: %v", err)
+	}
+	packet := make([]byte, binary.LittleEndian.Uint32(header[:]))
+	if _, err := io.ReadFull(s.r, packet); err != nil {
+		return nil, fmt.Errorf("read spilled packet: %v", err)
+	}
+	s.unread--
+	s.size -= int64(len(header) + len(packet))
+	if seg.read++; seg.read == seg.count {
+		// the segment being written is removed as well, the next packet to spill creates a new one
+		s.rf.Close()
+		s.rf, s.r = nil, nil
+		if seg.f != nil {
+			seg.f.Close()
+		}
+		os.Remove(seg.path)
+		s.segments = s.segments[1:]
+	}
+	return packet, nil
+}
+
+// close stops the pump and removes the segment files, the connection of the pump must be closed before.
+func (s *spill) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	done := s.done
+	s.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rf != nil {
+		s.rf.Close()
+	}
+	for _, seg := range s.segments {
+		if seg.f != nil {
+			seg.f.Close()
+		}
+	}
+	s.mem, s.segments, s.r, s.rf = nil, nil, nil, nil
+	os.RemoveAll(s.dir)
+}
@@ -0,0 +1,108 @@
+package binlog
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 2 packets in memory and 3 packets of 5 bytes with the headers per segment
+	s, err := newSpill(dir, 2, 15, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := func() int {
+		names, _ := ioutil.ReadDir(s.dir)
+		return len(names)
+	}
+	for i := 0; i < 10; i++ {
+		if err = s.push([]byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.fail(errors.New("pump stopped"))
+	if n := files(); n != 3 {
+		t.Errorf("expected 3 segment files, got %d", n)
+	}
+
+	for i := 0; i < 10; i++ {
+		packet, err := s.pop()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(packet) != 1 || packet[0] != byte(i) {
+			t.Fatalf("expected packet %d, got %v", i, packet)
+		}
+	}
+	if _, err = s.pop(); err == nil || err.Error() != "pump stopped" {
+		t.Errorf("expected the error after the packets, got %v", err)
+	}
+	if n := files(); n != 0 {
+		t.Errorf("expected the segment files removed once read, got %d", n)
+	}
+
+	// the packets are kept in memory again after the segments are read
+	if err = s.push([]byte{10}); err != nil {
+		t.Fatal(err)
+	}
+	if n := files(); n != 0 || len(s.mem) != 1 {
+		t.Errorf("expected the packet in memory, got %d segment files", n)
+	}
+	s.close()
+	if _, err = os.Stat(s.dir); !os.IsNotExist(err) {
+		t.Errorf("expected the directory removed, got %v", err)
+	}
+	if err = s.push([]byte{11}); err != errSpillClosed {
+		t.Errorf("expected errSpillClosed, got %v", err)
+	}
+}
+
+func TestSpillMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newSpill(dir, 1, 0, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	pushed := make(chan int, 4)
+	go func() {
+		for i := 0; i < 4; i++ {
+			if s.push([]byte{byte(i)}) != nil {
+				return
+			}
+			pushed <- i
+		}
+	}()
+	// one packet in memory and 2 packets of 5 bytes spilled
+	for i := 0; i < 3; i++ {
+		<-pushed
+	}
+	select {
+	case i := <-pushed:
+		t.Fatalf("expected the push blocked by the size, got packet %d pushed", i)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	for i := 0; i < 4; i++ {
+		if packet, err := s.pop(); err != nil || packet[0] != byte(i) {
+			t.Fatalf("expected packet %d, got %v, %v", i, packet, err)
+		}
+	}
+	if i := <-pushed; i != 3 {
+		t.Errorf("expected the last packet pushed, got %d", i)
+	}
+}
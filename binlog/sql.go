@@ -0,0 +1,258 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SQL reconstructs the executable statements of the rows: INSERT for WriteRowsEvent, UPDATE for UpdateRowsEvent
+// and DELETE for DeleteRowsEvent. The rows are matched by the primary key, or by all the columns of the before
+// image if the primary key is unknown. The column names must be known from the optional metadata or the DB.
+func (e *RowsEvent) SQL() ([]string, error) {
+	return e.statements(false)
+}
+
+// FlashbackSQL reconstructs the statements which undo the rows: DELETE for WriteRowsEvent, UPDATE back to
+// the before image for UpdateRowsEvent and INSERT for DeleteRowsEvent.
+func (e *RowsEvent) FlashbackSQL() ([]string, error) {
+	return e.statements(true)
+}
+
+func (e *RowsEvent) statements(flashback bool) ([]string, error) {
+	if e.Table.columns == nil {
+		return nil, fmt.Errorf("column names of %s.%s are unknown", e.Table.Database, e.Table.TableName)
+	}
+	table := quoteIdentifier(string(e.Table.Database)) + "." + quoteIdentifier(string(e.Table.TableName))
+
+	rows, err := e.allRows()
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []string
+	for i := 0; i < len(rows); i++ {
+		var before, after []ColumnValue
+		switch e.Op() {
+		case RowOpUpdate:
+			before, after = e.sqlValues(e.Columns, rows[i]), e.sqlValues(e.UpdatedColumns, rows[i+1])
+			i++
+		case RowOpDelete:
+			before = e.sqlValues(e.Columns, rows[i])
+		default:
+			after = e.sqlValues(e.Columns, rows[i])
+		}
+		if flashback {
+			before, after = after, before
+		}
+
+		var buf bytes.Buffer
+		switch {
+		case before == nil:
+			err = writeInsert(&buf, table, after)
+		case after == nil:
+			buf.WriteString("DELETE FROM " + table)
+			err = e.writeWhere(&buf, before)
+		default:
+			buf.WriteString("UPDATE " + table + " SET ")
+			if err = writeSetClause(&buf, after); err == nil {
+				err = e.writeWhere(&buf, before)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, buf.String())
+	}
+	return statements, nil
+}
+
+// sqlValues returns the column values of the row, the strings of the columns which are known to be
+// in non UTF-8 charsets are converted to []byte to be written as hex literals, and the TIMESTAMP values
+// decoded to UnixNano to sqlTimestamp.
+func (e *RowsEvent) sqlValues(includedColumns []byte, row []interface{}) []ColumnValue {
+	values := e.columnValues(includedColumns, row)
+	n := 0
+	for i := 0; i < int(e.ColumnCount); i++ {
+		if !isBitSet(includedColumns, i) {
+			continue
+		}
+		switch v := values[n].Value.(type) {
+		case string:
+			if e.Table.ColumnCharsets != nil && !isUTF8Collation(e.Table.ColumnCharsets[i]) {
+				values[n].Value = []byte(v)
+			}
+		case int64:
+			if types := e.Table.ColumnTypes; i < len(types) && (types[i] == fieldTypeTimestamp || types[i] == fieldTypeTimestampV2) {
+				values[n].Value = sqlTimestamp(v)
+			}
+		}
+		n++
+	}
+	return values
+}
+
+// sqlTimestamp is a TIMESTAMP value decoded to UnixNano. It's written as FROM_UNIXTIME, which converts it to
+// the session time zone of the target like the TIMESTAMP values are converted when they're read.
+type sqlTimestamp int64
+
+// isUTF8Collation reports whether the collation id is of utf8 or utf8mb4.
+func isUTF8Collation(id uint64) bool {
+	switch {
+	case id == 33, id == 45, id == 46, id == 76, id == 83:
+		return true
+	case id >= 192 && id <= 247, id >= 255 && id <= 323:
+		return true
+	}
+	return false
+}
+
+func writeInsert(buf *bytes.Buffer, table string, values []ColumnValue) error {
+	buf.WriteString("INSERT INTO " + table + " (")
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(quoteIdentifier(v.Name))
+	}
+	buf.WriteString(") VALUES (")
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if err := writeSQLValue(buf, v); err != nil {
+			return err
+		}
+	}
+	buf.WriteString(")")
+	return nil
+}
+
+// writeWhere writes the WHERE clause matching the primary key of the row, or all the columns if it's unknown.
+func (e *RowsEvent) writeWhere(buf *bytes.Buffer, row []ColumnValue) error {
+	match := row
+	if pk := e.Table.PrimaryKeyColumns(); len(pk) > 0 {
+		match = nil
+		for _, v := range row {
+			for _, name := range pk {
+				if v.Name == name {
+					match = append(match, v)
+				}
+			}
+		}
+		if len(match) != len(pk) {
+			// the primary key is not fully logged with binlog_row_image=NOBLOB or MINIMAL
+			match = row
+		}
+	}
+	buf.WriteString(" WHERE ")
+	for i, v := range match {
+		if i > 0 {
+			buf.WriteString(" AND ")
+		}
+		if v.Value == nil {
+			buf.WriteString(quoteIdentifier(v.Name) + " IS NULL")
+			continue
+		}
+		buf.WriteString(quoteIdentifier(v.Name) + " = ")
+		if err := writeSQLValue(buf, v); err != nil {
+			return err
+		}
+	}
+	buf.WriteString(" LIMIT 1")
+	return nil
+}
+
+func writeSetClause(buf *bytes.Buffer, values []ColumnValue) error {
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(quoteIdentifier(v.Name) + " = ")
+		if err := writeSQLValue(buf, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// writeSQLValue writes the value as a SQL literal. The []byte values and the strings which are not valid UTF-8
+// are written as hex literals, so that the bytes are kept as they are regardless of the connection charset.
+func writeSQLValue(buf *bytes.Buffer, v ColumnValue) error {
+	switch value := v.Value.(type) {
+	case nil:
+		buf.WriteString("NULL")
+	case string:
+		writeSQLString(buf, value)
+	case []byte:
+		fmt.Fprintf(buf, "X'%x'", value)
+	case time.Time:
+		writeSQLString(buf, value.Format("2006-01-02 15:04:05.999999"))
+	case time.Duration:
+		writeSQLString(buf, formatTimeDuration(value))
+	case sqlTimestamp:
+		if value == 0 {
+			writeSQLString(buf, zeroDateTime)
+			break
+		}
+		t := time.Unix(0, int64(value))
+		fmt.Fprintf(buf, "FROM_UNIXTIME(%d", t.Unix())
+		if usec := t.Nanosecond() / 1000; usec > 0 {
+			fmt.Fprintf(buf, ".%06d", usec)
+		}
+		buf.WriteString(")")
+	case *big.Rat:
+		buf.WriteString(value.FloatString(ratScale(value)))
+	case float32:
+		buf.WriteString(strconv.FormatFloat(float64(value), 'g', -1, 32))
+	case float64:
+		buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	case int, int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		fmt.Fprint(buf, value)
+	case fmt.Stringer:
+		writeSQLString(buf, value.String())
+	default:
+		// the parsed JSON values
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("column %s: %v", v.Name, err)
+		}
+		writeSQLString(buf, string(data))
+	}
+	return nil
+}
+
+func writeSQLString(buf *bytes.Buffer, s string) {
+	if !utf8.ValidString(s) {
+		fmt.Fprintf(buf, "X'%x'", s)
+		return
+	}
+	buf.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case 0:
+			buf.WriteString(`\0`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\x1a':
+			buf.WriteString(`\Z`)
+		case '\'', '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('\'')
+}
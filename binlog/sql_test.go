@@ -0,0 +1,87 @@
+package binlog
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRowsEventSQL(t *testing.T) {
+	table := &TableMapEvent{
+		Database:       []byte("db"),
+		TableName:      []byte("t`1"),
+		ColumnCount:    4,
+		ColumnTypes:    []byte{fieldTypeLong, fieldTypeVarChar, fieldTypeVarChar, fieldTypeTimestampV2},
+		ColumnCharsets: []uint64{0, 45, 8, 0},
+		columns:        []*Column{{Name: "id", IsPrimary: true}, {Name: "name"}, {Name: "latin"}, {Name: "ts"}},
+	}
+	rows := func(typ EventType, values ...[]interface{}) *RowsEvent {
+		return &RowsEvent{
+			baseEvent:      &baseEvent{header: &EventHeader{Type: typ}},
+			Table:          table,
+			ColumnCount:    4,
+			Columns:        []byte{0x0f},
+			UpdatedColumns: []byte{0x0f},
+			Rows:           values,
+		}
+	}
+
+	tests := []struct {
+		event     *RowsEvent
+		sql       []string
+		flashback []string
+	}{
+		{
+			rows(WriteRowsEventType, []interface{}{int64(1), "it's", "\xe9", int64(1500000000123456000)}),
+			[]string{"INSERT INTO `db`.`t``1` (`id`, `name`, `latin`, `ts`) VALUES (1, 'it\\'s', X'e9', FROM_UNIXTIME(1500000000.123456))"},
+			[]string{"DELETE FROM `db`.`t``1` WHERE `id` = 1 LIMIT 1"},
+		},
+		{
+			rows(UpdateRowsEventType, []interface{}{int64(1), "a", nil, int64(0)}, []interface{}{int64(1), "b", nil, int64(1500000000000000000)}),
+			[]string{"UPDATE `db`.`t``1` SET `id` = 1, `name` = 'b', `latin` = NULL, `ts` = FROM_UNIXTIME(1500000000) WHERE `id` = 1 LIMIT 1"},
+			[]string{"UPDATE `db`.`t``1` SET `id` = 1, `name` = 'a', `latin` = NULL, `ts` = '0000-00-00 00:00:00' WHERE `id` = 1 LIMIT 1"},
+		},
+		{
+			rows(DeleteRowsEventType, []interface{}{int64(2), "x\n", nil, nil}),
+			[]string{"DELETE FROM `db`.`t``1` WHERE `id` = 2 LIMIT 1"},
+			[]string{"INSERT INTO `db`.`t``1` (`id`, `name`, `latin`, `ts`) VALUES (2, 'x\\n', NULL, NULL)"},
+		},
+	}
+	for _, test := range tests {
+		sql, err := test.event.SQL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sql, test.sql) {
+			t.Errorf("expected %q, got %q", test.sql, sql)
+		}
+		flashback, err := test.event.FlashbackSQL()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(flashback, test.flashback) {
+			t.Errorf("expected flashback %q, got %q", test.flashback, flashback)
+		}
+	}
+}
+
+func TestRowsEventSQLWithoutPrimaryKey(t *testing.T) {
+	e := &RowsEvent{
+		baseEvent:   &baseEvent{header: &EventHeader{Type: DeleteRowsEventType}},
+		Table:       &TableMapEvent{Database: []byte("db"), TableName: []byte("t"), ColumnCount: 2, columns: []*Column{{Name: "a"}, {Name: "b"}}},
+		ColumnCount: 2,
+		Columns:     []byte{0x03},
+		Rows:        [][]interface{}{{int64(1), nil}},
+	}
+	sql, err := e.SQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "DELETE FROM `db`.`t` WHERE `a` = 1 AND `b` IS NULL LIMIT 1"; sql[0] != expected {
+		t.Errorf("expected %s, got %s", expected, sql[0])
+	}
+
+	e.Table.columns = nil
+	if _, err = e.SQL(); err == nil {
+		t.Error("expected error for unknown column names")
+	}
+}
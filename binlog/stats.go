@@ -0,0 +1,205 @@
+package binlog
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TableStats are the rows changed in a table.
+type TableStats struct {
+	Database string
+	Table    string
+	// Inserts, Updates and Deletes are the numbers of the rows.
+	Inserts uint64
+	Updates uint64
+	Deletes uint64
+	// Events and Bytes are the number and total size of the rows events.
+	Events uint64
+	Bytes  uint64
+}
+
+// Rows returns the number of the changed rows.
+func (t *TableStats) Rows() uint64 {
+	return t.Inserts + t.Updates + t.Deletes
+}
+
+// StatsSnapshot is a copy of the statistics collected by Stats.
+type StatsSnapshot struct {
+	// Since is when the collection started or was reset, Time is when the snapshot was taken.
+	Since time.Time
+	Time  time.Time
+	// Events and Bytes are the number and total size of the events added.
+	Events uint64
+	Bytes  uint64
+	// Transactions is the number of the transactions including the standalone statements like DDL,
+	// TransactionBytes and TransactionRows are their total sizes and changed rows.
+	Transactions        uint64
+	TransactionBytes    uint64
+	TransactionRows     uint64
+	MaxTransactionBytes uint64
+	MaxTransactionRows  uint64
+	// Tables are sorted by the changed rows in descending order, the hot tables come first.
+	Tables []TableStats
+}
+
+// Stats aggregates the per table row changes, bytes and transaction sizes of a stream for the workload analysis.
+// The zero value is ready to use and it's safe for concurrent use.
+//
+//	stats := new(binlog.Stats)
+//	go stats.Report(ctx, time.Minute, true, func(s *binlog.StatsSnapshot) { log.Print(s.Tables) })
+//	for {
+//	    ev, err := q.Pop(ctx)
+//	    ...
+//	    stats.Add(ev)
+//	}
+type Stats struct {
+	mu     sync.Mutex
+	txr    TransactionReader
+	since  time.Time
+	events uint64
+	bytes  uint64
+	tables map[string]*TableStats
+
+	transactions        uint64
+	transactionBytes    uint64
+	transactionRows     uint64
+	maxTransactionBytes uint64
+	maxTransactionRows  uint64
+}
+
+// Add accounts an event popped from the EventQueue or read by FileReader, the events embedded in
+// TransactionPayloadEvent are accounted with it and mustn't be added again. The rows are accounted
+// when their transactions are complete.
+func (s *Stats) Add(ev Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.since.IsZero() {
+		s.since = time.Now()
+	}
+	s.events++
+	s.bytes += uint64(ev.Header().EventSize)
+
+	tx := s.txr.add(ev)
+	if tx == nil {
+		return
+	}
+	var size, rows uint64
+	if tx.Begin != nil {
+		size += uint64(tx.Begin.header.EventSize)
+	}
+	for _, e := range tx.Events {
+		size += uint64(e.Header().EventSize)
+	}
+	size += uint64(tx.End.Header().EventSize)
+	for _, e := range tx.RowsEvents() {
+		rows += s.addRows(e)
+	}
+	s.transactions++
+	s.transactionBytes += size
+	s.transactionRows += rows
+	if size > s.maxTransactionBytes {
+		s.maxTransactionBytes = size
+	}
+	if rows > s.maxTransactionRows {
+		s.maxTransactionRows = rows
+	}
+}
+
+// addRows accounts the rows event to its table and returns the number of the rows.
+func (s *Stats) addRows(e *RowsEvent) uint64 {
+	database, table := string(e.Table.Database), string(e.Table.TableName)
+	key := schemaKey(database, table)
+	t := s.tables[key]
+	if t == nil {
+		if s.tables == nil {
+			s.tables = make(map[string]*TableStats)
+		}
+		t = &TableStats{Database: database, Table: table}
+		s.tables[key] = t
+	}
+	t.Events++
+	t.Bytes += uint64(e.header.EventSize)
+
+	n, _ := e.rowCount()
+	rows := uint64(n)
+	switch {
+	case e.isUpdate():
+		rows /= 2
+		t.Updates += rows
+	case e.isDelete():
+		t.Deletes += rows
+	default:
+		t.Inserts += rows
+	}
+	return rows
+}
+
+// Snapshot returns the statistics collected so far.
+func (s *Stats) Snapshot() *StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot()
+}
+
+func (s *Stats) snapshot() *StatsSnapshot {
+	snapshot := &StatsSnapshot{
+		Since:               s.since,
+		Time:                time.Now(),
+		Events:              s.events,
+		Bytes:               s.bytes,
+		Transactions:        s.transactions,
+		TransactionBytes:    s.transactionBytes,
+		TransactionRows:     s.transactionRows,
+		MaxTransactionBytes: s.maxTransactionBytes,
+		MaxTransactionRows:  s.maxTransactionRows,
+		Tables:              make([]TableStats, 0, len(s.tables)),
+	}
+	for _, t := range s.tables {
+		snapshot.Tables = append(snapshot.Tables, *t)
+	}
+	sort.Slice(snapshot.Tables, func(i, j int) bool {
+		a, b := &snapshot.Tables[i], &snapshot.Tables[j]
+		if a.Rows() != b.Rows() {
+			return a.Rows() > b.Rows()
+		}
+		return schemaKey(a.Database, a.Table) < schemaKey(b.Database, b.Table)
+	})
+	return snapshot
+}
+
+// Reset clears the statistics, the transaction in progress is still accounted when it's complete.
+func (s *Stats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+}
+
+func (s *Stats) reset() {
+	s.since = time.Now()
+	s.events, s.bytes, s.tables = 0, 0, nil
+	s.transactions, s.transactionBytes, s.transactionRows = 0, 0, 0
+	s.maxTransactionBytes, s.maxTransactionRows = 0, 0
+}
+
+// Report calls report with the snapshot every interval until ctx is done, the statistics are reset after
+// each report if reset is true so that the snapshots are of the intervals.
+func (s *Stats) Report(ctx context.Context, interval time.Duration, reset bool, report func(*StatsSnapshot)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			snapshot := s.snapshot()
+			if reset {
+				s.reset()
+			}
+			s.mu.Unlock()
+			report(snapshot)
+		}
+	}
+}
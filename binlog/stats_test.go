@@ -0,0 +1,67 @@
+package binlog
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	header := func(typ EventType, size uint32) *baseEvent {
+		return &baseEvent{header: &EventHeader{Type: typ, EventSize: size}}
+	}
+	table := func(name string) *TableMapEvent {
+		return &TableMapEvent{baseEvent: header(TableMapEventType, 10), Database: []byte("db"), TableName: []byte(name)}
+	}
+	t1, t2 := table("t1"), table("t2")
+	rows := func(typ EventType, table *TableMapEvent, n int) *RowsEvent {
+		return &RowsEvent{baseEvent: header(typ, 100), Table: table, Rows: make([][]interface{}, n)}
+	}
+
+	stats := new(Stats)
+	for _, ev := range []Event{
+		&QueryEvent{baseEvent: header(QueryEventType, 20), Query: []byte("BEGIN")},
+		t1, rows(WriteRowsEventType, t1, 3),
+		t2, rows(UpdateRowsEventType, t2, 4), rows(DeleteRowsEventType, t2, 1),
+		&XIDEvent{baseEvent: header(XidEventType, 30)},
+		&QueryEvent{baseEvent: header(QueryEventType, 50), Query: []byte("CREATE TABLE t3 (id INT)")},
+		// an incomplete transaction
+		&QueryEvent{baseEvent: header(QueryEventType, 20), Query: []byte("BEGIN")},
+		t1, rows(WriteRowsEventType, t1, 1),
+	} {
+		stats.Add(ev)
+	}
+
+	s := stats.Snapshot()
+	if s.Events != 11 || s.Bytes != 550 || s.Transactions != 2 || s.TransactionBytes != 420 ||
+		s.TransactionRows != 6 || s.MaxTransactionBytes != 370 || s.MaxTransactionRows != 6 {
+		t.Errorf("unexpected snapshot %+v", s)
+	}
+	expected := []TableStats{
+		{Database: "db", Table: "t1", Inserts: 3, Events: 1, Bytes: 100},
+		{Database: "db", Table: "t2", Updates: 2, Deletes: 1, Events: 2, Bytes: 200},
+	}
+	if len(s.Tables) != 2 || s.Tables[0] != expected[0] || s.Tables[1] != expected[1] {
+		t.Errorf("expected tables %+v, got %+v", expected, s.Tables)
+	}
+
+	stats.Reset()
+	stats.Add(&XIDEvent{baseEvent: header(XidEventType, 30)})
+	s = stats.Snapshot()
+	if s.Transactions != 1 || s.TransactionRows != 1 || len(s.Tables) != 1 || s.Tables[0].Inserts != 1 {
+		t.Errorf("unexpected snapshot after reset %+v", s)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reported := make(chan *StatsSnapshot)
+	go stats.Report(ctx, time.Millisecond, true, func(s *StatsSnapshot) {
+		cancel()
+		reported <- s
+	})
+	if s := <-reported; s.Transactions != 1 {
+		t.Errorf("unexpected report %+v", s)
+	}
+	if s := stats.Snapshot(); s.Transactions != 0 {
+		t.Errorf("expected the stats reset after the report, got %+v", s)
+	}
+}
@@ -0,0 +1,658 @@
+package binlog
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/LightKool/mysql-go"
+)
+
+const defaultBackoff = time.Second
+
+// Streamer dumps the binlog events from the MySQL server continuously into an EventQueue,
+// it reconnects automatically when the connection is dropped.
+type Streamer struct {
+	// Backoff is the interval to wait before reconnecting, default is 1 second.
+	Backoff time.Duration
+	// DB is used to retrieve the column metadata of tables, optional.
+	DB *sql.DB
+	// ColumnCacheTTL is how long the column metadata retrieved from DB is cached, see EventDecoder.ColumnCacheTTL.
+	ColumnCacheTTL time.Duration
+	// TableMapCacheSize is the maximum number of the table maps kept, see EventDecoder.TableMapCacheSize.
+	TableMapCacheSize int
+	// MaxRowsEventSize and MaxRows defer the rows of the giant rows events, see EventDecoder.MaxRowsEventSize.
+	MaxRowsEventSize int
+	MaxRows          int
+	// ChecksumPolicy controls the verification of event checksums, default is ChecksumSkip.
+	ChecksumPolicy ChecksumPolicy
+	// TLSConfig overrides the `tls` parameter of the DSN if not nil.
+	TLSConfig *tls.Config
+	// SemiSync enables the semi-synchronous replication, ACKs are sent to the master when requested.
+	// The events are dumped without it if the semi-sync plugin is not loaded or enabled on the master.
+	SemiSync bool
+	// QueueSize is the buffer size of the EventQueue, default is 128.
+	QueueSize int
+	// OverflowPolicy controls what to do when the EventQueue is full, default is OverflowBlock.
+	OverflowPolicy OverflowPolicy
+	// Filter skips the events of the unwanted types and tables if not nil.
+	Filter *EventFilter
+	// Flavor selects the binlog protocol of MySQL or MariaDB, default is MySQLFlavor.
+	Flavor Flavor
+	// HeartbeatPeriod makes the master send a HeartbeatEvent when there are no events for the period,
+	// so that an idle master can be told from a stalled one. The master's default is used if it's 0.
+	HeartbeatPeriod time.Duration
+	// ReadTimeout is the maximum time without receiving any packet from the master, the connection is considered
+	// dead and reconnected after it. Default is 3 times HeartbeatPeriod if it's set, otherwise the reads never time
+	// out unless the `readTimeout` parameter of the DSN is set. It should be longer than HeartbeatPeriod, since
+	// an idle master sends nothing but the heartbeats.
+	ReadTimeout time.Duration
+	// KeepAlivePeriod is the period of the TCP keepalive probes of the connection, the system default is used if 0.
+	KeepAlivePeriod time.Duration
+	// OnHeartbeat is called for every HeartbeatEvent if not nil, the heartbeats are not pushed into the EventQueue.
+	OnHeartbeat func(ev *HeartbeatEvent)
+	// Hosts are the addresses of the candidate masters like "host:3306" to fail over to when the master of
+	// the DSN is unreachable. They are used only by StartGTID, since the binlog positions differ between
+	// the servers while the GTIDs don't.
+	Hosts []string
+	// Observer receives the measurements of the dump if not nil, see package metrics.
+	Observer Observer
+	// DecodeWorkers decodes the rows of a RowsEvent in parallel if it's more than 1, see EventDecoder.DecodeWorkers.
+	DecodeWorkers int
+	// Decompress decompresses the payload of TransactionPayloadEvent, see EventDecoder.Decompress.
+	Decompress Decompressor
+	// Schema tracks the table definitions by the DDL in the binlog if not nil, see EventDecoder.Schema.
+	Schema *SchemaTracker
+	// DecodeErrorPolicy controls what to do with the events which can't be decoded, default is DecodeErrorFail.
+	// The position of the event is the one after the last event of Position. See EventDecoder.DecodeErrorPolicy.
+	DecodeErrorPolicy DecodeErrorPolicy
+	// OnDecodeError is called for the events which can't be decoded, see EventDecoder.OnDecodeError.
+	OnDecodeError func(err *DecodeError, data []byte)
+	// PoolBuffers reads the events into the buffers from a pool to reduce the allocations, the events should
+	// be passed to Release after use so that the buffers are reused. The events which are not released are
+	// collected by GC as usual.
+	PoolBuffers bool
+	// Log receives the milestones of the replication like connecting, rotating and reconnecting if not nil,
+	// it's passed to the connections and the EventDecoder as well.
+	Log mysql.LeveledLogger
+	// Drain keeps the queued events to be popped before ErrQueueClosed after Close or Shutdown,
+	// otherwise they are discarded.
+	Drain bool
+	// OnDelay is called with the replication delay of every event if not nil, see Delay.
+	OnDelay func(delay time.Duration)
+	// AutoServerID generates a random server ID in the upper half of the range if the given one is 0, and a new one
+	// to reconnect with when another replica connects with the same server ID. Otherwise the dump fails with
+	// *ServerIDConflictError on the collision.
+	AutoServerID bool
+	// Bounds limits the events dumped if not nil, the dump is stopped and the EventQueue fails with ErrStopReached
+	// once a stop bound is reached. It's checked from the position where the dump starts.
+	Bounds *Bounds
+	// DetectGaps checks that every event starts where the last one ends and the GTIDs of every source server
+	// are consecutive, which is broken when the events are missing, e.g. purged silently by the master.
+	// The positions are not checked by StartGTID, whose dump skips the transactions consumed already.
+	DetectGaps bool
+	// OnGap is called for every gap detected if not nil, otherwise the EventQueue fails with *GapError.
+	OnGap func(err *GapError)
+	// SpillDir makes the packets read from the master pass through a buffer in a goroutine, which is spilled to
+	// the segment files in a temporary directory created in SpillDir when QueueSize packets are waiting, so that
+	// a slow consumer doesn't stall reading from the master. The segment files are removed once consumed and
+	// the directory is removed when the dump exits. It can't be used with SemiSync.
+	SpillDir string
+	// SpillSegmentSize is the size of the segment files, default is 64MiB.
+	SpillSegmentSize int64
+	// SpillMaxSize and SpillMaxAge make reading from the master block when the unconsumed segment files reach
+	// the bytes or the oldest of them is older than the duration, they are unlimited if 0.
+	SpillMaxSize int64
+	SpillMaxAge  time.Duration
+	// MaxEventsPerSecond and MaxBytesPerSecond limit the rate of reading the events from the master if not 0,
+	// so that the dump trails the master deliberately instead of saturating the network or the consumers.
+	// The bursts of a second are allowed. It's the rate of taking the packets from the spill with SpillDir.
+	MaxEventsPerSecond int
+	MaxBytesPerSecond  int
+
+	// mu guards the position, delay and decoder which are read by the other goroutines
+	mu    sync.Mutex
+	delay time.Duration
+	q     *EventQueue
+
+	dsn      string
+	dsns     []string
+	host     int
+	serverID uint32
+	file     string
+	pos      uint32
+	dec      *EventDecoder
+	gtidMode bool
+	gtids    mysql.GTIDSet
+	bounder  *bounder
+	// gapPos is where the next event starts and gapGNOs are the last GNOs of the source servers for DetectGaps
+	gapPos  uint32
+	gapGNOs map[mysql.SID]uint64
+	spill   *spill
+	// eventBucket and byteBucket limit the rate by MaxEventsPerSecond and MaxBytesPerSecond
+	eventBucket *tokenBucket
+	byteBucket  *tokenBucket
+}
+
+// Observer receives the measurements of Streamer, it must be safe for concurrent use.
+type Observer interface {
+	// ObserveEvent is called for every event read from the master including the filtered ones,
+	// timestamp is 0 for the artificial events and the heartbeats.
+	ObserveEvent(typ EventType, timestamp uint32, size int, decodeTime time.Duration)
+	// ObserveReconnect is called for every attempt to reconnect to the master.
+	ObserveReconnect()
+}
+
+// Start connects to the MySQL server and dumps the binlog events from the position of the given file.
+// The events are delivered through the returned EventQueue until ctx is canceled, the EventQueue is closed
+// or an unrecoverable error occurs.
+func (s *Streamer) Start(ctx context.Context, dsn string, serverID uint32, file string, pos uint32) (*EventQueue, error) {
+	s.gtidMode = false
+	return s.start(ctx, dsn, serverID, file, pos)
+}
+
+// StartGTID is like Start but dumps the transactions which are not in gtidSet, it's supported by MySQL only.
+// The consumed transactions are added to a copy of the set, so that the dump resumes from them after
+// reconnecting or failing over to one of Hosts, and the events of an interrupted transaction are dumped again.
+func (s *Streamer) StartGTID(ctx context.Context, dsn string, serverID uint32, gtidSet mysql.GTIDSet) (*EventQueue, error) {
+	if s.Flavor == MariaDBFlavor {
+		return nil, fmt.Errorf("GTID dump is not supported by MariaDB flavor")
+	}
+	gtids, err := mysql.ParseGTIDSet(gtidSet.String())
+	if err != nil {
+		return nil, err
+	}
+	// the set is checked by Contain after failing over, which requires the normalized sets
+	gtids = gtids.Normalize()
+	s.dsns, s.host = []string{dsn}, 0
+	if len(s.Hosts) > 0 {
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		for _, host := range s.Hosts {
+			cfg.Addr = host
+			s.dsns = append(s.dsns, cfg.FormatDSN())
+		}
+	}
+	s.gtidMode, s.gtids = true, gtids
+	return s.start(ctx, dsn, serverID, "", 0)
+}
+
+func (s *Streamer) start(ctx context.Context, dsn string, serverID uint32, file string, pos uint32) (*EventQueue, error) {
+	if err := s.setServerID(serverID); err != nil {
+		return nil, err
+	}
+	s.dsn, s.file, s.pos = dsn, file, pos
+	s.bounder = newBounder(s.Bounds, file)
+	s.gapGNOs, s.spill = nil, nil
+	s.eventBucket, s.byteBucket = nil, nil
+	if s.MaxEventsPerSecond > 0 {
+		s.eventBucket = &tokenBucket{rate: float64(s.MaxEventsPerSecond)}
+	}
+	if s.MaxBytesPerSecond > 0 {
+		s.byteBucket = &tokenBucket{rate: float64(s.MaxBytesPerSecond)}
+	}
+	if s.SpillDir != "" {
+		if s.SemiSync {
+			return nil, fmt.Errorf("SpillDir can't be used with SemiSync")
+		}
+		var err error
+		if s.spill, err = newSpill(s.SpillDir, s.QueueSize, s.SpillSegmentSize, s.SpillMaxSize, s.SpillMaxAge); err != nil {
+			return nil, err
+		}
+	}
+	dec := &EventDecoder{DB: s.DB, ColumnCacheTTL: s.ColumnCacheTTL, ChecksumPolicy: s.ChecksumPolicy, Flavor: s.Flavor,
+		Filter: s.Filter, Log: s.Log, DecodeWorkers: s.DecodeWorkers, Decompress: s.Decompress, Schema: s.Schema,
+		DecodeErrorPolicy: s.DecodeErrorPolicy, OnDecodeError: s.OnDecodeError, tables: make(map[uint64]*TableMapEvent),
+		TableMapCacheSize: s.TableMapCacheSize, MaxRowsEventSize: s.MaxRowsEventSize, MaxRows: s.MaxRows, logFile: file}
+	s.mu.Lock()
+	s.dec = dec
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	conn, err := s.dump(ctx)
+	if err != nil {
+		cancel()
+		if s.spill != nil {
+			s.spill.close()
+		}
+		return nil, err
+	}
+
+	q := newEventQueue(s.QueueSize, s.OverflowPolicy)
+	// the pump keeps reading into the spill while the queue is paused
+	q.cancel, q.bufferPaused = cancel, s.spill == nil
+	s.mu.Lock()
+	s.q = q
+	s.mu.Unlock()
+	go s.run(ctx, conn, q)
+	return q, nil
+}
+
+// Close stops the dump started by Start or its variants and waits for it to exit, the connection is closed
+// and the queued events are kept or discarded according to Drain. It's the same as closing the EventQueue.
+func (s *Streamer) Close() {
+	s.mu.Lock()
+	q := s.q
+	s.mu.Unlock()
+	if q != nil {
+		q.Close(s.Drain)
+	}
+}
+
+// Shutdown is like Close but returns ctx.Err() if the dump doesn't exit before ctx is done,
+// the dump still exits in the background then.
+func (s *Streamer) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pause stops delivering the events until Resume without dropping the connection, see EventQueue.Pause.
+// It's effective after Start or its variants.
+func (s *Streamer) Pause() {
+	s.mu.Lock()
+	q := s.q
+	s.mu.Unlock()
+	if q != nil {
+		q.Pause()
+	}
+}
+
+// Resume resumes delivering the events after Pause.
+func (s *Streamer) Resume() {
+	s.mu.Lock()
+	q := s.q
+	s.mu.Unlock()
+	if q != nil {
+		q.Resume()
+	}
+}
+
+// InvalidateColumns removes the cached column metadata of the table, see EventDecoder.InvalidateColumns.
+// It's effective after Start or its variants.
+func (s *Streamer) InvalidateColumns(database, table string) {
+	s.mu.Lock()
+	dec := s.dec
+	s.mu.Unlock()
+	if dec != nil {
+		dec.InvalidateColumns(database, table)
+	}
+}
+
+// dump connects to the MySQL server, registers as a slave and sends the dump command from the current position.
+// The connection is canceled when ctx is done.
+func (s *Streamer) dump(ctx context.Context) (*mysql.ConnWrapper, error) {
+	conn := mysql.NewConnWrapper()
+	conn.Log, conn.KeepAlivePeriod = s.Log, s.KeepAlivePeriod
+	if err := conn.ConnectContext(ctx, s.dsn, s.TLSConfig); err != nil {
+		return nil, err
+	}
+	s.gapPos = 0
+
+	err := s.writeDumpCommands(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if timeout := s.readTimeout(); timeout > 0 {
+		conn.SetReadTimeout(timeout)
+	}
+	if s.spill != nil {
+		s.spill.start(conn)
+	}
+	return conn, nil
+}
+
+// readTimeout returns the timeout of reading the events, see ReadTimeout.
+func (s *Streamer) readTimeout() time.Duration {
+	if s.ReadTimeout > 0 {
+		return s.ReadTimeout
+	}
+	return 3 * s.HeartbeatPeriod
+}
+
+func (s *Streamer) writeDumpCommands(conn *mysql.ConnWrapper) error {
+	alg, err := masterChecksum(conn)
+	if err != nil {
+		return err
+	}
+	s.dec.masterChecksum = alg
+	if s.HeartbeatPeriod > 0 {
+		// the period is in nanoseconds
+		query := fmt.Sprintf("SET @master_heartbeat_period = %d", s.HeartbeatPeriod.Nanoseconds())
+		if _, err = conn.Exec(query, nil); err != nil {
+			return err
+		}
+	}
+
+	if s.gtidMode {
+		if err = s.checkGTIDs(conn); err != nil {
+			return err
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	if err := conn.WriteRegisterSlaveCommand(s.serverID, hostname, "", "", 0); err != nil {
+		return err
+	}
+	if err := conn.ReadOK(); err != nil {
+		return err
+	}
+	if s.Flavor == MariaDBFlavor {
+		query := fmt.Sprintf("SET @mariadb_slave_capability = %d", mariadbSlaveCapabilityGTID)
+		if _, err = conn.Exec(query, nil); err != nil {
+			return err
+		}
+	}
+	if s.SemiSync {
+		if err := conn.EnableSemiSync(); err == mysql.ErrSemiSyncDisabled {
+			s.log().Warn("semi-sync replication is not enabled on the master, dumping without it")
+		} else if err != nil {
+			return err
+		}
+	}
+	if s.gtidMode {
+		// the interrupted transaction is dumped again from its GtidEvent
+		return conn.WriteBinlogDumpGTIDCommand(s.serverID, s.gtids)
+	}
+	return conn.WriteBinlogDumpCommand(s.serverID, s.file, s.pos)
+}
+
+// checkGTIDs verifies that the master has executed all the consumed transactions and hasn't purged
+// any transaction to dump, so that there's no gap after failing over.
+func (s *Streamer) checkGTIDs(conn *mysql.ConnWrapper) error {
+	rows, err := conn.Query("SELECT @@GLOBAL.gtid_executed, @@GLOBAL.gtid_purged", nil)
+	if err != nil {
+		return err
+	}
+	dest := make([]driver.Value, 2)
+	err = rows.Next(dest)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	sets := make([]mysql.GTIDSet, 2)
+	for i, v := range dest {
+		b, _ := v.([]byte)
+		if sets[i], err = mysql.ParseGTIDSet(string(b)); err != nil {
+			return err
+		}
+		sets[i] = sets[i].Normalize()
+	}
+	executed, purged := sets[0], sets[1]
+	if !executed.Contain(s.gtids) {
+		return fmt.Errorf("master has not executed all the consumed transactions %s, executed: %s", s.gtids, executed)
+	}
+	if !s.gtids.Contain(purged) {
+		return fmt.Errorf("master has purged the transactions to dump, purged: %s, consumed: %s", purged, s.gtids)
+	}
+	return nil
+}
+
+// errUnknownSystemVariable is ER_UNKNOWN_SYSTEM_VARIABLE, which is returned for binlog_checksum by the masters
+// before 5.6.1.
+const errUnknownSystemVariable = 1193
+
+// masterChecksum tells the master that the checksums are supported and returns its checksum algorithm.
+// The masters before 5.6.1 don't support the checksums, and they're not told then.
+func masterChecksum(conn *mysql.ConnWrapper) (ChecksumAlgorithm, error) {
+	rows, err := conn.Query("SELECT @@global.binlog_checksum", nil)
+	if me, ok := err.(*mysql.MySQLError); ok && me.Number == errUnknownSystemVariable {
+		return ChecksumAlgorithmNone, nil
+	}
+	if err != nil {
+		return ChecksumAlgorithmUndef, err
+	}
+	dest := make([]driver.Value, 1)
+	err = rows.Next(dest)
+	rows.Close()
+	if err != nil {
+		return ChecksumAlgorithmUndef, err
+	}
+
+	alg := ChecksumAlgorithmNone
+	if v, ok := dest[0].([]byte); ok && string(v) == ChecksumAlgorithmCRC32.String() {
+		alg = ChecksumAlgorithmCRC32
+	}
+	_, err = conn.Exec("SET @master_binlog_checksum = @@global.binlog_checksum", nil)
+	return alg, err
+}
+
+func (s *Streamer) run(ctx context.Context, conn *mysql.ConnWrapper, q *EventQueue) {
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+		if s.spill != nil {
+			s.spill.close()
+		}
+		q.cancel()
+		close(q.stopped)
+	}()
+
+	for {
+		packet, buf, err := s.readPacket(conn)
+		if err == nil && (s.eventBucket != nil || s.byteBucket != nil) {
+			err = s.throttle(ctx, len(packet))
+		}
+		if err == nil {
+			var ev Event
+			start := time.Now()
+			if ev, err = s.dec.decode(packet); err != nil {
+				// the type is unknown if the header fails to decode
+				if de, ok := err.(*DecodeError); ok {
+					s.log().Error("failed to decode event", "file", s.file, "pos", s.pos, "type", de.Type, "error", err)
+				} else {
+					s.log().Error("failed to decode event", "file", s.file, "pos", s.pos, "error", err)
+				}
+				q.fail(err)
+				return
+			}
+			// the packet is the raw event starting with the header, which is checked by the decoding
+			typ := EventType(packet[4])
+			if buf != nil {
+				holdBuffer(ev, typ, buf)
+			}
+			if s.Observer != nil {
+				s.Observer.ObserveEvent(typ, binary.LittleEndian.Uint32(packet), len(packet), time.Since(start))
+			}
+			if hb, ok := ev.(*HeartbeatEvent); ok {
+				s.mu.Lock()
+				// the master has sent all the events
+				s.delay = 0
+				s.mu.Unlock()
+				if s.OnHeartbeat != nil {
+					s.OnHeartbeat(hb)
+				}
+				continue
+			}
+			if s.DetectGaps {
+				if gap := s.detectGap(packet, ev); gap != nil {
+					s.log().Error("detected binlog gap", "file", s.file, "pos", s.pos, "error", gap)
+					if s.OnGap == nil {
+						Release(ev)
+						q.fail(gap)
+						return
+					}
+					s.OnGap(gap)
+				}
+			}
+			if s.bounder != nil && s.bounder.reached(binary.LittleEndian.Uint32(packet),
+				binary.LittleEndian.Uint32(packet[9:]), binary.LittleEndian.Uint32(packet[13:])) {
+				Release(ev)
+				q.fail(ErrStopReached)
+				return
+			}
+			s.mu.Lock()
+			delay, tracked := s.trackDelay(packet)
+			s.updatePosition(packet, ev)
+			s.mu.Unlock()
+			if tracked && s.OnDelay != nil {
+				s.OnDelay(delay)
+			}
+			if ev != nil && s.bounder != nil && s.bounder.skip(ev) {
+				Release(ev)
+				ev = nil
+			}
+			// the event is accounted before it's pushed, as the consumer may release it right after
+			stopped := ev != nil && s.bounder != nil && s.bounder.replayed(ev)
+			if ev != nil && !q.push(ctx, ev) {
+				return
+			}
+			if stopped {
+				q.fail(ErrStopReached)
+				return
+			}
+			if conn.SemiSyncACKNeeded() {
+				err = conn.WriteSemiSyncACK(s.file, uint64(s.pos))
+			}
+			if err == nil {
+				continue
+			}
+		}
+
+		if ctx.Err() != nil {
+			q.fail(ctx.Err())
+			return
+		}
+		if !isConnError(err) {
+			var renewed bool
+			if renewed, err = s.serverIDConflict(err); !renewed {
+				q.fail(err)
+				return
+			}
+		}
+
+		conn.Close()
+		s.log().Warn("connection lost, reconnecting", "file", s.file, "pos", s.pos, "error", err)
+		conn, err = s.reconnect(ctx)
+		if err != nil {
+			q.fail(err)
+			return
+		}
+	}
+}
+
+// readPacket reads the next event packet, into a pooled buffer if PoolBuffers is set.
+// The packets are read by the pump of the spill if SpillDir is set.
+func (s *Streamer) readPacket(conn *mysql.ConnWrapper) ([]byte, *[]byte, error) {
+	if s.spill != nil {
+		packet, err := s.spill.pop()
+		return packet, nil, err
+	}
+	if !s.PoolBuffers {
+		packet, err := conn.ReadPacket()
+		return packet, nil, err
+	}
+	buf := getBuffer()
+	packet, err := conn.ReadPacketBuffer(*buf)
+	if err != nil {
+		putBuffer(buf)
+		return nil, nil, err
+	}
+	*buf = packet
+	return packet, buf, nil
+}
+
+// reconnect retries to dump from the last position until it succeeds, ctx is canceled or a non-connection error occurs.
+func (s *Streamer) reconnect(ctx context.Context) (*mysql.ConnWrapper, error) {
+	backoff := s.Backoff
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+	for {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+
+		if s.Observer != nil {
+			s.Observer.ObserveReconnect()
+		}
+		conn, err := s.dump(ctx)
+		if err == nil {
+			return conn, nil
+		}
+		if !isConnError(err) {
+			return nil, err
+		}
+		s.log().Warn("failed to reconnect", "error", err)
+		if s.gtidMode && len(s.dsns) > 1 {
+			// fail over to the next candidate
+			s.mu.Lock()
+			s.host = (s.host + 1) % len(s.dsns)
+			s.dsn = s.dsns[s.host]
+			s.mu.Unlock()
+			s.log().Warn("failing over to the next candidate master", "candidate", s.host)
+		}
+	}
+}
+
+func (s *Streamer) log() mysql.LeveledLogger {
+	if s.Log != nil {
+		return s.Log
+	}
+	return mysql.NopLogger
+}
+
+// updatePosition updates the position and the consumed GTIDs by the event decoded from the packet,
+// the GTIDs are tracked by the decoder even if the event is filtered out, i.e. ev is nil.
+func (s *Streamer) updatePosition(packet []byte, ev Event) {
+	if e := s.dec.takeEnded(); e != nil {
+		s.gtids = s.gtids.Add(e.sid, int64(e.gno))
+	}
+	switch e := ev.(type) {
+	case nil:
+		// the event is filtered out, only the position advances
+		s.advance(binary.LittleEndian.Uint32(packet[13:]))
+		return
+	case *RotateEvent:
+		s.file = string(e.NextLogName)
+		s.pos = uint32(e.Position)
+		s.log().Info("rotated binlog", "file", s.file, "pos", s.pos)
+		return
+	case *FormatDescriptionEvent:
+		s.log().Info("received format description", "server_version", string(e.ServerVersion),
+			"checksum", e.ChecksumAlgorithm)
+	}
+	s.advance(ev.Header().NextLogPos)
+}
+
+func (s *Streamer) advance(next uint32) {
+	// NextLogPos is 0 for the artificial events
+	if next > 0 {
+		s.pos = next
+	}
+}
+
+// isConnError reports whether err is caused by a broken connection, which can be recovered by reconnecting.
+func isConnError(err error) bool {
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF, driver.ErrBadConn, mysql.ErrInvalidConn:
+		return true
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
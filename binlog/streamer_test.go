@@ -0,0 +1,524 @@
+package binlog
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LightKool/mysql-go"
+)
+
+func TestStreamerTrackGTIDs(t *testing.T) {
+	// the rows events are the only ones not filtered out
+	s := &Streamer{gtidMode: true, dec: &EventDecoder{Filter: &EventFilter{EventTypes: []EventType{WriteRowsEventType}}}}
+	header := func(typ EventType) *baseEvent { return &baseEvent{header: &EventHeader{Type: typ}} }
+	query := func(q string) Event {
+		return &QueryEvent{baseEvent: header(QueryEventType), StatusVars: []byte{}, Database: []byte("test"), Query: []byte(q)}
+	}
+	var sid [16]byte
+	sid[15] = 1
+	events := []Event{
+		&GtidEvent{baseEvent: header(GtidEventType), sid: sid, gno: 1},
+		query("BEGIN"),
+		&XIDEvent{baseEvent: header(XidEventType)},
+		&GtidEvent{baseEvent: header(GtidEventType), sid: sid, gno: 2},
+		query("CREATE TABLE t (id int)"),
+		// a multi-statement transaction in STATEMENT format
+		&GtidEvent{baseEvent: header(GtidEventType), sid: sid, gno: 3},
+		query("BEGIN"),
+		query("INSERT INTO t VALUES (1)"),
+		query("INSERT INTO t VALUES (2)"),
+		query("COMMIT"),
+		// interrupted after a statement
+		&GtidEvent{baseEvent: header(GtidEventType), sid: sid, gno: 4},
+		query("BEGIN"),
+		query("INSERT INTO t VALUES (3)"),
+	}
+	enc := &EventEncoder{Pos: 4}
+	for i, ev := range events {
+		packet, err := enc.Encode(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := s.dec.decode(packet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded != nil {
+			t.Fatalf("expected event %d to be filtered out, got %T", i, decoded)
+		}
+		s.updatePosition(packet, decoded)
+		if i == 8 && s.gtids.String() != "00000000-0000-0000-0000-000000000001:1-2" {
+			t.Errorf("expected the GTID to be consumed at COMMIT, got %s", s.gtids)
+		}
+	}
+
+	expected := "00000000-0000-0000-0000-000000000001:1-3"
+	if s.gtids.String() != expected {
+		t.Errorf("expected %s, got %s", expected, s.gtids)
+	}
+	if s.pos != enc.Pos {
+		t.Errorf("expected position %d, got %d", enc.Pos, s.pos)
+	}
+}
+
+func TestStreamerTrackDelay(t *testing.T) {
+	s := &Streamer{}
+	packet := make([]byte, 20)
+	// an artificial event
+	binary.LittleEndian.PutUint32(packet, uint32(time.Now().Unix()-100))
+	if _, tracked := s.trackDelay(packet); tracked || s.Delay() != 0 {
+		t.Errorf("expected the artificial event to be skipped, got delay %v", s.Delay())
+	}
+
+	binary.LittleEndian.PutUint32(packet[13:], 1000)
+	delay, tracked := s.trackDelay(packet)
+	if !tracked || delay < 99*time.Second || delay > 110*time.Second {
+		t.Errorf("expected delay about 100s, got %v", delay)
+	}
+	if s.Delay() != delay {
+		t.Errorf("expected Delay %v, got %v", delay, s.Delay())
+	}
+}
+
+func TestStreamerShutdown(t *testing.T) {
+	q := newEventQueue(4, OverflowBlock)
+	<-produce(q, 2)
+	s := &Streamer{Drain: true, q: q}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := q.Pop(context.Background()); err != nil {
+			t.Fatalf("expected the queued events to be drained, got %v", err)
+		}
+	}
+	if _, err := q.Pop(context.Background()); err != ErrQueueClosed {
+		t.Errorf("expected ErrQueueClosed, got %v", err)
+	}
+}
+
+func TestStreamerServerIDConflict(t *testing.T) {
+	conflict := &mysql.MySQLError{Number: 1236, Message: "A slave with the same server_uuid/server_id as this slave has connected to the master"}
+
+	s := &Streamer{}
+	if err := s.setServerID(100); err != nil {
+		t.Fatal(err)
+	}
+	renewed, err := s.serverIDConflict(conflict)
+	if e, ok := err.(*ServerIDConflictError); renewed || !ok || e.ServerID != 100 {
+		t.Errorf("expected ServerIDConflictError of server ID 100, got %v", err)
+	}
+	if _, err = s.serverIDConflict(io.EOF); err != io.EOF {
+		t.Errorf("expected the other errors unchanged, got %v", err)
+	}
+
+	s = &Streamer{AutoServerID: true}
+	if err := s.setServerID(0); err != nil {
+		t.Fatal(err)
+	}
+	id := s.ServerID()
+	if id&0x80000000 == 0 {
+		t.Errorf("expected a server ID in the upper half, got %d", id)
+	}
+	if renewed, _ = s.serverIDConflict(conflict); !renewed || s.ServerID() == id || s.ServerID()&0x80000000 == 0 {
+		t.Errorf("expected a new server ID, got %d", s.ServerID())
+	}
+}
+
+func TestStreamerDetectGap(t *testing.T) {
+	s := &Streamer{file: "mysql-bin.000001", dec: &EventDecoder{}}
+	packet := func(start, size uint32) []byte {
+		p := make([]byte, eventHeaderSize)
+		binary.LittleEndian.PutUint32(p[9:], size)
+		if start > 0 {
+			binary.LittleEndian.PutUint32(p[13:], start+size)
+		}
+		return p
+	}
+	// the artificial events are skipped
+	for _, p := range [][]byte{packet(0, 40), packet(100, 50), packet(150, 20), packet(0, 30)} {
+		if gap := s.detectGap(p, nil); gap != nil {
+			t.Fatalf("unexpected gap %v", gap)
+		}
+	}
+	gap := s.detectGap(packet(200, 10), nil)
+	if gap == nil || gap.File != "mysql-bin.000001" || gap.Pos != 170 || gap.NextPos != 200 {
+		t.Fatalf("expected the gap between 170 and 200, got %v", gap)
+	}
+	// the next file is checked from its first event
+	if gap = s.detectGap(packet(210, 20), &RotateEvent{baseEvent: &baseEvent{header: &EventHeader{}}}); gap != nil {
+		t.Fatalf("unexpected gap %v", gap)
+	}
+	if gap = s.detectGap(packet(4, 100), nil); gap != nil {
+		t.Fatalf("unexpected gap %v", gap)
+	}
+
+	var sid mysql.SID
+	sid[15] = 1
+	// the packet of the GtidEvent decoded to txGtid, which is passed as nil like a filtered event
+	gtid := func(gno uint64) []byte {
+		s.dec.txGtid = &GtidEvent{baseEvent: &baseEvent{header: &EventHeader{}}, sid: sid, gno: gno}
+		p := packet(0, 0)
+		p[4] = byte(GtidEventType)
+		return p
+	}
+	for _, gno := range []uint64{1, 2, 2, 3} {
+		if gap = s.detectGap(gtid(gno), nil); gap != nil {
+			t.Fatalf("unexpected gap %v before GNO %d", gap, gno)
+		}
+	}
+	gap = s.detectGap(gtid(7), nil)
+	if gap == nil || gap.Missing.String() != "00000000-0000-0000-0000-000000000001:4-6" {
+		t.Fatalf("expected the GTID gap 4-6, got %v", gap)
+	}
+
+	// the consumed transactions are not missing, even if they're not consecutive
+	s.gtidMode = true
+	s.gtids, _ = mysql.ParseGTIDSet("00000000-0000-0000-0000-000000000001:1-9:12-15")
+	if gap = s.detectGap(gtid(10), nil); gap != nil {
+		t.Errorf("unexpected gap %v of the consumed transactions", gap)
+	}
+	s.gtids = s.gtids.Add(sid, 10)
+	// the master skips the events of 12-15 in the file
+	if gap = s.detectGap(gtid(11), nil); gap != nil {
+		t.Fatalf("unexpected gap %v", gap)
+	}
+	for _, p := range [][]byte{packet(400, 50), packet(900, 50)} {
+		if gap = s.detectGap(p, nil); gap != nil {
+			t.Fatalf("unexpected gap %v of the positions skipped by the GTID dump", gap)
+		}
+	}
+	if gap = s.detectGap(gtid(16), nil); gap != nil {
+		t.Fatalf("unexpected gap %v of the consumed transactions", gap)
+	}
+	if gap = s.detectGap(gtid(18), nil); gap == nil || gap.Missing.String() != "00000000-0000-0000-0000-000000000001:17" {
+		t.Errorf("expected the GTID gap 17, got %v", gap)
+	}
+}
+
+func TestStreamerReadTimeout(t *testing.T) {
+	s := &Streamer{}
+	if timeout := s.readTimeout(); timeout != 0 {
+		t.Errorf("expected no timeout, got %v", timeout)
+	}
+	s.HeartbeatPeriod = 10 * time.Second
+	if timeout := s.readTimeout(); timeout != 30*time.Second {
+		t.Errorf("expected 3 times the heartbeat period, got %v", timeout)
+	}
+	s.ReadTimeout = time.Minute
+	if timeout := s.readTimeout(); timeout != time.Minute {
+		t.Errorf("expected ReadTimeout, got %v", timeout)
+	}
+}
+
+// warnLogger sends the warnings to ch.
+type warnLogger struct {
+	mysql.LeveledLogger
+	ch chan string
+}
+
+func (l warnLogger) Warn(msg string, keysAndValues ...interface{}) {
+	l.ch <- msg
+}
+
+func TestStreamerReconnectCanceled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	header := func(typ EventType) *baseEvent {
+		return &baseEvent{header: &EventHeader{Timestamp: 1500000000, Type: typ, ServerID: 1}}
+	}
+	writeBinlogFile(t, filepath.Join(dir, "mysql-bin.000001"), &FormatDescriptionEvent{
+		baseEvent:              header(FormatDescriptionEventType),
+		BinlogVersion:          4,
+		ServerVersion:          []byte("5.7.18-log"),
+		EventHeaderLength:      eventHeaderSize,
+		EventPostHeaderLengths: []byte{56, 13, 0, 8},
+	}, &XIDEvent{baseEvent: header(XidEventType), TransactionID: 1})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCtx, stopServer := context.WithCancel(context.Background())
+	defer stopServer()
+	go (&Server{Source: &DirSource{Dir: dir, PollInterval: 10 * time.Millisecond}, AllowAnyUser: true}).Serve(serverCtx, ln)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	warnings := make(chan string, 16)
+	s := &Streamer{Backoff: time.Minute, Log: warnLogger{mysql.NopLogger, warnings}}
+	q, err := s.Start(ctx, "root@tcp("+ln.Addr().String()+")/", 100, "mysql-bin.000001", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		ev, err := q.Pop(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := ev.(*XIDEvent); ok {
+			break
+		}
+	}
+
+	// the streamer waits for the backoff to reconnect after the connection is lost
+	stopServer()
+	select {
+	case msg := <-warnings:
+		if msg != "connection lost, reconnecting" {
+			t.Fatalf("unexpected warning %q", msg)
+		}
+	case <-ctx.Done():
+		t.Fatal("expected the connection to be lost")
+	}
+	cancel()
+	if _, err = q.Pop(context.Background()); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	// the connection is nil after the reconnecting is canceled
+	<-q.stopped
+}
+
+func TestStreamerFailoverGTIDs(t *testing.T) {
+	const sid = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	// the master serves the first connection only, which is lost once the dump is requested
+	primary, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := primary.Accept()
+		primary.Close()
+		if err != nil {
+			return
+		}
+		sc := mysql.NewServerConn(conn)
+		defer sc.Close()
+		if err = sc.Handshake("5.7.18-log", 1, func(user string) (string, bool) { return "", true }); err != nil {
+			return
+		}
+		for {
+			command, data, err := sc.ReadCommand()
+			if err != nil {
+				return
+			}
+			switch query := string(data); {
+			case command == mysql.ComQuery && strings.Contains(query, "binlog_checksum"):
+				err = sc.WriteError(errUnknownSystemVariable, "HY000", "Unknown system variable 'binlog_checksum'")
+			case command == mysql.ComQuery && strings.Contains(query, "gtid_executed"):
+				err = sc.WriteResultSet([]string{"@@GLOBAL.gtid_executed", "@@GLOBAL.gtid_purged"},
+					[][]interface{}{{sid + ":1-10", sid + ":1-10"}})
+			case command == mysql.ComBinlogDumpGTID:
+				return
+			default:
+				err = sc.WriteOK()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// the candidate reports the sets which are not normalized, it doesn't support COM_BINLOG_DUMP_GTID
+	candidate, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	go (&Server{Source: eventsSource{}, AllowAnyUser: true, Variables: map[string]string{
+		"binlog_checksum": "NONE",
+		"gtid_executed":   sid + ":6-12," + sid + ":1-5",
+		"gtid_purged":     sid + ":6-10:1-5",
+	}}).Serve(ctx, candidate)
+
+	// the consumed transactions are not normalized either
+	gtids, err := mysql.ParseGTIDSet(sid + ":1-5:6-10," + sid + ":3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Streamer{Hosts: []string{candidate.Addr().String()}, Backoff: 10 * time.Millisecond}
+	q, err := s.StartGTID(ctx, "root@tcp("+primary.Addr().String()+")/?maxAllowedPacket=4194304", 100, gtids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	// the candidate passes the check of the GTIDs and refuses the dump
+	_, err = q.Pop(ctx)
+	if me, ok := err.(*mysql.MySQLError); !ok || me.Number != 1047 {
+		t.Errorf("expected the dump refused by the candidate, got %v", err)
+	}
+}
+
+func TestStreamerPauseWriteTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	header := func(typ EventType) *baseEvent {
+		return &baseEvent{header: &EventHeader{Timestamp: 1500000000, Type: typ, ServerID: 1}}
+	}
+	// the events are much larger than the socket buffers
+	const n = 256
+	events := []Event{&FormatDescriptionEvent{
+		baseEvent:              header(FormatDescriptionEventType),
+		BinlogVersion:          4,
+		ServerVersion:          []byte("5.7.18-log"),
+		EventHeaderLength:      eventHeaderSize,
+		EventPostHeaderLengths: []byte{56, 13, 0, 8},
+	}}
+	query := []byte("INSERT INTO t VALUES ('" + strings.Repeat("x", 64<<10) + "')")
+	for i := 0; i < n; i++ {
+		events = append(events, &QueryEvent{baseEvent: header(QueryEventType), StatusVars: []byte{},
+			Database: []byte("test"), Query: query})
+	}
+	writeBinlogFile(t, filepath.Join(dir, "mysql-bin.000001"), events...)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	server := &Server{
+		Source:       &DirSource{Dir: dir, PollInterval: 10 * time.Millisecond},
+		Users:        map[string]string{"root": ""},
+		WriteTimeout: 100 * time.Millisecond,
+	}
+	go server.Serve(ctx, ln)
+
+	warnings := make(chan string, 16)
+	s := &Streamer{QueueSize: 4, Log: warnLogger{mysql.NopLogger, warnings}}
+	q, err := s.Start(ctx, "root@tcp("+ln.Addr().String()+")/", 100, "mysql-bin.000001", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close(false)
+	q.Pause()
+	// the pause outlasts the write timeout of the master
+	time.Sleep(500 * time.Millisecond)
+	q.Resume()
+
+	for i := 0; i < n; {
+		ev, err := q.Pop(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := ev.(*QueryEvent); ok {
+			i++
+		}
+	}
+	select {
+	case msg := <-warnings:
+		t.Errorf("unexpected warning %q", msg)
+	default:
+	}
+}
+
+// eventsSource sends the raw events and waits until ctx is done.
+type eventsSource [][]byte
+
+func (s eventsSource) Dump(ctx context.Context, file string, pos uint32, nonBlock bool, send func(event []byte) error) error {
+	for _, event := range s {
+		if err := send(event); err != nil {
+			return err
+		}
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestStreamerShortPacket(t *testing.T) {
+	// the packets of only the OK byte, or with a part of the event type, fail to decode without the type
+	for _, event := range [][]byte{{}, {1, 2, 3}} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		go (&Server{Source: eventsSource{event}, AllowAnyUser: true}).Serve(ctx, ln)
+
+		s := &Streamer{Log: mysql.NopLogger}
+		q, err := s.Start(ctx, "root@tcp("+ln.Addr().String()+")/", 100, "mysql-bin.000001", 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			_, err = q.Pop(ctx)
+			if err != nil {
+				break
+			}
+		}
+		if _, ok := err.(*DecodeError); ok || err == context.DeadlineExceeded || !strings.Contains(err.Error(), "too short") {
+			t.Errorf("%d bytes: expected the header error, got %v", len(event), err)
+		}
+		s.Close()
+		cancel()
+	}
+}
+
+func TestMasterChecksumUnknown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	// a master before 5.6.1 which doesn't know binlog_checksum
+	queries := make(chan string, 16)
+	go func() {
+		defer close(queries)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		sc := mysql.NewServerConn(conn)
+		defer sc.Close()
+		if err = sc.Handshake("5.5.62-log", 1, func(user string) (string, bool) { return "", true }); err != nil {
+			return
+		}
+		for {
+			command, data, err := sc.ReadCommand()
+			if err != nil || command != mysql.ComQuery {
+				return
+			}
+			query := string(data)
+			queries <- query
+			if strings.Contains(query, "binlog_checksum") {
+				err = sc.WriteError(errUnknownSystemVariable, "HY000", "Unknown system variable 'binlog_checksum'")
+			} else {
+				err = sc.WriteOK()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	conn := mysql.NewConnWrapper()
+	if err = conn.Connect("root@tcp(" + ln.Addr().String() + ")/?maxAllowedPacket=4194304"); err != nil {
+		t.Fatal(err)
+	}
+	alg, err := masterChecksum(conn)
+	if err != nil || alg != ChecksumAlgorithmNone {
+		t.Errorf("expected no checksum, got %s, %v", alg, err)
+	}
+	conn.Close()
+	for query := range queries {
+		if strings.HasPrefix(query, "SET") {
+			t.Errorf("unexpected query %q", query)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package binlog
+
+import (
+	"fmt"
+)
+
+// optional metadata types of TableMapEvent
+// refer to https://github.com/mysql/mysql-server/blob/8.0/libbinlogevents/include/rows_event.h
+const (
+	signednessMetadata byte = iota + 1
+	defaultCharsetMetadata
+	columnCharsetMetadata
+	columnNameMetadata
+	setStrValueMetadata
+	enumStrValueMetadata
+	geometryTypeMetadata
+	simplePrimaryKeyMetadata
+	primaryKeyWithPrefixMetadata
+	enumAndSetDefaultCharsetMetadata
+	enumAndSetColumnCharsetMetadata
+)
+
+// realType returns the real type of the i-th column, ENUM and SET columns are written as STRING.
+func (e *TableMapEvent) realType(i int) byte {
+	typ := e.ColumnTypes[i]
+	if typ == fieldTypeString && e.ColumnMeta[i] >= 256 {
+		if realType := byte(e.ColumnMeta[i] >> 8); realType == fieldTypeEnum || realType == fieldTypeSet {
+			return realType
+		}
+	}
+	return typ
+}
+
+func isNumericType(typ byte) bool {
+	switch typ {
+	case fieldTypeDecimal, fieldTypeTiny, fieldTypeShort, fieldTypeInt24, fieldTypeLong, fieldTypeLongLong,
+		fieldTypeFloat, fieldTypeDouble, fieldTypeNewDecimal:
+		return true
+	}
+	return false
+}
+
+func isCharacterType(typ byte) bool {
+	switch typ {
+	case fieldTypeString, fieldTypeVarChar, fieldTypeVarString, fieldTypeBLOB:
+		return true
+	}
+	return false
+}
+
+func isEnumOrSetType(typ byte) bool {
+	return typ == fieldTypeEnum || typ == fieldTypeSet
+}
+
+// columnsOf returns the indexes of the columns whose real types match.
+func (e *TableMapEvent) columnsOf(match func(typ byte) bool) []int {
+	var indexes []int
+	for i := range e.ColumnTypes {
+		if match(e.realType(i)) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// decodeOptionalMetadata decodes the TLV fields written after the column nullability by 8.0 if binlog_row_metadata is set.
+func (e *TableMapEvent) decodeOptionalMetadata(data []byte) error {
+	packet := newBinlogPacket(data)
+	for !packet.EOF() {
+		typ := packet.readByte()
+		length := int(packet.ReadPackedInteger())
+		if packet.Len()-packet.Pos() < length {
+			return fmt.Errorf("optional metadata %d size %d too short, expect %d", typ, packet.Len()-packet.Pos(), length)
+		}
+		field := newBinlogPacket(packet.Read(length))
+
+		var err error
+		switch typ {
+		case signednessMetadata:
+			e.decodeSignedness(field.Read(-1))
+		case defaultCharsetMetadata:
+			err = e.decodeDefaultCharset(field, e.columnsOf(isCharacterType))
+		case columnCharsetMetadata:
+			e.decodeColumnCharsets(field, e.columnsOf(isCharacterType))
+		case enumAndSetDefaultCharsetMetadata:
+			err = e.decodeDefaultCharset(field, e.columnsOf(isEnumOrSetType))
+		case enumAndSetColumnCharsetMetadata:
+			e.decodeColumnCharsets(field, e.columnsOf(isEnumOrSetType))
+		case columnNameMetadata:
+			e.ColumnNames = make([]string, 0, e.ColumnCount)
+			for !field.EOF() {
+				name, err := field.ReadPackedString()
+				if err != nil {
+					return err
+				}
+				e.ColumnNames = append(e.ColumnNames, string(name))
+			}
+		case setStrValueMetadata:
+			e.SetValues, err = e.decodeStrValues(field, fieldTypeSet)
+		case enumStrValueMetadata:
+			e.EnumValues, err = e.decodeStrValues(field, fieldTypeEnum)
+		case simplePrimaryKeyMetadata:
+			for !field.EOF() {
+				e.PrimaryKey = append(e.PrimaryKey, int(field.ReadPackedInteger()))
+			}
+		case primaryKeyWithPrefixMetadata:
+			// pairs of column index and prefix length
+			for !field.EOF() {
+				e.PrimaryKey = append(e.PrimaryKey, int(field.ReadPackedInteger()))
+				field.ReadPackedInteger()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(e.ColumnNames) != 0 && len(e.ColumnNames) != int(e.ColumnCount) {
+		return fmt.Errorf("column names count %d != column count %d", len(e.ColumnNames), e.ColumnCount)
+	}
+	return nil
+}
+
+// decodeSignedness decodes the bitmap of the numeric columns, the most significant bit first.
+func (e *TableMapEvent) decodeSignedness(bitmap []byte) {
+	e.UnsignedColumns = make([]bool, e.ColumnCount)
+	for n, i := range e.columnsOf(isNumericType) {
+		if n>>3 < len(bitmap) {
+			e.UnsignedColumns[i] = bitmap[n>>3]&(0x80>>uint(n&7)) != 0
+		}
+	}
+}
+
+// decodeDefaultCharset decodes the default charset followed by the pairs of column index and charset
+// for the columns which don't use the default one.
+func (e *TableMapEvent) decodeDefaultCharset(field *binlogPacket, columns []int) error {
+	if e.ColumnCharsets == nil {
+		e.ColumnCharsets = make([]uint64, e.ColumnCount)
+	}
+	charset := field.ReadPackedInteger()
+	for _, i := range columns {
+		e.ColumnCharsets[i] = charset
+	}
+	for !field.EOF() {
+		n := field.ReadPackedInteger()
+		if n >= uint64(len(columns)) {
+			return fmt.Errorf("charset column index %d out of range", n)
+		}
+		e.ColumnCharsets[columns[n]] = field.ReadPackedInteger()
+	}
+	return nil
+}
+
+func (e *TableMapEvent) decodeColumnCharsets(field *binlogPacket, columns []int) {
+	if e.ColumnCharsets == nil {
+		e.ColumnCharsets = make([]uint64, e.ColumnCount)
+	}
+	for _, i := range columns {
+		if field.EOF() {
+			return
+		}
+		e.ColumnCharsets[i] = field.ReadPackedInteger()
+	}
+}
+
+// decodeStrValues decodes the member names of the ENUM or SET columns.
+func (e *TableMapEvent) decodeStrValues(field *binlogPacket, typ byte) ([][]string, error) {
+	values := make([][]string, e.ColumnCount)
+	for _, i := range e.columnsOf(func(t byte) bool { return t == typ }) {
+		if field.EOF() {
+			break
+		}
+		n := field.ReadPackedInteger()
+		// every member takes at least 1 byte of the length
+		if n > uint64(field.Len()-field.Pos()) {
+			return nil, fmt.Errorf("%d members of column %d exceed the metadata", n, i)
+		}
+		values[i] = make([]string, n)
+		for j := range values[i] {
+			value, err := field.ReadPackedString()
+			if err != nil {
+				return nil, err
+			}
+			values[i][j] = string(value)
+		}
+	}
+	return values, nil
+}
+
+// metadataColumns builds the column metadata from the optional metadata if the column names are present.
+func (e *TableMapEvent) metadataColumns() []*Column {
+	if len(e.ColumnNames) == 0 {
+		return nil
+	}
+	columns := make([]*Column, e.ColumnCount)
+	for i := range columns {
+		c := &Column{Name: e.ColumnNames[i], Position: i + 1}
+		if e.UnsignedColumns != nil {
+			c.Unsigned = e.UnsignedColumns[i]
+		}
+		if e.EnumValues != nil {
+			c.EnumValues = e.EnumValues[i]
+		}
+		if e.SetValues != nil {
+			c.SetValues = e.SetValues[i]
+		}
+		columns[i] = c
+	}
+	for _, i := range e.PrimaryKey {
+		if i < len(columns) {
+			columns[i].IsPrimary = true
+		}
+	}
+	return columns
+}
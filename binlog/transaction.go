@@ -0,0 +1,213 @@
+package binlog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/LightKool/mysql-go"
+)
+
+// Transaction is a complete transaction, or a standalone statement like DDL, in the binlog.
+type Transaction struct {
+	// GTID is empty if GTIDs are not enabled.
+	GTID string
+	// Begin is the BEGIN or XA START QueryEvent, nil for the standalone statements
+	// and the MariaDB transactions which begin with the GTID event.
+	Begin *QueryEvent
+	// Events are the events between Begin and End, e.g. TableMapEvent and RowsEvent.
+	Events []Event
+	// End is the event which ends the transaction: XIDEvent, COMMIT/ROLLBACK QueryEvent,
+	// XaPrepareLogEvent, or the QueryEvent of the standalone statement.
+	End Event
+	// Position is the position right after the transaction.
+	Position Position
+}
+
+// RowsEvents returns the rows events of the transaction.
+func (t *Transaction) RowsEvents() []*RowsEvent {
+	var events []*RowsEvent
+	for _, ev := range t.Events {
+		if e, ok := ev.(*RowsEvent); ok {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// TransactionReader groups the events popped from an EventQueue into transactions,
+// the events out of transactions like RotateEvent and FormatDescriptionEvent are dropped.
+type TransactionReader struct {
+	// Store saves the position after each transaction is processed if not nil, see Commit.
+	Store PositionStore
+
+	q        *EventQueue
+	tx       *Transaction
+	boundary txBoundary
+	gtid     *GtidEvent
+	pos      Position
+	gtids    mysql.GTIDSet
+
+	// pending is the transaction returned by Read and not committed yet
+	pending *Transaction
+}
+
+func NewTransactionReader(q *EventQueue) *TransactionReader {
+	return &TransactionReader{q: q}
+}
+
+// Position returns the position right after the last transaction read.
+func (r *TransactionReader) Position() Position {
+	return r.pos
+}
+
+// SetPosition sets the position where the EventQueue starts from, which is usually loaded from the Store.
+// The file and offset are updated by the RotateEvent sent by the master at the beginning anyway,
+// but the executed GTID set can only be restored this way if the dump doesn't start from a new binlog file.
+func (r *TransactionReader) SetPosition(pos Position) error {
+	gtids, err := mysql.ParseGTIDSet(pos.GTIDSet)
+	if err != nil {
+		return err
+	}
+	r.pos, r.gtids = pos, gtids
+	return nil
+}
+
+// Read commits the transaction returned by the last Read if it's not committed yet, then reads the next
+// complete transaction. So the position of a transaction is saved only after the caller has processed it
+// and asked for the next one, which is at-least-once delivery.
+func (r *TransactionReader) Read(ctx context.Context) (*Transaction, error) {
+	if r.pending != nil {
+		if err := r.Commit(r.pending); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		ev, err := r.q.Pop(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if tx := r.add(ev); tx != nil {
+			r.pending = tx
+			return tx, nil
+		}
+	}
+}
+
+// Commit saves the position of the transaction returned by Read into the Store, which marks it processed.
+// It's needed only to save the position before the next Read, e.g. before waiting for more events.
+func (r *TransactionReader) Commit(tx *Transaction) error {
+	if tx == r.pending {
+		r.pending = nil
+	}
+	if r.Store == nil {
+		return nil
+	}
+	if err := r.Store.Save(tx.Position); err != nil {
+		return fmt.Errorf("save position %s: %v", tx.Position, err)
+	}
+	return nil
+}
+
+// add adds the event to the current transaction, it returns the transaction if the event completes it.
+func (r *TransactionReader) add(ev Event) *Transaction {
+	begins, ends := r.boundary.track(ev)
+	switch e := ev.(type) {
+	case *RotateEvent:
+		r.pos.File, r.pos.Pos = string(e.NextLogName), uint32(e.Position)
+	case *PreviousGtidsEvent:
+		// the GTIDs executed before the current binlog file, copied since it's updated in place
+		r.gtids, _ = mysql.ParseGTIDSet(e.GTIDSet.String())
+		r.pos.GTIDSet = r.gtids.String()
+	case *GtidEvent:
+		r.start(e.GTID())
+		r.gtid = e
+	case *AnonymousGtidEvent:
+		r.start("")
+	case *MariadbGtidEvent:
+		r.start(e.GTID.String())
+	case *QueryEvent:
+		switch {
+		case begins:
+			r.current().Begin = e
+		case ends:
+			return r.end(e)
+		default:
+			r.current().Events = append(r.current().Events, e)
+		}
+	case *XIDEvent, *XaPrepareLogEvent:
+		return r.end(ev)
+	case *TransactionPayloadEvent:
+		// the payload is a whole transaction
+		var tx *Transaction
+		for _, inner := range e.Events {
+			if t := r.add(inner); t != nil {
+				tx = t
+			}
+		}
+		return tx
+	case *TableMapEvent, *RowsEvent, *IntvarEvent, *RandEvent, *UserVarEvent, *RowsQueryEvent,
+		*BeginLoadQueryEvent, *ExecuteLoadQueryEvent, *TransactionContextEvent, *MariadbAnnotateRowsEvent:
+		r.current().Events = append(r.current().Events, ev)
+	}
+	return nil
+}
+
+func (r *TransactionReader) start(gtid string) {
+	r.tx = &Transaction{GTID: gtid}
+	r.gtid = nil
+}
+
+func (r *TransactionReader) current() *Transaction {
+	if r.tx == nil {
+		r.tx = new(Transaction)
+	}
+	return r.tx
+}
+
+func (r *TransactionReader) end(ev Event) *Transaction {
+	tx := r.current()
+	tx.End = ev
+	if next := ev.Header().NextLogPos; next > 0 {
+		r.pos.Pos = next
+	}
+	if r.gtid != nil {
+		r.gtids = r.gtids.Add(r.gtid.sid, int64(r.gtid.gno))
+		r.pos.GTIDSet = r.gtids.String()
+	}
+	tx.Position = r.pos
+	r.tx, r.gtid = nil, nil
+	return tx
+}
+
+// txBoundary tracks whether the events are between BEGIN and COMMIT to find the ends of the transactions.
+// A transaction ends at XIDEvent, XaPrepareLogEvent, the COMMIT or ROLLBACK QueryEvent, or a statement out of
+// BEGIN like DDL. The statements between BEGIN and COMMIT in STATEMENT and MIXED format don't end it.
+type txBoundary struct {
+	begun bool
+}
+
+// track returns whether the event begins or ends a transaction.
+func (b *txBoundary) track(ev Event) (begins, ends bool) {
+	switch e := ev.(type) {
+	case *GtidEvent, *AnonymousGtidEvent:
+		b.begun = false
+	case *MariadbGtidEvent:
+		// the transactions begin with the GTID event instead of BEGIN in MariaDB
+		b.begun = e.Flags&MariadbGtidStandaloneFlag == 0
+	case *QueryEvent:
+		query := strings.ToUpper(strings.TrimSpace(string(e.Query)))
+		switch {
+		case query == "BEGIN" || strings.HasPrefix(query, "XA START") || strings.HasPrefix(query, "XA BEGIN"):
+			b.begun = true
+			return true, false
+		case query == "COMMIT" || query == "ROLLBACK" || !b.begun:
+			b.begun = false
+			return false, true
+		}
+	case *XIDEvent, *XaPrepareLogEvent:
+		b.begun = false
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,149 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// CompressionType is the compression algorithm of TransactionPayloadEvent.
+type CompressionType byte
+
+const (
+	CompressionZstd CompressionType = 0
+	CompressionNone CompressionType = 255
+)
+
+func (t CompressionType) String() string {
+	switch t {
+	case CompressionZstd:
+		return "ZSTD"
+	case CompressionNone:
+		return "NONE"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", byte(t))
+	}
+}
+
+// Decompressor decompresses the payload of TransactionPayloadEvent, size is the uncompressed size.
+// The package doesn't depend on any zstd library, wrap the implementation of your choice for CompressionZstd,
+// e.g. zstd.Decoder.DecodeAll of github.com/klauspost/compress/zstd.
+type Decompressor func(typ CompressionType, payload []byte, size uint64) ([]byte, error)
+
+// the fields of the TransactionPayloadEvent header
+const (
+	payloadHeaderEndMark         = 0
+	payloadSizeField             = 1
+	payloadCompressionTypeField  = 2
+	payloadUncompressedSizeField = 3
+)
+
+// TransactionPayloadEvent is written by MySQL 8.0.20+ with binlog_transaction_compression=ON, it carries
+// the compressed events of a transaction after the GtidEvent, i.e. from BEGIN to the XIDEvent.
+type TransactionPayloadEvent struct {
+	*baseEvent
+	CompressionType  CompressionType
+	UncompressedSize uint64
+	// Payload is the compressed events.
+	Payload []byte
+	// Events are the decoded events of the payload, the ones filtered out by the decoder are excluded.
+	// They take NextLogPos of the TransactionPayloadEvent since they are not in the binlog file by themselves.
+	Events []Event
+}
+
+func (e *TransactionPayloadEvent) Decode(dec *EventDecoder) error {
+	packet := e.header.packet
+	payloadSize := uint64(0)
+	for {
+		field := packet.ReadPackedInteger()
+		if field == payloadHeaderEndMark || packet.Err() != nil {
+			break
+		}
+		length := int(packet.ReadPackedInteger())
+		value := newBinlogPacket(packet.Read(length))
+		switch field {
+		case payloadSizeField:
+			payloadSize = value.ReadPackedInteger()
+		case payloadCompressionTypeField:
+			e.CompressionType = CompressionType(value.ReadPackedInteger())
+		case payloadUncompressedSizeField:
+			e.UncompressedSize = value.ReadPackedInteger()
+		}
+		// the unknown fields are skipped by their lengths
+		if err := value.Err(); err != nil {
+			return err
+		}
+	}
+	e.Payload = packet.Read(int(payloadSize))
+	if err := packet.Err(); err != nil {
+		return err
+	}
+
+	data := e.Payload
+	switch {
+	case e.CompressionType == CompressionNone:
+	case dec.Decompress == nil:
+		return fmt.Errorf("no decompressor for the %s compressed transaction payload", e.CompressionType)
+	default:
+		var err error
+		if data, err = dec.Decompress(e.CompressionType, e.Payload, e.UncompressedSize); err != nil {
+			return fmt.Errorf("failed to decompress the transaction payload: %v", err)
+		}
+	}
+	return e.decodeEvents(dec, data)
+}
+
+// decodeEvents decodes the events of the payload, which are written without checksums.
+func (e *TransactionPayloadEvent) decodeEvents(dec *EventDecoder, data []byte) error {
+	format, masterChecksum := dec.format, dec.masterChecksum
+	defer func() {
+		dec.format, dec.masterChecksum = format, masterChecksum
+	}()
+	if format != nil {
+		noChecksum := *format
+		noChecksum.ChecksumAlgorithm = ChecksumAlgorithmNone
+		dec.format = &noChecksum
+	}
+	dec.masterChecksum = ChecksumAlgorithmNone
+
+	for len(data) > 0 {
+		if len(data) < eventHeaderSize {
+			return fmt.Errorf("truncated event header in the transaction payload")
+		}
+		size := binary.LittleEndian.Uint32(data[9:])
+		if size < eventHeaderSize || int(size) > len(data) {
+			return fmt.Errorf("invalid event size %d in the transaction payload", size)
+		}
+		ev, err := dec.decode(data[:size])
+		if err != nil {
+			return err
+		}
+		data = data[size:]
+		if ev == nil {
+			continue
+		}
+		ev.Header().NextLogPos = e.header.NextLogPos
+		e.Events = append(e.Events, ev)
+	}
+	return nil
+}
+
+func (e *TransactionPayloadEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "Compression type: %s\n", e.CompressionType)
+	fmt.Fprintf(w, "Payload size: %d\n", len(e.Payload))
+	fmt.Fprintf(w, "Uncompressed size: %d\n", e.UncompressedSize)
+	fmt.Fprintln(w)
+	for _, ev := range e.Events {
+		ev.Print(w)
+	}
+}
+
+func (e *TransactionPayloadEvent) MarshalJSON() ([]byte, error) {
+	return e.marshalJSON(map[string]interface{}{
+		"compression_type":  e.CompressionType.String(),
+		"payload_size":      len(e.Payload),
+		"uncompressed_size": e.UncompressedSize,
+		"events":            e.Events,
+	})
+}
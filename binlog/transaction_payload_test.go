@@ -0,0 +1,100 @@
+package binlog
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/LightKool/mysql-go"
+)
+
+func buildPayloadEvent(t *testing.T, typ CompressionType, compress func([]byte) []byte, events ...Event) []byte {
+	var data []byte
+	enc := &EventEncoder{}
+	for _, ev := range events {
+		b, err := enc.Encode(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, b...)
+	}
+	payload := compress(data)
+
+	p := mysql.NewPacket(nil)
+	for _, field := range [][2]uint64{
+		{payloadCompressionTypeField, uint64(typ)},
+		{payloadUncompressedSizeField, uint64(len(data))},
+		{payloadSizeField, uint64(len(payload))},
+	} {
+		p.WriteLengthEncodedInteger(field[0])
+		value := mysql.NewPacket(nil)
+		value.WriteLengthEncodedInteger(field[1])
+		p.WriteLengthEncodedString(value.Raw())
+	}
+	p.WriteLengthEncodedInteger(payloadHeaderEndMark)
+	p.Write(payload)
+	return buildEvent(TransactionPayloadEventType, p.Raw(), true)
+}
+
+func TestDecodeTransactionPayloadEvent(t *testing.T) {
+	header := func(typ EventType) *baseEvent {
+		return &baseEvent{header: &EventHeader{Timestamp: 1500000000, Type: typ, ServerID: 1}}
+	}
+	events := []Event{
+		&QueryEvent{baseEvent: header(QueryEventType), StatusVars: []byte{}, Database: []byte("test"), Query: []byte("BEGIN")},
+		&XIDEvent{baseEvent: header(XidEventType), TransactionID: 7},
+	}
+	invert := func(data []byte) []byte {
+		out := make([]byte, len(data))
+		for i, b := range data {
+			out[i] = ^b
+		}
+		return out
+	}
+	dec := &EventDecoder{
+		ChecksumPolicy: ChecksumVerify,
+		format:         &FormatDescriptionEvent{ChecksumAlgorithm: ChecksumAlgorithmCRC32},
+		Decompress: func(typ CompressionType, payload []byte, size uint64) ([]byte, error) {
+			if data := invert(payload); typ == CompressionZstd && uint64(len(data)) == size {
+				return data, nil
+			}
+			return nil, fmt.Errorf("unexpected %s payload of size %d", typ, size)
+		},
+	}
+
+	for _, data := range [][]byte{
+		buildPayloadEvent(t, CompressionNone, func(b []byte) []byte { return b }, events...),
+		buildPayloadEvent(t, CompressionZstd, invert, events...),
+	} {
+		ev, err := dec.decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := ev.(*TransactionPayloadEvent)
+		if len(e.Events) != 2 {
+			t.Fatalf("expected 2 events, got %d", len(e.Events))
+		}
+		if q, ok := e.Events[0].(*QueryEvent); !ok || string(q.Query) != "BEGIN" {
+			t.Errorf("expected BEGIN, got %#v", e.Events[0])
+		}
+		if x, ok := e.Events[1].(*XIDEvent); !ok || x.TransactionID != 7 || x.Header().NextLogPos != 1000 {
+			t.Errorf("expected the XIDEvent at 1000, got %#v", e.Events[1])
+		}
+		if dec.format.ChecksumAlgorithm != ChecksumAlgorithmCRC32 {
+			t.Error("expected the checksum algorithm restored")
+		}
+	}
+
+	dec.Filter = &EventFilter{EventTypes: []EventType{XidEventType}}
+	ev, err := dec.decode(buildPayloadEvent(t, CompressionNone, func(b []byte) []byte { return b }, events...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := ev.(*TransactionPayloadEvent); len(e.Events) != 1 || e.Events[0].Header().Type != XidEventType {
+		t.Errorf("expected the BEGIN filtered out, got %v", e.Events)
+	}
+
+	dec.Decompress = nil
+	if _, err = dec.decode(buildPayloadEvent(t, CompressionZstd, invert, events...)); err == nil {
+		t.Error("expected the error of the missing decompressor")
+	}
+}
@@ -0,0 +1,127 @@
+package binlog
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/LightKool/mysql-go"
+)
+
+func TestTransactionReader(t *testing.T) {
+	header := &baseEvent{header: &EventHeader{}}
+	query := func(q string) *QueryEvent {
+		return &QueryEvent{baseEvent: header, Query: []byte(q)}
+	}
+	gtid := &GtidEvent{baseEvent: header, gno: 7}
+	rows := &RowsEvent{baseEvent: header}
+	xid := &XIDEvent{baseEvent: header}
+	ddl := query("CREATE TABLE t (id INT)")
+
+	q := newEventQueue(16, OverflowBlock)
+	for _, ev := range []Event{
+		&RotateEvent{baseEvent: header},
+		gtid, query("BEGIN"), &TableMapEvent{baseEvent: header}, rows, xid,
+		&AnonymousGtidEvent{GtidEvent{baseEvent: header}}, ddl,
+		&MariadbGtidEvent{baseEvent: header, GTID: MariadbGTID{0, 1, 9}}, rows, query("COMMIT"),
+	} {
+		q.ch <- ev
+	}
+	q.fail(ErrQueueClosed)
+
+	r := NewTransactionReader(q)
+	tx, err := r.Read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.GTID != gtid.GTID() || tx.Begin == nil || len(tx.Events) != 2 || tx.End != xid || len(tx.RowsEvents()) != 1 {
+		t.Errorf("unexpected transaction %+v", tx)
+	}
+
+	tx, err = r.Read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.GTID != "" || tx.Begin != nil || len(tx.Events) != 0 || tx.End != ddl {
+		t.Errorf("unexpected DDL transaction %+v", tx)
+	}
+
+	tx, err = r.Read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.GTID != "0-1-9" || tx.Begin != nil || len(tx.Events) != 1 || string(tx.End.(*QueryEvent).Query) != "COMMIT" {
+		t.Errorf("unexpected MariaDB transaction %+v", tx)
+	}
+
+	if _, err = r.Read(context.Background()); err != ErrQueueClosed {
+		t.Errorf("expected ErrQueueClosed, got %v", err)
+	}
+}
+
+func TestTransactionReaderPosition(t *testing.T) {
+	dir, err := ioutil.TempDir("", "position")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := &FilePositionStore{Path: filepath.Join(dir, "position.json")}
+
+	at := func(next uint32) *baseEvent {
+		return &baseEvent{header: &EventHeader{NextLogPos: next}}
+	}
+	previous, err := mysql.ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := newEventQueue(16, OverflowBlock)
+	for _, ev := range []Event{
+		&RotateEvent{baseEvent: at(0), Position: 4, NextLogName: []byte("mysql-bin.000002")},
+		&PreviousGtidsEvent{baseEvent: at(120), GTIDSet: previous},
+		&GtidEvent{baseEvent: at(200), sid: previous[0].SID, gno: 6},
+		&QueryEvent{baseEvent: at(300), Query: []byte("BEGIN")},
+		&XIDEvent{baseEvent: at(400)},
+	} {
+		q.ch <- ev
+	}
+	q.fail(ErrQueueClosed)
+
+	r := NewTransactionReader(q)
+	r.Store = store
+	tx, err := r.Read(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Position{File: "mysql-bin.000002", Pos: 400, GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6"}
+	if tx.Position != expected || r.Position() != expected {
+		t.Errorf("expected position %s, got %s", expected, tx.Position)
+	}
+	if previous.String() != "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5" {
+		t.Errorf("PreviousGtidsEvent is modified: %s", previous)
+	}
+
+	// the position is saved only when the next transaction is read
+	if saved, err := store.Load(); err != nil || saved != (Position{}) {
+		t.Fatalf("expected no saved position before the next Read, got %s, %v", saved, err)
+	}
+	if _, err = r.Read(context.Background()); err != ErrQueueClosed {
+		t.Errorf("expected ErrQueueClosed, got %v", err)
+	}
+	saved, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved != expected {
+		t.Errorf("expected saved position %s, got %s", expected, saved)
+	}
+}
+
+func TestFilePositionStoreEmpty(t *testing.T) {
+	store := &FilePositionStore{Path: filepath.Join(os.TempDir(), "no-such-dir", "position.json")}
+	pos, err := store.Load()
+	if err != nil || pos != (Position{}) {
+		t.Errorf("expected zero position, got %s, %v", pos, err)
+	}
+}
@@ -0,0 +1,41 @@
+package binlog
+
+// ColumnInfo describes the column of a value passed to ValueMapper.
+type ColumnInfo struct {
+	Table *TableMapEvent
+	Index int
+	Name  string
+	// Type is the real type of the column, ENUM and SET are not written as STRING.
+	Type     ColumnType
+	Meta     uint16
+	Unsigned bool
+}
+
+// ValueMapper transforms the column values of rows events after they're decoded,
+// e.g. to convert BLOB values from []byte to string. NULL values are not passed to it.
+type ValueMapper interface {
+	MapValue(column ColumnInfo, value interface{}) (interface{}, error)
+}
+
+// ValueMapperFunc is an adapter to use an ordinary function as ValueMapper.
+type ValueMapperFunc func(column ColumnInfo, value interface{}) (interface{}, error)
+
+func (f ValueMapperFunc) MapValue(column ColumnInfo, value interface{}) (interface{}, error) {
+	return f(column, value)
+}
+
+// mapValue maps the value of the i-th column with dec.ValueMapper if it's set.
+func (e *TableMapEvent) mapValue(dec *EventDecoder, i int, value interface{}) (interface{}, error) {
+	if dec == nil || dec.ValueMapper == nil {
+		return value, nil
+	}
+	column := ColumnInfo{
+		Table:    e,
+		Index:    i,
+		Name:     e.ColumnName(i),
+		Type:     ColumnType(e.realType(i)),
+		Meta:     e.ColumnMeta[i],
+		Unsigned: e.isUnsigned(i),
+	}
+	return dec.ValueMapper.MapValue(column, value)
+}
@@ -0,0 +1,149 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XID identifies a XA transaction.
+type XID struct {
+	FormatID int32
+	Gtrid    []byte
+	Bqual    []byte
+}
+
+// String returns the XID in the hex form written by MySQL, e.g. X'6774726964',X'6271',1.
+func (x XID) String() string {
+	return fmt.Sprintf("X'%x',X'%x',%d", x.Gtrid, x.Bqual, x.FormatID)
+}
+
+// XaPrepareLogEvent is written for XA PREPARE and XA COMMIT ... ONE PHASE.
+type XaPrepareLogEvent struct {
+	*baseEvent
+	OnePhase bool
+	XID      XID
+}
+
+func (e *XaPrepareLogEvent) Decode(dec *EventDecoder) error {
+	packet := e.header.packet
+	e.OnePhase = packet.readByte() != 0
+	e.XID.FormatID = int32(packet.readUint32())
+	gtridLen := packet.readUint32()
+	bqualLen := packet.readUint32()
+	if packet.Len()-packet.Pos() < int(gtridLen+bqualLen) {
+		return fmt.Errorf("xid data size %d too short, expect %d", packet.Len()-packet.Pos(), gtridLen+bqualLen)
+	}
+	e.XID.Gtrid = packet.Read(int(gtridLen))
+	e.XID.Bqual = packet.Read(int(bqualLen))
+	return nil
+}
+
+func (e *XaPrepareLogEvent) Print(w io.Writer) {
+	e.printHeader(w)
+	fmt.Fprintf(w, "One phase: %t\n", e.OnePhase)
+	fmt.Fprintf(w, "XID: %s\n", e.XID)
+	fmt.Fprintln(w)
+}
+
+// XAStatement is a XA statement replicated by QueryEvent.
+type XAStatement struct {
+	// Command is one of START, END, PREPARE, COMMIT and ROLLBACK.
+	Command string
+	XID     XID
+}
+
+var xaCommands = []string{"START", "BEGIN", "END", "PREPARE", "COMMIT", "ROLLBACK"}
+
+// XAStatement parses the query as a XA statement, it returns nil if the query is not a XA statement.
+func (e *QueryEvent) XAStatement() (*XAStatement, error) {
+	query := strings.TrimSpace(string(e.Query))
+	if len(query) < 3 || !strings.EqualFold(query[:3], "XA ") {
+		return nil, nil
+	}
+	query = strings.TrimSpace(query[3:])
+	for _, cmd := range xaCommands {
+		if len(query) > len(cmd) && strings.EqualFold(query[:len(cmd)], cmd) && query[len(cmd)] == ' ' {
+			xid, err := parseXID(strings.TrimSpace(query[len(cmd):]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid XA statement %q: %v", e.Query, err)
+			}
+			if cmd == "BEGIN" {
+				cmd = "START"
+			}
+			return &XAStatement{Command: cmd, XID: xid}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown XA statement %q", e.Query)
+}
+
+// parseXID parses the xid of the form gtrid[,bqual[,formatID]], the trailing options like ONE PHASE are ignored.
+func parseXID(s string) (xid XID, err error) {
+	xid.FormatID = 1
+	if xid.Gtrid, s, err = parseXIDString(s); err != nil {
+		return
+	}
+	if !strings.HasPrefix(s, ",") {
+		return
+	}
+	if xid.Bqual, s, err = parseXIDString(strings.TrimSpace(s[1:])); err != nil {
+		return
+	}
+	if !strings.HasPrefix(s, ",") {
+		return
+	}
+	s = strings.TrimSpace(s[1:])
+	end := strings.IndexAny(s, " \t\n")
+	if end < 0 {
+		end = len(s)
+	}
+	formatID, err := strconv.ParseInt(s[:end], 10, 32)
+	xid.FormatID = int32(formatID)
+	return
+}
+
+// parseXIDString parses the leading string literal of s in the form of X'hex', 0xhex or 'text'.
+func parseXIDString(s string) (value []byte, rest string, err error) {
+	switch {
+	case len(s) > 1 && (s[0] == 'X' || s[0] == 'x') && s[1] == '\'':
+		end := strings.IndexByte(s[2:], '\'')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unterminated hex literal %s", s)
+		}
+		value, err = hex.DecodeString(s[2 : 2+end])
+		rest = s[2+end+1:]
+	case strings.HasPrefix(s, "0x"):
+		end := 2
+		for end < len(s) && strings.IndexByte("0123456789abcdefABCDEF", s[end]) >= 0 {
+			end++
+		}
+		value, err = hex.DecodeString(s[2:end])
+		rest = s[end:]
+	case strings.HasPrefix(s, "'"):
+		var buf bytes.Buffer
+		i := 1
+		for ; i < len(s); i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+			} else if s[i] == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					i++
+				} else {
+					break
+				}
+			}
+			buf.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return nil, "", fmt.Errorf("unterminated string literal %s", s)
+		}
+		value = buf.Bytes()
+		rest = s[i+1:]
+	default:
+		return nil, "", fmt.Errorf("invalid xid string %s", s)
+	}
+	return value, strings.TrimSpace(rest), err
+}
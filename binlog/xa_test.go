@@ -0,0 +1,55 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestXAStatement(t *testing.T) {
+	for query, expected := range map[string]*XAStatement{
+		"XA START X'6774726964',X'6271',1":        {"START", XID{1, []byte("gtrid"), []byte("bq")}},
+		"xa end X'6774726964',X'',1":              {"END", XID{1, []byte("gtrid"), []byte{}}},
+		"XA COMMIT 'it''s',0x6271,7":              {"COMMIT", XID{7, []byte("it's"), []byte("bq")}},
+		"XA ROLLBACK 'gtrid'":                     {"ROLLBACK", XID{1, []byte("gtrid"), nil}},
+		"XA COMMIT X'6774726964',X'',1 ONE PHASE": {"COMMIT", XID{1, []byte("gtrid"), []byte{}}},
+		"BEGIN":                        nil,
+		"XA BEGIN X'6774726964',X'',1": {"START", XID{1, []byte("gtrid"), []byte{}}},
+	} {
+		e := &QueryEvent{Query: []byte(query)}
+		stmt, err := e.XAStatement()
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+		if expected == nil || stmt == nil {
+			if expected != stmt {
+				t.Errorf("%s: expected %v, got %v", query, expected, stmt)
+			}
+			continue
+		}
+		if stmt.Command != expected.Command || stmt.XID.String() != expected.XID.String() {
+			t.Errorf("%s: expected %s %s, got %s %s", query, expected.Command, expected.XID, stmt.Command, stmt.XID)
+		}
+	}
+
+	if _, err := (&QueryEvent{Query: []byte("XA START X'67")}).XAStatement(); err == nil {
+		t.Error("expected error for malformed xid")
+	}
+}
+
+func TestDecodeXaPrepareLogEvent(t *testing.T) {
+	body := make([]byte, 13)
+	body[0] = 1
+	binary.LittleEndian.PutUint32(body[1:], 1)
+	binary.LittleEndian.PutUint32(body[5:], 5)
+	binary.LittleEndian.PutUint32(body[9:], 2)
+	body = append(body, "gtridbq"...)
+	ev, err := (&EventDecoder{}).decode(buildEvent(XaPrepareLogEventType, body, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := ev.(*XaPrepareLogEvent)
+	if !e.OnePhase || e.XID.String() != "X'6774726964',X'6271',1" {
+		t.Errorf("unexpected xa prepare event %t %s", e.OnePhase, e.XID)
+	}
+}
@@ -0,0 +1,242 @@
+// Command binlogdump prints the binlog events read from a MySQL server or local binlog files,
+// like mysqlbinlog.
+//
+// Usage:
+//
+//	binlogdump [flags] [file ...]
+//
+// The events are read from the server if -dsn is given, otherwise from the local files.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"time"
+
+	"github.com/LightKool/mysql-go/binlog"
+)
+
+const dateTimeFormat = "2006-01-02 15:04:05"
+
+var (
+	dsn      = flag.String("dsn", "", "DSN of the server to read from, e.g. user:password@tcp(host:3306)/")
+	serverID = flag.Uint("server-id", 1001, "server id to register as a slave")
+	file     = flag.String("file", "", "binlog file on the server to start from")
+	pos      = flag.Uint("pos", 4, "position in -file to start from")
+
+	database  = flag.String("database", "", "print only the events of the database")
+	table     = flag.String("table", "", "print only the rows events of the table, requires -database")
+	startTime = flag.String("start-datetime", "", "print only the events at or after the local time "+dateTimeFormat)
+	stopTime  = flag.String("stop-datetime", "", "stop at the first event at or after the local time "+dateTimeFormat)
+	startPos  = flag.Uint("start-position", 0, "print only the events starting at or after the position of the first local file")
+	stopPos   = flag.Uint("stop-position", 0, "stop at the first event starting at or after the position of the last local file")
+	format    = flag.String("format", "text", "output format: text, json or sql")
+)
+
+// printer filters and prints the events.
+type printer struct {
+	w         io.Writer
+	database  string
+	startTime time.Time
+	stopTime  time.Time
+	startPos  uint32
+	stopPos   uint32
+	print     func(w io.Writer, ev binlog.Event) error
+}
+
+var errStop = errors.New("stop")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	p, filter, err := newPrinter()
+	if err != nil {
+		fatal(err)
+	}
+	dec := &binlog.EventDecoder{Filter: filter}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	if *dsn != "" {
+		err = dumpRemote(ctx, p, filter)
+	} else if flag.NArg() > 0 {
+		for i, name := range flag.Args() {
+			// like mysqlbinlog, the start position applies to the first file and the stop position to the last one
+			p.startPos, p.stopPos = 0, 0
+			if i == 0 {
+				p.startPos = uint32(*startPos)
+			}
+			if i == flag.NArg()-1 {
+				p.stopPos = uint32(*stopPos)
+			}
+			if err = dumpFile(p, name, dec); err != nil {
+				break
+			}
+		}
+	} else {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil && err != errStop && err != context.Canceled {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "binlogdump:", err)
+	os.Exit(1)
+}
+
+func newPrinter() (*printer, *binlog.EventFilter, error) {
+	p := &printer{w: os.Stdout, database: *database, startPos: uint32(*startPos), stopPos: uint32(*stopPos)}
+	var err error
+	if *startTime != "" {
+		if p.startTime, err = time.ParseInLocation(dateTimeFormat, *startTime, time.Local); err != nil {
+			return nil, nil, err
+		}
+	}
+	if *stopTime != "" {
+		if p.stopTime, err = time.ParseInLocation(dateTimeFormat, *stopTime, time.Local); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	switch *format {
+	case "text":
+		p.print = printText
+	case "json":
+		p.print = printJSON
+	case "sql":
+		p.print = printSQL
+	default:
+		return nil, nil, fmt.Errorf("unknown format %q", *format)
+	}
+
+	var filter *binlog.EventFilter
+	if *table != "" && *database == "" {
+		return nil, nil, fmt.Errorf("-table requires -database")
+	}
+	if *database != "" {
+		name := regexp.QuoteMeta(*database) + `\.`
+		if *table != "" {
+			name += regexp.QuoteMeta(*table)
+		} else {
+			name += ".*"
+		}
+		filter = &binlog.EventFilter{IncludeTables: []*regexp.Regexp{regexp.MustCompile("^" + name + "$")}}
+	}
+	return p, filter, nil
+}
+
+func dumpRemote(ctx context.Context, p *printer, filter *binlog.EventFilter) error {
+	s := &binlog.Streamer{Filter: filter}
+	q, err := s.Start(ctx, *dsn, uint32(*serverID), *file, uint32(*pos))
+	if err != nil {
+		return err
+	}
+	defer q.Close(false)
+
+	for {
+		ev, err := q.Pop(ctx)
+		if err != nil {
+			return err
+		}
+		if err = p.handle(ev); err != nil {
+			return err
+		}
+	}
+}
+
+func dumpFile(p *printer, name string, dec *binlog.EventDecoder) error {
+	r, err := binlog.OpenFile(name, dec)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for {
+		ev, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if err = p.handle(ev); err != nil {
+			return err
+		}
+	}
+}
+
+// handle prints the event if it passes the filters, it returns errStop once a stop condition is reached.
+func (p *printer) handle(ev binlog.Event) error {
+	h := ev.Header()
+	// the artificial events have no timestamp or position
+	if h.NextLogPos == 0 {
+		return nil
+	}
+	start := h.NextLogPos - h.EventSize
+	t := time.Unix(int64(h.Timestamp), 0)
+	if (p.stopPos > 0 && start >= p.stopPos) || (!p.stopTime.IsZero() && !t.Before(p.stopTime)) {
+		return errStop
+	}
+	if start < p.startPos || t.Before(p.startTime) {
+		return nil
+	}
+	if q, ok := ev.(*binlog.QueryEvent); ok && p.database != "" && string(q.Database) != p.database {
+		return nil
+	}
+	return p.print(p.w, ev)
+}
+
+func printText(w io.Writer, ev binlog.Event) error {
+	ev.Print(w)
+	return nil
+}
+
+func printJSON(w io.Writer, ev binlog.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+func printSQL(w io.Writer, ev binlog.Event) error {
+	switch e := ev.(type) {
+	case *binlog.QueryEvent:
+		if len(e.Database) > 0 {
+			fmt.Fprintf(w, "USE `%s`;\n", e.Database)
+		}
+		fmt.Fprintf(w, "%s;\n", e.Query)
+	case *binlog.XIDEvent:
+		fmt.Fprintln(w, "COMMIT;")
+	case *binlog.RowsEvent:
+		statements, err := e.SQL()
+		if err != nil {
+			return err
+		}
+		for _, stmt := range statements {
+			fmt.Fprintf(w, "%s;\n", stmt)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package mysql
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// ZstdCodec compresses and decompresses the packets for the `compress=zstd` parameter of the DSN.
+// The driver doesn't depend on any zstd library, register the implementation of your choice by RegisterZstdCodec,
+// e.g. struct{ *zstd.Encoder; *zstd.Decoder } of github.com/klauspost/compress/zstd.
+type ZstdCodec interface {
+	// EncodeAll appends the compressed src to dst.
+	EncodeAll(src, dst []byte) []byte
+	// DecodeAll appends the decompressed src to dst.
+	DecodeAll(src, dst []byte) ([]byte, error)
+}
+
+var (
+	zstdCodecLock sync.RWMutex
+	zstdCodec     ZstdCodec
+)
+
+// RegisterZstdCodec registers the ZstdCodec used by the connections with `compress=zstd`,
+// it must be safe for concurrent use.
+func RegisterZstdCodec(codec ZstdCodec) {
+	zstdCodecLock.Lock()
+	zstdCodec = codec
+	zstdCodecLock.Unlock()
+}
+
+func getZstdCodec() ZstdCodec {
+	zstdCodecLock.RLock()
+	defer zstdCodecLock.RUnlock()
+	return zstdCodec
+}
+
+const (
+	compressionZlib = "zlib"
+	compressionZstd = "zstd"
+
+	compressedHeaderSize = 7
+	// the level of the server to compress with zstd, which is the default of MySQL
+	zstdCompressionLevel = 3
+	// the shorter packets are sent uncompressed like the MySQL client does
+	minCompressLength = 50
+)
+
+// compressedConn speaks the compressed protocol over the connection, the packets written are sent in
+// the compressed packets and the ones read are decompressed into the stream of the plain packets.
+// The compressed packets have their own sequence numbers which are independent of the plain ones.
+type compressedConn struct {
+	net.Conn
+	// zstd is nil for zlib
+	zstd ZstdCodec
+
+	// seq is the sequence number of the next compressed packet to write,
+	// it follows the last compressed packet read or written
+	seq    uint8
+	header [compressedHeaderSize]byte
+	in     []byte
+	out    []byte
+	// unread is the decompressed data not read yet
+	unread []byte
+	zr     io.ReadCloser
+	zw     *zlib.Writer
+	zbuf   bytes.Buffer
+	wbuf   []byte
+}
+
+// compressionFlag returns the capability flag of the compression algorithm of the DSN,
+// it's 0 if the compression is disabled or not supported by the server.
+func (mc *mysqlConn) compressionFlag() clientFlag {
+	switch mc.cfg.Compress {
+	case compressionZlib:
+		return mc.flags & clientCompress
+	case compressionZstd:
+		return mc.flags & clientZstdCompressionAlgorithm
+	}
+	return 0
+}
+
+func newCompressedConn(conn net.Conn, algorithm string) (*compressedConn, error) {
+	c := &compressedConn{Conn: conn}
+	if algorithm == compressionZstd {
+		if c.zstd = getZstdCodec(); c.zstd == nil {
+			return nil, fmt.Errorf("no zstd codec registered by RegisterZstdCodec")
+		}
+	}
+	return c, nil
+}
+
+func (c *compressedConn) Read(b []byte) (int, error) {
+	for len(c.unread) == 0 {
+		if err := c.readCompressed(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, c.unread)
+	c.unread = c.unread[n:]
+	return n, nil
+}
+
+// readCompressed reads the next compressed packet into unread.
+func (c *compressedConn) readCompressed() error {
+	if _, err := io.ReadFull(c.Conn, c.header[:]); err != nil {
+		return err
+	}
+	compLen := int(uint32(c.header[0]) | uint32(c.header[1])<<8 | uint32(c.header[2])<<16)
+	c.seq = c.header[3] + 1
+	// the uncompressed length is 0 if the payload is not compressed
+	size := int(uint32(c.header[4]) | uint32(c.header[5])<<8 | uint32(c.header[6])<<16)
+
+	if cap(c.in) < compLen {
+		c.in = make([]byte, compLen)
+	}
+	c.in = c.in[:compLen]
+	if _, err := io.ReadFull(c.Conn, c.in); err != nil {
+		return err
+	}
+	if size == 0 {
+		c.unread = c.in
+		return nil
+	}
+
+	if c.zstd != nil {
+		out, err := c.zstd.DecodeAll(c.in, c.out[:0])
+		if err != nil {
+			return err
+		}
+		c.out = out
+	} else {
+		var err error
+		if c.zr == nil {
+			c.zr, err = zlib.NewReader(bytes.NewReader(c.in))
+		} else {
+			err = c.zr.(zlib.Resetter).Reset(bytes.NewReader(c.in), nil)
+		}
+		if err != nil {
+			return err
+		}
+		if cap(c.out) < size {
+			c.out = make([]byte, size)
+		}
+		c.out = c.out[:size]
+		if _, err = io.ReadFull(c.zr, c.out); err != nil {
+			return err
+		}
+	}
+	if len(c.out) != size {
+		return ErrMalformPkt
+	}
+	c.unread = c.out
+	return nil
+}
+
+// Write sends b in the compressed packets, b is a whole plain packet written by writePacket, whose sequence number
+// is used by the first compressed packet.
+func (c *compressedConn) Write(b []byte) (int, error) {
+	if len(b) >= 4 {
+		c.seq = b[3]
+	}
+	for rest := b; len(rest) > 0; {
+		chunk := rest
+		if len(chunk) > maxPacketSize {
+			chunk = chunk[:maxPacketSize]
+		}
+		rest = rest[len(chunk):]
+
+		payload, size := chunk, 0
+		if len(chunk) >= minCompressLength {
+			compressed, err := c.compress(chunk)
+			if err != nil {
+				return 0, err
+			}
+			// sent uncompressed if it doesn't help
+			if len(compressed) < len(chunk) {
+				payload, size = compressed, len(chunk)
+			}
+		}
+
+		c.wbuf = append(c.wbuf[:0],
+			byte(len(payload)), byte(len(payload)>>8), byte(len(payload)>>16), c.seq,
+			byte(size), byte(size>>8), byte(size>>16))
+		c.wbuf = append(c.wbuf, payload...)
+		if _, err := c.Conn.Write(c.wbuf); err != nil {
+			return 0, err
+		}
+		c.seq++
+	}
+	return len(b), nil
+}
+
+func (c *compressedConn) compress(data []byte) ([]byte, error) {
+	if c.zstd != nil {
+		return c.zstd.EncodeAll(data, nil), nil
+	}
+	c.zbuf.Reset()
+	if c.zw == nil {
+		c.zw = zlib.NewWriter(&c.zbuf)
+	} else {
+		c.zw.Reset(&c.zbuf)
+	}
+	if _, err := c.zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := c.zw.Close(); err != nil {
+		return nil, err
+	}
+	return c.zbuf.Bytes(), nil
+}
@@ -0,0 +1,78 @@
+package mysql
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+// reverseCodec stands for zstd in the tests, it "compresses" the data ending with 0 by reversing it
+// without the trailing 0.
+type reverseCodec struct{}
+
+func (reverseCodec) EncodeAll(src, dst []byte) []byte {
+	for i := len(src) - 2; i >= 0; i-- {
+		dst = append(dst, src[i])
+	}
+	return dst
+}
+
+func (reverseCodec) DecodeAll(src, dst []byte) ([]byte, error) {
+	for i := len(src) - 1; i >= 0; i-- {
+		dst = append(dst, src[i])
+	}
+	return append(dst, 0), nil
+}
+
+func TestCompressedConn(t *testing.T) {
+	RegisterZstdCodec(reverseCodec{})
+	defer RegisterZstdCodec(nil)
+
+	small := []byte{3, 0, 0, 0, 1, 2, 3}
+	large := append([]byte{100, 0, 0, 1}, bytes.Repeat([]byte{0}, 100)...)
+	for _, algorithm := range []string{compressionZlib, compressionZstd} {
+		client, server := net.Pipe()
+		cc, err := newCompressedConn(client, algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc, _ := newCompressedConn(server, algorithm)
+
+		done := make(chan error, 1)
+		go func() {
+			for _, packet := range [][]byte{small, large} {
+				if _, err := cc.Write(packet); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}()
+
+		buf := make([]byte, len(small)+len(large))
+		for n := 0; n < len(buf); {
+			nn, err := sc.Read(buf[n:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			n += nn
+		}
+		if err = <-done; err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, append(append([]byte(nil), small...), large...)) {
+			t.Errorf("%s: unexpected packets %v", algorithm, buf)
+		}
+		// the compressed packets are numbered by the plain packets written
+		if cc.seq != 2 || sc.seq != 2 {
+			t.Errorf("%s: expected the next sequence 2, got %d and %d", algorithm, cc.seq, sc.seq)
+		}
+		client.Close()
+		server.Close()
+	}
+
+	RegisterZstdCodec(nil)
+	if _, err := newCompressedConn(nil, compressionZstd); err == nil {
+		t.Error("expected the error of the missing zstd codec")
+	}
+}
@@ -41,6 +41,8 @@ type mysqlConn struct {
 	sequence         uint8
 	parseTime        bool
 	strict           bool
+	// compress is set after the compressed protocol is negotiated
+	compress *compressedConn
 
 	// for context support (Go 1.8+)
 	watching bool
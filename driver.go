@@ -51,6 +51,20 @@ func RegisterDial(net string, dial DialFunc) {
 // See https://github.com/go-sql-driver/mysql#dsn-data-source-name for how
 // the DSN string is formated
 func (d MySQLDriver) Open(dsn string) (driver.Conn, error) {
+	cfg, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	mc, err := d.open(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	return mc, nil
+}
+
+// open connects to the server with the config.
+// If ctx is not nil, the connection is canceled when ctx is done, during or after the handshake.
+func (d MySQLDriver) open(cfg *Config, ctx mysqlContext) (*mysqlConn, error) {
 	var err error
 
 	// New mysqlConn
@@ -58,10 +72,7 @@ func (d MySQLDriver) Open(dsn string) (driver.Conn, error) {
 		maxAllowedPacket: maxPacketSize,
 		maxWriteSize:     maxPacketSize - 1,
 		closech:          make(chan struct{}),
-	}
-	mc.cfg, err = ParseDSN(dsn)
-	if err != nil {
-		return nil, err
+		cfg:              cfg,
 	}
 	mc.parseTime = mc.cfg.ParseTime
 	mc.strict = mc.cfg.Strict
@@ -71,6 +82,9 @@ func (d MySQLDriver) Open(dsn string) (driver.Conn, error) {
 		mc.netConn, err = dial(mc.cfg.Addr)
 	} else {
 		nd := net.Dialer{Timeout: mc.cfg.Timeout}
+		if ctx != nil {
+			nd.Cancel = ctx.Done()
+		}
 		mc.netConn, err = nd.Dial(mc.cfg.Net, mc.cfg.Addr)
 	}
 	if err != nil {
@@ -85,6 +99,9 @@ func (d MySQLDriver) Open(dsn string) (driver.Conn, error) {
 			mc.netConn = nil
 			return nil, err
 		}
+		if mc.cfg.keepAlivePeriod > 0 {
+			tc.SetKeepAlivePeriod(mc.cfg.keepAlivePeriod)
+		}
 	}
 
 	// Call startWatcher for context support (From Go 1.8)
@@ -92,6 +109,17 @@ func (d MySQLDriver) Open(dsn string) (driver.Conn, error) {
 		s.startWatcher()
 	}
 
+	// Cancel the connection when ctx is done until the connection is closed
+	if ctx != nil && ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				mc.cancel(ctx.Err())
+			case <-mc.closech:
+			}
+		}()
+	}
+
 	mc.buf = newBuffer(mc.netConn)
 
 	// Set I/O timeouts
@@ -120,6 +148,16 @@ func (d MySQLDriver) Open(dsn string) (driver.Conn, error) {
 		return nil, err
 	}
 
+	// Switch to the compressed protocol after authentication
+	if mc.compressionFlag() != 0 {
+		if mc.compress, err = newCompressedConn(mc.netConn, mc.cfg.Compress); err != nil {
+			mc.Close()
+			return nil, err
+		}
+		mc.netConn = mc.compress
+		mc.buf.nc = mc.compress
+	}
+
 	if mc.cfg.MaxAllowedPacket > 0 {
 		mc.maxAllowedPacket = mc.cfg.MaxAllowedPacket
 	} else {
@@ -185,6 +223,10 @@ func handleAuthResult(mc *mysqlConn, oldCipher []byte) error {
 			return err
 		}
 		_, err = mc.readResultOK()
+	} else if err == errCachingSha2Password {
+		err = mc.cachingSha2Auth(cipher)
+	} else if err == errSha256Password {
+		err = mc.sha256Auth(cipher)
 	}
 	return err
 }
@@ -10,6 +10,7 @@ package mysql
 
 import (
 	"bytes"
+	"crypto/rsa"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -41,14 +42,19 @@ type Config struct {
 	MaxAllowedPacket int               // Max packet size allowed
 	TLSConfig        string            // TLS configuration name
 	tls              *tls.Config       // TLS configuration
+	ServerPubKey     string            // Server public key name
+	pubKey           *rsa.PublicKey    // Server public key
 	Timeout          time.Duration     // Dial timeout
 	ReadTimeout      time.Duration     // I/O read timeout
 	WriteTimeout     time.Duration     // I/O write timeout
+	Compress         string            // Protocol compression algorithm, "zlib" or "zstd"
+	keepAlivePeriod  time.Duration     // TCP keepalive period, set by ConnWrapper
 
 	AllowAllFiles           bool // Allow all files to be used with LOAD DATA LOCAL INFILE
 	AllowCleartextPasswords bool // Allows the cleartext client side plugin
 	AllowNativePasswords    bool // Allows the native password authentication method
 	AllowOldPasswords       bool // Allows the old insecure password method
+	AllowPublicKeyRetrieval bool // Allows fetching the public key from the server for the sha256 authentication
 	ClientFoundRows         bool // Return number of matching rows instead of rows changed
 	ColumnsWithAlias        bool // Prepend table alias to column names
 	InterpolateParams       bool // Interpolate placeholders into query string
@@ -122,6 +128,15 @@ func (cfg *Config) FormatDSN() string {
 		}
 	}
 
+	if cfg.AllowPublicKeyRetrieval {
+		if hasParam {
+			buf.WriteString("&allowPublicKeyRetrieval=true")
+		} else {
+			hasParam = true
+			buf.WriteString("?allowPublicKeyRetrieval=true")
+		}
+	}
+
 	if cfg.ClientFoundRows {
 		if hasParam {
 			buf.WriteString("&clientFoundRows=true")
@@ -150,6 +165,16 @@ func (cfg *Config) FormatDSN() string {
 		}
 	}
 
+	if len(cfg.Compress) > 0 {
+		if hasParam {
+			buf.WriteString("&compress=")
+		} else {
+			hasParam = true
+			buf.WriteString("?compress=")
+		}
+		buf.WriteString(cfg.Compress)
+	}
+
 	if cfg.InterpolateParams {
 		if hasParam {
 			buf.WriteString("&interpolateParams=true")
@@ -225,6 +250,16 @@ func (cfg *Config) FormatDSN() string {
 		buf.WriteString(cfg.Timeout.String())
 	}
 
+	if len(cfg.ServerPubKey) > 0 {
+		if hasParam {
+			buf.WriteString("&serverPubKey=")
+		} else {
+			hasParam = true
+			buf.WriteString("?serverPubKey=")
+		}
+		buf.WriteString(url.QueryEscape(cfg.ServerPubKey))
+	}
+
 	if len(cfg.TLSConfig) > 0 {
 		if hasParam {
 			buf.WriteString("&tls=")
@@ -423,6 +458,14 @@ func parseDSNParams(cfg *Config, params string) (err error) {
 				return errors.New("invalid bool value: " + value)
 			}
 
+		// Fetch the public key from the server for the sha256 authentication without TLS
+		case "allowPublicKeyRetrieval":
+			var isBool bool
+			cfg.AllowPublicKeyRetrieval, isBool = readBool(value)
+			if !isBool {
+				return errors.New("invalid bool value: " + value)
+			}
+
 		// Switch "rowsAffected" mode
 		case "clientFoundRows":
 			var isBool bool
@@ -445,7 +488,16 @@ func parseDSNParams(cfg *Config, params string) (err error) {
 
 		// Compression
 		case "compress":
-			return errors.New("compression not implemented yet")
+			if compress, isBool := readBool(value); isBool {
+				cfg.Compress = ""
+				if compress {
+					cfg.Compress = compressionZlib
+				}
+			} else if value == compressionZlib || value == compressionZstd {
+				cfg.Compress = value
+			} else {
+				return errors.New("invalid compression algorithm: " + value)
+			}
 
 		// Enable client side placeholder substitution
 		case "interpolateParams":
@@ -511,6 +563,20 @@ func parseDSNParams(cfg *Config, params string) (err error) {
 				return
 			}
 
+		// Server public key for the sha256 authentication without TLS
+		case "serverPubKey":
+			name, err := url.QueryUnescape(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for server pub key name: %v", err)
+			}
+
+			if pubKey := getServerPubKey(name); pubKey != nil {
+				cfg.ServerPubKey = name
+				cfg.pubKey = pubKey
+			} else {
+				return errors.New("invalid value / unknown server pub key name: " + name)
+			}
+
 		// TLS-Encryption
 		case "tls":
 			boolValue, isBool := readBool(value)
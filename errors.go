@@ -31,6 +31,11 @@ var (
 	ErrPktSyncMul        = errors.New("commands out of sync. Did you run multiple statements at once?")
 	ErrPktTooLarge       = errors.New("packet for query is too large. Try adjusting the 'max_allowed_packet' variable on the server")
 	ErrBusyBuffer        = errors.New("busy buffer")
+	ErrNoPubKey          = errors.New("this user requires TLS or the server's public key for sha256 authentication. Please use TLS, add 'serverPubKey=<name>' registered by RegisterServerPubKey or 'allowPublicKeyRetrieval=true' to your DSN")
+
+	// the plugins switched to which are always handled by the driver
+	errCachingSha2Password = errors.New("this user requires caching sha2 password authentication")
+	errSha256Password      = errors.New("this user requires sha256 password authentication")
 )
 
 var errLog = Logger(log.New(os.Stderr, "[mysql] ", log.Ldate|log.Ltime|log.Lshortfile))
@@ -60,6 +65,21 @@ func (me *MySQLError) Error() string {
 	return fmt.Sprintf("Error %d: %s", me.Number, me.Message)
 }
 
+// UnsupportedPluginError is returned when the server requires an authentication plugin
+// which is not supported by the driver.
+type UnsupportedPluginError struct {
+	Plugin string
+}
+
+func (e *UnsupportedPluginError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrUnknownPlugin, e.Plugin)
+}
+
+// Unwrap returns ErrUnknownPlugin, so that errors.Is(err, ErrUnknownPlugin) is true for UnsupportedPluginError.
+func (e *UnsupportedPluginError) Unwrap() error {
+	return ErrUnknownPlugin
+}
+
 // MySQLWarnings is an error type which represents a group of one or more MySQL
 // warnings
 type MySQLWarnings []MySQLWarning
@@ -0,0 +1,20 @@
+//go:build go1.13
+// +build go1.13
+
+package mysql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnsupportedPluginErrorIs(t *testing.T) {
+	var err error = &UnsupportedPluginError{Plugin: "auth_socket"}
+	if !errors.Is(err, ErrUnknownPlugin) {
+		t.Errorf("expected %v to be ErrUnknownPlugin", err)
+	}
+	var pluginErr *UnsupportedPluginError
+	if !errors.As(err, &pluginErr) || pluginErr.Plugin != "auth_socket" {
+		t.Errorf("expected UnsupportedPluginError, got %v", pluginErr)
+	}
+}
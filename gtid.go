@@ -0,0 +1,391 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SID is the UUID of the server where the transactions are originated.
+type SID [16]byte
+
+// String returns the canonical textual form of the UUID.
+func (sid SID) String() string {
+	buf := make([]byte, 36)
+
+	hex.Encode(buf[0:8], sid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], sid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], sid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], sid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], sid[10:])
+
+	return string(buf)
+}
+
+// GTIDInterval is a range of transaction numbers [Start, Stop).
+type GTIDInterval struct {
+	Start, Stop int64
+}
+
+// String returns the textual form of the interval like `1-5`, or `7` if it contains only one transaction.
+func (i GTIDInterval) String() string {
+	if i.Stop == i.Start+1 {
+		return strconv.FormatInt(i.Start, 10)
+	}
+	return strconv.FormatInt(i.Start, 10) + "-" + strconv.FormatInt(i.Stop-1, 10)
+}
+
+// UUIDSet holds the transaction intervals of one source server.
+type UUIDSet struct {
+	SID       SID
+	Intervals []GTIDInterval
+}
+
+// String returns the textual form of the set like `uuid:1-5:7`.
+func (s *UUIDSet) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(s.SID.String())
+	for _, interval := range s.Intervals {
+		buf.WriteByte(':')
+		buf.WriteString(interval.String())
+	}
+	return buf.String()
+}
+
+// GTIDSet is a set of global transaction identifiers.
+type GTIDSet []*UUIDSet
+
+// String returns the textual form of the set like `uuid:1-5:7-10,uuid2:1-3`.
+func (set GTIDSet) String() string {
+	parts := make([]string, len(set))
+	for i, us := range set {
+		parts[i] = us.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Add adds the transaction gno of sid into the set, the adjacent intervals are merged.
+func (set GTIDSet) Add(sid SID, gno int64) GTIDSet {
+	for _, us := range set {
+		if us.SID == sid {
+			us.add(gno)
+			return set
+		}
+	}
+	return append(set, &UUIDSet{SID: sid, Intervals: []GTIDInterval{{gno, gno + 1}}})
+}
+
+func (s *UUIDSet) add(gno int64) {
+	// the first interval which contains gno or is after it
+	i := sort.Search(len(s.Intervals), func(i int) bool { return s.Intervals[i].Stop >= gno })
+	if i < len(s.Intervals) && s.Intervals[i].Start <= gno {
+		if gno < s.Intervals[i].Stop {
+			return
+		}
+		s.Intervals[i].Stop++
+		if i+1 < len(s.Intervals) && s.Intervals[i+1].Start == s.Intervals[i].Stop {
+			s.Intervals[i].Stop = s.Intervals[i+1].Stop
+			s.Intervals = append(s.Intervals[:i+1], s.Intervals[i+2:]...)
+		}
+		return
+	}
+	if i < len(s.Intervals) && s.Intervals[i].Start == gno+1 {
+		s.Intervals[i].Start = gno
+		return
+	}
+	s.Intervals = append(s.Intervals, GTIDInterval{})
+	copy(s.Intervals[i+1:], s.Intervals[i:])
+	s.Intervals[i] = GTIDInterval{gno, gno + 1}
+}
+
+// Contain reports whether the set contains all the transactions of other, the set must be normalized
+// which means the intervals of a UUIDSet are sorted and not adjacent, like the ones returned by the server
+// or Normalize.
+func (set GTIDSet) Contain(other GTIDSet) bool {
+	for _, o := range other {
+		var us *UUIDSet
+		for _, s := range set {
+			if s.SID == o.SID {
+				us = s
+				break
+			}
+		}
+		for _, interval := range o.Intervals {
+			if interval.Start < interval.Stop && (us == nil || !us.contain(interval)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (s *UUIDSet) contain(interval GTIDInterval) bool {
+	for _, i := range s.Intervals {
+		if i.Start <= interval.Start && interval.Stop <= i.Stop {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone returns a deep copy of the set.
+func (set GTIDSet) Clone() GTIDSet {
+	if set == nil {
+		return nil
+	}
+	clone := make(GTIDSet, len(set))
+	for i, us := range set {
+		clone[i] = &UUIDSet{SID: us.SID, Intervals: append([]GTIDInterval(nil), us.Intervals...)}
+	}
+	return clone
+}
+
+// Normalize returns the normalized copy of the set: the UUIDSets of the same SID are merged and sorted by SID,
+// their intervals are sorted with the overlapping and adjacent ones merged, and the empty ones are removed.
+func (set GTIDSet) Normalize() GTIDSet {
+	var normalized GTIDSet
+	sets := make(map[SID]*UUIDSet)
+	for _, us := range set {
+		if n, ok := sets[us.SID]; ok {
+			n.Intervals = append(n.Intervals, us.Intervals...)
+			continue
+		}
+		n := &UUIDSet{SID: us.SID, Intervals: append([]GTIDInterval(nil), us.Intervals...)}
+		sets[us.SID] = n
+		normalized = append(normalized, n)
+	}
+	n := 0
+	for _, us := range normalized {
+		if us.Intervals = normalizeIntervals(us.Intervals); len(us.Intervals) > 0 {
+			normalized[n] = us
+			n++
+		}
+	}
+	normalized = normalized[:n]
+	sort.Slice(normalized, func(i, j int) bool {
+		return bytes.Compare(normalized[i].SID[:], normalized[j].SID[:]) < 0
+	})
+	return normalized
+}
+
+// normalizeIntervals sorts the intervals in place and merges the overlapping and adjacent ones.
+func normalizeIntervals(intervals []GTIDInterval) []GTIDInterval {
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i].Start < intervals[j].Start })
+	n := 0
+	for _, interval := range intervals {
+		if interval.Start >= interval.Stop {
+			continue
+		}
+		if n > 0 && interval.Start <= intervals[n-1].Stop {
+			if interval.Stop > intervals[n-1].Stop {
+				intervals[n-1].Stop = interval.Stop
+			}
+			continue
+		}
+		intervals[n] = interval
+		n++
+	}
+	return intervals[:n]
+}
+
+// Union returns the normalized set of the transactions in either set.
+func (set GTIDSet) Union(other GTIDSet) GTIDSet {
+	return append(set[:len(set):len(set)], other...).Normalize()
+}
+
+// Subtract returns the normalized set of the transactions in the set but not in other.
+func (set GTIDSet) Subtract(other GTIDSet) GTIDSet {
+	removed := make(map[SID][]GTIDInterval)
+	for _, us := range other.Normalize() {
+		removed[us.SID] = us.Intervals
+	}
+	result := set.Normalize()
+	n := 0
+	for _, us := range result {
+		if us.Intervals = subtractIntervals(us.Intervals, removed[us.SID]); len(us.Intervals) > 0 {
+			result[n] = us
+			n++
+		}
+	}
+	return result[:n]
+}
+
+// subtractIntervals returns the parts of the normalized intervals a which are not in the normalized intervals b.
+func subtractIntervals(a, b []GTIDInterval) []GTIDInterval {
+	var result []GTIDInterval
+	j := 0
+	for _, interval := range a {
+		for j < len(b) && b[j].Stop <= interval.Start {
+			j++
+		}
+		for k := j; k < len(b) && b[k].Start < interval.Stop; k++ {
+			if b[k].Start > interval.Start {
+				result = append(result, GTIDInterval{interval.Start, b[k].Start})
+			}
+			interval.Start = b[k].Stop
+		}
+		if interval.Start < interval.Stop {
+			result = append(result, interval)
+		}
+	}
+	return result
+}
+
+// Equal reports whether both sets contain the same transactions, regardless of their forms.
+func (set GTIDSet) Equal(other GTIDSet) bool {
+	a, b := set.Normalize(), other.Normalize()
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].SID != b[i].SID || len(a[i].Intervals) != len(b[i].Intervals) {
+			return false
+		}
+		for j := range a[i].Intervals {
+			if a[i].Intervals[j] != b[i].Intervals[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ParseGTIDSet parses a textual GTID set like `uuid:1-5:7-10,uuid2:1-3`,
+// the output of `Executed_Gtid_Set` which contains newlines is accepted as well.
+func ParseGTIDSet(s string) (GTIDSet, error) {
+	var set GTIDSet
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return set, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		us, err := parseUUIDSet(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, us)
+	}
+	return set, nil
+}
+
+func parseUUIDSet(s string) (*UUIDSet, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid GTID set %q: no interval found", s)
+	}
+
+	sid, err := parseSID(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	us := &UUIDSet{SID: sid}
+	for _, part := range parts[1:] {
+		interval, err := parseGTIDInterval(part)
+		if err != nil {
+			return nil, err
+		}
+		us.Intervals = append(us.Intervals, interval)
+	}
+	return us, nil
+}
+
+func parseSID(s string) (sid SID, err error) {
+	s = strings.TrimSpace(s)
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return sid, fmt.Errorf("invalid GTID server UUID %q", s)
+	}
+	_, err = hex.Decode(sid[:], []byte(strings.Replace(s, "-", "", -1)))
+	if err != nil {
+		return sid, fmt.Errorf("invalid GTID server UUID %q: %v", s, err)
+	}
+	return sid, nil
+}
+
+func parseGTIDInterval(s string) (GTIDInterval, error) {
+	var interval GTIDInterval
+	bounds := strings.Split(strings.TrimSpace(s), "-")
+	if len(bounds) > 2 {
+		return interval, fmt.Errorf("invalid GTID interval %q", s)
+	}
+
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil || start < 1 {
+		return interval, fmt.Errorf("invalid GTID interval %q", s)
+	}
+	stop := start
+	if len(bounds) == 2 {
+		stop, err = strconv.ParseInt(bounds[1], 10, 64)
+		if err != nil || stop < start {
+			return interval, fmt.Errorf("invalid GTID interval %q", s)
+		}
+	}
+	interval.Start, interval.Stop = start, stop+1
+	return interval, nil
+}
+
+// Encode serializes the GTID set into the binary form used by COM_BINLOG_DUMP_GTID and PreviousGtidsEvent:
+//
+//	n_sids (8 bytes)
+//	for each sid: sid (16 bytes), n_intervals (8 bytes), then start and stop (8 bytes each) of every interval
+func (set GTIDSet) Encode() []byte {
+	size := 8
+	for _, us := range set {
+		size += 16 + 8 + len(us.Intervals)*16
+	}
+	data := make([]byte, size)
+	pos := 0
+
+	binary.LittleEndian.PutUint64(data[pos:], uint64(len(set)))
+	pos += 8
+	for _, us := range set {
+		pos += copy(data[pos:], us.SID[:])
+		binary.LittleEndian.PutUint64(data[pos:], uint64(len(us.Intervals)))
+		pos += 8
+		for _, interval := range us.Intervals {
+			binary.LittleEndian.PutUint64(data[pos:], uint64(interval.Start))
+			pos += 8
+			binary.LittleEndian.PutUint64(data[pos:], uint64(interval.Stop))
+			pos += 8
+		}
+	}
+	return data
+}
+
+// DecodeGTIDSet decodes the binary form of the GTID set produced by Encode.
+func DecodeGTIDSet(data []byte) (GTIDSet, error) {
+	if len(data) < 8 {
+		return nil, ErrMalformPkt
+	}
+	sidCount := binary.LittleEndian.Uint64(data)
+	pos := 8
+
+	var set GTIDSet
+	for i := uint64(0); i < sidCount; i++ {
+		if len(data)-pos < 16+8 {
+			return nil, ErrMalformPkt
+		}
+		us := new(UUIDSet)
+		pos += copy(us.SID[:], data[pos:pos+16])
+		intervalCount := binary.LittleEndian.Uint64(data[pos:])
+		pos += 8
+		if uint64(len(data)-pos)/16 < intervalCount {
+			return nil, ErrMalformPkt
+		}
+		us.Intervals = make([]GTIDInterval, intervalCount)
+		for j := range us.Intervals {
+			us.Intervals[j].Start = int64(binary.LittleEndian.Uint64(data[pos:]))
+			us.Intervals[j].Stop = int64(binary.LittleEndian.Uint64(data[pos+8:]))
+			pos += 16
+		}
+		set = append(set, us)
+	}
+	return set, nil
+}
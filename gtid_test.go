@@ -0,0 +1,187 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseGTIDSet(t *testing.T) {
+	set, err := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,\n 4e11fa47-71ca-11e1-9e33-c80aa9429562:3-9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set) != 2 {
+		t.Fatalf("expected 2 uuid sets, got %d", len(set))
+	}
+	if len(set[0].Intervals) != 2 || set[0].Intervals[0] != (GTIDInterval{1, 6}) || set[0].Intervals[1] != (GTIDInterval{7, 8}) {
+		t.Errorf("unexpected intervals %v", set[0].Intervals)
+	}
+
+	data := set.Encode()
+	if len(data) != 8+2*(16+8)+3*16 {
+		t.Fatalf("unexpected encoded length %d", len(data))
+	}
+	if n := binary.LittleEndian.Uint64(data); n != 2 {
+		t.Errorf("expected 2 sids, got %d", n)
+	}
+	if !bytes.Equal(data[8:24], []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}) {
+		t.Errorf("unexpected sid %x", data[8:24])
+	}
+}
+
+func TestParseGTIDSetEmpty(t *testing.T) {
+	set, err := ParseGTIDSet("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := set.Encode(); !bytes.Equal(data, make([]byte, 8)) {
+		t.Errorf("unexpected encoded empty set %v", data)
+	}
+}
+
+func TestParseGTIDSetMalformed(t *testing.T) {
+	for _, s := range []string{
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562",
+		"3e11fa47-71ca-11e1-9e33:1-5",
+		"3e11fa47-71ca-11e1-9e33-c80aa942956z:1-5",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:5-1",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:0-1",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-2-3",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:a",
+	} {
+		if _, err := ParseGTIDSet(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
+
+func TestGTIDSetString(t *testing.T) {
+	s := "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,4e11fa47-71ca-11e1-9e33-c80aa9429562:3-9"
+	set, err := ParseGTIDSet(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.String() != s {
+		t.Errorf("expected %s, got %s", s, set.String())
+	}
+}
+
+func TestDecodeGTIDSet(t *testing.T) {
+	s := "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,4e11fa47-71ca-11e1-9e33-c80aa9429562:3-9"
+	set, err := ParseGTIDSet(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := set.Encode()
+	decoded, err := DecodeGTIDSet(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.String() != s {
+		t.Errorf("expected %s, got %s", s, decoded)
+	}
+
+	if _, err = DecodeGTIDSet(data[:len(data)-1]); err != ErrMalformPkt {
+		t.Errorf("expected ErrMalformPkt, got %v", err)
+	}
+}
+
+func TestGTIDSetAdd(t *testing.T) {
+	set, err := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:2-3:7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid := set[0].SID
+	for _, gno := range []int64{3, 4, 1, 9, 6, 5} {
+		set = set.Add(sid, gno)
+	}
+	var other SID
+	other[0] = 1
+	set = set.Add(other, 1)
+
+	expected := "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-7:9,01000000-0000-0000-0000-000000000000:1"
+	if set.String() != expected {
+		t.Errorf("expected %s, got %s", expected, set)
+	}
+}
+
+func TestGTIDSetContain(t *testing.T) {
+	set, err := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		other    string
+		expected bool
+	}{
+		{"", true},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:2-4:8", true},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-10", false},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:11", false},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1,3e11fa47-71ca-11e1-9e33-c80aa9429563:1", false},
+	}
+	for _, test := range tests {
+		other, err := ParseGTIDSet(test.other)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if set.Contain(other) != test.expected {
+			t.Errorf("expected %s contains %q to be %v", set, test.other, test.expected)
+		}
+	}
+}
+
+func TestGTIDSetAlgebra(t *testing.T) {
+	const a = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	const b = "01000000-0000-0000-0000-000000000000"
+	parse := func(s string) GTIDSet {
+		set, err := ParseGTIDSet(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return set
+	}
+
+	set := parse(a + ":7-9:1-3:4," + b + ":5," + a + ":8-12")
+	if s := set.Normalize().String(); s != b+":5,"+a+":1-4:7-12" {
+		t.Errorf("unexpected normalized set %s", s)
+	}
+	if s := set.String(); s != a+":7-9:1-3:4,"+b+":5,"+a+":8-12" {
+		t.Errorf("expected the set unchanged by Normalize, got %s", s)
+	}
+
+	tests := []struct {
+		x, y, union, subtract string
+	}{
+		{a + ":1-5", "", a + ":1-5", a + ":1-5"},
+		{a + ":1-5", a + ":6-8", a + ":1-8", a + ":1-5"},
+		{a + ":1-10", a + ":3-4:8", a + ":1-10", a + ":1-2:5-7:9-10"},
+		{a + ":1-10", a + ":1-10," + b + ":1", b + ":1," + a + ":1-10", ""},
+		{a + ":3-5:9", a + ":1-4:8-20", a + ":1-5:8-20", a + ":5"},
+	}
+	for _, test := range tests {
+		x, y := parse(test.x), parse(test.y)
+		if s := x.Union(y).String(); s != test.union {
+			t.Errorf("%q union %q: expected %s, got %s", test.x, test.y, test.union, s)
+		}
+		if s := x.Subtract(y).String(); s != test.subtract {
+			t.Errorf("%q subtract %q: expected %s, got %s", test.x, test.y, test.subtract, s)
+		}
+		if x.String() != test.x || y.String() != test.y {
+			t.Errorf("expected the operands unchanged, got %s and %s", x, y)
+		}
+	}
+
+	if !parse(a + ":1-3:4-5").Equal(parse(a + ":1-5")) {
+		t.Error("expected the sets equal")
+	}
+	if parse(a + ":1-5").Equal(parse(a + ":1-6")) {
+		t.Error("expected the sets not equal")
+	}
+	clone := set.Clone()
+	clone.Add(clone[0].SID, 100)
+	if set.String() == clone.String() {
+		t.Error("expected the clone independent of the set")
+	}
+}
@@ -0,0 +1,69 @@
+package mysql
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+)
+
+// LeveledLogger logs the messages at the levels, it's used by ConnWrapper and package binlog to report
+// the milestones and failures of the replication. The fields are passed as alternating keys and values
+// like `"file", "mysql-bin.000001", "pos", 4`.
+type LeveledLogger interface {
+	Debug(msg string, keysAndValues ...interface{})
+	Info(msg string, keysAndValues ...interface{})
+	Warn(msg string, keysAndValues ...interface{})
+	Error(msg string, keysAndValues ...interface{})
+}
+
+// NopLogger discards all the messages.
+var NopLogger LeveledLogger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, keysAndValues ...interface{}) {}
+func (nopLogger) Info(msg string, keysAndValues ...interface{})  {}
+func (nopLogger) Warn(msg string, keysAndValues ...interface{})  {}
+func (nopLogger) Error(msg string, keysAndValues ...interface{}) {}
+
+// NewStdLogger returns a LeveledLogger which prints the messages like `INFO msg key=value` through l,
+// the debug messages are discarded unless debug is true.
+func NewStdLogger(l *log.Logger, debug bool) LeveledLogger {
+	return &stdLogger{l: l, debug: debug}
+}
+
+type stdLogger struct {
+	l     *log.Logger
+	debug bool
+}
+
+func (s *stdLogger) Debug(msg string, keysAndValues ...interface{}) {
+	if s.debug {
+		s.print("DEBUG", msg, keysAndValues)
+	}
+}
+
+func (s *stdLogger) Info(msg string, keysAndValues ...interface{}) {
+	s.print("INFO", msg, keysAndValues)
+}
+
+func (s *stdLogger) Warn(msg string, keysAndValues ...interface{}) {
+	s.print("WARN", msg, keysAndValues)
+}
+
+func (s *stdLogger) Error(msg string, keysAndValues ...interface{}) {
+	s.print("ERROR", msg, keysAndValues)
+}
+
+func (s *stdLogger) print(level, msg string, keysAndValues []interface{}) {
+	var buf bytes.Buffer
+	buf.WriteString(level + " " + msg)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 < len(keysAndValues) {
+			fmt.Fprintf(&buf, " %v=%v", keysAndValues[i], keysAndValues[i+1])
+		} else {
+			fmt.Fprintf(&buf, " %v", keysAndValues[i])
+		}
+	}
+	s.l.Print(buf.String())
+}
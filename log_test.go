@@ -0,0 +1,20 @@
+package mysql
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewStdLogger(log.New(&buf, "", 0), false)
+	l.Debug("hidden")
+	l.Info("dumping binlog", "file", "mysql-bin.000001", "pos", 4)
+	l.Error("odd", "key")
+
+	expected := "INFO dumping binlog file=mysql-bin.000001 pos=4\nERROR odd key\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
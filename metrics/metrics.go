@@ -0,0 +1,194 @@
+// Package metrics collects the metrics of binlog.Streamer and exports them in the Prometheus text format.
+//
+// The package doesn't depend on the Prometheus client, Collector serves the metrics over HTTP by itself,
+// and the applications which use the client can register a collector converting Families to const metrics.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LightKool/mysql-go/binlog"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the decode latency histogram.
+var DefaultBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1}
+
+// Label is a label of a sample.
+type Label struct {
+	Name, Value string
+}
+
+// Sample is a value of a metric.
+type Sample struct {
+	Name   string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a metric with its samples, Type is "counter", "gauge" or "histogram".
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Collector implements binlog.Observer to collect the metrics of a Streamer:
+//
+//	binlog_events_total{type}           counter of the events received per type
+//	binlog_bytes_total                  counter of the bytes of the events received
+//	binlog_decode_duration_seconds      histogram of the decode latency
+//	binlog_queue_depth                  gauge of the events in the EventQueue
+//	binlog_replication_delay_seconds    gauge of the time between the last event is executed and received
+//	binlog_reconnects_total             counter of the attempts to reconnect
+type Collector struct {
+	// Queue is the EventQueue of the Streamer to report the depth of, optional.
+	Queue *binlog.EventQueue
+	// Buckets are the upper bounds of the decode latency histogram, default is DefaultBuckets.
+	Buckets []float64
+
+	mu         sync.Mutex
+	events     map[binlog.EventType]uint64
+	bytes      uint64
+	counts     []uint64
+	count      uint64
+	sum        float64
+	delay      float64
+	reconnects uint64
+	now        func() time.Time
+}
+
+// ObserveEvent implements binlog.Observer.
+func (c *Collector) ObserveEvent(typ binlog.EventType, timestamp uint32, size int, decodeTime time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.events == nil {
+		c.events = make(map[binlog.EventType]uint64)
+		c.counts = make([]uint64, len(c.buckets()))
+	}
+	c.events[typ]++
+	c.bytes += uint64(size)
+
+	seconds := decodeTime.Seconds()
+	for i, bound := range c.buckets() {
+		if seconds <= bound {
+			c.counts[i]++
+		}
+	}
+	c.count++
+	c.sum += seconds
+
+	if timestamp > 0 {
+		now := time.Now
+		if c.now != nil {
+			now = c.now
+		}
+		c.delay = now().Sub(time.Unix(int64(timestamp), 0)).Seconds()
+		if c.delay < 0 {
+			c.delay = 0
+		}
+	}
+}
+
+// ObserveReconnect implements binlog.Observer.
+func (c *Collector) ObserveReconnect() {
+	c.mu.Lock()
+	c.reconnects++
+	c.mu.Unlock()
+}
+
+func (c *Collector) buckets() []float64 {
+	if c.Buckets != nil {
+		return c.Buckets
+	}
+	return DefaultBuckets
+}
+
+// Families returns the current values of the metrics.
+func (c *Collector) Families() []*Family {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	events := &Family{Name: "binlog_events_total", Help: "Number of the binlog events received.", Type: "counter"}
+	types := make([]int, 0, len(c.events))
+	for typ := range c.events {
+		types = append(types, int(typ))
+	}
+	sort.Ints(types)
+	for _, typ := range types {
+		events.Samples = append(events.Samples, Sample{
+			Name:   events.Name,
+			Labels: []Label{{"type", binlog.EventType(typ).String()}},
+			Value:  float64(c.events[binlog.EventType(typ)]),
+		})
+	}
+
+	decode := &Family{Name: "binlog_decode_duration_seconds", Help: "Latency of decoding the binlog events.", Type: "histogram"}
+	for i, bound := range c.buckets() {
+		var count uint64
+		if c.counts != nil {
+			count = c.counts[i]
+		}
+		decode.Samples = append(decode.Samples, Sample{
+			Name:   decode.Name + "_bucket",
+			Labels: []Label{{"le", strconv.FormatFloat(bound, 'g', -1, 64)}},
+			Value:  float64(count),
+		})
+	}
+	decode.Samples = append(decode.Samples,
+		Sample{Name: decode.Name + "_bucket", Labels: []Label{{"le", "+Inf"}}, Value: float64(c.count)},
+		Sample{Name: decode.Name + "_sum", Value: c.sum},
+		Sample{Name: decode.Name + "_count", Value: float64(c.count)},
+	)
+
+	var depth float64
+	if c.Queue != nil {
+		depth = float64(c.Queue.Len())
+	}
+	return []*Family{
+		events,
+		single("binlog_bytes_total", "Number of the bytes of the binlog events received.", "counter", float64(c.bytes)),
+		decode,
+		single("binlog_queue_depth", "Number of the events in the event queue.", "gauge", depth),
+		single("binlog_replication_delay_seconds", "Time between the last event is executed on the master and received.", "gauge", c.delay),
+		single("binlog_reconnects_total", "Number of the attempts to reconnect to the master.", "counter", float64(c.reconnects)),
+	}
+}
+
+func single(name, help, typ string, value float64) *Family {
+	return &Family{Name: name, Help: help, Type: typ, Samples: []Sample{{Name: name, Value: value}}}
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	for _, f := range c.Families() {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", f.Name, f.Help, f.Name, f.Type)
+		for _, s := range f.Samples {
+			buf.WriteString(s.Name)
+			if len(s.Labels) > 0 {
+				labels := make([]string, len(s.Labels))
+				for i, l := range s.Labels {
+					labels[i] = l.Name + "=" + strconv.Quote(l.Value)
+				}
+				buf.WriteString("{" + strings.Join(labels, ",") + "}")
+			}
+			buf.WriteString(" " + strconv.FormatFloat(s.Value, 'g', -1, 64) + "\n")
+		}
+	}
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	c.WriteTo(w)
+}
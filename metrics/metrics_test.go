@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LightKool/mysql-go/binlog"
+)
+
+func TestCollector(t *testing.T) {
+	c := &Collector{Buckets: []float64{0.001, 0.01}, now: func() time.Time { return time.Unix(1000, 0) }}
+	c.ObserveEvent(binlog.QueryEventType, 990, 100, 500*time.Microsecond)
+	c.ObserveEvent(binlog.XidEventType, 995, 30, 5*time.Millisecond)
+	c.ObserveEvent(binlog.HeartbeatEventType, 0, 20, 0)
+	c.ObserveReconnect()
+
+	var buf bytes.Buffer
+	if _, err := c.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, expected := range []string{
+		"# TYPE binlog_events_total counter\n",
+		`binlog_events_total{type="QueryEvent"} 1` + "\n",
+		"binlog_bytes_total 150\n",
+		`binlog_decode_duration_seconds_bucket{le="0.001"} 2` + "\n",
+		`binlog_decode_duration_seconds_bucket{le="0.01"} 3` + "\n",
+		`binlog_decode_duration_seconds_bucket{le="+Inf"} 3` + "\n",
+		"binlog_decode_duration_seconds_count 3\n",
+		"binlog_queue_depth 0\n",
+		"binlog_replication_delay_seconds 5\n",
+		"binlog_reconnects_total 1\n",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected %q in:\n%s", expected, out)
+		}
+	}
+}
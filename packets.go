@@ -42,7 +42,11 @@ func (mc *mysqlConn) readPacket() ([]byte, error) {
 		pktLen := int(uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16)
 
 		// check packet sync [8 bit]
-		if data[3] != mc.sequence {
+		if mc.compress != nil {
+			// the plain packets are not checked in the compressed packets like the MySQL client does,
+			// since the server numbers them from the compressed ones
+			mc.sequence = data[3]
+		} else if data[3] != mc.sequence {
 			if data[3] > mc.sequence {
 				return nil, ErrPktSyncMul
 			}
@@ -109,6 +113,10 @@ func (mc *mysqlConn) writePacket(data []byte) error {
 			data[2] = byte(pktLen >> 16)
 			size = pktLen
 		}
+		if mc.compress != nil && mc.sequence != 0 {
+			// follow the compressed packets read, the sequence numbers are reset by the commands only
+			mc.sequence = mc.compress.seq
+		}
 		data[3] = mc.sequence
 
 		// Write packet
@@ -195,6 +203,9 @@ func (mc *mysqlConn) readInitPacket() ([]byte, error) {
 		// capability flags (upper 2 bytes) [2 bytes]
 		// length of auth-plugin-data [1 byte]
 		// reserved (all [00]) [10 bytes]
+		if len(data) >= pos+5 {
+			mc.flags |= clientFlag(binary.LittleEndian.Uint16(data[pos+3:pos+5])) << 16
+		}
 		pos += 1 + 2 + 2 + 1 + 10
 
 		// second part of the password cipher [mininum 13 bytes],
@@ -258,11 +269,19 @@ func (mc *mysqlConn) writeAuthPacket(cipher []byte) error {
 		clientFlags |= clientMultiStatements
 	}
 
+	// To enable the compression if the server supports it
+	clientFlags |= mc.compressionFlag()
+
 	// User Password
 	scrambleBuff := scramblePassword(cipher, []byte(mc.cfg.Passwd))
 
 	pktLen := 4 + 4 + 1 + 23 + len(mc.cfg.User) + 1 + 1 + len(scrambleBuff) + 21 + 1
 
+	// zstd compression level [1 byte]
+	if clientFlags&clientZstdCompressionAlgorithm != 0 {
+		pktLen++
+	}
+
 	// To specify a db name
 	if n := len(mc.cfg.DBName); n > 0 {
 		clientFlags |= clientConnectWithDB
@@ -344,6 +363,10 @@ func (mc *mysqlConn) writeAuthPacket(cipher []byte) error {
 	pos += copy(data[pos:], "mysql_native_password")
 	data[pos] = 0x00
 
+	if clientFlags&clientZstdCompressionAlgorithm != 0 {
+		data[pos+1] = zstdCompressionLevel
+	}
+
 	// Send Auth packet
 	return mc.writePacket(data)
 }
@@ -507,8 +530,13 @@ func (mc *mysqlConn) readResultOK() ([]byte, error) {
 				case "mysql_native_password":
 					// using mysql default authentication method
 					return cipher, ErrNativePassword
+				case "caching_sha2_password":
+					// using the default authentication method of MySQL 8.0
+					return cipher, errCachingSha2Password
+				case "sha256_password":
+					return cipher, errSha256Password
 				default:
-					return cipher, ErrUnknownPlugin
+					return cipher, &UnsupportedPluginError{Plugin: plugin}
 				}
 			}
 
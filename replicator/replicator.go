@@ -0,0 +1,431 @@
+// Package replicator applies the row changes of binlog transactions to another database through database/sql,
+// e.g. SQLite or PostgreSQL, which makes a heterogeneous replica of the MySQL tables.
+//
+// The changes are applied with parametrized INSERT, UPDATE and DELETE statements, the DDL is not replicated
+// so the target tables must be created beforehand. The column names of the source tables must be known,
+// i.e. the master writes them with binlog_row_metadata=FULL, or the DB of the EventDecoder is set.
+package replicator
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LightKool/mysql-go/binlog"
+	"github.com/LightKool/mysql-go/sink"
+)
+
+// Dialect is the SQL dialect of the target database.
+type Dialect int
+
+const (
+	// DialectMySQL quotes the identifiers with backticks and uses ? placeholders.
+	DialectMySQL Dialect = iota
+	// DialectSQLite quotes the identifiers with double quotes and uses ? placeholders.
+	DialectSQLite
+	// DialectPostgres quotes the identifiers with double quotes and uses $1, $2... placeholders.
+	DialectPostgres
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case DialectSQLite:
+		return "SQLite"
+	case DialectPostgres:
+		return "PostgreSQL"
+	default:
+		return "MySQL"
+	}
+}
+
+func (d Dialect) quote(name string) string {
+	if d == DialectMySQL {
+		return "`" + strings.Replace(name, "`", "``", -1) + "`"
+	}
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// placeholder returns the placeholder of the n-th argument from 1.
+func (d Dialect) placeholder(n int) string {
+	if d == DialectPostgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// ConflictPolicy controls what to do when a change doesn't match the target, e.g. the row to update is missing.
+type ConflictPolicy int
+
+const (
+	// ConflictFail rolls back the transaction with a *ConflictError for an update or delete of a missing row,
+	// or the error of the target for an insert of an existing row.
+	ConflictFail ConflictPolicy = iota
+	// ConflictIgnore skips the change: an insert of an existing row, an update or delete of a missing row.
+	ConflictIgnore
+	// ConflictOverwrite makes the target match the change: an insert replaces the existing row, an update of
+	// a missing row inserts it, and a delete of a missing row is skipped. It makes the transactions applied
+	// again after a crash idempotent.
+	ConflictOverwrite
+)
+
+// ConflictError is returned with ConflictFail for the update or delete of a missing row.
+type ConflictError struct {
+	Table string
+	Op    binlog.RowOp
+	// Key is the columns which identify the row.
+	Key map[string]interface{}
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %s: the row %v is missing", strings.ToLower(e.Op.String()), e.Table, e.Key)
+}
+
+// Replicator applies every transaction in a transaction of the target database, Run commits the transaction
+// to the reader only after the target commits it, which is at-least-once delivery.
+//
+// The conflicts are detected by the affected rows of UPDATE and DELETE, so the MySQL target needs
+// clientFoundRows=true in the DSN, otherwise an update which doesn't change the row is a conflict.
+type Replicator struct {
+	DB      *sql.DB
+	Dialect Dialect
+	// Table returns the target table of the source table, the changes of the table are skipped if it's empty.
+	// Default is the source table name without the database.
+	Table func(database, table string) string
+	// ConflictPolicy controls the changes which don't match the target, default is ConflictFail.
+	ConflictPolicy ConflictPolicy
+}
+
+// Run applies the transactions read from r until an error occurs or ctx is done.
+func (r *Replicator) Run(ctx context.Context, tr sink.Reader) error {
+	return sink.Run(ctx, tr, r.Apply)
+}
+
+// Apply applies the row changes of the transaction.
+func (r *Replicator) Apply(ctx context.Context, tx *binlog.Transaction) error {
+	var dbtx *sql.Tx
+	for _, e := range tx.RowsEvents() {
+		table := r.table(string(e.Table.Database), string(e.Table.TableName))
+		if table == "" {
+			continue
+		}
+		if e.Table.Columns() == nil {
+			return fmt.Errorf("column names of %s.%s are unknown", e.Table.Database, e.Table.TableName)
+		}
+		var err error
+		if dbtx == nil {
+			if dbtx, err = r.DB.BeginTx(ctx, nil); err != nil {
+				return err
+			}
+		}
+		if err = r.applyRows(ctx, dbtx, table, e); err != nil {
+			dbtx.Rollback()
+			return err
+		}
+	}
+	if dbtx != nil {
+		return dbtx.Commit()
+	}
+	return nil
+}
+
+func (r *Replicator) table(database, table string) string {
+	if r.Table != nil {
+		return r.Table(database, table)
+	}
+	return table
+}
+
+// applyRows applies the rows of the event, which are iterated one by one for the deferred rows.
+func (r *Replicator) applyRows(ctx context.Context, tx *sql.Tx, table string, e *binlog.RowsEvent) error {
+	op := e.Op()
+	pk := e.Table.PrimaryKeyColumns()
+	var before map[string]interface{}
+	it := e.Iter()
+	for it.Next() {
+		bitmap := e.Columns
+		if op == binlog.RowOpUpdate && it.Index()%2 == 1 {
+			bitmap = e.UpdatedColumns
+		}
+		row, err := rowMap(e.Table, bitmap, it.Row())
+		if err != nil {
+			return err
+		}
+		switch {
+		case op == binlog.RowOpInsert:
+			err = r.insert(ctx, tx, table, pk, row)
+		case op == binlog.RowOpDelete:
+			err = r.delete(ctx, tx, table, pk, row)
+		case before == nil:
+			// the before image of the update
+			before = row
+			continue
+		default:
+			err = r.update(ctx, tx, table, pk, binlog.RowChange{Before: before, After: row})
+			before = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// rowMap returns the row keyed by the column names with the values converted for database/sql.
+func rowMap(t *binlog.TableMapEvent, bitmap []byte, row []interface{}) (map[string]interface{}, error) {
+	m, index := make(map[string]interface{}, len(row)), 0
+	for i := 0; i < int(t.ColumnCount); i++ {
+		if bitmap[i/8]&(1<<uint(i%8)) == 0 {
+			continue
+		}
+		v := row[index]
+		if ns, ok := v.(int64); ok && isTimestamp(t, i) {
+			v = timestampValue(ns)
+		}
+		v, err := convertValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", t.ColumnName(i), err)
+		}
+		m[t.ColumnName(i)] = v
+		index++
+	}
+	return m, nil
+}
+
+// isTimestamp reports whether the i-th column is TIMESTAMP, whose values are decoded to UnixNano.
+func isTimestamp(t *binlog.TableMapEvent, i int) bool {
+	if i >= len(t.ColumnTypes) {
+		return false
+	}
+	typ := binlog.ColumnType(t.ColumnTypes[i])
+	return typ == binlog.ColumnTypeTimestamp || typ == binlog.ColumnTypeTimestampV2
+}
+
+// timestampValue converts the UnixNano of a TIMESTAMP value to time.Time, which the driver of the target
+// writes in its own time zone, e.g. the loc of the DSN of this driver. The zero value is the zero date.
+func timestampValue(ns int64) interface{} {
+	if ns == 0 {
+		return "0000-00-00 00:00:00"
+	}
+	return time.Unix(0, ns).UTC()
+}
+
+func (r *Replicator) insert(ctx context.Context, tx *sql.Tx, table string, pk []string, row map[string]interface{}) error {
+	switch r.ConflictPolicy {
+	case ConflictIgnore:
+		exists, err := r.exists(ctx, tx, table, key(pk, row))
+		if err != nil || exists {
+			return err
+		}
+	case ConflictOverwrite:
+		if _, err := r.exec(ctx, tx, r.deleteSQL(table, key(pk, row))); err != nil {
+			return err
+		}
+	}
+	_, err := r.exec(ctx, tx, r.insertSQL(table, row))
+	return err
+}
+
+func (r *Replicator) update(ctx context.Context, tx *sql.Tx, table string, pk []string, change binlog.RowChange) error {
+	where := key(pk, change.Before)
+	n, err := r.exec(ctx, tx, r.updateSQL(table, change.After, where))
+	if err != nil || n > 0 {
+		return err
+	}
+	switch r.ConflictPolicy {
+	case ConflictIgnore:
+		return nil
+	case ConflictOverwrite:
+		// the row is missing, the after image completed with the before image is inserted
+		_, err = r.exec(ctx, tx, r.insertSQL(table, change.Merged()))
+		return err
+	}
+	return &ConflictError{Table: table, Op: binlog.RowOpUpdate, Key: where}
+}
+
+func (r *Replicator) delete(ctx context.Context, tx *sql.Tx, table string, pk []string, row map[string]interface{}) error {
+	where := key(pk, row)
+	n, err := r.exec(ctx, tx, r.deleteSQL(table, where))
+	if err != nil || n > 0 || r.ConflictPolicy != ConflictFail {
+		return err
+	}
+	return &ConflictError{Table: table, Op: binlog.RowOpDelete, Key: where}
+}
+
+func (r *Replicator) exists(ctx context.Context, tx *sql.Tx, table string, where map[string]interface{}) (bool, error) {
+	s := &statement{dialect: r.Dialect}
+	s.WriteString("SELECT 1 FROM " + r.Dialect.quote(table))
+	s.where(where)
+	var one int
+	err := tx.QueryRowContext(ctx, s.String(), s.args...).Scan(&one)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// exec executes the statement and returns the affected rows.
+func (r *Replicator) exec(ctx context.Context, tx *sql.Tx, s *statement) (int64, error) {
+	res, err := tx.ExecContext(ctx, s.String(), s.args...)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", s, err)
+	}
+	return res.RowsAffected()
+}
+
+// key returns the primary key columns of the row, or all the columns if the primary key is unknown
+// or not fully included with binlog_row_image=MINIMAL or NOBLOB.
+func key(pk []string, row map[string]interface{}) map[string]interface{} {
+	if len(pk) == 0 {
+		return row
+	}
+	k := make(map[string]interface{}, len(pk))
+	for _, name := range pk {
+		v, ok := row[name]
+		if !ok {
+			return row
+		}
+		k[name] = v
+	}
+	return k
+}
+
+// statement is a SQL statement with the arguments of its placeholders.
+type statement struct {
+	bytes.Buffer
+	dialect Dialect
+	args    []interface{}
+}
+
+func (s *statement) arg(v interface{}) string {
+	s.args = append(s.args, v)
+	return s.dialect.placeholder(len(s.args))
+}
+
+// where writes the WHERE clause matching the columns, the columns are sorted so that the statements
+// of the same table are the same.
+func (s *statement) where(columns map[string]interface{}) {
+	s.WriteString(" WHERE ")
+	for i, name := range sortedNames(columns) {
+		if i > 0 {
+			s.WriteString(" AND ")
+		}
+		if v := columns[name]; v == nil {
+			s.WriteString(s.dialect.quote(name) + " IS NULL")
+		} else {
+			s.WriteString(s.dialect.quote(name) + " = " + s.arg(v))
+		}
+	}
+}
+
+func (r *Replicator) insertSQL(table string, row map[string]interface{}) *statement {
+	s := &statement{dialect: r.Dialect}
+	names := sortedNames(row)
+	quoted, placeholders := make([]string, len(names)), make([]string, len(names))
+	for i, name := range names {
+		quoted[i], placeholders[i] = r.Dialect.quote(name), s.arg(row[name])
+	}
+	s.WriteString("INSERT INTO " + r.Dialect.quote(table) + " (" + strings.Join(quoted, ", ") +
+		") VALUES (" + strings.Join(placeholders, ", ") + ")")
+	return s
+}
+
+func (r *Replicator) updateSQL(table string, row, where map[string]interface{}) *statement {
+	s := &statement{dialect: r.Dialect}
+	s.WriteString("UPDATE " + r.Dialect.quote(table) + " SET ")
+	for i, name := range sortedNames(row) {
+		if i > 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString(r.Dialect.quote(name) + " = " + s.arg(row[name]))
+	}
+	s.where(where)
+	return s
+}
+
+func (r *Replicator) deleteSQL(table string, where map[string]interface{}) *statement {
+	s := &statement{dialect: r.Dialect}
+	s.WriteString("DELETE FROM " + r.Dialect.quote(table))
+	s.where(where)
+	return s
+}
+
+func sortedNames(row map[string]interface{}) []string {
+	names := make([]string, 0, len(row))
+	for name := range row {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// convertValue converts the decoded value to a value supported by database/sql.
+func convertValue(v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case nil, int64, float64, bool, string, []byte, time.Time:
+		return v, nil
+	case int:
+		return int64(value), nil
+	case int8:
+		return int64(value), nil
+	case int16:
+		return int64(value), nil
+	case int32:
+		return int64(value), nil
+	case uint8:
+		return int64(value), nil
+	case uint16:
+		return int64(value), nil
+	case uint32:
+		return int64(value), nil
+	case uint64:
+		if value > math.MaxInt64 {
+			return strconv.FormatUint(value, 10), nil
+		}
+		return int64(value), nil
+	case float32:
+		return float64(value), nil
+	case time.Duration:
+		return formatDuration(value), nil
+	case *big.Rat:
+		return value.FloatString(ratScale(value)), nil
+	case fmt.Stringer:
+		return value.String(), nil
+	default:
+		// the parsed JSON values
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+}
+
+// formatDuration formats the TIME value like MySQL, e.g. -838:59:59.000000.
+func formatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	return fmt.Sprintf("%s%02d:%02d:%02d.%06d", sign, d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second,
+		d%time.Second/time.Microsecond)
+}
+
+// ratScale returns the digits after the decimal point to format the DECIMAL value exactly, whose scale is up to 30.
+func ratScale(r *big.Rat) int {
+	scale := 0
+	for pow, ten, mod := big.NewInt(1), big.NewInt(10), new(big.Int); scale < 30; scale++ {
+		if mod.Mod(pow, r.Denom()).Sign() == 0 {
+			break
+		}
+		pow.Mul(pow, ten)
+	}
+	return scale
+}
@@ -0,0 +1,216 @@
+package replicator
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LightKool/mysql-go/binlog"
+	"github.com/LightKool/mysql-go/sink/sinktest"
+)
+
+// target is the fake database which records the statements, the UPDATE and DELETE statements affect
+// the rows of affected, and the SELECT statements find a row if exists.
+type target struct {
+	affected   int64
+	exists     bool
+	statements []string
+	args       [][]driver.Value
+	commits    int
+	rollbacks  int
+}
+
+var targets = make(map[string]*target)
+
+func init() {
+	sql.Register("replicatortest", testDriver{})
+}
+
+type testDriver struct{}
+
+func (testDriver) Open(name string) (driver.Conn, error) {
+	return &testConn{t: targets[name]}, nil
+}
+
+type testConn struct {
+	t *target
+}
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) {
+	return &testStmt{t: c.t, query: query}, nil
+}
+
+func (c *testConn) Close() error              { return nil }
+func (c *testConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *testConn) Commit() error             { c.t.commits++; return nil }
+func (c *testConn) Rollback() error           { c.t.rollbacks++; return nil }
+
+type testStmt struct {
+	t     *target
+	query string
+}
+
+func (s *testStmt) Close() error  { return nil }
+func (s *testStmt) NumInput() int { return -1 }
+
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.t.statements, s.t.args = append(s.t.statements, s.query), append(s.t.args, args)
+	if strings.HasPrefix(s.query, "INSERT") {
+		return driver.RowsAffected(1), nil
+	}
+	return driver.RowsAffected(s.t.affected), nil
+}
+
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.t.statements, s.t.args = append(s.t.statements, s.query), append(s.t.args, args)
+	return &testRows{n: map[bool]int{true: 1}[s.t.exists]}, nil
+}
+
+type testRows struct {
+	n int
+}
+
+func (r *testRows) Columns() []string { return []string{"1"} }
+func (r *testRows) Close() error      { return nil }
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if r.n == 0 {
+		return io.EOF
+	}
+	r.n--
+	dest[0] = int64(1)
+	return nil
+}
+
+func newTarget(t *testing.T, name string) (*target, *sql.DB) {
+	targets[name] = new(target)
+	db, err := sql.Open("replicatortest", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return targets[name], db
+}
+
+func verbs(statements []string) string {
+	var verbs []string
+	for _, s := range statements {
+		verbs = append(verbs, s[:strings.IndexByte(s, ' ')])
+	}
+	return strings.Join(verbs, " ")
+}
+
+func TestApply(t *testing.T) {
+	tx := sinktest.FixtureTransaction(t)
+	target, db := newTarget(t, "apply")
+	defer db.Close()
+	target.affected = 1
+	r := &Replicator{DB: db, Dialect: DialectSQLite}
+	reader := &sinktest.Reader{Transactions: []*binlog.Transaction{tx}}
+	if err := r.Run(context.Background(), reader); err != io.EOF {
+		t.Fatal(err)
+	}
+	if v := verbs(target.statements); v != "INSERT INSERT UPDATE DELETE" {
+		t.Fatalf("unexpected statements %s", v)
+	}
+	if s := target.statements[2]; !strings.HasPrefix(s, `UPDATE "all_types" SET "ca" = ?, "cb" = ?`) || !strings.HasSuffix(s, ` WHERE "ca" = ?`) {
+		t.Errorf("unexpected UPDATE %s", s)
+	}
+	args := target.args[2]
+	if len(args) != 23 || args[0] != int64(1) || args[22] != int64(-128) {
+		t.Errorf("unexpected UPDATE arguments %v", args)
+	}
+	if s := target.statements[3]; s != `DELETE FROM "all_types" WHERE "ca" = ?` || target.args[3][0] != int64(0) {
+		t.Errorf("unexpected DELETE %s %v", s, target.args[3])
+	}
+	if target.commits != 1 || len(reader.Committed) != 1 || reader.Committed[0] != tx.Position {
+		t.Errorf("expected the transaction committed to both, got %d commits and %v", target.commits, reader.Committed)
+	}
+}
+
+func TestApplyConflicts(t *testing.T) {
+	tx := sinktest.FixtureTransaction(t)
+	target, db := newTarget(t, "conflicts")
+	defer db.Close()
+
+	r := &Replicator{DB: db, Dialect: DialectPostgres}
+	err := r.Apply(context.Background(), tx)
+	if e, ok := err.(*ConflictError); !ok || e.Op != binlog.RowOpUpdate || e.Table != "all_types" || e.Key["ca"] != int64(-128) {
+		t.Fatalf("expected *ConflictError of the update, got %v", err)
+	}
+	if target.rollbacks != 1 || !strings.HasSuffix(target.statements[2], ` WHERE "ca" = $23`) {
+		t.Errorf("expected the transaction rolled back, got %v", target.statements)
+	}
+
+	target.statements = nil
+	r.ConflictPolicy = ConflictIgnore
+	if err = r.Apply(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if v := verbs(target.statements); v != "SELECT INSERT SELECT INSERT UPDATE DELETE" {
+		t.Errorf("unexpected statements %s", v)
+	}
+	target.statements, target.exists = nil, true
+	if err = r.Apply(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if v := verbs(target.statements); v != "SELECT SELECT UPDATE DELETE" {
+		t.Errorf("unexpected statements %s", v)
+	}
+
+	target.statements = nil
+	r.ConflictPolicy = ConflictOverwrite
+	if err = r.Apply(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if v := verbs(target.statements); v != "DELETE INSERT DELETE INSERT UPDATE INSERT DELETE" {
+		t.Errorf("unexpected statements %s", v)
+	}
+}
+
+func TestApplyTableMapping(t *testing.T) {
+	tx := sinktest.FixtureTransaction(t)
+	target, db := newTarget(t, "mapping")
+	defer db.Close()
+	target.affected = 1
+
+	r := &Replicator{DB: db, Dialect: DialectMySQL, Table: func(database, table string) string { return "" }}
+	if err := r.Apply(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if len(target.statements) != 0 || target.commits != 0 {
+		t.Errorf("expected the table skipped, got %v", target.statements)
+	}
+
+	r.Table = func(database, table string) string { return database + "_" + table }
+	if err := r.Apply(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	if s := target.statements[0]; !strings.HasPrefix(s, "INSERT INTO `test_all_types` (`ca`, `cb`") {
+		t.Errorf("unexpected INSERT %s", s)
+	}
+}
+
+func TestRowMapTimestamp(t *testing.T) {
+	table := &binlog.TableMapEvent{
+		ColumnCount: 3,
+		ColumnTypes: []byte{byte(binlog.ColumnTypeLongLong), byte(binlog.ColumnTypeTimestampV2), byte(binlog.ColumnTypeTimestamp)},
+	}
+	ts := time.Date(2024, 1, 2, 3, 4, 5, 678000000, time.UTC)
+	m, err := rowMap(table, []byte{7}, []interface{}{ts.UnixNano(), ts.UnixNano(), int64(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["@1"] != ts.UnixNano() {
+		t.Errorf("unexpected BIGINT value %v", m["@1"])
+	}
+	if v, ok := m["@2"].(time.Time); !ok || !v.Equal(ts) {
+		t.Errorf("expected TIMESTAMP value %v, got %#v", ts, m["@2"])
+	}
+	if m["@3"] != "0000-00-00 00:00:00" {
+		t.Errorf("expected the zero date, got %#v", m["@3"])
+	}
+}
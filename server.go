@@ -0,0 +1,283 @@
+package mysql
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"time"
+)
+
+// The commands served by ServerConn.
+const (
+	ComQuit           = comQuit
+	ComInitDB         = comInitDB
+	ComQuery          = comQuery
+	ComPing           = comPing
+	ComBinlogDump     = comBinlogDump
+	ComRegisterSlave  = comRegisterSlave
+	ComBinlogDumpGTID = comBinlogDumpGTID
+)
+
+// BinlogDumpNonBlock is the flag of COM_BINLOG_DUMP asking the master to send an EOF packet
+// instead of waiting for the new events at the end of the binlog.
+const BinlogDumpNonBlock = 0x01
+
+const (
+	// the capabilities announced by ServerConn, TLS and compression are not supported
+	serverCapabilities = clientLongPassword | clientLongFlag | clientConnectWithDB | clientProtocol41 |
+		clientTransactions | clientSecureConn | clientPluginAuth
+	serverStatusAutocommit = 0x0002
+	nativePasswordPlugin   = "mysql_native_password"
+	utf8GeneralCI          = 33
+)
+
+// ServerConn is the server side of a connection speaking the MySQL protocol, it's used by binlog.Server
+// to serve the replicas. Only the commands needed by the replication are supported.
+type ServerConn struct {
+	*mysqlConn
+	// User is the user name sent by the client in the handshake.
+	User string
+}
+
+// NewServerConn wraps the connection accepted from a client.
+func NewServerConn(conn net.Conn) *ServerConn {
+	return &ServerConn{mysqlConn: &mysqlConn{
+		buf:              newBuffer(conn),
+		netConn:          conn,
+		maxAllowedPacket: maxPacketSize,
+		maxWriteSize:     maxPacketSize - 1,
+		closech:          make(chan struct{}),
+	}}
+}
+
+// Handshake authenticates the client by mysql_native_password. password returns the password of the user,
+// or false if the user is unknown. The client is switched to mysql_native_password if it uses another plugin.
+func (sc *ServerConn) Handshake(serverVersion string, connectionID uint32, password func(user string) (string, bool)) error {
+	scramble, err := newScramble()
+	if err != nil {
+		return err
+	}
+	if err = sc.writeHandshake(serverVersion, connectionID, scramble); err != nil {
+		return err
+	}
+	user, response, plugin, err := sc.readHandshakeResponse()
+	if err != nil {
+		return err
+	}
+
+	if plugin != nativePasswordPlugin {
+		if response, err = sc.switchAuthPlugin(nativePasswordPlugin, scramble); err != nil {
+			return err
+		}
+	}
+
+	passwd, ok := password(user)
+	if !ok || subtle.ConstantTimeCompare(response, scramblePassword(scramble, []byte(passwd))) != 1 {
+		sc.WriteError(1045, "28000", fmt.Sprintf("Access denied for user '%s'", user))
+		return fmt.Errorf("access denied for user %q", user)
+	}
+	sc.User = user
+	return sc.WriteOK()
+}
+
+// newScramble returns the random scramble of 20 bytes without NUL.
+func newScramble() ([]byte, error) {
+	scramble := make([]byte, 20)
+	if _, err := rand.Read(scramble); err != nil {
+		return nil, err
+	}
+	for i, b := range scramble {
+		// the scramble is a NUL terminated string
+		if b == 0 {
+			scramble[i] = 1
+		}
+	}
+	return scramble, nil
+}
+
+// writeHandshake writes the initial handshake packet v10.
+func (sc *ServerConn) writeHandshake(serverVersion string, connectionID uint32, scramble []byte) error {
+	p := NewPacket(make([]byte, 4, 128))
+	p.WriteUintBySize(1, uint64(minProtocolVersion))
+	p.WriteZeroTerminated(serverVersion)
+	p.WriteUintBySize(4, uint64(connectionID))
+	p.Write(scramble[:8])
+	p.WriteUintBySize(1, 0)
+	p.WriteUintBySize(2, uint64(serverCapabilities&0xffff))
+	p.WriteUintBySize(1, utf8GeneralCI)
+	p.WriteUintBySize(2, serverStatusAutocommit)
+	p.WriteUintBySize(2, uint64(serverCapabilities>>16))
+	p.WriteUintBySize(1, uint64(len(scramble)+1))
+	p.Write(make([]byte, 10))
+	p.WriteZeroTerminated(string(scramble[8:]))
+	p.WriteZeroTerminated(nativePasswordPlugin)
+	sc.sequence = 0
+	return sc.writePacket(p.Raw())
+}
+
+// readHandshakeResponse reads the HandshakeResponse41 packet, response is copied out of the read buffer.
+func (sc *ServerConn) readHandshakeResponse() (user string, response []byte, plugin string, err error) {
+	data, err := sc.readPacket()
+	if err != nil {
+		return "", nil, "", err
+	}
+	// capability flags, max packet size, charset, filler
+	r := NewPacket(data)
+	flags := clientFlag(r.ReadUintBySize(4))
+	r.Skip(4 + 1 + 23)
+	if flags&clientProtocol41 == 0 || r.Err() != nil {
+		return "", nil, "", ErrOldProtocol
+	}
+	user = r.readZeroTerminated()
+	if flags&clientPluginAuthLenEncClientData != 0 {
+		response, _ = r.ReadPackedString()
+	} else {
+		response = r.Read(int(r.ReadUintBySize(1)))
+	}
+	if flags&clientConnectWithDB != 0 {
+		r.readZeroTerminated()
+	}
+	plugin = nativePasswordPlugin
+	if flags&clientPluginAuth != 0 && !r.EOF() {
+		plugin = r.readZeroTerminated()
+	}
+	if r.Err() != nil {
+		return "", nil, "", r.Err()
+	}
+	return user, append([]byte(nil), response...), plugin, nil
+}
+
+// switchAuthPlugin asks the client to authenticate by the plugin with the scramble and returns the response.
+func (sc *ServerConn) switchAuthPlugin(plugin string, scramble []byte) ([]byte, error) {
+	p := NewPacket(make([]byte, 4, 64))
+	p.WriteUintBySize(1, uint64(iEOF))
+	p.WriteZeroTerminated(plugin)
+	p.WriteZeroTerminated(string(scramble))
+	if err := sc.writePacket(p.Raw()); err != nil {
+		return nil, err
+	}
+	data, err := sc.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// ReadCommand reads the next command from the client, data is valid until the next read.
+func (sc *ServerConn) ReadCommand() (command byte, data []byte, err error) {
+	sc.sequence = 0
+	if data, err = sc.readPacket(); err != nil {
+		return 0, nil, err
+	}
+	if len(data) == 0 {
+		return 0, nil, ErrMalformPkt
+	}
+	return data[0], data[1:], nil
+}
+
+// WriteOK writes an OK packet.
+func (sc *ServerConn) WriteOK() error {
+	p := NewPacket(make([]byte, 4, 11))
+	p.WriteUintBySize(1, uint64(iOK))
+	// affected rows, last insert id
+	p.WriteLengthEncodedInteger(0)
+	p.WriteLengthEncodedInteger(0)
+	p.WriteUintBySize(2, serverStatusAutocommit)
+	// warnings
+	p.WriteUintBySize(2, 0)
+	return sc.writePacket(p.Raw())
+}
+
+// WriteError writes an ERR packet with the error code, the SQL state and the message.
+func (sc *ServerConn) WriteError(code uint16, state, message string) error {
+	p := NewPacket(make([]byte, 4, 4+9+len(message)))
+	p.WriteUintBySize(1, uint64(iERR))
+	p.WriteUintBySize(2, uint64(code))
+	p.Write([]byte("#" + state))
+	p.Write([]byte(message))
+	return sc.writePacket(p.Raw())
+}
+
+// WriteEOF writes an EOF packet.
+func (sc *ServerConn) WriteEOF() error {
+	p := NewPacket(make([]byte, 4, 9))
+	p.WriteUintBySize(1, uint64(iEOF))
+	// warnings
+	p.WriteUintBySize(2, 0)
+	p.WriteUintBySize(2, serverStatusAutocommit)
+	return sc.writePacket(p.Raw())
+}
+
+// WriteResultSet writes a text result set of the columns, the values are strings and nil for NULL.
+func (sc *ServerConn) WriteResultSet(columns []string, rows [][]interface{}) error {
+	p := NewPacket(make([]byte, 4, 13))
+	p.WriteLengthEncodedInteger(uint64(len(columns)))
+	if err := sc.writePacket(p.Raw()); err != nil {
+		return err
+	}
+	for _, name := range columns {
+		p = NewPacket(make([]byte, 4, 4+24+len(name)))
+		p.WriteLengthEncodedString([]byte("def"))
+		// schema, table, original table
+		p.WriteLengthEncodedString(nil)
+		p.WriteLengthEncodedString(nil)
+		p.WriteLengthEncodedString(nil)
+		p.WriteLengthEncodedString([]byte(name))
+		p.WriteLengthEncodedString([]byte(name))
+		// length of the fixed fields
+		p.WriteLengthEncodedInteger(0x0c)
+		p.WriteUintBySize(2, utf8GeneralCI)
+		// column length, type, flags, decimals and filler
+		p.WriteUintBySize(4, 1024)
+		p.WriteUintBySize(1, uint64(fieldTypeVarString))
+		p.WriteUintBySize(2, 0)
+		p.WriteUintBySize(1, 0)
+		p.WriteUintBySize(2, 0)
+		if err := sc.writePacket(p.Raw()); err != nil {
+			return err
+		}
+	}
+	if err := sc.WriteEOF(); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		p = NewPacket(make([]byte, 4, 64))
+		for _, v := range row {
+			if v == nil {
+				p.WriteUintBySize(1, 0xfb)
+			} else {
+				p.WriteLengthEncodedString([]byte(fmt.Sprint(v)))
+			}
+		}
+		if err := sc.writePacket(p.Raw()); err != nil {
+			return err
+		}
+	}
+	return sc.WriteEOF()
+}
+
+// WriteEvent writes a binlog event to the client which is dumping the binlog.
+// The semi-sync header is prepended without requesting the ACK if semiSync is true.
+func (sc *ServerConn) WriteEvent(event []byte, semiSync bool) error {
+	p := NewPacket(make([]byte, 4, 4+3+len(event)))
+	p.WriteUintBySize(1, uint64(iOK))
+	if semiSync {
+		p.WriteUintBySize(1, uint64(semiSyncIndicator))
+		p.WriteUintBySize(1, 0)
+	}
+	p.Write(event)
+	return sc.writePacket(p.Raw())
+}
+
+// SetWriteTimeout sets the timeout of every packet written to the client, like net_write_timeout of MySQL.
+// The write fails and the connection is closed once the client doesn't read for the timeout.
+func (sc *ServerConn) SetWriteTimeout(timeout time.Duration) {
+	sc.writeTimeout = timeout
+}
+
+// Close closes the connection without sending COM_QUIT.
+func (sc *ServerConn) Close() error {
+	sc.cleanup()
+	return nil
+}
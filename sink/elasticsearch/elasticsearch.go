@@ -0,0 +1,256 @@
+// Package elasticsearch indexes the row changes of binlog transactions into Elasticsearch with the bulk API.
+//
+// The rows are indexed as documents whose ids are the primary key values, so the tables without primary key
+// are not supported. The package talks to Elasticsearch with HTTPClient, or any Client of the applications.
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/LightKool/mysql-go/binlog"
+	"github.com/LightKool/mysql-go/sink"
+)
+
+// the operations of the bulk API
+const (
+	OpIndex  = "index"
+	OpUpdate = "update"
+	OpDelete = "delete"
+)
+
+// Action is an action of the bulk API.
+type Action struct {
+	// Op is OpIndex, OpUpdate or OpDelete.
+	Op    string
+	Index string
+	ID    string
+	// Doc is the document of OpIndex, or the fields to update of OpUpdate which creates the document
+	// if it's missing. It's nil for OpDelete.
+	Doc map[string]interface{}
+}
+
+// Client executes the bulk actions.
+type Client interface {
+	// Bulk executes the actions and returns after all of them succeed.
+	Bulk(ctx context.Context, actions []*Action) error
+}
+
+// Rule maps a table to the index.
+type Rule struct {
+	Database string
+	// Table is the table name, or "*" for all the tables of the database.
+	Table string
+	Index string
+	// Fields renames the columns to the fields of the documents, the other columns are kept as they are.
+	Fields map[string]string
+	// Exclude are the columns left out of the documents.
+	Exclude []string
+}
+
+// Sink indexes the row changes of every transaction, the transaction is committed by Run only after
+// the bulk actions succeed, which is at-least-once delivery.
+type Sink struct {
+	Client Client
+	// Rules map the tables to the indexes, the first matching rule applies and the changes of the tables
+	// matching no rule are skipped. All the tables are indexed into "<database>.<table>" if it's empty.
+	Rules []Rule
+}
+
+// Run indexes the transactions read from r until an error occurs or ctx is done.
+func (s *Sink) Run(ctx context.Context, r sink.Reader) error {
+	return sink.Run(ctx, r, s.Index)
+}
+
+// Index executes the bulk actions of the row changes of the transaction.
+func (s *Sink) Index(ctx context.Context, tx *binlog.Transaction) error {
+	actions, err := s.actions(tx)
+	if err != nil {
+		return err
+	}
+	if len(actions) > 0 {
+		if err = s.Client.Bulk(ctx, actions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Sink) actions(tx *binlog.Transaction) ([]*Action, error) {
+	var actions []*Action
+	for _, e := range tx.RowsEvents() {
+		database, table := string(e.Table.Database), string(e.Table.TableName)
+		rule := s.rule(database, table)
+		if rule == nil {
+			continue
+		}
+		pk := e.Table.PrimaryKeyColumns()
+		if len(pk) == 0 {
+			return nil, fmt.Errorf("primary key of %s.%s is unknown", database, table)
+		}
+		for _, change := range e.RowChanges() {
+			var before, after string
+			if change.Before != nil {
+				before = documentID(pk, change.Before)
+			}
+			if change.After != nil {
+				// the primary key is taken from the before image if it's not in the minimal after image
+				after = documentID(pk, change.Merged())
+			}
+			switch {
+			case change.After == nil:
+				actions = append(actions, &Action{Op: OpDelete, Index: rule.Index, ID: before})
+			case change.Before == nil:
+				actions = append(actions, &Action{Op: OpIndex, Index: rule.Index, ID: after, Doc: rule.document(change.After)})
+			case before != after:
+				// the primary key is changed, the document is moved to the new id
+				actions = append(actions, &Action{Op: OpDelete, Index: rule.Index, ID: before},
+					&Action{Op: OpIndex, Index: rule.Index, ID: after, Doc: rule.document(change.Merged())})
+			default:
+				actions = append(actions, &Action{Op: OpUpdate, Index: rule.Index, ID: after, Doc: rule.document(change.After)})
+			}
+		}
+	}
+	return actions, nil
+}
+
+func (s *Sink) rule(database, table string) *Rule {
+	if len(s.Rules) == 0 {
+		return &Rule{Database: database, Table: table, Index: strings.ToLower(database + "." + table)}
+	}
+	for i := range s.Rules {
+		r := &s.Rules[i]
+		if r.Database == database && (r.Table == table || r.Table == "*") {
+			return r
+		}
+	}
+	return nil
+}
+
+// document returns the document of the row with the fields renamed.
+func (r *Rule) document(row map[string]interface{}) map[string]interface{} {
+	doc := make(map[string]interface{}, len(row))
+	for name, v := range row {
+		if r.excluded(name) {
+			continue
+		}
+		if field, ok := r.Fields[name]; ok {
+			name = field
+		}
+		doc[name] = v
+	}
+	return doc
+}
+
+func (r *Rule) excluded(column string) bool {
+	for _, name := range r.Exclude {
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
+// documentID returns the primary key values of the row joined with ":", like go-mysql-elasticsearch.
+func documentID(pk []string, row map[string]interface{}) string {
+	values := make([]string, len(pk))
+	for i, name := range pk {
+		switch v := row[name].(type) {
+		case []byte:
+			values[i] = string(v)
+		default:
+			values[i] = fmt.Sprint(v)
+		}
+	}
+	return strings.Join(values, ":")
+}
+
+// HTTPClient executes the bulk actions with the REST API of Elasticsearch.
+type HTTPClient struct {
+	// URL is the address of the cluster, e.g. http://127.0.0.1:9200.
+	URL                string
+	Username, Password string
+	// Client sends the requests, default is http.DefaultClient.
+	Client *http.Client
+}
+
+type bulkMeta struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string          `json:"_id"`
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// Bulk sends the actions in a request of the bulk API, it fails if any of them fails except that
+// the document to delete is missing.
+func (c *HTTPClient) Bulk(ctx context.Context, actions []*Action) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, a := range actions {
+		if err := enc.Encode(map[string]bulkMeta{a.Op: {Index: a.Index, ID: a.ID}}); err != nil {
+			return err
+		}
+		var err error
+		switch a.Op {
+		case OpIndex:
+			err = enc.Encode(a.Doc)
+		case OpUpdate:
+			err = enc.Encode(map[string]interface{}{"doc": a.Doc, "doc_as_upsert": true})
+		}
+		if err != nil {
+			return fmt.Errorf("document %s of %s: %v", a.ID, a.Index, err)
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(c.URL, "/")+"/_bulk", &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("bulk request: %s: %s", resp.Status, msg)
+	}
+
+	var result bulkResponse
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("bulk response: %v", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+	for _, item := range result.Items {
+		for op, r := range item {
+			if r.Status < 300 || op == OpDelete && r.Status == http.StatusNotFound {
+				continue
+			}
+			return fmt.Errorf("bulk %s of document %s: %d %s", op, r.ID, r.Status, r.Error)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LightKool/mysql-go/sink/sinktest"
+)
+
+func TestSinkActions(t *testing.T) {
+	tx := sinktest.FixtureTransaction(t)
+	sink := &Sink{}
+	actions, err := sink.actions(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ops []string
+	for _, a := range actions {
+		if a.Index != "test.all_types" {
+			t.Errorf("unexpected index %s", a.Index)
+		}
+		ops = append(ops, a.Op+" "+a.ID)
+	}
+	if s := strings.Join(ops, ", "); s != "index -128, index 0, delete -128, index 1, delete 0" {
+		t.Errorf("unexpected actions %s", s)
+	}
+	if doc := actions[3].Doc; doc["cn"] != "xyz" || len(doc) != 22 {
+		t.Errorf("unexpected document %v", doc)
+	}
+
+	sink.Rules = []Rule{{Database: "test", Table: "*", Index: "types", Fields: map[string]string{"cn": "name"}, Exclude: []string{"cu", "cv"}}}
+	if actions, err = sink.actions(tx); err != nil {
+		t.Fatal(err)
+	}
+	if doc := actions[0].Doc; actions[0].Index != "types" || doc["name"] != "abc" || len(doc) != 20 {
+		t.Errorf("unexpected document %v of %s", doc, actions[0].Index)
+	}
+	sink.Rules = []Rule{{Database: "other", Table: "*", Index: "other"}}
+	if actions, err = sink.actions(tx); err != nil || len(actions) != 0 {
+		t.Errorf("expected the table skipped, got %v, %v", actions, err)
+	}
+}
+
+func TestHTTPClientBulk(t *testing.T) {
+	var lines []string
+	var failed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		lines = nil
+		for s := bufio.NewScanner(r.Body); s.Scan(); {
+			lines = append(lines, s.Text())
+		}
+		status := 200
+		if failed {
+			status = 409
+		}
+		fmt.Fprintf(w, `{"errors":%v,"items":[{"delete":{"_id":"2","status":404}},{"update":{"_id":"1","status":%d,"error":{"type":"version_conflict_engine_exception"}}}]}`,
+			failed, status)
+	}))
+	defer server.Close()
+
+	c := &HTTPClient{URL: server.URL + "/"}
+	actions := []*Action{
+		{Op: OpDelete, Index: "t", ID: "2"},
+		{Op: OpUpdate, Index: "t", ID: "1", Doc: map[string]interface{}{"v": "a"}},
+	}
+	if err := c.Bulk(context.Background(), actions); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`{"delete":{"_index":"t","_id":"2"}}`, `{"update":{"_index":"t","_id":"1"}}`, `{"doc":{"v":"a"},"doc_as_upsert":true}`}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected request body:\n%s", strings.Join(lines, "\n"))
+	}
+
+	// the missing document to delete is not an error
+	failed = true
+	err := c.Bulk(context.Background(), actions)
+	if err == nil || !strings.Contains(err.Error(), "bulk update of document 1: 409") {
+		t.Errorf("expected the error of the update, got %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+// Package kafka publishes the row changes of binlog transactions to Kafka.
+//
+// The package is not a Kafka client and doesn't depend on one, the applications implement Producer with
+// the client of their choice, e.g. sarama or kafka-go. The package builds the messages, retries the transactions
+// whose messages are not all acknowledged and commits the others, so the messages acknowledged before a failure
+// are duplicated in the topics.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/LightKool/mysql-go/binlog"
+	"github.com/LightKool/mysql-go/sink"
+)
+
+// Message is a message to publish.
+type Message struct {
+	Topic string
+	// Key is the JSON of the primary key columns, which should be hashed by the partitioner of the Producer
+	// so that the changes of the same row are kept in order. It's nil if the table has no primary key.
+	Key   []byte
+	Value []byte
+}
+
+// Producer publishes the messages to Kafka.
+type Producer interface {
+	// Produce publishes the messages and returns after all of them are acknowledged by the brokers,
+	// or an error if any of them isn't, then all the messages are produced again.
+	Produce(ctx context.Context, msgs []*Message) error
+}
+
+// Sink publishes the row changes of every transaction to the topics of their tables,
+// the transaction is committed by Run only after the messages are acknowledged, which is at-least-once delivery.
+type Sink struct {
+	Producer Producer
+	// Topic returns the topic of the table, default is "<TopicPrefix>.<database>.<table>",
+	// or "<database>.<table>" if TopicPrefix is empty.
+	Topic       func(database, table string) string
+	TopicPrefix string
+	// MaxRetries is the number of the retries of a failed Produce, default is 0 which retries until ctx is done.
+	MaxRetries int
+	// Backoff is the interval before the first retry, which is doubled for every retry up to MaxBackoff.
+	// Default is 1 second and 1 minute.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Run publishes the transactions read from r until an error occurs or ctx is done.
+func (s *Sink) Run(ctx context.Context, r sink.Reader) error {
+	return sink.Run(ctx, r, s.Publish)
+}
+
+// Publish publishes the row changes of the transaction and retries with backoff until all of them are
+// acknowledged.
+func (s *Sink) Publish(ctx context.Context, tx *binlog.Transaction) error {
+	msgs, err := s.messages(tx)
+	if err != nil || len(msgs) == 0 {
+		return err
+	}
+	return sink.Retry(ctx, s.MaxRetries, s.Backoff, s.MaxBackoff, func() (bool, error) {
+		return true, s.Producer.Produce(ctx, msgs)
+	})
+}
+
+func (s *Sink) messages(tx *binlog.Transaction) ([]*Message, error) {
+	var msgs []*Message
+	for _, e := range tx.RowsEvents() {
+		database, table := string(e.Table.Database), string(e.Table.TableName)
+		topic := s.topic(database, table)
+		pk := e.Table.PrimaryKeyColumns()
+		for _, change := range e.RowChanges() {
+			value, err := json.Marshal(sink.NewChange(tx, e, change))
+			if err != nil {
+				return nil, err
+			}
+			key, err := messageKey(pk, change)
+			if err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, &Message{Topic: topic, Key: key, Value: value})
+		}
+	}
+	return msgs, nil
+}
+
+func (s *Sink) topic(database, table string) string {
+	if s.Topic != nil {
+		return s.Topic(database, table)
+	}
+	if s.TopicPrefix == "" {
+		return database + "." + table
+	}
+	return s.TopicPrefix + "." + database + "." + table
+}
+
+func messageKey(pk []string, change binlog.RowChange) ([]byte, error) {
+	if len(pk) == 0 {
+		return nil, nil
+	}
+	row := change.After
+	if row == nil {
+		row = change.Before
+	}
+	key := make(map[string]interface{}, len(pk))
+	for _, name := range pk {
+		key[name] = row[name]
+	}
+	return json.Marshal(key)
+}
@@ -0,0 +1,106 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/LightKool/mysql-go/binlog"
+	"github.com/LightKool/mysql-go/sink/sinktest"
+)
+
+type producerFunc func(ctx context.Context, msgs []*Message) error
+
+func (f producerFunc) Produce(ctx context.Context, msgs []*Message) error {
+	return f(ctx, msgs)
+}
+
+func TestSinkPublishWithoutRows(t *testing.T) {
+	errProduce := errors.New("produce failed")
+	s := &Sink{
+		Producer: producerFunc(func(ctx context.Context, msgs []*Message) error { return errProduce }),
+	}
+	tx := &binlog.Transaction{Position: binlog.Position{File: "mysql-bin.000001", Pos: 1000}}
+
+	// the Producer is not called if there is nothing to publish
+	r := &sinktest.Reader{Transactions: []*binlog.Transaction{tx}}
+	if err := s.Run(context.Background(), r); err != io.EOF {
+		t.Fatal(err)
+	}
+	if len(r.Committed) != 1 || r.Committed[0] != tx.Position {
+		t.Errorf("unexpected committed positions %v", r.Committed)
+	}
+}
+
+func TestSinkPublishRetry(t *testing.T) {
+	tx := sinktest.FixtureTransaction(t)
+	errProduce := errors.New("produce failed")
+	var attempts [][]*Message
+	s := &Sink{
+		Producer: producerFunc(func(ctx context.Context, msgs []*Message) error {
+			attempts = append(attempts, msgs)
+			return errProduce
+		}),
+		MaxRetries: 2,
+		Backoff:    time.Millisecond,
+	}
+	// the transaction is not committed if the messages are not acknowledged after the retries
+	r := &sinktest.Reader{Transactions: []*binlog.Transaction{tx}}
+	if err := s.Run(context.Background(), r); err != errProduce || len(attempts) != 3 || len(r.Committed) != 0 {
+		t.Fatalf("expected the produce error after 2 retries, got %v after %d attempts and committed positions %v",
+			err, len(attempts), r.Committed)
+	}
+
+	attempts = nil
+	s.Producer = producerFunc(func(ctx context.Context, msgs []*Message) error {
+		if attempts = append(attempts, msgs); len(attempts) == 1 {
+			return errProduce
+		}
+		return nil
+	})
+	r.Transactions = []*binlog.Transaction{tx}
+	if err := s.Run(context.Background(), r); err != io.EOF {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 || !reflect.DeepEqual(attempts[0], attempts[1]) || len(attempts[1]) != 4 {
+		t.Fatalf("expected the 4 messages produced twice, got %d attempts", len(attempts))
+	}
+	if msg := attempts[1][2]; msg.Topic != "test.all_types" || string(msg.Key) != `{"ca":1}` {
+		t.Errorf("unexpected message %s %s", msg.Topic, msg.Key)
+	}
+	if len(r.Committed) != 1 || r.Committed[0] != tx.Position {
+		t.Errorf("unexpected committed positions %v", r.Committed)
+	}
+}
+
+func TestSinkTopic(t *testing.T) {
+	s := &Sink{}
+	if topic := s.topic("db", "t"); topic != "db.t" {
+		t.Errorf("unexpected topic %s", topic)
+	}
+	s.TopicPrefix = "mysql1"
+	if topic := s.topic("db", "t"); topic != "mysql1.db.t" {
+		t.Errorf("unexpected topic %s", topic)
+	}
+	s.Topic = func(database, table string) string { return database }
+	if topic := s.topic("db", "t"); topic != "db" {
+		t.Errorf("unexpected topic %s", topic)
+	}
+}
+
+func TestMessageKey(t *testing.T) {
+	change := binlog.RowChange{Before: map[string]interface{}{"id": int64(1), "v": "a"}}
+	key, err := messageKey([]string{"id"}, change)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(key) != `{"id":1}` {
+		t.Errorf("unexpected key %s", key)
+	}
+	if key, _ = messageKey(nil, change); key != nil {
+		t.Errorf("expected nil key without primary key, got %s", key)
+	}
+}
@@ -0,0 +1,108 @@
+// Package nats publishes the row changes of binlog transactions to NATS JetStream.
+//
+// The package is not a NATS client and doesn't depend on one, the applications implement Publisher with nats.go,
+// e.g. by JetStreamContext.PublishMsgAsync with the Nats-Msg-Id header set to Message.ID, then waiting for
+// the acks. The package builds the messages, retries the transactions whose messages are not all acknowledged
+// and commits the others, the acks and the deduplication are up to the Publisher and the stream.
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LightKool/mysql-go/binlog"
+	"github.com/LightKool/mysql-go/sink"
+)
+
+// Message is a message to publish.
+type Message struct {
+	Subject string
+	// ID is unique to the row change, "<file>:<pos>:<n>" where n is the index of the change in the transaction.
+	// It should be the Nats-Msg-Id header, so that the stream discards the duplicates republished after
+	// a restart within its duplicate window.
+	ID   string
+	Data []byte
+}
+
+// Publisher publishes the messages to JetStream.
+type Publisher interface {
+	// Publish publishes the messages and returns after all of them are acknowledged by the stream,
+	// or an error if any of them isn't, then all the messages are published again with the same IDs.
+	Publish(ctx context.Context, msgs []*Message) error
+}
+
+// Sink publishes the row changes of every transaction to the subjects of their tables,
+// the transaction is committed by Run only after the messages are acknowledged, which is at-least-once delivery.
+type Sink struct {
+	Publisher Publisher
+	// Subject returns the subject of the table, default is "<SubjectPrefix>.<database>.<table>",
+	// or "<database>.<table>" if SubjectPrefix is empty. The characters not allowed in the tokens of
+	// the subjects, i.e. '.', '*', '>' and the whitespaces, are replaced with '_' in the names.
+	Subject       func(database, table string) string
+	SubjectPrefix string
+	// MaxRetries is the number of the retries of a failed Publish, default is 0 which retries until ctx is done.
+	MaxRetries int
+	// Backoff is the interval before the first retry, which is doubled for every retry up to MaxBackoff.
+	// Default is 1 second and 1 minute.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Run publishes the transactions read from r until an error occurs or ctx is done.
+func (s *Sink) Run(ctx context.Context, r sink.Reader) error {
+	return sink.Run(ctx, r, s.Publish)
+}
+
+// Publish publishes the row changes of the transaction and retries with backoff until all of them are
+// acknowledged.
+func (s *Sink) Publish(ctx context.Context, tx *binlog.Transaction) error {
+	msgs, err := s.messages(tx)
+	if err != nil || len(msgs) == 0 {
+		return err
+	}
+	return sink.Retry(ctx, s.MaxRetries, s.Backoff, s.MaxBackoff, func() (bool, error) {
+		return true, s.Publisher.Publish(ctx, msgs)
+	})
+}
+
+func (s *Sink) messages(tx *binlog.Transaction) ([]*Message, error) {
+	var msgs []*Message
+	for _, e := range tx.RowsEvents() {
+		database, table := string(e.Table.Database), string(e.Table.TableName)
+		subject := s.subject(database, table)
+		for _, change := range e.RowChanges() {
+			data, err := json.Marshal(sink.NewChange(tx, e, change))
+			if err != nil {
+				return nil, err
+			}
+			id := fmt.Sprintf("%s:%d:%d", tx.Position.File, tx.Position.Pos, len(msgs))
+			msgs = append(msgs, &Message{Subject: subject, ID: id, Data: data})
+		}
+	}
+	return msgs, nil
+}
+
+func (s *Sink) subject(database, table string) string {
+	if s.Subject != nil {
+		return s.Subject(database, table)
+	}
+	subject := token(database) + "." + token(table)
+	if s.SubjectPrefix == "" {
+		return subject
+	}
+	return s.SubjectPrefix + "." + subject
+}
+
+// token replaces the characters not allowed in a token of the subjects.
+func token(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, name)
+}
@@ -0,0 +1,93 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/LightKool/mysql-go/binlog"
+	"github.com/LightKool/mysql-go/sink"
+	"github.com/LightKool/mysql-go/sink/sinktest"
+)
+
+type publisherFunc func(ctx context.Context, msgs []*Message) error
+
+func (f publisherFunc) Publish(ctx context.Context, msgs []*Message) error {
+	return f(ctx, msgs)
+}
+
+func TestSinkPublish(t *testing.T) {
+	tx := sinktest.FixtureTransaction(t)
+	errPublish := errors.New("publish failed")
+	s := &Sink{
+		Publisher:     publisherFunc(func(ctx context.Context, msgs []*Message) error { return errPublish }),
+		SubjectPrefix: "cdc",
+		MaxRetries:    1,
+		Backoff:       time.Millisecond,
+	}
+	// the transaction is not committed if the messages are not acknowledged
+	r := &sinktest.Reader{Transactions: []*binlog.Transaction{tx}}
+	err := s.Run(context.Background(), r)
+	if err != errPublish || len(r.Committed) != 0 {
+		t.Fatalf("expected the publish error, got %v and committed positions %v", err, r.Committed)
+	}
+
+	// the messages are published again until they are acknowledged
+	var attempts [][]*Message
+	s.Publisher = publisherFunc(func(ctx context.Context, msgs []*Message) error {
+		attempts = append(attempts, msgs)
+		if len(attempts) == 1 {
+			return errPublish
+		}
+		return nil
+	})
+	r.Transactions = []*binlog.Transaction{tx}
+	if err = s.Run(context.Background(), r); err != io.EOF {
+		t.Fatal(err)
+	}
+	if len(attempts) != 2 || !reflect.DeepEqual(attempts[0], attempts[1]) {
+		t.Fatalf("expected the same messages published twice, got %d attempts", len(attempts))
+	}
+	published := attempts[1]
+	if len(published) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(published))
+	}
+	for i, msg := range published {
+		if msg.Subject != "cdc.test.all_types" {
+			t.Errorf("unexpected subject %s", msg.Subject)
+		}
+		if id := fmt.Sprintf("mysql-bin.000001:1347:%d", i); msg.ID != id {
+			t.Errorf("expected message id %s, got %s", id, msg.ID)
+		}
+	}
+	var v sink.Change
+	if err = json.Unmarshal(published[2].Data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Op != "update" || v.Before["cn"] != "abc" || v.After["cn"] != "xyz" {
+		t.Errorf("unexpected value %+v", v)
+	}
+	if len(r.Committed) != 1 || r.Committed[0] != tx.Position {
+		t.Errorf("unexpected committed positions %v", r.Committed)
+	}
+}
+
+func TestSinkSubject(t *testing.T) {
+	s := &Sink{}
+	if subject := s.subject("db", "t.1 *>"); subject != "db.t_1___" {
+		t.Errorf("unexpected subject %s", subject)
+	}
+	s.SubjectPrefix = "mysql1"
+	if subject := s.subject("db", "t"); subject != "mysql1.db.t" {
+		t.Errorf("unexpected subject %s", subject)
+	}
+	s.Subject = func(database, table string) string { return database }
+	if subject := s.subject("db", "t"); subject != "db" {
+		t.Errorf("unexpected subject %s", subject)
+	}
+}
@@ -0,0 +1,108 @@
+// Package sink has the pieces shared by the sinks which deliver the row changes of binlog transactions
+// to the other systems, e.g. sink/kafka and replicator.
+package sink
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/LightKool/mysql-go/binlog"
+)
+
+// Change is a row change in the JSON messages of the sinks.
+type Change struct {
+	Database string                 `json:"database"`
+	Table    string                 `json:"table"`
+	Op       string                 `json:"op"`
+	Before   map[string]interface{} `json:"before,omitempty"`
+	After    map[string]interface{} `json:"after,omitempty"`
+	GTID     string                 `json:"gtid,omitempty"`
+	File     string                 `json:"file"`
+	Pos      uint32                 `json:"pos"`
+	// Timestamp is the time when the change is executed on the master, in seconds.
+	Timestamp uint32 `json:"ts"`
+}
+
+// NewChange returns the Change of a row change of the rows event in the transaction.
+func NewChange(tx *binlog.Transaction, e *binlog.RowsEvent, change binlog.RowChange) Change {
+	return Change{
+		Database:  string(e.Table.Database),
+		Table:     string(e.Table.TableName),
+		Op:        strings.ToLower(e.Op().String()),
+		Before:    change.Before,
+		After:     change.After,
+		GTID:      tx.GTID,
+		File:      tx.Position.File,
+		Pos:       tx.Position.Pos,
+		Timestamp: e.Header().Timestamp,
+	}
+}
+
+// Changes returns the row changes of the transaction.
+func Changes(tx *binlog.Transaction) []Change {
+	var changes []Change
+	for _, e := range tx.RowsEvents() {
+		for _, change := range e.RowChanges() {
+			changes = append(changes, NewChange(tx, e, change))
+		}
+	}
+	return changes
+}
+
+// Reader reads the transactions to deliver, it's usually a *binlog.TransactionReader.
+type Reader interface {
+	Read(ctx context.Context) (*binlog.Transaction, error)
+	// Commit marks the transaction delivered, e.g. saves its position into the Store of TransactionReader.
+	Commit(tx *binlog.Transaction) error
+}
+
+// Run delivers the transactions read from r until an error occurs or ctx is done. Every transaction is
+// committed right after deliver returns for it, so it's delivered again after a crash before the commit,
+// which is at-least-once delivery.
+func Run(ctx context.Context, r Reader, deliver func(ctx context.Context, tx *binlog.Transaction) error) error {
+	for {
+		tx, err := r.Read(ctx)
+		if err != nil {
+			return err
+		}
+		if err = deliver(ctx, tx); err != nil {
+			return err
+		}
+		if err = r.Commit(tx); err != nil {
+			return err
+		}
+	}
+}
+
+// Retry calls fn until it succeeds, it fails with an error not to retry, or ctx is done. It's retried up to
+// maxRetries times, or until ctx is done if maxRetries is 0. The interval before the first retry is backoff,
+// which is doubled for every retry up to maxBackoff, default is 1 second and 1 minute.
+func Retry(ctx context.Context, maxRetries int, backoff, maxBackoff time.Duration, fn func() (retry bool, err error)) error {
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = time.Minute
+	}
+	for retries := 0; ; retries++ {
+		retry, err := fn()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !retry || maxRetries > 0 && retries >= maxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/LightKool/mysql-go/binlog"
+	"github.com/LightKool/mysql-go/sink/sinktest"
+)
+
+func TestChanges(t *testing.T) {
+	changes := Changes(sinktest.FixtureTransaction(t))
+	var ops []string
+	for _, c := range changes {
+		if c.Database != "test" || c.Table != "all_types" || c.File != sinktest.FixturePosition.File ||
+			c.Pos != sinktest.FixturePosition.Pos {
+			t.Errorf("unexpected change %+v", c)
+		}
+		ops = append(ops, c.Op)
+	}
+	if ops := strings.Join(ops, ","); ops != "insert,insert,update,delete" {
+		t.Errorf("unexpected operations %s", ops)
+	}
+	if changes[2].Before["cn"] != "abc" || changes[2].After["cn"] != "xyz" {
+		t.Errorf("unexpected update %+v", changes[2])
+	}
+}
+
+func TestRun(t *testing.T) {
+	tx1 := &binlog.Transaction{Position: binlog.Position{File: "mysql-bin.000001", Pos: 100}}
+	tx2 := &binlog.Transaction{Position: binlog.Position{File: "mysql-bin.000001", Pos: 200}}
+	r := &sinktest.Reader{Transactions: []*binlog.Transaction{tx1, tx2}}
+	errDeliver := errors.New("deliver failed")
+	// the transaction failed to deliver is not committed
+	err := Run(context.Background(), r, func(ctx context.Context, tx *binlog.Transaction) error {
+		if tx == tx2 {
+			return errDeliver
+		}
+		return nil
+	})
+	if err != errDeliver || len(r.Committed) != 1 || r.Committed[0] != tx1.Position {
+		t.Fatalf("expected the error of the second transaction, got %v and committed positions %v", err, r.Committed)
+	}
+
+	r.Transactions = []*binlog.Transaction{tx2}
+	err = Run(context.Background(), r, func(ctx context.Context, tx *binlog.Transaction) error { return nil })
+	if err != io.EOF || len(r.Committed) != 2 || r.Committed[1] != tx2.Position {
+		t.Errorf("expected the transactions committed, got %v and committed positions %v", err, r.Committed)
+	}
+}
@@ -0,0 +1,49 @@
+// Package sinktest has the helpers to test the sinks.
+package sinktest
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/LightKool/mysql-go/binlog"
+)
+
+// FixturePosition is the position of the transaction returned by FixtureTransaction.
+var FixturePosition = binlog.Position{File: "mysql-bin.000001", Pos: 1347}
+
+// FixtureTransaction returns the transaction of binlog/testdata/mysql-8.0.bin, which inserts 2 rows into
+// the table test.all_types whose primary key is the column ca, then updates the primary key and the column cn
+// from "abc" to "xyz" of one of them and deletes the other.
+func FixtureTransaction(t testing.TB) *binlog.Transaction {
+	_, file, _, _ := runtime.Caller(0)
+	path := filepath.Join(filepath.Dir(file), "..", "..", "binlog", "testdata", "mysql-8.0.bin")
+	events, err := binlog.ReadFile(path, binlog.NewEventDecoder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &binlog.Transaction{Events: events, Position: FixturePosition}
+}
+
+// Reader is a sink.Reader reading the transactions in memory, Read returns io.EOF after all of them.
+type Reader struct {
+	Transactions []*binlog.Transaction
+	// Committed are the positions of the committed transactions.
+	Committed []binlog.Position
+}
+
+func (r *Reader) Read(ctx context.Context) (*binlog.Transaction, error) {
+	if len(r.Transactions) == 0 {
+		return nil, io.EOF
+	}
+	tx := r.Transactions[0]
+	r.Transactions = r.Transactions[1:]
+	return tx, nil
+}
+
+func (r *Reader) Commit(tx *binlog.Transaction) error {
+	r.Committed = append(r.Committed, tx.Position)
+	return nil
+}
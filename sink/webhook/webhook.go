@@ -0,0 +1,121 @@
+// Package webhook delivers the row changes of binlog transactions to an HTTP endpoint in JSON,
+// so that the services can consume the changes without a message broker.
+//
+// Every request is a POST of a JSON array of the changes of a transaction, the large transactions are split
+// into the batches of BatchSize changes. The requests are signed with HMAC-SHA256 if Secret is set, the receiver
+// verifies the X-Signature-256 header, which is "sha256=" followed by the hex of the HMAC of the body.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/LightKool/mysql-go/binlog"
+	"github.com/LightKool/mysql-go/sink"
+)
+
+// SignatureHeader is the header of the HMAC-SHA256 signature of the request body.
+const SignatureHeader = "X-Signature-256"
+
+// Sink posts the row changes of every transaction to the URL, the transaction is committed by Run only after
+// all the requests succeed, which is at-least-once delivery.
+type Sink struct {
+	URL string
+	// Header is added to the requests, e.g. Authorization.
+	Header http.Header
+	// Secret signs the requests with HMAC-SHA256 if not empty, see SignatureHeader.
+	Secret []byte
+	// BatchSize is the maximum number of the changes per request, default is 500.
+	BatchSize int
+	// MaxRetries is the number of the retries of a failed request, default is 0 which retries until ctx is done.
+	// The requests are retried on the network errors, 429 and 5xx responses.
+	MaxRetries int
+	// Backoff is the interval before the first retry, which is doubled for every retry up to MaxBackoff.
+	// Default is 1 second and 1 minute.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Client sends the requests, default is http.DefaultClient.
+	Client *http.Client
+}
+
+// Run posts the transactions read from r until an error occurs or ctx is done.
+func (s *Sink) Run(ctx context.Context, r sink.Reader) error {
+	return sink.Run(ctx, r, s.Post)
+}
+
+// Post posts the row changes of the transaction.
+func (s *Sink) Post(ctx context.Context, tx *binlog.Transaction) error {
+	changes := sink.Changes(tx)
+	size := s.BatchSize
+	if size <= 0 {
+		size = 500
+	}
+	for len(changes) > 0 {
+		n := size
+		if n > len(changes) {
+			n = len(changes)
+		}
+		body, err := json.Marshal(changes[:n])
+		if err != nil {
+			return err
+		}
+		if err = s.send(ctx, body); err != nil {
+			return err
+		}
+		changes = changes[n:]
+	}
+	return nil
+}
+
+// send posts the body and retries with backoff until it succeeds.
+func (s *Sink) send(ctx context.Context, body []byte) error {
+	return sink.Retry(ctx, s.MaxRetries, s.Backoff, s.MaxBackoff, func() (bool, error) {
+		return s.post(ctx, body)
+	})
+}
+
+// post posts the body once, it returns whether the failed request can be retried.
+func (s *Sink) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for name, values := range s.Header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.Secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(s.Secret, body))
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("post %s: %s: %s", s.URL, resp.Status, msg)
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+// Sign returns the signature of the body in SignatureHeader.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
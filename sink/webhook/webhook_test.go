@@ -0,0 +1,107 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LightKool/mysql-go/binlog"
+	"github.com/LightKool/mysql-go/sink"
+	"github.com/LightKool/mysql-go/sink/sinktest"
+)
+
+func TestSinkPost(t *testing.T) {
+	secret := []byte("secret")
+	var batches [][]sink.Change
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get(SignatureHeader) != Sign(secret, body) || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// the first request fails to be retried
+		if requests == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var changes []sink.Change
+		if err := json.Unmarshal(body, &changes); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		batches = append(batches, changes)
+	}))
+	defer server.Close()
+
+	s := &Sink{
+		URL:       server.URL,
+		Header:    http.Header{"Authorization": {"Bearer token"}},
+		Secret:    secret,
+		BatchSize: 3,
+		Backoff:   time.Millisecond,
+	}
+	r := &sinktest.Reader{Transactions: []*binlog.Transaction{sinktest.FixtureTransaction(t)}}
+	if err := s.Run(context.Background(), r); err != io.EOF {
+		t.Fatal(err)
+	}
+	if requests != 3 || len(batches) != 2 || len(batches[0]) != 3 || len(batches[1]) != 1 {
+		t.Fatalf("unexpected %d requests of batches %v", requests, batches)
+	}
+	var ops []string
+	for _, changes := range batches {
+		for _, c := range changes {
+			if c.Database != "test" || c.Table != "all_types" || c.File != "mysql-bin.000001" || c.Pos != sinktest.FixturePosition.Pos {
+				t.Errorf("unexpected change %+v", c)
+			}
+			ops = append(ops, c.Op)
+		}
+	}
+	if ops := strings.Join(ops, ","); ops != "insert,insert,update,delete" {
+		t.Errorf("unexpected operations %s", ops)
+	}
+	if len(r.Committed) != 1 || r.Committed[0] != sinktest.FixturePosition {
+		t.Errorf("unexpected committed positions %v", r.Committed)
+	}
+}
+
+func TestSinkPostFailure(t *testing.T) {
+	var requests int
+	status := http.StatusBadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "failed", status)
+	}))
+	defer server.Close()
+
+	s := &Sink{URL: server.URL, Backoff: time.Millisecond}
+	tx := sinktest.FixtureTransaction(t)
+	// 4xx is not retried
+	if err := s.Post(context.Background(), tx); err == nil || requests != 1 {
+		t.Errorf("expected the request failed without retries, got %v after %d requests", err, requests)
+	}
+
+	status, requests = http.StatusInternalServerError, 0
+	s.MaxRetries = 2
+	if err := s.Post(context.Background(), tx); err == nil || requests != 3 {
+		t.Errorf("expected the request failed after 2 retries, got %v after %d requests", err, requests)
+	}
+
+	s.MaxRetries = 0
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	r := &sinktest.Reader{Transactions: []*binlog.Transaction{tx}}
+	if err := s.Run(ctx, r); err != context.DeadlineExceeded {
+		t.Errorf("expected the retries stopped by ctx, got %v", err)
+	}
+	if len(r.Committed) != 0 {
+		t.Errorf("expected no transaction committed, got %v", r.Committed)
+	}
+}
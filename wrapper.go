@@ -1,14 +1,26 @@
 package mysql
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"database/sql/driver"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Packet reads or writes the fields of a packet sequentially. The reads are bounds-checked: reading beyond the end
+// of the packet returns zero values and records ErrMalformPkt, which is returned by Err afterwards.
 type Packet struct {
 	data []byte
 	pos  int
+	err  error
 }
 
 func NewPacket(data []byte) *Packet {
@@ -24,9 +36,30 @@ func (p *Packet) Len() int {
 	return len(p.data)
 }
 
+// Pos returns the current read position of this packet.
+func (p *Packet) Pos() int {
+	return p.pos
+}
+
 // EOF returns if the buffer of this packet has been consumed completely.
 func (p *Packet) EOF() bool {
-	return p.pos == len(p.data)
+	return p.pos >= len(p.data)
+}
+
+// Err returns ErrMalformPkt if any read went beyond the end of the packet.
+func (p *Packet) Err() error {
+	return p.err
+}
+
+// check reports whether there are size bytes left, otherwise the error is recorded
+// and the packet is consumed to stop the following reads.
+func (p *Packet) check(size int) bool {
+	if p.err == nil && size >= 0 && size <= len(p.data)-p.pos {
+		return true
+	}
+	p.err = ErrMalformPkt
+	p.pos = len(p.data)
+	return false
 }
 
 func (p *Packet) SliceRight(length int) (slice []byte) {
@@ -39,21 +72,34 @@ func (p *Packet) SliceRight(length int) (slice []byte) {
 }
 
 func (p *Packet) Skip(step int) {
-	p.pos += step
+	if p.check(step) {
+		p.pos += step
+	}
 }
 
+// Read reads size bytes, or all the remaining bytes if size is negative.
+// It returns a zero-filled slice if there are not enough bytes.
 func (p *Packet) Read(size int) (result []byte) {
 	if size < 0 {
 		result = p.data[p.pos:]
 		p.pos = len(p.data)
-	} else {
+	} else if p.check(size) {
 		result = p.data[p.pos : p.pos+size]
 		p.pos += size
+	} else if size <= len(p.data) {
+		// no more than the packet size, so that a corrupted length can't allocate too much
+		result = make([]byte, size)
 	}
 	return
 }
 
 func (p *Packet) ReadUintBySize(size int) (u uint64) {
+	if size > 8 {
+		panic("size must be between 0 and 8")
+	}
+	if !p.check(size) {
+		return 0
+	}
 	switch {
 	case size == 0:
 		u = 0
@@ -79,6 +125,12 @@ func (p *Packet) ReadUintBySize(size int) (u uint64) {
 }
 
 func (p *Packet) ReadUintBySizeBE(size int) (u uint64) {
+	if size > 8 {
+		panic("size must be between 0 and 8")
+	}
+	if !p.check(size) {
+		return 0
+	}
 	switch {
 	case size == 0:
 		u = 0
@@ -104,41 +156,164 @@ func (p *Packet) ReadUintBySizeBE(size int) (u uint64) {
 }
 
 func (p *Packet) ReadPackedInteger() uint64 {
+	if !p.check(1) || !p.check(lengthEncodedIntegerSize(p.data[p.pos])) {
+		return 0
+	}
 	num, _, n := readLengthEncodedInteger(p.data[p.pos:])
 	p.pos += n
 	return num
 }
 
+// lengthEncodedIntegerSize returns the size of the length-encoded integer by its first byte.
+func lengthEncodedIntegerSize(b byte) int {
+	switch b {
+	case 0xfc:
+		return 3
+	case 0xfd:
+		return 4
+	case 0xfe:
+		return 9
+	}
+	return 1
+}
+
 func (p *Packet) ReadPackedString() ([]byte, error) {
+	if !p.check(1) || !p.check(lengthEncodedIntegerSize(p.data[p.pos])) {
+		return nil, p.err
+	}
+	// the malformed lengths beyond the data would overflow the slicing
+	if num, _, n := readLengthEncodedInteger(p.data[p.pos:]); num > uint64(len(p.data)-p.pos-n) {
+		p.check(-1)
+		return nil, io.EOF
+	}
 	data, _, n, err := readLengthEncodedString(p.data[p.pos:])
 	if err != nil {
+		p.check(-1)
 		return nil, err
 	}
 	p.pos += n
 	return data, nil
 }
 
+// readZeroTerminated reads a NUL terminated string.
+func (p *Packet) readZeroTerminated() string {
+	i := bytes.IndexByte(p.data[p.pos:], 0)
+	if i < 0 {
+		p.check(-1)
+		return ""
+	}
+	s := string(p.data[p.pos : p.pos+i])
+	p.pos += i + 1
+	return s
+}
+
+// Write appends the bytes to the packet.
+func (p *Packet) Write(b []byte) {
+	p.data = append(p.data, b...)
+}
+
+// WriteUintBySize appends u as a little-endian integer of size bytes.
+func (p *Packet) WriteUintBySize(size int, u uint64) {
+	if size > 8 {
+		panic("size must be between 0 and 8")
+	}
+	for i := 0; i < size; i++ {
+		p.data = append(p.data, byte(u>>(uint(i)*8)))
+	}
+}
+
+// WriteUintBySizeBE appends u as a big-endian integer of size bytes.
+func (p *Packet) WriteUintBySizeBE(size int, u uint64) {
+	if size > 8 {
+		panic("size must be between 0 and 8")
+	}
+	for i := size - 1; i >= 0; i-- {
+		p.data = append(p.data, byte(u>>(uint(i)*8)))
+	}
+}
+
+// WriteLengthEncodedInteger appends n as a length-encoded integer.
+func (p *Packet) WriteLengthEncodedInteger(n uint64) {
+	p.data = appendLengthEncodedInteger(p.data, n)
+}
+
+// WriteLengthEncodedString appends b prefixed by its length-encoded length.
+func (p *Packet) WriteLengthEncodedString(b []byte) {
+	p.data = appendLengthEncodedInteger(p.data, uint64(len(b)))
+	p.data = append(p.data, b...)
+}
+
+// WriteZeroTerminated appends s followed by a zero byte.
+func (p *Packet) WriteZeroTerminated(s string) {
+	p.data = append(p.data, s...)
+	p.data = append(p.data, 0)
+}
+
 // ConnWrapper wraps the unexported `mysqlConn` to export its functionalities.
 type ConnWrapper struct {
 	*mysqlConn
-	drv driver.Driver
+	// Log receives the milestones of the replication if not nil.
+	Log LeveledLogger
+	// KeepAlivePeriod is the period of the TCP keepalive probes of the connection, the system default is used if 0.
+	KeepAlivePeriod time.Duration
+
+	semiSync          bool
+	semiSyncACKNeeded bool
 }
 
 // NewConnWrapper create a new `mysql.ConnWrapper` instance.
 func NewConnWrapper() *ConnWrapper {
-	return &ConnWrapper{drv: &MySQLDriver{}}
+	return &ConnWrapper{}
+}
+
+func (cw *ConnWrapper) log() LeveledLogger {
+	if cw.Log != nil {
+		return cw.Log
+	}
+	return NopLogger
 }
 
 // Connect to the MySQL server.
 func (cw *ConnWrapper) Connect(dsn string) error {
-	conn, err := cw.drv.Open(dsn)
+	return cw.ConnectContext(context.Background(), dsn, nil)
+}
+
+// ConnectContext connects to the MySQL server with the TLS config, which overrides the `tls` parameter of the DSN if not nil.
+// The connection is bound to ctx: canceling ctx aborts the connecting or tears down the established connection,
+// so that a blocking ReadPacket returns ctx.Err(). Use the `timeout` parameter of the DSN for the dial timeout only.
+func (cw *ConnWrapper) ConnectContext(ctx context.Context, dsn string, tlsConfig *tls.Config) error {
+	cfg, err := ParseDSN(dsn)
 	if err != nil {
 		return err
 	}
-	cw.mysqlConn = conn.(*mysqlConn)
+	if tlsConfig != nil {
+		cfg.tls = cloneTLSConfig(tlsConfig)
+		if len(cfg.tls.ServerName) == 0 && !cfg.tls.InsecureSkipVerify {
+			if host, _, err := net.SplitHostPort(cfg.Addr); err == nil {
+				cfg.tls.ServerName = host
+			}
+		}
+	}
+
+	cfg.keepAlivePeriod = cw.KeepAlivePeriod
+	mc, err := MySQLDriver{}.open(cfg, ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	cw.mysqlConn = mc
+	cw.log().Info("connected to MySQL server", "addr", cfg.Addr)
 	return nil
 }
 
+// Cancel closes the underlying network connection immediately without sending COM_QUIT,
+// so a blocking ReadPacket returns err. Unlike Close, it's safe to be called from another goroutine.
+func (cw *ConnWrapper) Cancel(err error) {
+	cw.cancel(err)
+}
+
 // ReadOK reads and checks the OK packet returned from the MySQL server.
 func (cw *ConnWrapper) ReadOK() error {
 	_, err := cw.readResultOK()
@@ -147,6 +322,42 @@ func (cw *ConnWrapper) ReadOK() error {
 
 // ReadPacket read returned data from the MySQL server.
 func (cw *ConnWrapper) ReadPacket() ([]byte, error) {
+	data, err := cw.readPayload()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, len(data))
+	copy(buf, data)
+	return buf, nil
+}
+
+// ReadPacketBuffer is like ReadPacket but appends the data to buf instead of allocating a new slice,
+// so that the buffers can be reused by the caller.
+func (cw *ConnWrapper) ReadPacketBuffer(buf []byte) ([]byte, error) {
+	data, err := cw.readPayload()
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, data...), nil
+}
+
+// SetReadTimeout sets the timeout of every read from the connection, which overrides the `readTimeout` parameter
+// of the DSN. A blocking ReadPacket fails with driver.ErrBadConn and the connection is closed once no data
+// is received for the timeout.
+func (cw *ConnWrapper) SetReadTimeout(timeout time.Duration) {
+	cw.buf.timeout = timeout
+}
+
+// ReadPacketNoCopy is like ReadPacket but returns the data in the read buffer of the connection without
+// copying, which is valid only until the next read or write on the connection, e.g. the ACK of semi-sync.
+// The events decoded from it must not be retained after that.
+func (cw *ConnWrapper) ReadPacketNoCopy() ([]byte, error) {
+	return cw.readPayload()
+}
+
+// readPayload reads the packet and strips the OK byte and the semi-sync header, the returned data is
+// in the read buffer of the connection which is overwritten by the next read or write.
+func (cw *ConnWrapper) readPayload() ([]byte, error) {
 	data, err := cw.readPacket()
 	if err != nil {
 		return nil, err
@@ -157,63 +368,245 @@ func (cw *ConnWrapper) ReadPacket() ([]byte, error) {
 	case iERR:
 		return nil, cw.handleErrorPacket(data)
 	default:
-		buf := make([]byte, len(data)-1)
-		copy(buf, data[1:])
-		return buf, nil
+		data = data[1:]
+		if cw.semiSync {
+			// semi-sync header: indicator(1 byte) + reply needed flag(1 byte)
+			if len(data) < 2 || data[0] != semiSyncIndicator {
+				return nil, ErrMalformPkt
+			}
+			cw.semiSyncACKNeeded = data[1] == 0x01
+			data = data[2:]
+		}
+		return data, nil
 	}
 }
 
+// ReadPacketContext is like ReadPacket but the blocking read can be canceled by ctx,
+// the connection is closed and can't be used anymore once canceled.
+func (cw *ConnWrapper) ReadPacketContext(ctx context.Context) ([]byte, error) {
+	if err := cw.watchCancel(ctx); err != nil {
+		return nil, err
+	}
+	data, err := cw.ReadPacket()
+	cw.finish()
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return data, err
+}
+
 // WriteRegisterSlaveCommand send `RegisterSlave` command to the MySQL server.
 func (cw *ConnWrapper) WriteRegisterSlaveCommand(serverID uint32, localhost, user, password string, port uint16) error {
-	data := make([]byte, 4+1+len(localhost)+1+len(user)+1+len(password)+2+4+4)
-	pos := 0
+	p := NewPacket(make([]byte, 0, 4+1+len(localhost)+1+len(user)+1+len(password)+2+4+4))
+	p.WriteUintBySize(4, uint64(serverID))
+	for _, s := range []string{localhost, user, password} {
+		p.WriteUintBySize(1, uint64(len(s)))
+		p.Write([]byte(s))
+	}
+	p.WriteUintBySize(2, uint64(port))
+	// replication rank, not used
+	p.WriteUintBySize(4, 0)
+	// master ID, 0 is OK
+	p.WriteUintBySize(4, 0)
 
-	binary.LittleEndian.PutUint32(data[pos:], serverID)
-	pos += 4
+	cw.log().Info("registering slave", "server_id", serverID)
+	return cw.writeCommandPacketStr(comRegisterSlave, string(p.Raw()))
+}
 
-	data[pos] = byte(len(localhost))
-	pos++
-	n := copy(data[pos:], localhost)
-	pos += n
+// WriteBinlogDumpCommand sends the `BinlogDump` command to the MySQL server.
+func (cw *ConnWrapper) WriteBinlogDumpCommand(serverID uint32, file string, position uint32) error {
+	p := NewPacket(make([]byte, 0, 4+2+4+len(file)))
+	p.WriteUintBySize(4, uint64(position))
+	// flags
+	p.WriteUintBySize(2, 0)
+	p.WriteUintBySize(4, uint64(serverID))
+	p.Write([]byte(file))
+
+	cw.log().Info("dumping binlog", "file", file, "pos", position)
+	return cw.writeCommandPacketStr(comBinlogDump, string(p.Raw()))
+}
 
-	data[pos] = byte(len(user))
-	pos++
-	n = copy(data[pos:], user)
-	pos += n
+const (
+	// flag of COM_BINLOG_DUMP_GTID indicating that the GTID data block is sent
+	binlogThroughGTID = 0x04
+	// the first byte of the semi-sync header and the ACK packet
+	semiSyncIndicator byte = 0xef
+)
 
-	data[pos] = byte(len(password))
-	pos++
-	n = copy(data[pos:], password)
-	pos += n
+// WriteBinlogDumpGTIDCommand sends the `BinlogDumpGTID` command to the MySQL server.
+// gtidSet is the set of the transactions which have been received, use ParseGTIDSet to parse its textual form.
+func (cw *ConnWrapper) WriteBinlogDumpGTIDCommand(serverID uint32, gtidSet GTIDSet) error {
+	gtidData := gtidSet.Encode()
+
+	p := NewPacket(make([]byte, 0, 2+4+4+8+4+len(gtidData)))
+	p.WriteUintBySize(2, binlogThroughGTID)
+	p.WriteUintBySize(4, uint64(serverID))
+	// binlog file name, empty
+	p.WriteUintBySize(4, 0)
+	// binlog position
+	p.WriteUintBySize(8, 4)
+	p.WriteUintBySize(4, uint64(len(gtidData)))
+	p.Write(gtidData)
+
+	cw.log().Info("dumping binlog by GTID", "gtid_set", gtidSet)
+	return cw.writeCommandPacketStr(comBinlogDumpGTID, string(p.Raw()))
+}
 
-	binary.LittleEndian.PutUint16(data[pos:], port)
-	pos += 2
+// ErrSemiSyncDisabled is returned by EnableSemiSync if the semi-sync plugin is not loaded on the master
+// or it's not enabled, the dump can go on without semi-sync.
+var ErrSemiSyncDisabled = errors.New("semi-sync replication is not enabled on the master")
 
-	// replication rank, not used
-	binary.LittleEndian.PutUint32(data[pos:], 0)
-	pos += 4
+// EnableSemiSync tells the master that this slave supports semi-synchronous replication,
+// it must be called before sending the `BinlogDump` command. It returns ErrSemiSyncDisabled without
+// telling the master if rpl_semi_sync_master_enabled, or rpl_semi_sync_source_enabled since 8.0.26, is not ON.
+// The semi-sync header of every event is stripped by ReadPacket afterwards.
+func (cw *ConnWrapper) EnableSemiSync() error {
+	enabled, err := cw.semiSyncEnabled()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return ErrSemiSyncDisabled
+	}
+	if err = cw.exec("SET @rpl_semi_sync_slave = 1"); err != nil {
+		return err
+	}
+	cw.semiSync = true
+	cw.log().Info("semi-sync replication enabled")
+	return nil
+}
 
-	// master ID, 0 is OK
-	binary.LittleEndian.PutUint32(data[pos:], 0)
+// semiSyncEnabled reports whether the semi-sync plugin of the master is loaded and enabled,
+// the variables don't exist if it's not loaded.
+func (cw *ConnWrapper) semiSyncEnabled() (bool, error) {
+	for _, name := range []string{"rpl_semi_sync_master_enabled", "rpl_semi_sync_source_enabled"} {
+		_, rows, err := cw.queryAll("SHOW GLOBAL VARIABLES LIKE '" + name + "'")
+		if err != nil {
+			return false, err
+		}
+		if len(rows) > 0 && len(rows[0]) > 1 {
+			return strings.EqualFold(string(rows[0][1]), "ON"), nil
+		}
+	}
+	return false, nil
+}
 
-	return cw.writeCommandPacketStr(comRegisterSlave, string(data))
+// SemiSyncACKNeeded reports whether the master requests an ACK for the last event read by ReadPacket.
+func (cw *ConnWrapper) SemiSyncACKNeeded() bool {
+	return cw.semiSyncACKNeeded
 }
 
-// WriteBinlogDumpCommand sends the `BinlogDump` command to the MySQL server.
-func (cw *ConnWrapper) WriteBinlogDumpCommand(serverID uint32, file string, position uint32) error {
-	data := make([]byte, 4+2+4+len(file))
-	pos := 0
+// WriteSemiSyncACK sends the ACK of the event ending at the position of the binlog file to the master.
+func (cw *ConnWrapper) WriteSemiSyncACK(file string, position uint64) error {
+	// the first 4 bytes are reserved for the packet header
+	p := NewPacket(make([]byte, 4, 4+1+8+len(file)))
+	p.WriteUintBySize(1, uint64(semiSyncIndicator))
+	p.WriteUintBySize(8, position)
+	p.Write([]byte(file))
+
+	// the ACK is a standalone packet, restore the sequence of the dump stream after sending it
+	sequence := cw.sequence
+	cw.sequence = 0
+	err := cw.writePacket(p.Raw())
+	cw.sequence = sequence
+	if err != nil {
+		return err
+	}
+	cw.semiSyncACKNeeded = false
+	return nil
+}
+
+// LogInfo is a binlog file of the master listed by `SHOW BINARY LOGS`.
+type LogInfo struct {
+	Name      string
+	Size      uint64
+	Encrypted bool
+}
 
-	binary.LittleEndian.PutUint32(data[pos:], position)
-	pos += 4
+// MasterStatus returns the current binlog file and position and the executed GTID set of the master by
+// `SHOW MASTER STATUS`, or `SHOW BINARY LOG STATUS` since 8.4. The GTID set is empty if GTIDs are not enabled.
+func (cw *ConnWrapper) MasterStatus() (file string, pos uint32, gtidSet GTIDSet, err error) {
+	columns, rows, err := cw.queryAll("SHOW MASTER STATUS")
+	if me, ok := err.(*MySQLError); ok && me.Number == 1064 {
+		columns, rows, err = cw.queryAll("SHOW BINARY LOG STATUS")
+	}
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if len(rows) == 0 {
+		return "", 0, nil, fmt.Errorf("empty master status, binlog may be disabled")
+	}
 
-	binary.LittleEndian.PutUint16(data[pos:], 0)
-	pos += 2
+	for i, column := range columns {
+		value := string(rows[0][i])
+		switch column {
+		case "File":
+			file = value
+		case "Position":
+			n, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return "", 0, nil, fmt.Errorf("invalid master position %q", value)
+			}
+			pos = uint32(n)
+		case "Executed_Gtid_Set":
+			if gtidSet, err = ParseGTIDSet(value); err != nil {
+				return "", 0, nil, err
+			}
+		}
+	}
+	return file, pos, gtidSet, nil
+}
 
-	binary.LittleEndian.PutUint32(data[pos:], serverID)
-	pos += 4
+// BinaryLogs returns the binlog files of the master by `SHOW BINARY LOGS`.
+func (cw *ConnWrapper) BinaryLogs() ([]LogInfo, error) {
+	columns, rows, err := cw.queryAll("SHOW BINARY LOGS")
+	if err != nil {
+		return nil, err
+	}
 
-	copy(data[pos:], file)
+	logs := make([]LogInfo, len(rows))
+	for i, row := range rows {
+		for j, column := range columns {
+			switch column {
+			case "Log_name":
+				logs[i].Name = string(row[j])
+			case "File_size":
+				if logs[i].Size, err = strconv.ParseUint(string(row[j]), 10, 64); err != nil {
+					return nil, fmt.Errorf("invalid binlog file size %q", row[j])
+				}
+			case "Encrypted":
+				logs[i].Encrypted = string(row[j]) == "Yes"
+			}
+		}
+	}
+	return logs, nil
+}
 
-	return cw.writeCommandPacketStr(comBinlogDump, string(data))
+// queryAll executes the query and returns the column names and the values of all the rows in text.
+func (cw *ConnWrapper) queryAll(query string) ([]string, [][][]byte, error) {
+	rows, err := cw.query(query, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	columns := rows.Columns()
+	dest := make([]driver.Value, len(columns))
+	var values [][][]byte
+	for {
+		if err = rows.Next(dest); err != nil {
+			break
+		}
+		row := make([][]byte, len(dest))
+		for i, v := range dest {
+			if b, ok := v.([]byte); ok {
+				row[i] = append([]byte{}, b...)
+			}
+		}
+		values = append(values, row)
+	}
+	if err != io.EOF {
+		return nil, nil, err
+	}
+	return columns, values, nil
 }
@@ -0,0 +1,345 @@
+package mysql
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadPacketSemiSync(t *testing.T) {
+	conn := new(mockConn)
+	cw := &ConnWrapper{mysqlConn: &mysqlConn{
+		buf:              newBuffer(conn),
+		netConn:          conn,
+		maxAllowedPacket: maxPacketSize,
+	}}
+	cw.semiSync = true
+
+	conn.data = []byte{0x05, 0x00, 0x00, 0x00, iOK, semiSyncIndicator, 0x01, 0xaa, 0xbb}
+	packet, err := cw.ReadPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packet, []byte{0xaa, 0xbb}) {
+		t.Fatalf("unexpected packet content: %x", packet)
+	}
+	if !cw.SemiSyncACKNeeded() {
+		t.Fatal("expected ACK needed")
+	}
+
+	if err = cw.WriteSemiSyncACK("mysql-bin.000001", 4); err != nil {
+		t.Fatal(err)
+	}
+	if conn.written != 4+1+8+16 {
+		t.Errorf("unexpected ACK packet length: %d", conn.written)
+	}
+	if cw.sequence != 1 {
+		t.Errorf("expected the sequence of the dump stream restored, got %d", cw.sequence)
+	}
+	if cw.SemiSyncACKNeeded() {
+		t.Error("expected no ACK needed after replying")
+	}
+
+	// events without the semi-sync header are malformed
+	conn.data = []byte{0x03, 0x00, 0x00, 0x01, iOK, 0xaa, 0xbb}
+	if _, err = cw.ReadPacket(); err != ErrMalformPkt {
+		t.Errorf("expected ErrMalformPkt, got %v", err)
+	}
+}
+
+func TestConnectContextCanceled(t *testing.T) {
+	// a server which accepts connections but never sends the handshake packet
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	cw := NewConnWrapper()
+	err = cw.ConnectContext(ctx, "user:pass@tcp("+ln.Addr().String()+")/", nil)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestReadPacketBuffer(t *testing.T) {
+	conn := new(mockConn)
+	cw := &ConnWrapper{mysqlConn: &mysqlConn{
+		buf:              newBuffer(conn),
+		netConn:          conn,
+		maxAllowedPacket: maxPacketSize,
+	}}
+
+	conn.data = []byte{0x03, 0x00, 0x00, 0x00, iOK, 0xaa, 0xbb}
+	buf := make([]byte, 0, 16)
+	packet, err := cw.ReadPacketBuffer(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packet, []byte{0xaa, 0xbb}) || &packet[0] != &buf[:1][0] {
+		t.Fatalf("expected the packet appended to the buffer, got %x", packet)
+	}
+}
+
+func TestPacketOutOfRange(t *testing.T) {
+	p := NewPacket([]byte{0x01, 0x02, 0x03})
+	if u := p.ReadUintBySize(2); u != 0x0201 || p.Err() != nil {
+		t.Fatalf("unexpected %x, %v", u, p.Err())
+	}
+	if u := p.ReadUintBySize(4); u != 0 || p.Err() != ErrMalformPkt {
+		t.Fatalf("expected ErrMalformPkt, got %x, %v", u, p.Err())
+	}
+	if !p.EOF() {
+		t.Error("expected the packet consumed")
+	}
+	if b := p.Read(2); !bytes.Equal(b, []byte{0, 0}) {
+		t.Errorf("expected zero bytes, got %x", b)
+	}
+	if b := p.Read(1 << 30); b != nil {
+		t.Errorf("expected nil for a corrupted length, got %d bytes", len(b))
+	}
+
+	p = NewPacket([]byte{0xfc, 0x01})
+	if n := p.ReadPackedInteger(); n != 0 || p.Err() != ErrMalformPkt {
+		t.Errorf("expected ErrMalformPkt, got %d, %v", n, p.Err())
+	}
+}
+
+func TestPacketWrite(t *testing.T) {
+	p := NewPacket(nil)
+	p.WriteUintBySize(3, 0x030201)
+	p.WriteLengthEncodedInteger(0x10000)
+	p.WriteLengthEncodedString([]byte("abc"))
+	p.WriteZeroTerminated("mysql-bin.000001")
+
+	r := NewPacket(p.Raw())
+	if u := r.ReadUintBySize(3); u != 0x030201 {
+		t.Errorf("expected 0x030201, got %x", u)
+	}
+	if n := r.ReadPackedInteger(); n != 0x10000 {
+		t.Errorf("expected 0x10000, got %x", n)
+	}
+	if b, err := r.ReadPackedString(); err != nil || string(b) != "abc" {
+		t.Errorf("expected abc, got %q, %v", b, err)
+	}
+	if b := r.Read(-1); string(b) != "mysql-bin.000001\x00" {
+		t.Errorf("unexpected zero-terminated string %q", b)
+	}
+
+	// the length overflowing int
+	r = NewPacket([]byte{0xfe, 0, 0, 0, 0, 0, 0, 0, 0x80, 'a'})
+	if b, err := r.ReadPackedString(); err == nil || r.Err() != ErrMalformPkt {
+		t.Errorf("expected ErrMalformPkt, got %q, %v", b, err)
+	}
+}
+
+func TestReadPacketContextCanceled(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	cw := &ConnWrapper{mysqlConn: &mysqlConn{
+		buf:              newBuffer(client),
+		netConn:          client,
+		closech:          make(chan struct{}),
+		maxAllowedPacket: maxPacketSize,
+	}}
+	cw.startWatcher()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := cw.ReadPacketContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("read not canceled promptly: %v", elapsed)
+	}
+}
+
+// resultSetPackets builds the packets of a text result set with the sequence starting from 1.
+func resultSetPackets(columns []string, rows [][]string) []byte {
+	var data []byte
+	seq := byte(1)
+	appendPacket := func(payload []byte) {
+		data = append(data, byte(len(payload)), byte(len(payload)>>8), byte(len(payload)>>16), seq)
+		data = append(data, payload...)
+		seq++
+	}
+	lenenc := func(b []byte, s string) []byte {
+		return append(append(b, byte(len(s))), s...)
+	}
+	eof := []byte{iEOF, 0, 0, 0, 0}
+
+	appendPacket([]byte{byte(len(columns))})
+	for _, name := range columns {
+		var column []byte
+		for _, s := range []string{"def", "", "", "", name, name} {
+			column = lenenc(column, s)
+		}
+		column = append(column, 0x0c, 33, 0, 0, 1, 0, 0, fieldTypeVarString, 0, 0, 0, 0, 0)
+		appendPacket(column)
+	}
+	appendPacket(eof)
+	for _, row := range rows {
+		var payload []byte
+		for _, value := range row {
+			payload = lenenc(payload, value)
+		}
+		appendPacket(payload)
+	}
+	appendPacket(eof)
+	return data
+}
+
+func newMockConnWrapper(data []byte) *ConnWrapper {
+	conn := &mockConn{data: data}
+	return &ConnWrapper{mysqlConn: &mysqlConn{
+		buf:              newBuffer(conn),
+		netConn:          conn,
+		cfg:              &Config{},
+		maxAllowedPacket: maxPacketSize,
+	}}
+}
+
+func TestMasterStatus(t *testing.T) {
+	cw := newMockConnWrapper(resultSetPackets(
+		[]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"},
+		[][]string{{"mysql-bin.000003", "154", "", "", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}},
+	))
+	file, pos, gtidSet, err := cw.MasterStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != "mysql-bin.000003" || pos != 154 {
+		t.Errorf("unexpected position %s:%d", file, pos)
+	}
+	if gtidSet.String() != "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5" {
+		t.Errorf("unexpected GTID set %s", gtidSet)
+	}
+}
+
+func TestBinaryLogs(t *testing.T) {
+	cw := newMockConnWrapper(resultSetPackets(
+		[]string{"Log_name", "File_size", "Encrypted"},
+		[][]string{{"mysql-bin.000001", "177", "No"}, {"mysql-bin.000002", "4096", "Yes"}},
+	))
+	logs, err := cw.BinaryLogs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []LogInfo{{"mysql-bin.000001", 177, false}, {"mysql-bin.000002", 4096, true}}
+	if len(logs) != 2 || logs[0] != expected[0] || logs[1] != expected[1] {
+		t.Errorf("expected %v, got %v", expected, logs)
+	}
+}
+
+func TestSetReadTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	cw := &ConnWrapper{mysqlConn: &mysqlConn{
+		buf:              newBuffer(client),
+		netConn:          client,
+		closech:          make(chan struct{}),
+		maxAllowedPacket: maxPacketSize,
+	}}
+	cw.SetReadTimeout(50 * time.Millisecond)
+	// the connection is closed with COM_QUIT after the timeout
+	go io.Copy(ioutil.Discard, server)
+
+	start := time.Now()
+	if _, err := cw.ReadPacket(); err != driver.ErrBadConn {
+		t.Fatalf("expected driver.ErrBadConn, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("read not timed out promptly: %v", elapsed)
+	}
+}
+
+func TestEnableSemiSync(t *testing.T) {
+	for _, tc := range []struct {
+		variables map[string]string
+		err       error
+	}{
+		// the plugin is not loaded
+		{nil, ErrSemiSyncDisabled},
+		{map[string]string{"rpl_semi_sync_master_enabled": "OFF"}, ErrSemiSyncDisabled},
+		{map[string]string{"rpl_semi_sync_master_enabled": "ON"}, nil},
+		{map[string]string{"rpl_semi_sync_source_enabled": "ON"}, nil},
+	} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		queries := make(chan string, 16)
+		go func() {
+			defer close(queries)
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			sc := NewServerConn(conn)
+			defer sc.Close()
+			if err = sc.Handshake("5.7.30-log", 1, func(user string) (string, bool) { return "", true }); err != nil {
+				return
+			}
+			for {
+				command, data, err := sc.ReadCommand()
+				if err != nil || command != ComQuery {
+					return
+				}
+				query := string(data)
+				queries <- query
+				if strings.HasPrefix(query, "SHOW") {
+					var rows [][]interface{}
+					for name, value := range tc.variables {
+						if strings.Contains(query, name) {
+							rows = append(rows, []interface{}{name, value})
+						}
+					}
+					err = sc.WriteResultSet([]string{"Variable_name", "Value"}, rows)
+				} else {
+					err = sc.WriteOK()
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+
+		cw := NewConnWrapper()
+		if err = cw.Connect("root@tcp(" + ln.Addr().String() + ")/?maxAllowedPacket=4194304"); err != nil {
+			t.Fatal(err)
+		}
+		if err = cw.EnableSemiSync(); err != tc.err {
+			t.Errorf("%v: expected %v, got %v", tc.variables, tc.err, err)
+		}
+		if cw.semiSync != (tc.err == nil) {
+			t.Errorf("%v: unexpected semi-sync %v", tc.variables, cw.semiSync)
+		}
+		cw.Close()
+		ln.Close()
+		var set bool
+		for query := range queries {
+			set = set || strings.HasPrefix(query, "SET @rpl_semi_sync_slave")
+		}
+		if set != (tc.err == nil) {
+			t.Errorf("%v: unexpected SET @rpl_semi_sync_slave %v", tc.variables, set)
+		}
+	}
+}